@@ -0,0 +1,331 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TableConfig is the full desired configuration of IPVS, which Client.Apply
+// makes IPVS match. It is named after the IPVS table, as Config holds the
+// connection timeouts.
+type TableConfig struct {
+	Services []ServiceConfig
+}
+
+// ServiceConfig is the desired configuration of a Service, with all its
+// Destinations.
+type ServiceConfig struct {
+	Service
+	Destinations []Destination
+
+	// Owner labels the Service with the controller owning it, which limits
+	// the services a controller prunes, see ApplyOptions.
+	Owner string
+}
+
+// ApplyOptions configure Client.Apply.
+type ApplyOptions struct {
+	// Prune removes the services in IPVS which are missing from the
+	// configuration. Without it, they are left as is.
+	Prune bool
+
+	// Owner makes Apply only manage the services owned by Owner, so that it
+	// shares IPVS with other controllers and tools, such as kube-proxy or
+	// keepalived: other services are neither pruned nor changed, and
+	// configuring one which exists fails with ErrNotOwned, unless it is
+	// added to Owners. Services created are owned by Owner, unless their
+	// ServiceConfig names another owner.
+	//
+	// When empty, all the services configured are managed, and all the
+	// services missing from the configuration are pruned.
+	Owner string
+
+	// Owners labels the services in IPVS with their owner. IPVS does not
+	// store labels, so these are usually the Owners of the ApplyResult of
+	// the previous Apply, kept in a file with ReadOwners and WriteOwners.
+	Owners map[ServiceKey]string
+
+	// Owned derives the ownership of the services missing from Owners from
+	// a convention, such as ranges of firewall marks or ports reserved to
+	// Owner, see OwnFWMarks and OwnPorts. The services for which it returns
+	// true are owned by Owner.
+	Owned func(ServiceKey) bool
+
+	// PruneUnowned also prunes the services owned by no one, which are
+	// otherwise left as is when Owner is set.
+	PruneUnowned bool
+}
+
+// ErrNotOwned indicates that Apply was configured with a service which
+// exists in IPVS, but is not owned by the ApplyOptions.Owner.
+var ErrNotOwned = errors.New("ipvs: service not owned")
+
+// OwnFWMarks returns an ApplyOptions.Owned func owning the firewall mark
+// services with marks between first and last, inclusive.
+func OwnFWMarks(first, last uint32) func(ServiceKey) bool {
+	return func(key ServiceKey) bool {
+		return key.FWMark != 0 && key.FWMark >= first && key.FWMark <= last
+	}
+}
+
+// OwnPorts returns an ApplyOptions.Owned func owning the address based
+// services with ports between first and last, inclusive.
+func OwnPorts(first, last uint16) func(ServiceKey) bool {
+	return func(key ServiceKey) bool {
+		return key.FWMark == 0 && key.Port >= first && key.Port <= last
+	}
+}
+
+// ApplyResult reports the changes made by Client.Apply.
+type ApplyResult struct {
+	// CreatedServices, UpdatedServices and RemovedServices are the
+	// services changed, in order.
+	CreatedServices []ServiceKey
+	UpdatedServices []ServiceKey
+	RemovedServices []ServiceKey
+
+	// CreatedDestinations, UpdatedDestinations and RemovedDestinations
+	// count the destinations changed, excluding those of removed services.
+	CreatedDestinations int
+	UpdatedDestinations int
+	RemovedDestinations int
+
+	// Owners labels the services in IPVS with their owner after Apply, to
+	// pass in the ApplyOptions of the next Apply.
+	Owners map[ServiceKey]string
+}
+
+// Changed reports whether Apply changed IPVS.
+func (r ApplyResult) Changed() bool {
+	return len(r.CreatedServices) > 0 || len(r.UpdatedServices) > 0 || len(r.RemovedServices) > 0 ||
+		r.CreatedDestinations > 0 || r.UpdatedDestinations > 0 || r.RemovedDestinations > 0
+}
+
+// Apply makes IPVS match cfg, fetching the current state and making only
+// the changes needed: services and destinations are created before the
+// destinations missing from cfg are removed, so that traffic keeps flowing,
+// and services are pruned last. It stops at the first failing change,
+// returning the changes made so far along with the error.
+//
+// Before making any change, Apply checks cfg like TableConfig.Validate with
+// zero Capabilities, and when opts.Owner is set, that it owns the services
+// configured.
+func (c *client) Apply(ctx context.Context, cfg TableConfig, opts ApplyOptions) (ApplyResult, error) {
+	return apply(ctx, c, cfg, opts)
+}
+
+// apply implements Client.Apply over c.
+func apply(ctx context.Context, c Client, cfg TableConfig, opts ApplyOptions) (ApplyResult, error) {
+	p, err := planApply(ctx, c, cfg, opts)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+
+	for _, ch := range p.changes {
+		if err := ch.apply(ctx, c, &p.result, p.desired); err != nil {
+			return p.result, err
+		}
+	}
+
+	return p.result, nil
+}
+
+// Plan returns the changes Apply would make to IPVS to match cfg, in the
+// order it would make them, such as to review them before applying cfg. It
+// fails like Apply, when cfg is invalid or opts.Owner does not own the
+// services configured.
+func (c *client) Plan(ctx context.Context, cfg TableConfig, opts ApplyOptions) (Changeset, error) {
+	p, err := planApply(ctx, c, cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.changes, nil
+}
+
+// applyPlan is the plan of an Apply, before any change is made.
+type applyPlan struct {
+	// current holds the services diffed, as they are in IPVS, and desired
+	// as they are configured.
+	current, desired map[ServiceKey]watchedService
+	changes          Changeset
+	// result is the ApplyResult before any change, with the owners of the
+	// services after Apply.
+	result ApplyResult
+}
+
+// planApply fetches the current state of IPVS through c and plans the
+// changes making it match cfg, checking the ownership of the services.
+func planApply(ctx context.Context, c Client, cfg TableConfig, opts ApplyOptions) (applyPlan, error) {
+	desired, err := cfg.watched()
+	if err != nil {
+		return applyPlan{}, err
+	}
+
+	if err := cfg.Validate(Capabilities{}); err != nil {
+		return applyPlan{}, err
+	}
+
+	svcs, err := c.ServicesWithDestinations(ctx)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return applyPlan{}, err
+	}
+
+	owners := make(map[ServiceKey]string, len(cfg.Services))
+	for _, sc := range cfg.Services {
+		owner := sc.Owner
+		if owner == "" {
+			owner = opts.Owner
+		}
+		owners[sc.Normalize().Key()] = owner
+	}
+
+	r := ApplyResult{Owners: make(map[ServiceKey]string)}
+	current := make(map[ServiceKey]watchedService, len(svcs))
+	for _, svc := range svcs {
+		ws := watchedService{
+			svc:   svc.Service,
+			dests: make(map[destinationKey]Destination, len(svc.Destinations)),
+		}
+		for _, dest := range svc.Destinations {
+			ws.dests[destinationKeyOf(dest.Destination)] = dest.Destination
+		}
+
+		key := svc.Key()
+		owner := opts.owner(key)
+		if owner != "" {
+			r.Owners[key] = owner
+		}
+
+		// Only the services configured or pruned are diffed.
+		want, ok := owners[key]
+		switch {
+		case ok && opts.Owner != "" && owner == "":
+			return applyPlan{}, fmt.Errorf("ipvs: service %s is owned by no one, not %q: %w", key, want, ErrNotOwned)
+		case ok && opts.Owner != "" && owner != want:
+			return applyPlan{}, fmt.Errorf("ipvs: service %s is owned by %q, not %q: %w", key, owner, want, ErrNotOwned)
+		case !ok && !opts.prunes(owner):
+			continue
+		}
+		current[key] = ws
+	}
+
+	for key, owner := range owners {
+		if owner != "" {
+			r.Owners[key] = owner
+		}
+	}
+
+	return applyPlan{
+		current: current,
+		desired: desired,
+		changes: diff(current, desired),
+		result:  r,
+	}, nil
+}
+
+// owner returns the owner of the Service identified by key, or the empty
+// string if it is owned by no one.
+func (o ApplyOptions) owner(key ServiceKey) string {
+	if owner, ok := o.Owners[key]; ok {
+		return owner
+	}
+
+	if o.Owned != nil && o.Owned(key) {
+		return o.Owner
+	}
+
+	return ""
+}
+
+// prunes reports whether a Service owned by owner is pruned when it is
+// missing from the configuration.
+func (o ApplyOptions) prunes(owner string) bool {
+	switch {
+	case !o.Prune:
+		return false
+	case o.Owner == "":
+		return true
+	case owner == "":
+		return o.PruneUnowned
+	}
+
+	return owner == o.Owner
+}
+
+// watched returns cfg in the form of a snapshot of a Watcher, keyed by
+// normalized keys but keeping the services and destinations as configured.
+// It fails if cfg holds a service or destination twice.
+func (cfg TableConfig) watched() (map[ServiceKey]watchedService, error) {
+	m := make(map[ServiceKey]watchedService, len(cfg.Services))
+	for _, sc := range cfg.Services {
+		key := sc.Normalize().Key()
+		if _, ok := m[key]; ok {
+			return nil, fmt.Errorf("ipvs: service %s configured twice", key)
+		}
+
+		ws := watchedService{
+			svc:   sc.Service,
+			dests: make(map[destinationKey]Destination, len(sc.Destinations)),
+		}
+		for _, dest := range sc.Destinations {
+			dkey := destinationKeyOf(dest.Normalize())
+			if _, ok := ws.dests[dkey]; ok {
+				return nil, fmt.Errorf("ipvs: destination %s of service %s configured twice", dkey.addr, key)
+			}
+			ws.dests[dkey] = dest
+		}
+
+		m[key] = ws
+	}
+
+	return m, nil
+}
+
+// apply makes the change ch through c, recording it in r. The
+// destinations of services removed are removed along with them.
+func (ch Change) apply(ctx context.Context, c Client, r *ApplyResult, desired map[ServiceKey]watchedService) error {
+	svc := ch.Service
+	key := svc.Normalize().Key()
+
+	switch ch.Type {
+	case ServiceAdded:
+		if err := c.CreateService(ctx, svc); err != nil {
+			return err
+		}
+		r.CreatedServices = append(r.CreatedServices, key)
+	case ServiceUpdated:
+		if err := c.UpdateService(ctx, svc); err != nil {
+			return err
+		}
+		r.UpdatedServices = append(r.UpdatedServices, key)
+	case ServiceRemoved:
+		if err := c.RemoveService(ctx, svc); err != nil {
+			return err
+		}
+		r.RemovedServices = append(r.RemovedServices, key)
+		delete(r.Owners, key)
+	case DestinationAdded:
+		if err := c.CreateDestination(ctx, svc, ch.Destination); err != nil {
+			return err
+		}
+		r.CreatedDestinations++
+	case DestinationUpdated, DestinationWeightChanged:
+		if err := c.UpdateDestination(ctx, svc, ch.Destination); err != nil {
+			return err
+		}
+		r.UpdatedDestinations++
+	case DestinationRemoved:
+		if ch.skipped(desired) {
+			return nil
+		}
+		if err := c.RemoveDestination(ctx, svc, ch.Destination); err != nil {
+			return err
+		}
+		r.RemovedDestinations++
+	}
+
+	return nil
+}
@@ -0,0 +1,385 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"sort"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// tableClient is a Client keeping an in-memory IPVS table, recording the
+// changes made to it.
+type tableClient struct {
+	Client
+	svcs map[ServiceKey]*ServiceWithDestinations
+	ops  []string
+	// fail lists the changes failing.
+	fail []string
+}
+
+func newTableClient(svcs ...ServiceConfig) *tableClient {
+	c := &tableClient{svcs: make(map[ServiceKey]*ServiceWithDestinations)}
+	for _, sc := range svcs {
+		s := &ServiceWithDestinations{ServiceExtended: ServiceExtended{Service: sc.Normalize()}}
+		for _, dest := range sc.Destinations {
+			s.Destinations = append(s.Destinations, DestinationExtended{Destination: dest.Normalize()})
+		}
+		c.svcs[sc.Key()] = s
+	}
+
+	return c
+}
+
+func (c *tableClient) record(op string) error {
+	for _, f := range c.fail {
+		if op == f {
+			return errors.New("injected failure")
+		}
+	}
+
+	c.ops = append(c.ops, op)
+	return nil
+}
+
+func (c *tableClient) ServicesWithDestinations(context.Context, ...ListOption) ([]ServiceWithDestinations, error) {
+	var svcs []ServiceWithDestinations
+	for _, s := range c.svcs {
+		svcs = append(svcs, s.Clone())
+	}
+
+	sort.Slice(svcs, func(i, j int) bool {
+		return svcs[i].Key().Compare(svcs[j].Key()) < 0
+	})
+	return svcs, nil
+}
+
+func (c *tableClient) CreateService(_ context.Context, svc Service) error {
+	if err := c.record("create_service " + svc.Key().String()); err != nil {
+		return err
+	}
+
+	c.svcs[svc.Key()] = &ServiceWithDestinations{ServiceExtended: ServiceExtended{Service: svc.Normalize()}}
+	return nil
+}
+
+func (c *tableClient) UpdateService(_ context.Context, svc Service) error {
+	if err := c.record("update_service " + svc.Key().String()); err != nil {
+		return err
+	}
+
+	c.svcs[svc.Key()].Service = svc.Normalize()
+	return nil
+}
+
+func (c *tableClient) RemoveService(_ context.Context, svc Service) error {
+	if err := c.record("remove_service " + svc.Key().String()); err != nil {
+		return err
+	}
+
+	delete(c.svcs, svc.Key())
+	return nil
+}
+
+func (c *tableClient) CreateDestination(_ context.Context, svc Service, dest Destination) error {
+	if err := c.record(fmt.Sprintf("create_destination %s %s", svc.Key(), netip.AddrPortFrom(dest.Address, dest.Port))); err != nil {
+		return err
+	}
+
+	s := c.svcs[svc.Key()]
+	s.Destinations = append(s.Destinations, DestinationExtended{Destination: dest.Normalize()})
+	return nil
+}
+
+func (c *tableClient) UpdateDestination(_ context.Context, svc Service, dest Destination) error {
+	if err := c.record(fmt.Sprintf("update_destination %s %s", svc.Key(), netip.AddrPortFrom(dest.Address, dest.Port))); err != nil {
+		return err
+	}
+
+	s := c.svcs[svc.Key()]
+	for i := range s.Destinations {
+		if destinationKeyOf(s.Destinations[i].Destination) == destinationKeyOf(dest.Normalize()) {
+			s.Destinations[i].Destination = dest.Normalize()
+		}
+	}
+	return nil
+}
+
+func (c *tableClient) RemoveDestination(_ context.Context, svc Service, dest Destination, _ ...RemoveOption) error {
+	if err := c.record(fmt.Sprintf("remove_destination %s %s", svc.Key(), netip.AddrPortFrom(dest.Address, dest.Port))); err != nil {
+		return err
+	}
+
+	s := c.svcs[svc.Key()]
+	for i := range s.Destinations {
+		if destinationKeyOf(s.Destinations[i].Destination) == destinationKeyOf(dest.Normalize()) {
+			s.Destinations = append(s.Destinations[:i], s.Destinations[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestApply(t *testing.T) {
+	type testCase struct {
+		name     string
+		current  []ServiceConfig
+		cfg      TableConfig
+		opts     ApplyOptions
+		fail     []string
+		expected []string
+		owners   map[ServiceKey]string
+		err      string
+	}
+
+	svc := func(last byte, port uint16) Service {
+		return Service{Address: netip.AddrFrom4([4]byte{192, 0, 2, last}), Port: port, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	}
+	dest := func(last byte, weight uint32) Destination {
+		return Destination{Address: netip.AddrFrom4([4]byte{198, 51, 100, last}), Port: 8080, Family: INET, Weight: weight}
+	}
+	wrr := svc(1, 80)
+	wrr.Scheduler = WeightedRoundRobin
+	wrr8080 := svc(2, 8080)
+	wrr8080.Scheduler = WeightedRoundRobin
+
+	run := func(t *testing.T, tc testCase) {
+		c := newTableClient(tc.current...)
+		c.fail = tc.fail
+
+		r, err := apply(context.Background(), c, tc.cfg, tc.opts)
+		if tc.err != "" {
+			assert.ErrorContains(t, err, tc.err)
+		} else {
+			assert.NilError(t, err)
+		}
+		assert.DeepEqual(t, c.ops, tc.expected)
+		assert.Equal(t, r.Changed(), len(tc.expected) > 0)
+		if tc.owners != nil {
+			assert.DeepEqual(t, r.Owners, tc.owners)
+		}
+
+		if tc.err != "" {
+			return
+		}
+
+		// Applying again changes nothing.
+		c.ops = nil
+		opts := tc.opts
+		opts.Owners = r.Owners
+		r, err = apply(context.Background(), c, tc.cfg, opts)
+		assert.NilError(t, err)
+		assert.Assert(t, !r.Changed(), "changed: %v", c.ops)
+	}
+
+	testCases := []testCase{
+		{
+			name: "create",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: svc(1, 80), Destinations: []Destination{dest(2, 1), dest(1, 1)}},
+			}},
+			expected: []string{
+				"create_service TCP 192.0.2.1:80",
+				"create_destination TCP 192.0.2.1:80 198.51.100.1:8080",
+				"create_destination TCP 192.0.2.1:80 198.51.100.2:8080",
+			},
+		},
+		{
+			name:    "in sync",
+			current: []ServiceConfig{{Service: svc(1, 80), Destinations: []Destination{dest(1, 1)}}},
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: svc(1, 80), Destinations: []Destination{dest(1, 1)}},
+			}},
+		},
+		{
+			name:    "update",
+			current: []ServiceConfig{{Service: svc(1, 80), Destinations: []Destination{dest(1, 1), dest(2, 1), dest(3, 1)}}},
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: wrr, Destinations: []Destination{dest(1, 5), dest(3, 1), dest(4, 1)}},
+			}},
+			expected: []string{
+				"update_service TCP 192.0.2.1:80",
+				"update_destination TCP 192.0.2.1:80 198.51.100.1:8080",
+				"create_destination TCP 192.0.2.1:80 198.51.100.4:8080",
+				"remove_destination TCP 192.0.2.1:80 198.51.100.2:8080",
+			},
+		},
+		{
+			name: "unconfigured kept",
+			current: []ServiceConfig{
+				{Service: svc(1, 80)},
+				{Service: svc(2, 80), Destinations: []Destination{dest(1, 1)}},
+			},
+			cfg: TableConfig{Services: []ServiceConfig{{Service: svc(1, 80)}}},
+		},
+		{
+			name: "prune",
+			current: []ServiceConfig{
+				{Service: svc(1, 80)},
+				{Service: svc(2, 80), Destinations: []Destination{dest(1, 1)}},
+			},
+			cfg:      TableConfig{Services: []ServiceConfig{{Service: svc(3, 80)}}},
+			opts:     ApplyOptions{Prune: true},
+			expected: []string{"create_service TCP 192.0.2.3:80", "remove_service TCP 192.0.2.1:80", "remove_service TCP 192.0.2.2:80"},
+		},
+		{
+			name: "prune owned",
+			current: []ServiceConfig{
+				{Service: svc(1, 80)},
+				{Service: svc(2, 80)},
+				{Service: svc(3, 80)},
+			},
+			cfg: TableConfig{Services: []ServiceConfig{{Service: svc(4, 80), Owner: "a"}}},
+			opts: ApplyOptions{Prune: true, Owner: "a", Owners: map[ServiceKey]string{
+				svc(2, 80).Key(): "b",
+				svc(3, 80).Key(): "a",
+				svc(9, 80).Key(): "a",
+			}},
+			expected: []string{"create_service TCP 192.0.2.4:80", "remove_service TCP 192.0.2.3:80"},
+			owners: map[ServiceKey]string{
+				svc(2, 80).Key(): "b",
+				svc(4, 80).Key(): "a",
+			},
+		},
+		{
+			name: "prune unowned",
+			current: []ServiceConfig{
+				{Service: svc(1, 80)},
+				{Service: svc(2, 80)},
+			},
+			opts: ApplyOptions{Prune: true, Owner: "a", PruneUnowned: true, Owners: map[ServiceKey]string{
+				svc(2, 80).Key(): "b",
+			}},
+			expected: []string{"remove_service TCP 192.0.2.1:80"},
+			owners: map[ServiceKey]string{
+				svc(2, 80).Key(): "b",
+			},
+		},
+		{
+			name: "owned by convention",
+			current: []ServiceConfig{
+				{Service: svc(1, 80)},
+				{Service: svc(2, 8080)},
+				{Service: svc(3, 8081)},
+			},
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: wrr8080},
+			}},
+			opts:     ApplyOptions{Prune: true, Owner: "a", Owned: OwnPorts(8000, 8999)},
+			expected: []string{"update_service TCP 192.0.2.2:8080", "remove_service TCP 192.0.2.3:8081"},
+			owners: map[ServiceKey]string{
+				svc(2, 8080).Key(): "a",
+			},
+		},
+		{
+			name:    "not owned",
+			current: []ServiceConfig{{Service: svc(1, 80)}, {Service: svc(2, 80)}},
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: svc(3, 80)},
+				{Service: wrr},
+			}},
+			opts: ApplyOptions{Owner: "a"},
+			err:  "ipvs: service TCP 192.0.2.1:80 is owned by no one, not \"a\": ipvs: service not owned",
+		},
+		{
+			name:    "owned by another",
+			current: []ServiceConfig{{Service: svc(1, 80)}},
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: wrr},
+			}},
+			opts: ApplyOptions{Owner: "a", Owners: map[ServiceKey]string{svc(1, 80).Key(): "b"}},
+			err:  "ipvs: service TCP 192.0.2.1:80 is owned by \"b\", not \"a\": ipvs: service not owned",
+		},
+		{
+			name:    "adopted",
+			current: []ServiceConfig{{Service: svc(1, 80)}},
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: wrr},
+			}},
+			opts:     ApplyOptions{Owner: "a", Owners: map[ServiceKey]string{svc(1, 80).Key(): "a"}},
+			expected: []string{"update_service TCP 192.0.2.1:80"},
+		},
+		{
+			name: "duplicate service",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: svc(1, 80)},
+				{Service: svc(1, 80)},
+			}},
+			err: "service TCP 192.0.2.1:80 configured twice",
+		},
+		{
+			name: "duplicate destination",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: svc(1, 80), Destinations: []Destination{dest(1, 1), dest(1, 2)}},
+			}},
+			err: "destination 198.51.100.1:8080 of service TCP 192.0.2.1:80 configured twice",
+		},
+		{
+			name: "unset destination family",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: svc(1, 80), Destinations: []Destination{{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Weight: 1}}},
+			}},
+			expected: []string{
+				"create_service TCP 192.0.2.1:80",
+				"create_destination TCP 192.0.2.1:80 198.51.100.1:8080",
+			},
+		},
+		{
+			name: "firewall mark masquerading to the original port",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: Service{FWMark: 42, Family: INET, Scheduler: RoundRobin}, Destinations: []Destination{
+					{Address: netip.MustParseAddr("198.51.100.1"), Family: INET, FwdMethod: Masquerade, Weight: 1},
+				}},
+			}},
+			expected: []string{
+				"create_service FWM 42",
+				"create_destination FWM 42 198.51.100.1:0",
+			},
+		},
+		{
+			name: "invalid",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: svc(1, 80), Destinations: []Destination{{Address: netip.MustParseAddr("198.51.100.1"), Family: INET, FwdMethod: Masquerade}}},
+			}},
+			err: "masquerading requires a destination port",
+		},
+		{
+			name: "failure",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: svc(1, 80), Destinations: []Destination{dest(1, 1), dest(2, 1)}},
+			}},
+			fail: []string{"create_destination TCP 192.0.2.1:80 198.51.100.2:8080"},
+			expected: []string{
+				"create_service TCP 192.0.2.1:80",
+				"create_destination TCP 192.0.2.1:80 198.51.100.1:8080",
+			},
+			err: "injected failure",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+	c := newTableClient(ServiceConfig{Service: svc, Destinations: []Destination{dest}})
+
+	wrr := svc
+	wrr.Scheduler = WeightedRoundRobin
+	p, err := planApply(context.Background(), c, TableConfig{Services: []ServiceConfig{{Service: wrr}}}, ApplyOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(c.ops), 0)
+	assert.Equal(t, p.changes.Format(), ""+
+		"-TCP  192.0.2.1:80 rr\n"+
+		"+TCP  192.0.2.1:80 wrr\n"+
+		"-  -> 198.51.100.1:8080 Masq 1\n")
+}
@@ -0,0 +1,145 @@
+package ipvs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a successful change made to IPVS by a Client.
+type AuditRecord struct {
+	// Time is when the change completed.
+	Time time.Time `json:"time"`
+	// Op is the change made, such as "create_service" or
+	// "update_destination".
+	Op string `json:"op"`
+	// Reason is the reason supplied with WithAuditReason, if any.
+	Reason string `json:"reason,omitempty"`
+	// Service is the service changed, or the service of the destination
+	// changed, if any.
+	Service *ServiceKey `json:"service,omitempty"`
+	// Request is the Service, Destination, Config, Daemon or DaemonState
+	// passed to the Client, or nil for Flush.
+	Request any `json:"request,omitempty"`
+	// Result is the state in IPVS after the change, fetched from IPVS once
+	// the change succeeded. It is nil for removals, and when fetching the
+	// state failed.
+	Result any `json:"result,omitempty"`
+}
+
+// An Auditor records the changes made to IPVS. Implementations must be safe
+// for concurrent use.
+type Auditor interface {
+	// Audit is called for every successful change, once it completed. It is
+	// called synchronously, delaying the call making the change.
+	Audit(ctx context.Context, r AuditRecord)
+}
+
+// AuditFunc adapts a func to an Auditor.
+type AuditFunc func(ctx context.Context, r AuditRecord)
+
+// Audit calls f.
+func (f AuditFunc) Audit(ctx context.Context, r AuditRecord) {
+	f(ctx, r)
+}
+
+// WithAudit makes the Client report every successful change it makes to IPVS
+// to a.
+func WithAudit(a Auditor) Option {
+	return func(o *options) {
+		o.audit = a
+	}
+}
+
+type auditReasonKey struct{}
+
+// WithAuditReason returns a copy of ctx carrying reason, which is recorded
+// in the AuditRecord of the changes made with the returned context.
+func WithAuditReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, auditReasonKey{}, reason)
+}
+
+// auditRecord returns the AuditRecord of the successful mutation m.
+func auditRecord(ctx context.Context, m mutation) AuditRecord {
+	r := AuditRecord{
+		Op:      m.op,
+		Service: m.service,
+		Request: m.after,
+	}
+	if r.Request == nil {
+		r.Request = m.before
+	}
+	r.Reason, _ = ctx.Value(auditReasonKey{}).(string)
+
+	if m.result != nil {
+		if result, err := m.result(); err == nil {
+			r.Result = result
+		}
+	}
+
+	r.Time = time.Now()
+	return r
+}
+
+// AuditLog is an Auditor writing each AuditRecord as a line of JSON.
+type AuditLog struct {
+	mu  sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+	err error
+}
+
+var _ Auditor = (*AuditLog)(nil)
+
+// NewAuditLog returns an AuditLog writing to w.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w, enc: json.NewEncoder(w)}
+}
+
+// OpenAuditLog returns an AuditLog appending to the file at path, which is
+// created if needed.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewAuditLog(f), nil
+}
+
+// Audit implements Auditor, writing r as a line of JSON. Write errors are kept, and reported by
+// Err and Close.
+func (l *AuditLog) Audit(_ context.Context, r AuditRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.enc.Encode(r); err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+// Err returns the first error writing a record, if any.
+func (l *AuditLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.err
+}
+
+// Close closes the underlying writer, if it is an io.Closer, and returns the
+// first error writing a record or closing.
+func (l *AuditLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if c, ok := l.w.(io.Closer); ok {
+		if err := c.Close(); err != nil && l.err == nil {
+			l.err = err
+		}
+	}
+
+	return l.err
+}
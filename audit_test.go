@@ -0,0 +1,76 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAuditRecord(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	key := svc.Key()
+
+	ctx := WithAuditReason(context.Background(), "deploy 42")
+	r := auditRecord(ctx, mutation{
+		op:      "create_service",
+		service: &key,
+		after:   svc,
+		result:  func() (any, error) { return svc, nil },
+	})
+	assert.Assert(t, !r.Time.IsZero())
+	assert.Equal(t, r.Op, "create_service")
+	assert.Equal(t, r.Reason, "deploy 42")
+	assert.Equal(t, r.Service, &key)
+	assert.Equal(t, r.Request, any(svc))
+	assert.Equal(t, r.Result, any(svc))
+
+	r = auditRecord(context.Background(), mutation{
+		op:      "remove_service",
+		service: &key,
+		before:  svc,
+		result:  func() (any, error) { return nil, errors.New("gone") },
+	})
+	assert.Equal(t, r.Reason, "")
+	assert.Equal(t, r.Request, any(svc))
+	assert.Equal(t, r.Result, nil)
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	l, err := OpenAuditLog(path)
+	assert.NilError(t, err)
+
+	key := ServiceKey{Family: INET, FWMark: 42}
+	at := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	l.Audit(context.Background(), AuditRecord{Time: at, Op: "flush"})
+	l.Audit(context.Background(), AuditRecord{Time: at, Op: "remove_service", Reason: "decommission", Service: &key})
+	assert.NilError(t, l.Err())
+	assert.NilError(t, l.Close())
+
+	b, err := os.ReadFile(path)
+	assert.NilError(t, err)
+
+	expected := []string{
+		`{"time":"2023-10-01T12:00:00Z","op":"flush"}`,
+		`{"time":"2023-10-01T12:00:00Z","op":"remove_service","reason":"decommission","service":"FWM 42"}`,
+	}
+	assert.DeepEqual(t, strings.Split(strings.TrimSpace(string(b)), "\n"), expected)
+
+	// Records are appended to an existing file.
+	l, err = OpenAuditLog(path)
+	assert.NilError(t, err)
+	l.Audit(context.Background(), AuditRecord{Time: at, Op: "flush"})
+	assert.NilError(t, l.Close())
+
+	b, err = os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.Count(string(b), "\n"), 3)
+}
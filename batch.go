@@ -0,0 +1,107 @@
+package ipvs
+
+import (
+	"fmt"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+)
+
+// Batch queues changes to IPVS, which Client.Batch sends together over the
+// socket of the Client without waiting for the acknowledgement of each
+// change before sending the next one.
+type Batch struct {
+	ops []batchOp
+}
+
+// batchOp is a change queued in a Batch.
+type batchOp struct {
+	cmd     uint8
+	svc     Service
+	dest    Destination
+	hasDest bool
+}
+
+// CreateService queues the creation of svc.
+func (b *Batch) CreateService(svc Service) {
+	b.ops = append(b.ops, batchOp{cmd: cipvs.CmdNewService, svc: svc})
+}
+
+// UpdateService queues the update of svc.
+func (b *Batch) UpdateService(svc Service) {
+	b.ops = append(b.ops, batchOp{cmd: cipvs.CmdSetService, svc: svc})
+}
+
+// RemoveService queues the removal of svc.
+func (b *Batch) RemoveService(svc Service) {
+	b.ops = append(b.ops, batchOp{cmd: cipvs.CmdDelService, svc: svc})
+}
+
+// CreateDestination queues the creation of dest for svc.
+func (b *Batch) CreateDestination(svc Service, dest Destination) {
+	b.ops = append(b.ops, batchOp{cmd: cipvs.CmdNewDest, svc: svc, dest: dest, hasDest: true})
+}
+
+// UpdateDestination queues the update of dest of svc.
+func (b *Batch) UpdateDestination(svc Service, dest Destination) {
+	b.ops = append(b.ops, batchOp{cmd: cipvs.CmdSetDest, svc: svc, dest: dest, hasDest: true})
+}
+
+// RemoveDestination queues the removal of dest from svc.
+func (b *Batch) RemoveDestination(svc Service, dest Destination) {
+	b.ops = append(b.ops, batchOp{cmd: cipvs.CmdDelDest, svc: svc, dest: dest, hasDest: true})
+}
+
+// Len returns the number of changes queued.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// validate checks the change like the corresponding Client method.
+func (op batchOp) validate() error {
+	switch op.cmd {
+	case cipvs.CmdNewService, cipvs.CmdSetService:
+		return op.svc.validate()
+	case cipvs.CmdNewDest, cipvs.CmdSetDest:
+		return op.dest.validate(op.svc)
+	}
+
+	return nil
+}
+
+// BatchError reports the changes of a Batch which failed. Changes are
+// applied independently, so the others succeeded.
+type BatchError struct {
+	// Errors holds the error of each change, in the order they were queued,
+	// which is nil for the changes which succeeded.
+	Errors []error
+}
+
+// Error implements error.
+func (e *BatchError) Error() string {
+	var (
+		n     int
+		first error
+	)
+	for _, err := range e.Errors {
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			n++
+		}
+	}
+
+	return fmt.Sprintf("ipvs: %d of %d batched changes failed, first: %v", n, len(e.Errors), first)
+}
+
+// Unwrap returns the errors of the changes which failed.
+func (e *BatchError) Unwrap() []error {
+	var errs []error
+	for _, err := range e.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
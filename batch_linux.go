@@ -0,0 +1,218 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+// Batch calls fn to queue changes, then sends them to IPVS in windows of
+// batchWindow changes, reading the acknowledgements of a window after
+// sending it, saving a round trip per change. All changes are validated
+// before any is sent. IPVS applies each change independently, so when some
+// fail, Batch returns a *BatchError, and the others are applied.
+//
+// The timeout of the Client applies to the whole batch. Changes are logged
+// and audited like those made one at a time, without the state before
+// updates.
+func (c *client) Batch(ctx context.Context, fn func(*Batch)) error {
+	var b Batch
+	fn(&b)
+
+	if len(b.ops) == 0 {
+		return nil
+	}
+
+	for _, op := range b.ops {
+		if err := op.validate(); err != nil {
+			return err
+		}
+	}
+
+	var done func(OpResult)
+	if c.instrumentation != nil {
+		ctx, done = c.instrumentation.StartOp(ctx, "batch")
+	}
+
+	errs := make([]error, len(b.ops))
+	dryRun := isDryRun(ctx, c.dryRun)
+	start := time.Now()
+	var (
+		acked int
+		err   error
+	)
+	if !dryRun {
+		err = c.withSocket(ctx, func(time.Time) error {
+			var err error
+			acked, err = c.sendBatch(b.ops, errs)
+			return err
+		})
+	}
+	d := time.Since(start)
+
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			err = ctxErr
+		}
+		// The changes which were not acknowledged may or may not have been
+		// applied.
+		for i := acked; i < len(errs); i++ {
+			errs[i] = err
+		}
+	}
+
+	failed := false
+	for i, op := range b.ops {
+		if !dryRun {
+			c.metrics.request(newOpResult(opNames[op.cmd], d, errs[i]), false, 0)
+		}
+		c.recordMutation(ctx, c.batchMutation(ctx, op, errs[i]))
+
+		if errs[i] != nil {
+			failed = true
+		}
+	}
+
+	if failed {
+		err = &BatchError{Errors: errs}
+	}
+	if done != nil {
+		done(newOpResult("batch", d, err))
+	}
+
+	return err
+}
+
+// batchWindow is the number of changes of a Batch sent before reading
+// their acknowledgements, bounding the acknowledgements queued in the
+// receive buffer of the socket, which drops them when full.
+const batchWindow = 64
+
+// sendBatch sends ops in windows of batchWindow, reading the
+// acknowledgements of each window before sending the next, and setting the
+// error of each op which failed in errs. IPVS acknowledges each request in
+// a datagram of its own, in order, which attributes each error to its op. It
+// returns the number of ops acknowledged, and an error if the socket
+// failed.
+func (c *client) sendBatch(ops []batchOp, errs []error) (int, error) {
+	var acked int
+	for acked < len(ops) {
+		window := ops[acked:]
+		if len(window) > batchWindow {
+			window = window[:batchWindow]
+		}
+
+		seqs := make([]uint32, 0, len(window))
+		var sendErr error
+		for _, op := range window {
+			buf := op.encode()
+			req, err := c.c.Send(genetlink.Message{
+				Header: genetlink.Header{
+					Command: op.cmd,
+					Version: cipvs.GenlVersion,
+				},
+				Data: *buf,
+			}, c.family.ID, netlink.Request|netlink.Acknowledge)
+			releaseRequest(buf)
+
+			if err != nil {
+				// Still read the acknowledgements of the ops sent, so that
+				// they are not mistaken for replies to later requests.
+				sendErr = err
+				break
+			}
+
+			seqs = append(seqs, req.Header.Sequence)
+		}
+
+		for i, seq := range seqs {
+			_, msgs, err := c.c.Receive()
+			switch {
+			case isAckError(err):
+				errs[acked] = window[i].error(err)
+			case err != nil:
+				return acked, err
+			case len(msgs) != 1 || msgs[0].Header.Sequence != seq:
+				return acked, fmt.Errorf("ipvs: unexpected reply to batched request %d", acked)
+			}
+			acked++
+		}
+
+		if sendErr != nil {
+			return acked, sendErr
+		}
+	}
+
+	return acked, nil
+}
+
+// isAckError reports whether err was returned by IPVS in the acknowledgement
+// of a request, rather than by the socket. The netlink package reports the
+// error codes of NLMSG_ERROR replies as bare errnos, while the failures of
+// the socket, such as ENOBUFS when acknowledgements were dropped, are
+// wrapped in an *os.SyscallError.
+func isAckError(err error) bool {
+	var opErr *netlink.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "receive" {
+		return false
+	}
+
+	errno, ok := opErr.Err.(syscall.Errno)
+	return ok && errno != syscall.ENOBUFS
+}
+
+// encode encodes the attributes of the request for op, like encodeRequest.
+func (op batchOp) encode() *[]byte {
+	var dest *Destination
+	if op.hasDest {
+		dest = &op.dest
+	}
+
+	return encodeRequest(op.svc, dest)
+}
+
+// error wraps the error IPVS returned for op like the corresponding Client
+// method.
+func (op batchOp) error(err error) error {
+	buf := op.encode()
+	err = commandError(op.cmd, extendedError(err, *buf))
+	releaseRequest(buf)
+
+	if op.cmd == cipvs.CmdNewService || op.cmd == cipvs.CmdSetService {
+		return serviceError(op.svc, err)
+	}
+
+	return err
+}
+
+// batchMutation describes op, which returned err, for logging and auditing.
+func (c *client) batchMutation(ctx context.Context, op batchOp, err error) mutation {
+	key := op.svc.Key()
+	m := mutation{service: &key, err: err}
+
+	switch op.cmd {
+	case cipvs.CmdNewService:
+		m.op, m.after, m.result = "create_service", op.svc, c.serviceResult(ctx, op.svc)
+	case cipvs.CmdSetService:
+		m.op, m.after, m.result = "update_service", op.svc, c.serviceResult(ctx, op.svc)
+	case cipvs.CmdDelService:
+		m.op, m.before = "remove_service", op.svc
+	case cipvs.CmdNewDest:
+		m.op, m.after, m.result = "create_destination", op.dest, c.destinationResult(ctx, op.svc, op.dest)
+	case cipvs.CmdSetDest:
+		m.op, m.after, m.result = "update_destination", op.dest, c.destinationResult(ctx, op.svc, op.dest)
+	case cipvs.CmdDelDest:
+		m.op, m.before = "remove_destination", op.dest
+	}
+
+	return m
+}
@@ -0,0 +1,197 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"gotest.tools/v3/assert"
+)
+
+// queueSocket is a netlink.Socket queueing the replies to each request until
+// they are received. Unlike nltest, it supports sending several requests
+// before receiving their replies.
+type queueSocket struct {
+	fn      nltest.Func
+	replies [][]netlink.Message
+	// maxPending is the largest number of replies queued.
+	maxPending int
+	// recvErr fails the receives after the first recvOK, if set.
+	recvErr error
+	recvOK  int
+}
+
+func (s *queueSocket) Close() error { return nil }
+
+func (s *queueSocket) Send(m netlink.Message) error {
+	msgs, err := s.fn([]netlink.Message{m})
+	if err != nil {
+		return err
+	}
+
+	s.replies = append(s.replies, msgs)
+	if len(s.replies) > s.maxPending {
+		s.maxPending = len(s.replies)
+	}
+	return nil
+}
+
+func (s *queueSocket) SendMessages(msgs []netlink.Message) error {
+	for _, m := range msgs {
+		if err := s.Send(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *queueSocket) Receive() ([]netlink.Message, error) {
+	if len(s.replies) == 0 {
+		return nil, errors.New("no pending replies")
+	}
+	if s.recvErr != nil {
+		if s.recvOK == 0 {
+			return nil, s.recvErr
+		}
+		s.recvOK--
+	}
+
+	msgs := s.replies[0]
+	s.replies = s.replies[1:]
+	return msgs, nil
+}
+
+func TestBatch(t *testing.T) {
+	var cmds []uint8
+	sock := &queueSocket{fn: func(reqs []netlink.Message) ([]netlink.Message, error) {
+		var gm genetlink.Message
+		assert.NilError(t, gm.UnmarshalBinary(reqs[0].Data))
+		assert.Equal(t, reqs[0].Header.Flags, netlink.Request|netlink.Acknowledge)
+
+		cmds = append(cmds, gm.Header.Command)
+		if gm.Header.Command == cipvs.CmdNewDest {
+			return nltest.Error(int(syscall.EEXIST), reqs)
+		}
+		return nltest.Error(0, reqs)
+	}}
+
+	client := &client{
+		c:      genetlink.NewConn(netlink.NewConn(sock, 0)),
+		family: genetlink.Family{ID: familyID, Version: cipvs.GenlVersion, Name: cipvs.GenlName},
+		sem:    make(chan struct{}, 1),
+	}
+	defer client.Close()
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+
+	err := client.Batch(context.Background(), func(b *Batch) {
+		b.CreateService(svc)
+		b.CreateDestination(svc, dest)
+		b.UpdateDestination(svc, dest)
+		b.RemoveService(svc)
+	})
+
+	var batchErr *BatchError
+	assert.Assert(t, errors.As(err, &batchErr))
+	assert.Equal(t, len(batchErr.Errors), 4)
+	assert.NilError(t, batchErr.Errors[0])
+	assert.Assert(t, errors.Is(batchErr.Errors[1], ErrDestinationExists))
+	assert.NilError(t, batchErr.Errors[2])
+	assert.NilError(t, batchErr.Errors[3])
+	assert.Assert(t, errors.Is(err, ErrDestinationExists))
+	assert.ErrorContains(t, err, "1 of 4 batched changes failed")
+
+	assert.DeepEqual(t, cmds, []uint8{cipvs.CmdNewService, cipvs.CmdNewDest, cipvs.CmdSetDest, cipvs.CmdDelService})
+	assert.Equal(t, len(sock.replies), 0)
+
+	m := client.Metrics()
+	assert.Equal(t, m.Requests["new_dest"], uint64(1))
+	assert.Equal(t, m.Errors[syscall.EEXIST], uint64(1))
+
+	// All changes succeeding.
+	cmds = nil
+	err = client.Batch(context.Background(), func(b *Batch) {
+		b.UpdateService(svc)
+		b.RemoveDestination(svc, dest)
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, cmds, []uint8{cipvs.CmdSetService, cipvs.CmdDelDest})
+}
+
+func TestBatch_Window(t *testing.T) {
+	sock := &queueSocket{fn: func(reqs []netlink.Message) ([]netlink.Message, error) {
+		return nltest.Error(0, reqs)
+	}}
+
+	client := &client{
+		c:      genetlink.NewConn(netlink.NewConn(sock, 0)),
+		family: genetlink.Family{ID: familyID, Version: cipvs.GenlVersion, Name: cipvs.GenlName},
+		sem:    make(chan struct{}, 1),
+	}
+	defer client.Close()
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	batch := func(b *Batch) {
+		for i := 0; i < 2*batchWindow+1; i++ {
+			b.CreateDestination(svc, Destination{
+				Address: netip.AddrFrom4([4]byte{198, 51, byte(i / 256), byte(i)}), Port: 8080, Family: INET, Weight: 1,
+			})
+		}
+	}
+
+	assert.NilError(t, client.Batch(context.Background(), batch))
+	assert.Equal(t, sock.maxPending, batchWindow)
+	assert.Equal(t, len(sock.replies), 0)
+
+	// When the socket fails, only the changes not acknowledged fail.
+	sock.recvErr = os.NewSyscallError("recvmsg", syscall.ENOBUFS)
+	sock.recvOK = batchWindow + 3
+	err := client.Batch(context.Background(), batch)
+
+	var batchErr *BatchError
+	assert.Assert(t, errors.As(err, &batchErr))
+	for i, err := range batchErr.Errors {
+		if i < batchWindow+3 {
+			assert.NilError(t, err, "change %d", i)
+		} else {
+			assert.Assert(t, errors.Is(err, syscall.ENOBUFS), "change %d: %v", i, err)
+		}
+	}
+}
+
+func TestBatch_Invalid(t *testing.T) {
+	sock := &queueSocket{fn: func(reqs []netlink.Message) ([]netlink.Message, error) {
+		t.Fatal("unexpected request")
+		return nil, nil
+	}}
+
+	client := &client{
+		c:      genetlink.NewConn(netlink.NewConn(sock, 0)),
+		family: genetlink.Family{ID: familyID, Version: cipvs.GenlVersion, Name: cipvs.GenlName},
+		sem:    make(chan struct{}, 1),
+	}
+	defer client.Close()
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+
+	// Nothing is sent when a change is invalid.
+	err := client.Batch(context.Background(), func(b *Batch) {
+		b.CreateService(svc)
+		b.CreateService(Service{Scheduler: RoundRobin, FWMark: 42})
+	})
+	assert.ErrorContains(t, err, "firewall mark service requires")
+
+	assert.NilError(t, client.Batch(context.Background(), func(*Batch) {}))
+}
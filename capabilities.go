@@ -0,0 +1,98 @@
+package ipvs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Capabilities reports the IPVS features supported by the kernel, so that
+// callers can avoid features that would be rejected, or silently ignored.
+type Capabilities struct {
+	// Kernel is the version of the running kernel, such as [3]int{6, 1, 0}.
+	Kernel [3]int
+
+	// Stats64 reports whether Stats holds 64-bit counters, which requires
+	// Linux 4.1 or later.
+	Stats64 bool
+	// MixedFamilyDestinations reports whether Destinations of another family
+	// than their Service are supported, which requires Linux 4.1 or later.
+	MixedFamilyDestinations bool
+	// TunnelGUE and TunnelGRE report whether the GUE and GRE tunnel types
+	// are supported, which requires Linux 5.3 or later.
+	TunnelGUE bool
+	TunnelGRE bool
+	// EstimatorCPUList reports whether the CPUs running the rate estimators
+	// can be configured with the est_cpulist sysctl.
+	EstimatorCPUList bool
+	// WeightedRandomTwoChoices reports whether the twos scheduler is
+	// available.
+	WeightedRandomTwoChoices bool
+	// Schedulers lists the available schedulers, as returned by
+	// Client.AvailableSchedulers. It is nil when none were found, such as
+	// in containers without /lib/modules.
+	Schedulers []Scheduler
+}
+
+// known reports whether caps describe a kernel, rather than being the zero
+// Capabilities.
+func (caps Capabilities) known() bool {
+	return caps.Kernel != [3]int{}
+}
+
+// hasScheduler reports whether s is available, assuming all schedulers are
+// when caps do not list them.
+func (caps Capabilities) hasScheduler(s Scheduler) bool {
+	if len(caps.Schedulers) == 0 {
+		return s != WeightedRandomTwoChoices || caps.WeightedRandomTwoChoices || !caps.known()
+	}
+
+	for _, available := range caps.Schedulers {
+		if available == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// capabilitiesFrom derives the Capabilities of a kernel from its version.
+func capabilitiesFrom(kernel [3]int) Capabilities {
+	atLeast := Info{Version: kernel}.AtLeast
+
+	return Capabilities{
+		Kernel:                  kernel,
+		Stats64:                 atLeast(4, 1, 0),
+		MixedFamilyDestinations: atLeast(4, 1, 0),
+		TunnelGUE:               atLeast(5, 3, 0),
+		TunnelGRE:               atLeast(5, 3, 0),
+	}
+}
+
+// parseKernelRelease parses the version of a kernel release, such as
+// "6.1.0-13-amd64" or "5.15.0", ignoring any suffix.
+func parseKernelRelease(release string) ([3]int, error) {
+	var v [3]int
+
+	release = strings.TrimSpace(release)
+	if i := strings.IndexFunc(release, func(r rune) bool {
+		return r != '.' && (r < '0' || r > '9')
+	}); i >= 0 {
+		release = release[:i]
+	}
+
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return v, fmt.Errorf("ipvs: invalid kernel release %q", release)
+	}
+
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, fmt.Errorf("ipvs: invalid kernel release %q", release)
+		}
+		v[i] = n
+	}
+
+	return v, nil
+}
@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// Capabilities detects the IPVS features of the running kernel, from its
+// version and by probing /proc. The sysctls of IPVS are those of the network
+// namespace of c.
+func (c *client) Capabilities(ctx context.Context) (Capabilities, error) {
+	if err := ctx.Err(); err != nil {
+		return Capabilities{}, err
+	}
+
+	var caps Capabilities
+	err := inNetNS(c.opts, func() error {
+		var err error
+		caps, err = capabilities(os.DirFS("/"))
+		return err
+	})
+	return caps, err
+}
+
+// capabilities implements Capabilities with the files of fsys.
+func capabilities(fsys fs.FS) (Capabilities, error) {
+	release, err := fs.ReadFile(fsys, "proc/sys/kernel/osrelease")
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	kernel, err := parseKernelRelease(string(release))
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	caps := capabilitiesFrom(kernel)
+
+	_, err = fs.Stat(fsys, "proc/sys/net/ipv4/vs/est_cpulist")
+	switch {
+	case err == nil:
+		caps.EstimatorCPUList = true
+	case !errors.Is(err, fs.ErrNotExist):
+		return Capabilities{}, err
+	}
+
+	schedulers, err := availableSchedulers(fsys)
+	if err != nil {
+		return Capabilities{}, err
+	}
+
+	if len(schedulers) > 0 {
+		caps.Schedulers = schedulers
+	}
+	for _, s := range schedulers {
+		if s == WeightedRandomTwoChoices {
+			caps.WeightedRandomTwoChoices = true
+		}
+	}
+
+	return caps, nil
+}
@@ -0,0 +1,54 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	fsys := fstest.MapFS{
+		"proc/sys/kernel/osrelease":             {Data: []byte("6.2.0-1-amd64\n")},
+		"proc/sys/net/ipv4/vs/est_cpulist":      {Data: []byte("0-3\n")},
+		"lib/modules/6.2.0-1-amd64/modules.dep": {Data: []byte("kernel/net/netfilter/ipvs/ip_vs_twos.ko:\n")},
+	}
+
+	actual, err := capabilities(fsys)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, Capabilities{
+		Kernel:                   [3]int{6, 2, 0},
+		Stats64:                  true,
+		MixedFamilyDestinations:  true,
+		TunnelGUE:                true,
+		TunnelGRE:                true,
+		EstimatorCPUList:         true,
+		WeightedRandomTwoChoices: true,
+		Schedulers:               []Scheduler{WeightedRandomTwoChoices},
+	})
+}
+
+func TestCapabilities_Old(t *testing.T) {
+	fsys := fstest.MapFS{
+		"proc/sys/kernel/osrelease": {Data: []byte("4.19.0-21-amd64\n")},
+	}
+
+	actual, err := capabilities(fsys)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, Capabilities{
+		Kernel:                  [3]int{4, 19, 0},
+		Stats64:                 true,
+		MixedFamilyDestinations: true,
+	})
+}
+
+func TestCapabilities_NetNSNotExist(t *testing.T) {
+	c := &client{opts: options{netNSPath: "/var/run/netns/does-not-exist"}}
+
+	_, err := c.Capabilities(context.Background())
+	assert.ErrorContains(t, err, "ipvs: opening network namespace")
+}
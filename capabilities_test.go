@@ -0,0 +1,78 @@
+package ipvs
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseKernelRelease(t *testing.T) {
+	type testCase struct {
+		release  string
+		expected [3]int
+		err      string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		actual, err := parseKernelRelease(tc.release)
+		if tc.err != "" {
+			assert.ErrorContains(t, err, tc.err)
+			return
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, actual, tc.expected)
+	}
+
+	testCases := []testCase{
+		{release: "6.1.0-13-amd64\n", expected: [3]int{6, 1, 0}},
+		{release: "5.15.0", expected: [3]int{5, 15, 0}},
+		{release: "4.19", expected: [3]int{4, 19, 0}},
+		{release: "5.10.102+", expected: [3]int{5, 10, 102}},
+		{release: "6.8.0-rc1", expected: [3]int{6, 8, 0}},
+		{release: "", err: "invalid kernel release"},
+		{release: "6", err: "invalid kernel release"},
+		{release: "6..1", err: "invalid kernel release"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.release, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestCapabilitiesFrom(t *testing.T) {
+	type testCase struct {
+		kernel   [3]int
+		expected Capabilities
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		tc.expected.Kernel = tc.kernel
+		assert.DeepEqual(t, capabilitiesFrom(tc.kernel), tc.expected)
+	}
+
+	testCases := []testCase{
+		{kernel: [3]int{3, 10, 0}},
+		{
+			kernel:   [3]int{4, 1, 0},
+			expected: Capabilities{Stats64: true, MixedFamilyDestinations: true},
+		},
+		{
+			kernel:   [3]int{5, 2, 21},
+			expected: Capabilities{Stats64: true, MixedFamilyDestinations: true},
+		},
+		{
+			kernel:   [3]int{5, 3, 0},
+			expected: Capabilities{Stats64: true, MixedFamilyDestinations: true, TunnelGUE: true, TunnelGRE: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(Info{Version: tc.kernel}.String(), func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
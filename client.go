@@ -3,6 +3,7 @@
 package ipvs
 
 import (
+	"context"
 	"fmt"
 	"net/netip"
 	"strings"
@@ -13,32 +14,153 @@ import (
 // Client represents an opaque IPVS client.
 // This would most commonly be connected to IPVS running on the same machine,
 // but may represent a connection to a broker on another machine.
+//
+// Every operation takes a context, whose deadline bounds the operation and
+// whose cancellation aborts it, returning the error of the context.
 type Client interface {
-	Info() (Info, error)
-
-	Config() (Config, error)
-	SetConfig(Config) error
-
-	Services() ([]ServiceExtended, error)
-	Service(Service) (ServiceExtended, error)
-	CreateService(Service) error
-	UpdateService(Service) error
-	RemoveService(Service) error
-
-	Destinations(Service) ([]DestinationExtended, error)
-	CreateDestination(Service, Destination) error
-	UpdateDestination(Service, Destination) error
-	RemoveDestination(Service, Destination) error
+	// Info fetches the version and connection table size of IPVS.
+	Info(context.Context) (Info, error)
+
+	Config(context.Context) (Config, error)
+	SetConfig(context.Context, Config) error
+
+	// GetTimeouts and SetTimeouts are the time.Duration counterparts of
+	// Config and SetConfig.
+	GetTimeouts(context.Context) (Timeouts, error)
+	SetTimeouts(context.Context, Timeouts) error
+
+	// Services and Destinations return their results in a stable order,
+	// sorted by ServiceKey and by address and port respectively.
+	Services(context.Context, ...ListOption) ([]ServiceExtended, error)
+	Service(context.Context, Service) (ServiceExtended, error)
+	CreateService(context.Context, Service) error
+	UpdateService(context.Context, Service) error
+	RemoveService(context.Context, Service) error
+
+	// AddOrUpdateService creates a service, or updates it if it exists
+	// with a different configuration, reporting whether anything changed.
+	AddOrUpdateService(context.Context, Service) (bool, error)
+
+	// Apply makes IPVS match a declarative configuration of all its
+	// services and destinations, making only the changes needed.
+	Apply(context.Context, TableConfig, ApplyOptions) (ApplyResult, error)
+	// ApplyWithRollback is Apply, undoing the changes made when one fails.
+	ApplyWithRollback(context.Context, TableConfig, ApplyOptions) (ApplyResult, error)
+	// Plan returns the changes Apply would make, without making them.
+	Plan(context.Context, TableConfig, ApplyOptions) (Changeset, error)
+
+	// Snapshot captures the services, destinations, timeouts and daemons
+	// of IPVS, which Restore reinstates, such as to back up IPVS before a
+	// change.
+	Snapshot(context.Context) (Snapshot, error)
+	Restore(context.Context, Snapshot, RestoreOptions) (ApplyResult, error)
+
+	// ServicesWithDestinations returns all services, each with its
+	// destinations.
+	ServicesWithDestinations(context.Context, ...ListOption) ([]ServiceWithDestinations, error)
+
+	// ServicesFunc and DestinationsFunc call a func for each entry, in the
+	// order IPVS dumps them, instead of materializing the whole list. The
+	// dump is received in full before the first call, as a truncated dump
+	// is made again. Returning an error from the func stops the iteration.
+	ServicesFunc(context.Context, func(ServiceExtended) error, ...ListOption) error
+	DestinationsFunc(context.Context, Service, func(DestinationExtended) error) error
+
+	// Flush removes all services, and their destinations, from IPVS.
+	Flush(context.Context) error
+
+	// Batch sends the changes queued by a func together, without waiting
+	// for each to be acknowledged before sending the next.
+	Batch(context.Context, func(*Batch)) error
+
+	Destinations(context.Context, Service) ([]DestinationExtended, error)
+	CreateDestination(context.Context, Service, Destination) error
+	UpdateDestination(context.Context, Service, Destination) error
+	RemoveDestination(context.Context, Service, Destination, ...RemoveOption) error
+
+	// AddOrUpdateDestination creates a destination, or updates it if it
+	// exists with a different configuration, reporting whether anything
+	// changed.
+	AddOrUpdateDestination(context.Context, Service, Destination) (bool, error)
+
+	// SetDestinationWeight changes only the weight of an existing
+	// destination, preserving the rest of its configuration.
+	SetDestinationWeight(ctx context.Context, svc Service, dest Destination, weight uint32) error
+
+	// DrainDestination quiesces a destination, waits for its connections
+	// to finish, and then removes it.
+	DrainDestination(ctx context.Context, svc Service, dest Destination, opts DrainOptions) error
+
+	// GlobalStats returns the statistics of IPVS as a whole.
+	GlobalStats(context.Context) (Stats, error)
+
+	// PerCPUStats returns the statistics of IPVS as a whole, broken down by
+	// CPU.
+	PerCPUStats(context.Context) (PerCPUStats, error)
+
+	// Connections returns the entries of the connection table, such as to
+	// inspect the flows to a Destination before removing it.
+	Connections(context.Context, ...ConnectionOption) ([]Connection, error)
+
+	// ConnectionSummary counts the connections of a Service per real server
+	// and state, without returning the connections themselves.
+	ConnectionSummary(context.Context, Service) (ConnectionSummary, error)
+
+	// StartDaemon starts a connection synchronization daemon, StopDaemon
+	// stops the daemon of a state, and Daemons returns the running daemons.
+	StartDaemon(context.Context, Daemon) error
+	StopDaemon(context.Context, DaemonState) error
+	Daemons(context.Context) ([]Daemon, error)
+
+	// SyncConfig and SetSyncConfig read and write the sysctls tuning the
+	// synchronization between daemons.
+	SyncConfig(context.Context) (SyncConfig, error)
+	SetSyncConfig(context.Context, SyncConfig) error
+
+	// AvailableSchedulers returns the schedulers the kernel can load, to
+	// check a Service before creating it.
+	AvailableSchedulers(context.Context) ([]Scheduler, error)
+
+	// Capabilities detects the features supported by the kernel, so that
+	// callers can degrade gracefully on older kernels.
+	Capabilities(context.Context) (Capabilities, error)
+
+	// Metrics returns a snapshot of the counters the Client keeps about its
+	// netlink requests.
+	Metrics() Metrics
+
+	// WithNetNS returns a new Client with the same options, connected to
+	// IPVS in the network namespace ns, for targeting another namespace
+	// without reconfiguring the Client. The returned Client must be closed
+	// separately.
+	WithNetNS(ns NetNS) (Client, error)
+
+	// Close releases the resources of the Client.
+	Close() error
 }
 
 // Service represents a virtual server.
 //
 // When referencing an existing Service, only the identifying fields
 // (Address, Port, Family, and Protocol) are required to be set.
+//
+// Services can instead be identified by a firewall mark, set by netfilter
+// rules, which allows a single Service to match multiple ports and
+// protocols. When FWMark is non-zero, the Address, Port and Protocol are
+// ignored, and Family is required to be set.
+//
+// The Netmask groups clients of persistent services, and must be of the same
+// family as the Service. IPv6 masks must be prefixes, such as
+// netmask.MaskFrom(64, 128). When unset, each client is treated individually.
+//
+// PEName selects a persistence engine, such as "sip", which groups the
+// connections of persistent services by application data instead of the
+// client address alone.
 type Service struct {
 	Address   netip.Addr
 	Netmask   netmask.Mask
-	Scheduler string
+	Scheduler Scheduler
+	PEName    string
 	Timeout   uint32
 	Flags     Flags
 	Port      uint16
@@ -49,13 +171,31 @@ type Service struct {
 
 // ServiceExtended contains fields that are not necessary for
 // comparison of the identity of a Service.
+//
+// Stats holds the 64-bit counters of IPVS when available, on Linux 4.1 or
+// later, and otherwise the 32-bit counters, which wrap on busy services.
 type ServiceExtended struct {
 	Service
-	Stats   Stats
+	Stats Stats
+
+	// Deprecated: Stats holds the 64-bit counters when available.
 	Stats64 Stats
 }
 
 // Destination represents a connection to the real server.
+//
+// When referencing an existing Destination, only the identifying fields
+// (Address, Port, and Family) are required to be set. The remaining fields
+// are sent to IPVS when creating or updating a Destination, where the tunnel
+// fields only apply to the Tunnel forwarding method.
+//
+// UpperThreshold limits the connections to a Destination, where zero means no
+// limit. Once reached, the Destination is overloaded until its connections
+// drop to the LowerThreshold, see DestinationExtended.Overloaded.
+//
+// The Family of a Destination may differ from its Service, such as an IPv6
+// Service balancing over IPv4 real servers, when the Tunnel forwarding method
+// is used. This requires Linux 4.1 or later.
 type Destination struct {
 	Address        netip.Addr
 	FwdMethod      ForwardType
@@ -71,17 +211,41 @@ type Destination struct {
 
 // DestinationExtended contains fields that are not neccesarry
 // for comparison of the identity of a Destination.
+//
+// Stats holds the 64-bit counters of IPVS when available, on Linux 4.1 or
+// later, and otherwise the 32-bit counters.
 type DestinationExtended struct {
 	Destination
 	ActiveConnections     uint32
 	InactiveConnections   uint32
 	PersistentConnections uint32
 	Stats                 Stats
-	Stats64               Stats
+
+	// Deprecated: Stats holds the 64-bit counters when available.
+	Stats64 Stats
+}
+
+// Overloaded reports whether the destination has reached its upper connection
+// threshold, at which point IPVS marks it overloaded and stops scheduling new
+// connections to it. IPVS does not report the overload state itself, so it is
+// derived from the connection counts. Once overloaded, IPVS keeps scheduling
+// around a destination until its connections drop to the lower threshold, or
+// three quarters of the upper threshold when no lower threshold is set, which
+// cannot be observed.
+func (dest DestinationExtended) Overloaded() bool {
+	if dest.UpperThreshold == 0 {
+		return false
+	}
+
+	return uint64(dest.ActiveConnections)+uint64(dest.InactiveConnections) >= uint64(dest.UpperThreshold)
 }
 
 // Stats represents the statistics of a Service as a whole,
 // or the individual Destination connections.
+//
+// The rates are estimated by IPVS, averaged over the last few seconds, and
+// returned together with the counters, so they need not be derived from
+// successive counters.
 type Stats struct {
 	Connections     uint64
 	IncomingPackets uint64
@@ -89,11 +253,11 @@ type Stats struct {
 	IncomingBytes   uint64
 	OutgoingBytes   uint64
 
-	ConnectionRate     uint64
-	IncomingPacketRate uint64 // pktbs
-	OutgoingPacketRate uint64 // pktbs
-	IncomingByteRate   uint64 // bps
-	OutgoingByteRate   uint64 // bps
+	ConnectionRate     uint64 // connections per second
+	IncomingPacketRate uint64 // packets per second
+	OutgoingPacketRate uint64 // packets per second
+	IncomingByteRate   uint64 // bytes per second
+	OutgoingByteRate   uint64 // bytes per second
 }
 
 // Info returns basic high-level information about the IPVS instance.
@@ -102,21 +266,67 @@ type Info struct {
 	ConnectionTableSize uint32
 }
 
+// AtLeast reports whether the IPVS version is major.minor.patch or later,
+// which can be used to gate features.
+func (i Info) AtLeast(major, minor, patch int) bool {
+	for n, v := range [3]int{major, minor, patch} {
+		if i.Version[n] != v {
+			return i.Version[n] > v
+		}
+	}
+
+	return true
+}
+
+// String returns the info in the form printed by ipvsadm, such as
+// "IP Virtual Server version 1.2.1 (size=4096)".
+func (i Info) String() string {
+	return fmt.Sprintf("IP Virtual Server version %d.%d.%d (size=%d)", i.Version[0], i.Version[1], i.Version[2], i.ConnectionTableSize)
+}
+
 // Config represents the timeout values (in seconds) for TCP sessions,
 // TCP sessions after receiving a FIN packet, and UDP packets.
+// See Timeouts for the same values as time.Duration.
+//
+// IPVS does not expose the timeouts of SCTP sessions over netlink; the
+// kernel applies its built-in per-state SCTP timeouts instead.
 type Config struct {
 	TCPTimeout    uint32
 	TCPFinTimeout uint32
 	UDPTimeout    uint32
 }
 
-// New returns an instance of Client.
+// New returns an instance of Client with the default options.
 func New() (Client, error) {
-	// BUG(terin): We might want to make the client type configurable in calls to New.
-	return newClient()
+	return NewClient()
 }
 
-//go:generate stringer -type=ForwardType,AddressFamily,Protocol,TunnelType,TunnelFlags --output zz_generated.stringer.go
+// NewClient returns an instance of Client connected to IPVS over generic
+// netlink, configured by opts. The Client is safe for concurrent use by multiple
+// goroutines, which share a single socket.
+func NewClient(opts ...Option) (Client, error) {
+	c, err := newClient(buildOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// NewClientConn returns an instance of Client sending its requests over
+// conn, configured by opts. Options configuring the socket, such as
+// WithReadBuffer and WithNetNSPath, are ignored, as conn is already
+// connected. The Client closes conn when it is closed.
+func NewClientConn(conn Conn, opts ...Option) (Client, error) {
+	c, err := newClientConn(conn, buildOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+//go:generate stringer -type=ForwardType,AddressFamily,Protocol,TunnelType,TunnelFlags,DaemonState,EventType --output zz_generated.stringer.go
 
 // ForwardType configures how IPVS forwards traffic to the real server.
 type ForwardType uint32
@@ -153,6 +363,16 @@ const (
 	SCTP Protocol = 0x84
 )
 
+// IsValid reports whether p is one of the protocols IPVS can balance.
+func (p Protocol) IsValid() bool {
+	switch p {
+	case TCP, UDP, SCTP:
+		return true
+	}
+
+	return false
+}
+
 // Flags tweak the behavior of a virtual service, and the chosen scheduler.
 type Flags uint32
 
@@ -166,6 +386,22 @@ const (
 	ServiceSchedulerOpt3 Flags = 0x0020
 )
 
+// Flags of the source hashing (sh) and maglev hashing (mh) schedulers, which
+// are carried by the scheduler option bits.
+const (
+	// SourceHashFallback makes the sh scheduler pick another destination
+	// when the hashed one is unavailable.
+	SourceHashFallback = ServiceSchedulerOpt1
+	// SourceHashPort makes the sh scheduler include the source port in the
+	// hash.
+	SourceHashPort = ServiceSchedulerOpt2
+	// MaglevFallback makes the mh scheduler pick another destination when
+	// the hashed one is unavailable.
+	MaglevFallback = ServiceSchedulerOpt1
+	// MaglevPort makes the mh scheduler include the source port in the hash.
+	MaglevPort = ServiceSchedulerOpt2
+)
+
 // String returns a human readable representation of flags.
 func (i Flags) String() string {
 	flags := []string{}
@@ -195,16 +431,21 @@ func (i Flags) String() string {
 	return strings.Join(flags, " | ")
 }
 
+// TunnelType configures the encapsulation of the Tunnel forwarding method.
 type TunnelType uint8
 
+// Tunnel types known to IPVS. GUE and GRE require Linux 5.3 or later.
 const (
 	IPIP TunnelType = iota
 	GUE
 	GRE
 )
 
+// TunnelFlags configure the checksums of GUE and GRE encapsulated packets.
 type TunnelFlags uint16
 
+// Checksum modes of encapsulated packets. TunnelEncapRemoteChecksum only
+// applies to GUE, and cannot be combined with TunnelEncapChecksum.
 const (
 	TunnelEncapNoChecksum     TunnelFlags = 0
 	TunnelEncapChecksum       TunnelFlags = 0x0001
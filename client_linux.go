@@ -4,12 +4,21 @@
 package ipvs
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/netip"
 	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/cloudflare/ipvs/internal/netns"
 	"github.com/cloudflare/ipvs/netmask"
 	"github.com/josharian/native"
 	"github.com/mdlayher/genetlink"
@@ -18,27 +27,186 @@ import (
 )
 
 // client implements Client by connecting to IPVS
-// on the local machine over netlink.
+// on the local machine over netlink. It is safe for concurrent use, as
+// requests are serialized over its single socket.
 type client struct {
-	c      *genetlink.Conn
-	family genetlink.Family
+	c       Conn
+	family  genetlink.Family
+	timeout time.Duration
+
+	// instrumentation, if set, observes each request.
+	instrumentation Instrumentation
+	// metrics counts the requests made.
+	metrics clientMetrics
+	// logger, if set, logs the changes made to IPVS.
+	logger mutationLogger
+	// audit, if set, is called for each successful change.
+	audit Auditor
+	// retry is the policy for retrying requests failing with transient
+	// errors.
+	retry RetryPolicy
+	// dryRun makes all changes dry runs, see WithDryRun.
+	dryRun bool
+
+	// opts are the options the client was created with.
+	opts options
+
+	// sem is held while executing a request, guarding the socket and
+	// deadline. A channel is used so that waiting honors cancellation.
+	sem chan struct{}
+	// deadline reports whether a deadline is set on the socket.
+	deadline bool
 }
 
-// newClient creates a netlink connection,
-// then passes to initClient.
-func newClient() (*client, error) {
-	c, err := genetlink.Dial(nil)
+// newClient creates a netlink connection configured by o,
+// then passes to newClientConn.
+func newClient(o options) (*client, error) {
+	var c *genetlink.Conn
+	err := withNetlinkConfig(o, func(cfg *netlink.Config) error {
+		var err error
+		c, err = genetlink.Dial(cfg)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return initClient(c)
+	if o.readBuffer > 0 {
+		if err := setReadBuffer(c, o.readBuffer, o.forceReadBuffer); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if o.writeBuffer > 0 {
+		if err := c.SetWriteBuffer(o.writeBuffer); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	// Have the kernel explain rejected requests and check them strictly,
+	// where supported.
+	for _, opt := range []netlink.ConnOption{netlink.ExtendedAcknowledge, netlink.GetStrictCheck} {
+		if err := c.SetOption(opt, true); err != nil && !errors.Is(err, syscall.ENOPROTOOPT) {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return newClientConn(c, o)
+}
+
+// newClientConn returns a client configured by o sending its requests over
+// c, which is closed on failure.
+func newClientConn(c Conn, o options) (*client, error) {
+	var (
+		load   func() error
+		loaded bool
+	)
+	if o.modprobe {
+		load = func() error {
+			loaded = true
+			if o.logger != nil {
+				o.logger.retry(context.Background(), "get_family", ErrNotLoaded)
+			}
+			return modprobe()
+		}
+	}
+
+	client, err := initClient(c, load)
+	if err != nil {
+		return nil, err
+	}
+	if loaded {
+		client.metrics.retry()
+	}
+
+	client.opts = o
+	client.timeout = o.timeout
+	client.instrumentation = o.instrumentation
+	client.logger = o.logger
+	client.audit = o.audit
+	client.retry = o.retry
+	client.dryRun = o.dryRun
+	return client, nil
+}
+
+// setReadBuffer sets the size of the receive buffer of c. With force, it is
+// set with SO_RCVBUFFORCE to exceed the limit of the kernel, falling back to
+// SO_RCVBUF when lacking the CAP_NET_ADMIN capability.
+func setReadBuffer(c *genetlink.Conn, bytes int, force bool) error {
+	if force {
+		rc, err := c.SyscallConn()
+		if err != nil {
+			return err
+		}
+
+		var serr error
+		err = rc.Control(func(fd uintptr) {
+			serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUFFORCE, bytes)
+		})
+		if err != nil {
+			return err
+		}
+		if !errors.Is(serr, syscall.EPERM) {
+			return serr
+		}
+	}
+
+	return c.SetReadBuffer(bytes)
+}
+
+// withNetlinkConfig calls fn with the configuration of a netlink connection
+// to the network namespace of o.
+func withNetlinkConfig(o options, fn func(cfg *netlink.Config) error) error {
+	cfg := &netlink.Config{NetNS: o.netNSFD}
+	if o.netNSPath != "" {
+		f, err := os.Open(o.netNSPath)
+		if err != nil {
+			return fmt.Errorf("ipvs: opening network namespace: %w", err)
+		}
+		defer f.Close()
+
+		cfg.NetNS = int(f.Fd())
+	}
+
+	return fn(cfg)
+}
+
+// inNetNS calls fn in the network namespace of o, for the files of /proc
+// which cannot be opened in another namespace. Errors of fn are returned
+// as is.
+func inNetNS(o options, fn func() error) error {
+	var ferr error
+	err := netns.Do(o.netNSPath, o.netNSFD, func() error {
+		ferr = fn()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ipvs: %w", err)
+	}
+
+	return ferr
 }
 
 // initClient configures a netlink connection for the
-// IPVS family, then returns a configured client.
-func initClient(c *genetlink.Conn) (*client, error) {
+// IPVS family, then returns a configured client. When the family is missing,
+// load is called, if set, to load the IPVS module before trying again.
+func initClient(c Conn, load func() error) (*client, error) {
 	f, err := c.GetFamily(cipvs.GenlName)
+	if errors.Is(err, os.ErrNotExist) && load != nil {
+		if err := load(); err != nil {
+			c.Close()
+			return nil, err
+		}
+
+		f, err = c.GetFamily(cipvs.GenlName)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		c.Close()
+		return nil, &kernelError{sentinel: ErrNotLoaded, err: err}
+	}
 	if err != nil {
 		c.Close()
 		return nil, err
@@ -47,11 +215,22 @@ func initClient(c *genetlink.Conn) (*client, error) {
 	return &client{
 		c:      c,
 		family: f,
+		sem:    make(chan struct{}, 1),
 	}, nil
 }
 
+// modprobe loads the IPVS kernel module.
+func modprobe() error {
+	out, err := exec.Command("modprobe", "ip_vs").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ipvs: loading ip_vs module: %v: %s", err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
 // Info fetches the Info object from the netlink connection.
-func (c *client) Info() (Info, error) {
+func (c *client) Info(ctx context.Context) (Info, error) {
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdGetInfo,
@@ -60,7 +239,7 @@ func (c *client) Info() (Info, error) {
 	}
 	flags := netlink.Request
 
-	msgs, err := c.c.Execute(msg, c.family.ID, flags)
+	msgs, err := c.execute(ctx, msg, flags)
 	if err != nil {
 		return Info{}, err
 	}
@@ -97,7 +276,7 @@ func (c *client) Info() (Info, error) {
 }
 
 // Config fetches the Config object from the netlink connection.
-func (c *client) Config() (Config, error) {
+func (c *client) Config(ctx context.Context) (Config, error) {
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdGetConfig,
@@ -106,7 +285,7 @@ func (c *client) Config() (Config, error) {
 	}
 	flags := netlink.Request
 
-	msgs, err := c.c.Execute(msg, c.family.ID, flags)
+	msgs, err := c.execute(ctx, msg, flags)
 	if err != nil {
 		return Config{}, err
 	}
@@ -142,7 +321,20 @@ func (c *client) Config() (Config, error) {
 }
 
 // SetConfig changes the timeout values used for IPVS connections.
-func (c *client) SetConfig(config Config) error {
+func (c *client) SetConfig(ctx context.Context, config Config) (err error) {
+	var before any
+	if c.logging(ctx) {
+		if cur, err := c.Config(ctx); err == nil {
+			before = cur
+		}
+	}
+	defer func() {
+		c.recordMutation(ctx, mutation{
+			op: "set_config", before: before, after: config, err: err,
+			result: func() (any, error) { return c.Config(ctx) },
+		})
+	}()
+
 	ae := netlink.NewAttributeEncoder()
 	ae.Uint32(cipvs.CmdAttrTimeoutTcp, config.TCPTimeout)
 	ae.Uint32(cipvs.CmdAttrTimeoutTcpFin, config.TCPFinTimeout)
@@ -162,7 +354,7 @@ func (c *client) SetConfig(config Config) error {
 	}
 	flags := netlink.Request | netlink.Acknowledge
 
-	r, err := c.c.Execute(msg, c.family.ID, flags)
+	r, err := c.execute(ctx, msg, flags)
 	if err != nil {
 		return err
 	}
@@ -174,8 +366,46 @@ func (c *client) SetConfig(config Config) error {
 	return nil
 }
 
-// Services returns a list of Services from the netlink connection.
-func (c *client) Services() ([]ServiceExtended, error) {
+// Services returns a list of Services matching opts from the netlink
+// connection, sorted by their ServiceKey.
+func (c *client) Services(ctx context.Context, opts ...ListOption) ([]ServiceExtended, error) {
+	var svcs []ServiceExtended
+	n, err := c.services(ctx, buildListOptions(opts), func(svc ServiceExtended) error {
+		svcs = append(svcs, svc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	if svcs == nil {
+		svcs = []ServiceExtended{}
+	}
+
+	sortServices(svcs)
+	return svcs, nil
+}
+
+// ServicesFunc calls fn for each Service matching opts, in the order IPVS
+// dumps them, without materializing the list. If fn returns an error,
+// ServicesFunc stops and returns it.
+func (c *client) ServicesFunc(ctx context.Context, fn func(ServiceExtended) error, opts ...ListOption) error {
+	_, err := c.services(ctx, buildListOptions(opts), fn)
+	return err
+}
+
+// services dumps the Services, calling fn for each one matching o. It
+// returns the number of Services dumped, matching or not.
+//
+// The whole dump is received before decoding starts, so that a dump which
+// overran the socket is made again rather than delivered in part: the raw
+// messages are buffered, but the decoded Services are never materialized
+// together. Large dumps are decoded in parallel, see decodeMessages.
+func (c *client) services(ctx context.Context, o listOptions, fn func(ServiceExtended) error) (int, error) {
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdGetService,
@@ -184,41 +414,27 @@ func (c *client) Services() ([]ServiceExtended, error) {
 	}
 	flags := netlink.Request | netlink.Dump
 
-	msgs, err := c.c.Execute(msg, c.family.ID, flags)
+	msgs, err := c.execute(ctx, msg, flags)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	if len(msgs) == 0 {
-		return nil, os.ErrNotExist
-	}
-
-	svcs := make([]ServiceExtended, 0, len(msgs))
-	for _, msg := range msgs {
-		var s ServiceExtended
-		ad, err := netlink.NewAttributeDecoder(msg.Data)
-		if err != nil {
-			return nil, err
+	err = decodeMessages(msgs, decodeService, func(s ServiceExtended) error {
+		if !o.match(s.Service) {
+			return nil
 		}
-
-		for ad.Next() {
-			if ad.Type() == cipvs.CmdAttrService {
-				ad.Do(unpackService(&s))
-			}
-		}
-
-		if err := ad.Err(); err != nil {
-			return nil, err
-		}
-
-		svcs = append(svcs, s)
+		return fn(s)
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return svcs, nil
+	return len(msgs), nil
 }
 
-// Services returns a list of Services from the netlink connection.
-func (c *client) Service(svc Service) (ServiceExtended, error) {
+// Service fetches a single Service, identified by its address, port, family
+// and protocol, or its firewall mark, from the netlink connection.
+func (c *client) Service(ctx context.Context, svc Service) (ServiceExtended, error) {
 	ae := netlink.NewAttributeEncoder()
 	ae.Do(cipvs.CmdAttrService, packService(svc))
 	b, err := ae.Encode()
@@ -236,7 +452,7 @@ func (c *client) Service(svc Service) (ServiceExtended, error) {
 	}
 	flags := netlink.Request
 
-	msgs, err := c.c.Execute(msg, c.family.ID, flags)
+	msgs, err := c.execute(ctx, msg, flags)
 	if err != nil {
 		return ServiceExtended{}, err
 	}
@@ -245,48 +461,38 @@ func (c *client) Service(svc Service) (ServiceExtended, error) {
 		return ServiceExtended{}, os.ErrNotExist
 	}
 
-	msg = msgs[0]
-	var s ServiceExtended
-	ad, err := netlink.NewAttributeDecoder(msg.Data)
-	if err != nil {
-		return ServiceExtended{}, err
-	}
-
-	for ad.Next() {
-		if ad.Type() == cipvs.CmdAttrService {
-			ad.Do(unpackService(&s))
-		}
-	}
-
-	if err := ad.Err(); err != nil {
-		return ServiceExtended{}, err
-	}
-
-	return s, nil
+	return decodeService(msgs[0])
 }
 
 // CreateService creates a new virtual service.
-func (c *client) CreateService(svc Service) error {
-	ae := netlink.NewAttributeEncoder()
-	ae.Do(cipvs.CmdAttrService, packService(svc))
-	b, err := ae.Encode()
-
-	if err != nil {
+func (c *client) CreateService(ctx context.Context, svc Service) (err error) {
+	defer func() {
+		key := svc.Key()
+		c.recordMutation(ctx, mutation{
+			op: "create_service", service: &key, after: svc, err: err,
+			result: c.serviceResult(ctx, svc),
+		})
+	}()
+
+	if err := svc.validate(); err != nil {
 		return err
 	}
 
+	buf := encodeRequest(svc, nil)
+	defer releaseRequest(buf)
+
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdNewService,
 			Version: cipvs.GenlVersion,
 		},
-		Data: b,
+		Data: *buf,
 	}
 	flags := netlink.Request | netlink.Acknowledge
 
-	r, err := c.c.Execute(msg, c.family.ID, flags)
+	r, err := c.execute(ctx, msg, flags)
 	if err != nil {
-		return err
+		return serviceError(svc, err)
 	}
 
 	if len(r) == 0 {
@@ -298,50 +504,63 @@ func (c *client) CreateService(svc Service) error {
 
 // RemoveService deletes a virtual service, and any configured Destinations,
 // from IPVS.
-func (c *client) RemoveService(svc Service) error {
-	ae := netlink.NewAttributeEncoder()
-	ae.Do(cipvs.CmdAttrService, packService(svc))
-	b, err := ae.Encode()
+func (c *client) RemoveService(ctx context.Context, svc Service) (err error) {
+	defer func() {
+		key := svc.Key()
+		c.recordMutation(ctx, mutation{op: "remove_service", service: &key, before: svc, err: err})
+	}()
 
-	if err != nil {
-		return err
-	}
+	buf := encodeRequest(svc, nil)
+	defer releaseRequest(buf)
 
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdDelService,
 			Version: cipvs.GenlVersion,
 		},
-		Data: b,
+		Data: *buf,
 	}
 	flags := netlink.Request | netlink.Acknowledge
 
-	_, err = c.c.Execute(msg, c.family.ID, flags)
+	_, err = c.execute(ctx, msg, flags)
 	return err
 }
 
 // UpdateService replaces the configuration of a Service.
-func (c *client) UpdateService(svc Service) error {
-	ae := netlink.NewAttributeEncoder()
-	ae.Do(cipvs.CmdAttrService, packService(svc))
-	b, err := ae.Encode()
-
-	if err != nil {
+func (c *client) UpdateService(ctx context.Context, svc Service) (err error) {
+	var before any
+	if c.logging(ctx) {
+		if cur, err := c.Service(ctx, svc); err == nil {
+			before = cur.Service
+		}
+	}
+	defer func() {
+		key := svc.Key()
+		c.recordMutation(ctx, mutation{
+			op: "update_service", service: &key, before: before, after: svc, err: err,
+			result: c.serviceResult(ctx, svc),
+		})
+	}()
+
+	if err := svc.validate(); err != nil {
 		return err
 	}
 
+	buf := encodeRequest(svc, nil)
+	defer releaseRequest(buf)
+
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdSetService,
 			Version: cipvs.GenlVersion,
 		},
-		Data: b,
+		Data: *buf,
 	}
 	flags := netlink.Request | netlink.Acknowledge
 
-	r, err := c.c.Execute(msg, c.family.ID, flags)
+	r, err := c.execute(ctx, msg, flags)
 	if err != nil {
-		return err
+		return serviceError(svc, err)
 	}
 
 	if len(r) == 0 {
@@ -351,14 +570,61 @@ func (c *client) UpdateService(svc Service) error {
 	return nil
 }
 
-// Destinations returns the configured Destinations for a service.
-func (c *client) Destinations(svc Service) ([]DestinationExtended, error) {
+// Flush removes all virtual services, and their Destinations, from IPVS.
+func (c *client) Flush(ctx context.Context) (err error) {
+	defer func() {
+		c.recordMutation(ctx, mutation{op: "flush", err: err})
+	}()
+
+	msg := genetlink.Message{
+		Header: genetlink.Header{
+			Command: cipvs.CmdFlush,
+			Version: cipvs.GenlVersion,
+		},
+	}
+	flags := netlink.Request | netlink.Acknowledge
+
+	_, err = c.execute(ctx, msg, flags)
+	return err
+}
+
+// Destinations returns the configured Destinations for a service, sorted by
+// their address and port.
+func (c *client) Destinations(ctx context.Context, svc Service) ([]DestinationExtended, error) {
+	var dests []DestinationExtended
+	n, err := c.destinations(ctx, svc, func(dest DestinationExtended) error {
+		dests = append(dests, dest)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	sortDestinations(dests)
+	return dests, nil
+}
+
+// DestinationsFunc calls fn for each Destination of svc, in the order IPVS
+// dumps them, without materializing the list. If fn returns an error,
+// DestinationsFunc stops and returns it.
+func (c *client) DestinationsFunc(ctx context.Context, svc Service, fn func(DestinationExtended) error) error {
+	_, err := c.destinations(ctx, svc, fn)
+	return err
+}
+
+// destinations dumps the Destinations of svc, calling fn for each one. It
+// returns the number of Destinations dumped.
+func (c *client) destinations(ctx context.Context, svc Service, fn func(DestinationExtended) error) (int, error) {
 	ae := netlink.NewAttributeEncoder()
 	ae.Do(cipvs.CmdAttrService, packService(svc))
 	b, err := ae.Encode()
 
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
 	msg := genetlink.Message{
@@ -370,65 +636,45 @@ func (c *client) Destinations(svc Service) ([]DestinationExtended, error) {
 	}
 	flags := netlink.Request | netlink.Dump
 
-	msgs, err := c.c.Execute(msg, c.family.ID, flags)
+	msgs, err := c.execute(ctx, msg, flags)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	if len(msgs) == 0 {
-		return nil, os.ErrNotExist
+	if err := decodeMessages(msgs, destinationDecoder(svc.Family), fn); err != nil {
+		return 0, err
 	}
 
-	dests := make([]DestinationExtended, 0, len(msgs))
-	for _, msg := range msgs {
-		var dest DestinationExtended
-		// In Linux kernels before 3.18, the address family of a destination
-		// could not differ from the service. Pass down the service's address
-		// family, which will be overridden by the kernel, if available.
-		dest.Family = svc.Family
-
-		ad, err := netlink.NewAttributeDecoder(msg.Data)
-		if err != nil {
-			return nil, err
-		}
-
-		for ad.Next() {
-			if ad.Type() == cipvs.CmdAttrDest {
-				ad.Do(unpackDestination(&dest))
-			}
-		}
-
-		if err := ad.Err(); err != nil {
-			return nil, err
-		}
-
-		dests = append(dests, dest)
-	}
-
-	return dests, nil
+	return len(msgs), nil
 }
 
 // CreateDestination creates a Destination for the Service.
-func (c *client) CreateDestination(svc Service, dest Destination) error {
-	ae := netlink.NewAttributeEncoder()
-	ae.Do(cipvs.CmdAttrService, packService(svc))
-	ae.Do(cipvs.CmdAttrDest, packDest(dest))
-	b, err := ae.Encode()
-
-	if err != nil {
+func (c *client) CreateDestination(ctx context.Context, svc Service, dest Destination) (err error) {
+	defer func() {
+		key := svc.Key()
+		c.recordMutation(ctx, mutation{
+			op: "create_destination", service: &key, after: dest, err: err,
+			result: c.destinationResult(ctx, svc, dest),
+		})
+	}()
+
+	if err := dest.validate(svc); err != nil {
 		return err
 	}
 
+	buf := encodeRequest(svc, &dest)
+	defer releaseRequest(buf)
+
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdNewDest,
 			Version: cipvs.GenlVersion,
 		},
-		Data: b,
+		Data: *buf,
 	}
 	flags := netlink.Request | netlink.Acknowledge
 
-	r, err := c.c.Execute(msg, c.family.ID, flags)
+	r, err := c.execute(ctx, msg, flags)
 	if err != nil {
 		return err
 	}
@@ -441,26 +687,38 @@ func (c *client) CreateDestination(svc Service, dest Destination) error {
 }
 
 // UpdateDestination replaces the configuration of a Destination.
-func (c *client) UpdateDestination(svc Service, dest Destination) error {
-	ae := netlink.NewAttributeEncoder()
-	ae.Do(cipvs.CmdAttrService, packService(svc))
-	ae.Do(cipvs.CmdAttrDest, packDest(dest))
-	b, err := ae.Encode()
-
-	if err != nil {
+func (c *client) UpdateDestination(ctx context.Context, svc Service, dest Destination) (err error) {
+	var before any
+	if c.logging(ctx) {
+		if cur, err := c.destination(ctx, svc, dest); err == nil {
+			before = cur.Destination
+		}
+	}
+	defer func() {
+		key := svc.Key()
+		c.recordMutation(ctx, mutation{
+			op: "update_destination", service: &key, before: before, after: dest, err: err,
+			result: c.destinationResult(ctx, svc, dest),
+		})
+	}()
+
+	if err := dest.validate(svc); err != nil {
 		return err
 	}
 
+	buf := encodeRequest(svc, &dest)
+	defer releaseRequest(buf)
+
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdSetDest,
 			Version: cipvs.GenlVersion,
 		},
-		Data: b,
+		Data: *buf,
 	}
 	flags := netlink.Request | netlink.Acknowledge
 
-	r, err := c.c.Execute(msg, c.family.ID, flags)
+	r, err := c.execute(ctx, msg, flags)
 	if err != nil {
 		return err
 	}
@@ -472,28 +730,362 @@ func (c *client) UpdateDestination(svc Service, dest Destination) error {
 	return nil
 }
 
-// RemoveDestination removes the Destinaation from a Service.
-func (c *client) RemoveDestination(svc Service, dest Destination) error {
-	ae := netlink.NewAttributeEncoder()
-	ae.Do(cipvs.CmdAttrService, packService(svc))
-	ae.Do(cipvs.CmdAttrDest, packDest(dest))
-	b, err := ae.Encode()
+// RemoveDestination removes the Destinaation from a Service, configured by
+// opts.
+func (c *client) RemoveDestination(ctx context.Context, svc Service, dest Destination, opts ...RemoveOption) (err error) {
+	defer func() {
+		key := svc.Key()
+		c.recordMutation(ctx, mutation{op: "remove_destination", service: &key, before: dest, err: err})
+	}()
 
-	if err != nil {
-		return err
-	}
+	o := buildRemoveOptions(opts)
+
+	buf := encodeRequest(svc, &dest)
+	defer releaseRequest(buf)
 
 	msg := genetlink.Message{
 		Header: genetlink.Header{
 			Command: cipvs.CmdDelDest,
 			Version: cipvs.GenlVersion,
 		},
-		Data: b,
+		Data: *buf,
 	}
 	flags := netlink.Request | netlink.Acknowledge
 
-	_, err = c.c.Execute(msg, c.family.ID, flags)
-	return err
+	if _, err := c.execute(ctx, msg, flags); err != nil {
+		return err
+	}
+
+	if o.flushConntrack {
+		return c.flushConntrack(ctx, svc, dest)
+	}
+
+	return nil
+}
+
+// logging reports whether c logs its mutations.
+func (c *client) logging(ctx context.Context) bool {
+	return c.logger != nil && c.logger.enabled(ctx)
+}
+
+// recordMutation logs m, if c has a logger, and audits it when it succeeded,
+// if c has an audit hook and it was not a dry run.
+func (c *client) recordMutation(ctx context.Context, m mutation) {
+	m.dryRun = isDryRun(ctx, c.dryRun)
+	if c.logger != nil {
+		c.logger.mutation(ctx, m)
+	}
+
+	if c.audit != nil && m.err == nil && !m.dryRun {
+		c.audit.Audit(ctx, auditRecord(ctx, m))
+	}
+}
+
+// serviceResult returns a func fetching svc, for auditing.
+func (c *client) serviceResult(ctx context.Context, svc Service) func() (any, error) {
+	return func() (any, error) {
+		cur, err := c.Service(ctx, svc)
+		return cur.Service, err
+	}
+}
+
+// destinationResult returns a func fetching dest of svc, for auditing.
+func (c *client) destinationResult(ctx context.Context, svc Service, dest Destination) func() (any, error) {
+	return func() (any, error) {
+		cur, err := c.destination(ctx, svc, dest)
+		return cur.Destination, err
+	}
+}
+
+// opNames are the names of the IPVS commands reported to Instrumentation.
+var opNames = map[uint8]string{
+	cipvs.CmdNewService: "new_service",
+	cipvs.CmdSetService: "set_service",
+	cipvs.CmdDelService: "del_service",
+	cipvs.CmdGetService: "get_service",
+	cipvs.CmdNewDest:    "new_dest",
+	cipvs.CmdSetDest:    "set_dest",
+	cipvs.CmdDelDest:    "del_dest",
+	cipvs.CmdGetDest:    "get_dest",
+	cipvs.CmdNewDaemon:  "new_daemon",
+	cipvs.CmdDelDaemon:  "del_daemon",
+	cipvs.CmdGetDaemon:  "get_daemon",
+	cipvs.CmdSetConfig:  "set_config",
+	cipvs.CmdGetConfig:  "get_config",
+	cipvs.CmdSetInfo:    "set_info",
+	cipvs.CmdGetInfo:    "get_info",
+	cipvs.CmdZero:       "zero",
+	cipvs.CmdFlush:      "flush",
+}
+
+// execute sends msg to IPVS and waits for its replies, recording the request
+// in the metrics of c, and reporting it to its instrumentation, if any.
+func (c *client) execute(ctx context.Context, msg genetlink.Message, flags netlink.HeaderFlags) ([]genetlink.Message, error) {
+	op := opNames[msg.Header.Command]
+
+	// Changes are the requests IPVS acknowledges. In a dry run, they are
+	// acknowledged without being sent.
+	if flags&netlink.Acknowledge != 0 && isDryRun(ctx, c.dryRun) {
+		return []genetlink.Message{{}}, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		msgs, err := c.executeOnce(ctx, op, msg, flags)
+		if err == nil || !retryable(err, flags) {
+			return msgs, err
+		}
+		if attempt >= c.retry.Attempts {
+			if attempt > 1 {
+				return nil, &RetryError{Op: op, Attempts: attempt, Err: err}
+			}
+			return nil, err
+		}
+
+		c.metrics.retry()
+		if c.logger != nil {
+			c.logger.retry(ctx, op, err)
+		}
+		if err := sleepContext(ctx, c.retry.backoff(attempt, rand.Float64)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// executeOnce makes a single attempt of the request op, observed by the
+// instrumentation and metrics of c.
+func (c *client) executeOnce(ctx context.Context, op string, msg genetlink.Message, flags netlink.HeaderFlags) ([]genetlink.Message, error) {
+	var done func(OpResult)
+	if c.instrumentation != nil {
+		ctx, done = c.instrumentation.StartOp(ctx, op)
+	}
+
+	start := time.Now()
+	msgs, err := c.executeRequest(ctx, msg, flags)
+	r := newOpResult(op, time.Since(start), err)
+
+	c.metrics.request(r, flags&netlink.Dump != 0, len(msgs))
+	if done != nil {
+		done(r)
+	}
+
+	return msgs, err
+}
+
+// retryable reports whether a request with flags failing with err can be
+// retried. Changes, which are acknowledged, are only retried when IPVS
+// rejected them, rather than when receiving their acknowledgement failed.
+func retryable(err error, flags netlink.HeaderFlags) bool {
+	if !isTransient(err) {
+		return false
+	}
+
+	return flags&netlink.Acknowledge == 0 || isAckError(err)
+}
+
+// executeRequest sends msg to IPVS and waits for its replies, holding the
+// socket with withSocket. Dumps interrupted by a concurrent change fail with
+// ErrDumpInterrupted, and errors explained by the kernel wrap an
+// *ExtendedError.
+//
+// When the socket overruns while dumping, dropping some of the replies, the
+// rest are discarded and the dump is made again, up to dumpResyncs times, so
+// that a truncated dump is never returned.
+func (c *client) executeRequest(ctx context.Context, msg genetlink.Message, flags netlink.HeaderFlags) ([]genetlink.Message, error) {
+	var msgs []genetlink.Message
+	err := c.withSocket(ctx, func(deadline time.Time) error {
+		for resync := 0; ; resync++ {
+			var err error
+			msgs, err = c.request(msg, flags)
+			if flags&netlink.Dump == 0 || !isOverrun(err) || resync == dumpResyncs {
+				return err
+			}
+
+			op := opNames[msg.Header.Command]
+			c.metrics.retry()
+			if c.logger != nil {
+				c.logger.retry(ctx, op, err)
+			}
+
+			if err := c.drain(ctx, deadline); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, commandError(msg.Header.Command, extendedError(err, msg.Data))
+	}
+
+	return msgs, nil
+}
+
+// request sends msg with flags and receives its replies, checking they match
+// the request.
+func (c *client) request(msg genetlink.Message, flags netlink.HeaderFlags) ([]genetlink.Message, error) {
+	req, err := c.c.Send(msg, c.family.ID, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, nmsgs, err := c.c.Receive()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := netlink.Validate(req, nmsgs); err != nil {
+		return nil, err
+	}
+
+	for _, m := range nmsgs {
+		if m.Header.Flags&netlink.DumpInterrupted != 0 {
+			return nil, ErrDumpInterrupted
+		}
+	}
+
+	return msgs, nil
+}
+
+const (
+	// dumpResyncs is the number of times a dump is made again after the
+	// socket overran.
+	dumpResyncs = 3
+	// drainTimeout is how long drain waits for further replies.
+	drainTimeout = 10 * time.Millisecond
+)
+
+// isOverrun reports whether err is the socket reporting that it overran,
+// rather than IPVS returning ENOBUFS.
+func isOverrun(err error) bool {
+	return errors.Is(err, syscall.ENOBUFS) && !isAckError(err)
+}
+
+// drain discards the replies pending on the socket, until none arrives for
+// drainTimeout, then restores the deadline of the request.
+func (c *client) drain(ctx context.Context, deadline time.Time) error {
+	for {
+		d := time.Now().Add(drainTimeout)
+		if !deadline.IsZero() && deadline.Before(d) {
+			d = deadline
+		}
+		if err := c.c.SetDeadline(d); err != nil {
+			return err
+		}
+
+		if _, _, err := c.c.Receive(); errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+	}
+
+	if err := c.c.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	// Cancelling ctx while draining expired the deadline, which was just
+	// overwritten.
+	return ctx.Err()
+}
+
+// withSocket calls fn while holding the socket of c. The socket deadline is
+// set to the earliest of the configured timeout and the deadline of ctx,
+// which is passed to fn, and cancelling ctx aborts a pending read, including
+// multi-part dumps.
+func (c *client) withSocket(ctx context.Context, fn func(deadline time.Time) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case c.sem <- struct{}{}:
+		defer func() { <-c.sem }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	var deadline time.Time
+	if c.timeout > 0 {
+		deadline = time.Now().Add(c.timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	// Only touch the deadline when needed, clearing one set by a previous
+	// call.
+	if !deadline.IsZero() || c.deadline {
+		if err := c.c.SetDeadline(deadline); err != nil {
+			return err
+		}
+		c.deadline = !deadline.IsZero()
+	}
+
+	if done := ctx.Done(); done != nil {
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-done:
+				// Unblock the pending read by expiring the deadline.
+				_ = c.c.SetDeadline(time.Unix(1, 0))
+				c.deadline = true
+			case <-stop:
+			}
+		}()
+		defer wg.Wait()
+		defer close(stop)
+	}
+
+	return fn(deadline)
+}
+
+// commandError maps the errno of err, returned by IPVS for cmd, onto the
+// exported sentinel errors. IPVS reuses errnos across commands, so their
+// meaning depends on the command.
+func commandError(cmd uint8, err error) error {
+	var sentinel error
+	switch {
+	case errors.Is(err, syscall.EPERM):
+		sentinel = ErrNotPermitted
+	case errors.Is(err, syscall.ESRCH) && cmd == cipvs.CmdDelDaemon:
+		sentinel = ErrDaemonNotRunning
+	case errors.Is(err, syscall.ESRCH):
+		sentinel = ErrServiceNotFound
+	case errors.Is(err, syscall.EEXIST) && cmd == cipvs.CmdNewDaemon:
+		sentinel = ErrDaemonRunning
+	case errors.Is(err, syscall.EEXIST) && cmd == cipvs.CmdNewService:
+		sentinel = ErrServiceExists
+	case errors.Is(err, syscall.EEXIST) && cmd == cipvs.CmdNewDest:
+		sentinel = ErrDestinationExists
+	case errors.Is(err, syscall.ENOENT) && (cmd == cipvs.CmdNewService || cmd == cipvs.CmdSetService):
+		sentinel = ErrSchedulerNotAvailable
+	case errors.Is(err, syscall.ENOENT) && (cmd == cipvs.CmdSetDest || cmd == cipvs.CmdDelDest):
+		sentinel = ErrDestinationNotFound
+	default:
+		return err
+	}
+
+	return &kernelError{sentinel: sentinel, err: err}
+}
+
+// Metrics returns a snapshot of the counters of c.
+func (c *client) Metrics() Metrics {
+	return c.metrics.snapshot()
+}
+
+// WithNetNS returns a new client with the options of c, connected to IPVS in
+// the network namespace ns.
+func (c *client) WithNetNS(ns NetNS) (Client, error) {
+	o := c.opts
+	o.netNSPath, o.netNSFD = ns.Path, ns.FD
+
+	client, err := newClient(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
 }
 
 // Close implements io.Closer
@@ -501,6 +1093,19 @@ func (c *client) Close() error {
 	return c.c.Close()
 }
 
+// serviceError wraps the error IPVS returned for creating or updating svc.
+func serviceError(svc Service, err error) error {
+	if svc.PEName != "" && errors.Is(err, syscall.ENOENT) {
+		return &PersistenceEngineError{Name: svc.PEName, Err: err}
+	}
+
+	if errors.Is(err, syscall.ENOENT) {
+		return fmt.Errorf("ipvs: scheduler %q not available on this kernel: %w", svc.Scheduler, err)
+	}
+
+	return err
+}
+
 // unpackService unpacks a Service from a netlink-encoded message
 func unpackService(svc *ServiceExtended) func(b []byte) error {
 	return func(b []byte) error {
@@ -512,6 +1117,7 @@ func unpackService(svc *ServiceExtended) func(b []byte) error {
 		var addr []byte
 		var flags []byte
 		var mask []byte
+		var stats64 bool
 		for ad.Next() {
 			switch ad.Type() {
 			case cipvs.SvcAttrAf:
@@ -527,7 +1133,9 @@ func unpackService(svc *ServiceExtended) func(b []byte) error {
 			case cipvs.SvcAttrFwmark:
 				svc.FWMark = ad.Uint32()
 			case cipvs.SvcAttrSchedName:
-				svc.Scheduler = ad.String()
+				svc.Scheduler = Scheduler(ad.String())
+			case cipvs.SvcAttrPeName:
+				svc.PEName = ad.String()
 			case cipvs.SvcAttrTimeout:
 				svc.Timeout = ad.Uint32()
 			case cipvs.SvcAttrNetmask:
@@ -536,14 +1144,19 @@ func unpackService(svc *ServiceExtended) func(b []byte) error {
 				ad.Do(unpackStats(&svc.Stats))
 			case cipvs.SvcAttrStats64:
 				ad.Do(unpackStats64(&svc.Stats64))
+				stats64 = true
 			}
 		}
 		if err = ad.Err(); err != nil {
 			return err
 		}
 
+		if stats64 {
+			svc.Stats = svc.Stats64
+		}
+
 		if svc.FWMark == 0 {
-			if svc.Family == INET {
+			if svc.Family == INET && len(addr) >= 4 {
 				addr = addr[0:4]
 			}
 
@@ -577,35 +1190,7 @@ func unpackService(svc *ServiceExtended) func(b []byte) error {
 // packService encodes the service attributes
 func packService(svc Service) func() ([]byte, error) {
 	return func() ([]byte, error) {
-		flags := make([]byte, 4)
-		native.Endian.PutUint32(flags, uint32(svc.Flags))
-		flags = append(flags, []byte{0xFF, 0xFF, 0xFF, 0xFF}...)
-
-		ae := netlink.NewAttributeEncoder()
-		ae.Uint16(cipvs.SvcAttrAf, uint16(svc.Family))
-		ae.String(cipvs.SvcAttrSchedName, svc.Scheduler)
-		ae.Bytes(cipvs.SvcAttrFlags, flags)
-		ae.Uint32(cipvs.SvcAttrTimeout, svc.Timeout)
-		switch {
-		case svc.Netmask.Is4():
-			ae.Bytes(cipvs.SvcAttrNetmask, svc.Netmask.AsSlice())
-		case svc.Netmask.Is6():
-			if ones := svc.Netmask.Bits(); ones >= 0 {
-				b := make([]byte, 4)
-				nlenc.PutUint32(b, uint32(ones))
-				ae.Bytes(cipvs.SvcAttrNetmask, b)
-			}
-		}
-
-		if svc.FWMark != 0 {
-			ae.Uint32(cipvs.SvcAttrFwmark, svc.FWMark)
-		} else {
-			ae.Uint16(cipvs.SvcAttrProtocol, uint16(svc.Protocol))
-			ae.Bytes(cipvs.SvcAttrAddr, svc.Address.AsSlice())
-			ae.Do(cipvs.SvcAttrPort, packPort(svc.Port))
-		}
-
-		return ae.Encode()
+		return appendService(nil, svc), nil
 	}
 }
 
@@ -618,6 +1203,7 @@ func unpackDestination(dest *DestinationExtended) func(b []byte) error {
 		}
 
 		var addr []byte
+		var stats64 bool
 		for ad.Next() {
 			switch ad.Type() {
 			case cipvs.DestAttrAddr:
@@ -650,13 +1236,18 @@ func unpackDestination(dest *DestinationExtended) func(b []byte) error {
 				ad.Do(unpackStats(&dest.Stats))
 			case cipvs.DestAttrStats64:
 				ad.Do(unpackStats64(&dest.Stats64))
+				stats64 = true
 			}
 		}
 		if err = ad.Err(); err != nil {
 			return err
 		}
 
-		if dest.Family == INET {
+		if stats64 {
+			dest.Stats = dest.Stats64
+		}
+
+		if dest.Family == INET && len(addr) >= 4 {
 			addr = addr[0:4]
 		}
 		if addr, ok := netip.AddrFromSlice(addr); ok {
@@ -670,20 +1261,23 @@ func unpackDestination(dest *DestinationExtended) func(b []byte) error {
 // packDest encodes the destination attributes
 func packDest(dest Destination) func() ([]byte, error) {
 	return func() ([]byte, error) {
-		ae := netlink.NewAttributeEncoder()
-		ae.Uint16(cipvs.DestAttrAddrFamily, uint16(dest.Family))
-		ae.Bytes(cipvs.DestAttrAddr, dest.Address.AsSlice())
-		ae.Do(cipvs.DestAttrPort, packPort(dest.Port))
-		ae.Uint32(cipvs.DestAttrFwdMethod, uint32(dest.FwdMethod))
-		ae.Uint32(cipvs.DestAttrWeight, dest.Weight)
-		ae.Uint32(cipvs.DestAttrUThresh, dest.UpperThreshold)
-		ae.Uint32(cipvs.DestAttrLThresh, dest.LowerThreshold)
-		ae.Uint8(cipvs.DestAttrTunType, uint8(dest.TunnelType))
-		ae.Do(cipvs.DestAttrTunPort, packPort(dest.TunnelPort))
-		ae.Uint16(cipvs.DestAttrTunFlags, uint16(dest.TunnelFlags))
+		return appendDest(nil, dest), nil
+	}
+}
 
-		return ae.Encode()
+// packAddr encodes addr in the form of family, so that IPv4-mapped IPv6
+// addresses are sent as IPv4 for INET, and IPv4 addresses as IPv4-mapped for
+// INET6. Other families are encoded as is.
+func packAddr(family AddressFamily, addr netip.Addr) []byte {
+	switch {
+	case family == INET && addr.Is4In6():
+		addr = addr.Unmap()
+	case family == INET6 && addr.Is4():
+		b := addr.As16()
+		return b[:]
 	}
+
+	return addr.AsSlice()
 }
 
 // unpackStats unpacks Stats from the 32-bit netlink message.
@@ -775,13 +1369,3 @@ func unpackPort(port *uint16) func(b []byte) error {
 		return nil
 	}
 }
-
-// packPort packs a port into a byte slice for a netlink message.
-func packPort(port uint16) func() ([]byte, error) {
-	return func() ([]byte, error) {
-		out := make([]byte, 2)
-		binary.BigEndian.PutUint16(out, port)
-
-		return out, nil
-	}
-}
@@ -4,11 +4,16 @@
 package ipvs
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/netip"
 	"os"
+	"syscall"
 	"testing"
+	"time"
 	"unicode"
 
 	"github.com/cloudflare/ipvs/internal/cipvs"
@@ -17,13 +22,94 @@ import (
 	"github.com/mdlayher/genetlink"
 	"github.com/mdlayher/genetlink/genltest"
 	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
 	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
 	"gotest.tools/v3/assert"
 	"pgregory.net/rapid"
 )
 
 const familyID = 0x24
 
+func TestInitClient_NotLoaded(t *testing.T) {
+	type testCase struct {
+		name   string
+		load   func() error
+		loads  bool
+		err    error
+		errMsg string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		loaded := false
+		fn := func(greq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			assert.Equal(t, greq.Header.Command, uint8(unix.CTRL_CMD_GETFAMILY))
+			if !loaded {
+				return nil, genltest.Error(int(syscall.ENOENT))
+			}
+
+			return []genetlink.Message{{
+				Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+					{Type: unix.CTRL_ATTR_FAMILY_ID, Data: nlenc.Uint16Bytes(familyID)},
+					{Type: unix.CTRL_ATTR_FAMILY_NAME, Data: nlenc.Bytes(cipvs.GenlName)},
+					{Type: unix.CTRL_ATTR_VERSION, Data: nlenc.Uint32Bytes(cipvs.GenlVersion)},
+				}),
+			}}, nil
+		}
+
+		var load func() error
+		if tc.load != nil {
+			load = func() error {
+				loaded = tc.loads
+				return tc.load()
+			}
+		}
+
+		client, err := initClient(genltest.Dial(fn), load)
+		if tc.err == nil && tc.errMsg == "" {
+			assert.NilError(t, err)
+			assert.Equal(t, client.family.ID, uint16(familyID))
+			client.Close()
+			return
+		}
+
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err)
+		}
+		assert.ErrorContains(t, err, tc.errMsg)
+	}
+
+	testCases := []testCase{
+		{
+			name:   "not loaded",
+			err:    ErrNotLoaded,
+			errMsg: "modprobe ip_vs",
+		},
+		{
+			name:   "load fails",
+			load:   func() error { return errors.New("modprobe: FATAL: Module ip_vs not found") },
+			errMsg: "Module ip_vs not found",
+		},
+		{
+			name:  "load succeeds",
+			load:  func() error { return nil },
+			loads: true,
+		},
+		{
+			name: "still not loaded",
+			load: func() error { return nil },
+			err:  ErrNotLoaded,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
 func TestServices_IsNotExist(t *testing.T) {
 	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
 		return nil, io.EOF
@@ -31,7 +117,7 @@ func TestServices_IsNotExist(t *testing.T) {
 	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdGetService, netlink.Request|netlink.Dump, fn))
 	defer client.Close()
 
-	if _, err := client.Services(); !os.IsNotExist(err) {
+	if _, err := client.Services(context.Background()); !os.IsNotExist(err) {
 		t.Fatalf("expected to not exists, but got: %v", err)
 	}
 }
@@ -272,7 +358,7 @@ func TestServices(t *testing.T) {
 
 			defer client.Close()
 
-			se, err := client.Services()
+			se, err := client.Services(context.Background())
 			assert.NilError(t, err)
 
 			services := []Service{}
@@ -285,6 +371,116 @@ func TestServices(t *testing.T) {
 	}
 }
 
+func TestUnpackStats64(t *testing.T) {
+	type testCase struct {
+		name     string
+		attrs    []netlink.Attribute
+		expected Stats
+	}
+
+	stats32 := netlink.Attribute{
+		Type: cipvs.SvcAttrStats,
+		Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: cipvs.StatsAttrConns, Data: nlenc.Uint32Bytes(7)},
+			{Type: cipvs.StatsAttrInbytes, Data: nlenc.Uint64Bytes(0x1FFFFFFFF)},
+			{Type: cipvs.StatsAttrCps, Data: nlenc.Uint32Bytes(2)},
+		}),
+	}
+	stats64 := netlink.Attribute{
+		Type: cipvs.SvcAttrStats64,
+		Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: cipvs.StatsAttrConns, Data: nlenc.Uint64Bytes(0x100000007)},
+			{Type: cipvs.StatsAttrInbytes, Data: nlenc.Uint64Bytes(0x1FFFFFFFF)},
+			{Type: cipvs.StatsAttrCps, Data: nlenc.Uint64Bytes(2)},
+		}),
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		// The statistics attributes of services and destinations share their
+		// types.
+		svcAttrs := append([]netlink.Attribute{
+			{Type: cipvs.SvcAttrFlags, Data: make([]byte, 8)},
+		}, tc.attrs...)
+
+		var svc ServiceExtended
+		assert.NilError(t, unpackService(&svc)(nltest.MustMarshalAttributes(svcAttrs)))
+		assert.Equal(t, svc.Stats, tc.expected)
+
+		var dest DestinationExtended
+		assert.NilError(t, unpackDestination(&dest)(nltest.MustMarshalAttributes(tc.attrs)))
+		assert.Equal(t, dest.Stats, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "32-bit",
+			attrs:    []netlink.Attribute{stats32},
+			expected: Stats{Connections: 7, IncomingBytes: 0x1FFFFFFFF, ConnectionRate: 2},
+		},
+		{
+			name:     "64-bit",
+			attrs:    []netlink.Attribute{stats32, stats64},
+			expected: Stats{Connections: 0x100000007, IncomingBytes: 0x1FFFFFFFF, ConnectionRate: 2},
+		},
+		{
+			name:     "64-bit first",
+			attrs:    []netlink.Attribute{stats64, stats32},
+			expected: Stats{Connections: 0x100000007, IncomingBytes: 0x1FFFFFFFF, ConnectionRate: 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestUnpackStats_Rates(t *testing.T) {
+	type testCase struct {
+		name   string
+		unpack func(stats *Stats) func(b []byte) error
+		rate   func(v uint32) []byte
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		b := nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: cipvs.StatsAttrCps, Data: tc.rate(1)},
+			{Type: cipvs.StatsAttrInpps, Data: tc.rate(2)},
+			{Type: cipvs.StatsAttrOutpps, Data: tc.rate(3)},
+			{Type: cipvs.StatsAttrInbps, Data: tc.rate(4)},
+			{Type: cipvs.StatsAttrOutbps, Data: tc.rate(5)},
+		})
+
+		var stats Stats
+		assert.NilError(t, tc.unpack(&stats)(b))
+		assert.Equal(t, stats, Stats{
+			ConnectionRate:     1,
+			IncomingPacketRate: 2,
+			OutgoingPacketRate: 3,
+			IncomingByteRate:   4,
+			OutgoingByteRate:   5,
+		})
+	}
+
+	testCases := []testCase{
+		{name: "32-bit", unpack: unpackStats, rate: nlenc.Uint32Bytes},
+		{
+			name:   "64-bit",
+			unpack: unpackStats64,
+			rate:   func(v uint32) []byte { return nlenc.Uint64Bytes(uint64(v)) },
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
 func TestService_PackUnpack(t *testing.T) {
 	rapid.Check(t, func(t *rapid.T) {
 		svc := rapid.Custom[Service](func(t *rapid.T) Service {
@@ -304,7 +500,8 @@ func TestService_PackUnpack(t *testing.T) {
 			return Service{
 				Address:   addr,
 				Netmask:   mask,
-				Scheduler: rapid.StringOf(rapid.RuneFrom(nil, unicode.Letter, unicode.Number)).Draw(t, "Scheduler"),
+				Scheduler: Scheduler(rapid.StringOf(rapid.RuneFrom(nil, unicode.Letter, unicode.Number)).Draw(t, "Scheduler")),
+				PEName:    rapid.SampledFrom([]string{"", "sip"}).Draw(t, "PEName"),
 				Timeout:   rapid.Uint32().Draw(t, "Timeout"),
 				Flags:     Flags(rapid.Uint32().Draw(t, "Flags")),
 				Port:      rapid.Uint16().Draw(t, "Port"),
@@ -334,6 +531,562 @@ func TestService_PackUnpack(t *testing.T) {
 	})
 }
 
+func TestService_Commands(t *testing.T) {
+	type testCase struct {
+		name    string
+		command uint8
+		flags   netlink.HeaderFlags
+		call    func(c *client, ctx context.Context, svc Service) error
+	}
+
+	svc := Service{
+		Address:   netip.MustParseAddr("127.0.1.1"),
+		Scheduler: "wlc",
+		Timeout:   300,
+		Port:      8080,
+		Family:    INET,
+		Protocol:  TCP,
+	}
+
+	expected := nltest.MustMarshalAttributes([]netlink.Attribute{
+		{
+			Type: cipvs.CmdAttrService,
+			Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: cipvs.SvcAttrAf, Data: []byte{0x02, 0x00}},
+				{Type: cipvs.SvcAttrSchedName, Data: []byte{'w', 'l', 'c', 0x00}},
+				{Type: cipvs.SvcAttrFlags, Data: []byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}},
+				{Type: cipvs.SvcAttrTimeout, Data: []byte{0x2C, 0x01, 0x00, 0x00}},
+				{Type: cipvs.SvcAttrNetmask, Data: []byte{0xFF, 0xFF, 0xFF, 0xFF}},
+				{Type: cipvs.SvcAttrProtocol, Data: []byte{0x06, 0x00}},
+				{Type: cipvs.SvcAttrAddr, Data: []byte{0x7F, 0x00, 0x01, 0x01}},
+				{Type: cipvs.SvcAttrPort, Data: []byte{0x1F, 0x90}},
+			}),
+		},
+	})
+
+	run := func(t *testing.T, tc testCase) {
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			assert.DeepEqual(t, gerq.Data, expected)
+			return []genetlink.Message{{}}, nil
+		}
+
+		client := testClient(t, genltest.CheckRequest(familyID, tc.command, tc.flags, fn))
+		defer client.Close()
+
+		assert.NilError(t, tc.call(client, context.Background(), svc))
+	}
+
+	testCases := []testCase{
+		{
+			name:    "create",
+			command: cipvs.CmdNewService,
+			flags:   netlink.Request | netlink.Acknowledge,
+			call:    (*client).CreateService,
+		},
+		{
+			name:    "update",
+			command: cipvs.CmdSetService,
+			flags:   netlink.Request | netlink.Acknowledge,
+			call:    (*client).UpdateService,
+		},
+		{
+			name:    "remove",
+			command: cipvs.CmdDelService,
+			flags:   netlink.Request | netlink.Acknowledge,
+			call:    (*client).RemoveService,
+		},
+		{
+			name:    "get",
+			command: cipvs.CmdGetService,
+			flags:   netlink.Request,
+			call: func(c *client, ctx context.Context, svc Service) error {
+				_, err := c.Service(ctx, svc)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestService_FWMark(t *testing.T) {
+	svc := Service{
+		Netmask:   netmask.MaskFrom(128, 128),
+		Scheduler: "rr",
+		FWMark:    42,
+		Family:    INET6,
+	}
+
+	expected := nltest.MustMarshalAttributes([]netlink.Attribute{
+		{
+			Type: cipvs.CmdAttrService,
+			Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: cipvs.SvcAttrAf, Data: []byte{0x0A, 0x00}},
+				{Type: cipvs.SvcAttrSchedName, Data: []byte{'r', 'r', 0x00}},
+				{Type: cipvs.SvcAttrFlags, Data: []byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}},
+				{Type: cipvs.SvcAttrTimeout, Data: []byte{0x00, 0x00, 0x00, 0x00}},
+				{Type: cipvs.SvcAttrNetmask, Data: []byte{0x80, 0x00, 0x00, 0x00}},
+				{Type: cipvs.SvcAttrFwmark, Data: []byte{0x2A, 0x00, 0x00, 0x00}},
+			}),
+		},
+	})
+
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		assert.DeepEqual(t, gerq.Data, expected)
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdNewService, netlink.Request|netlink.Acknowledge, fn))
+	defer client.Close()
+
+	assert.NilError(t, client.CreateService(context.Background(), svc))
+
+	var out ServiceExtended
+	ad, err := netlink.NewAttributeDecoder(expected)
+	assert.NilError(t, err)
+	for ad.Next() {
+		ad.Do(unpackService(&out))
+	}
+	assert.NilError(t, ad.Err())
+	assert.DeepEqual(t, out.Service, svc, cmp.Comparer(NetipAddrCompare))
+	assert.Equal(t, out.Key(), ServiceKey{Family: INET6, FWMark: 42})
+}
+
+func TestService_IPv6(t *testing.T) {
+	svc := Service{
+		Address:   netip.MustParseAddr("2001:db8::1"),
+		Netmask:   netmask.MaskFrom(64, 128),
+		Scheduler: "rr",
+		Port:      443,
+		Family:    INET6,
+		Protocol:  TCP,
+		Flags:     ServicePersistent,
+	}
+
+	expected := nltest.MustMarshalAttributes([]netlink.Attribute{
+		{
+			Type: cipvs.CmdAttrService,
+			Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: cipvs.SvcAttrAf, Data: []byte{0x0A, 0x00}},
+				{Type: cipvs.SvcAttrSchedName, Data: []byte{'r', 'r', 0x00}},
+				{Type: cipvs.SvcAttrFlags, Data: []byte{0x01, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}},
+				{Type: cipvs.SvcAttrTimeout, Data: []byte{0x00, 0x00, 0x00, 0x00}},
+				{Type: cipvs.SvcAttrNetmask, Data: []byte{0x40, 0x00, 0x00, 0x00}},
+				{Type: cipvs.SvcAttrProtocol, Data: []byte{0x06, 0x00}},
+				{Type: cipvs.SvcAttrAddr, Data: []byte{0x20, 0x01, 0x0D, 0xB8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}},
+				{Type: cipvs.SvcAttrPort, Data: []byte{0x01, 0xBB}},
+			}),
+		},
+	})
+
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		assert.DeepEqual(t, gerq.Data, expected)
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdNewService, netlink.Request|netlink.Acknowledge, fn))
+	defer client.Close()
+
+	assert.NilError(t, client.CreateService(context.Background(), svc))
+
+	var out ServiceExtended
+	ad, err := netlink.NewAttributeDecoder(expected)
+	assert.NilError(t, err)
+	for ad.Next() {
+		ad.Do(unpackService(&out))
+	}
+	assert.NilError(t, ad.Err())
+	assert.DeepEqual(t, out.Service, svc, cmp.Comparer(NetipAddrCompare))
+	assert.Equal(t, out.Key().String(), "TCP [2001:db8::1]:443")
+}
+
+func TestPackAddr(t *testing.T) {
+	type testCase struct {
+		name     string
+		family   AddressFamily
+		addr     netip.Addr
+		expected []byte
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.DeepEqual(t, packAddr(tc.family, tc.addr), tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "ipv4",
+			family:   INET,
+			addr:     netip.MustParseAddr("192.0.2.1"),
+			expected: []byte{192, 0, 2, 1},
+		},
+		{
+			name:     "ipv4-mapped in INET",
+			family:   INET,
+			addr:     netip.MustParseAddr("::ffff:192.0.2.1"),
+			expected: []byte{192, 0, 2, 1},
+		},
+		{
+			name:     "ipv4 in INET6",
+			family:   INET6,
+			addr:     netip.MustParseAddr("192.0.2.1"),
+			expected: []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xFF, 0xFF, 192, 0, 2, 1},
+		},
+		{
+			name:     "ipv6",
+			family:   INET6,
+			addr:     netip.MustParseAddr("2001:db8::1"),
+			expected: []byte{0x20, 0x01, 0x0D, 0xB8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+		},
+		{
+			name:   "unset",
+			family: INET,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestService_PersistenceEngine(t *testing.T) {
+	svc := Service{
+		Address:   netip.MustParseAddr("192.0.2.1"),
+		Scheduler: "rr",
+		PEName:    "sip",
+		Flags:     ServicePersistent,
+		Port:      5060,
+		Family:    INET,
+		Protocol:  UDP,
+	}
+
+	expected := nltest.MustMarshalAttributes([]netlink.Attribute{
+		{
+			Type: cipvs.CmdAttrService,
+			Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: cipvs.SvcAttrAf, Data: []byte{0x02, 0x00}},
+				{Type: cipvs.SvcAttrSchedName, Data: []byte{'r', 'r', 0x00}},
+				{Type: cipvs.SvcAttrPeName, Data: []byte{'s', 'i', 'p', 0x00}},
+				{Type: cipvs.SvcAttrFlags, Data: []byte{0x01, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}},
+				{Type: cipvs.SvcAttrTimeout, Data: []byte{0x00, 0x00, 0x00, 0x00}},
+				{Type: cipvs.SvcAttrNetmask, Data: []byte{0xFF, 0xFF, 0xFF, 0xFF}},
+				{Type: cipvs.SvcAttrProtocol, Data: []byte{0x11, 0x00}},
+				{Type: cipvs.SvcAttrAddr, Data: []byte{0xC0, 0x00, 0x02, 0x01}},
+				{Type: cipvs.SvcAttrPort, Data: []byte{0x13, 0xC4}},
+			}),
+		},
+	})
+
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		assert.DeepEqual(t, gerq.Data, expected)
+		return nil, genltest.Error(int(syscall.ENOENT))
+	}
+	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdNewService, netlink.Request|netlink.Acknowledge, fn))
+	defer client.Close()
+
+	err := client.CreateService(context.Background(), svc)
+
+	var peErr *PersistenceEngineError
+	assert.Assert(t, errors.As(err, &peErr))
+	assert.Equal(t, peErr.Name, "sip")
+	assert.Assert(t, errors.Is(err, ErrSchedulerNotAvailable))
+	assert.Assert(t, errors.Is(err, syscall.ENOENT))
+}
+
+func TestService_SchedulerNotAvailable(t *testing.T) {
+	fn := func(_ genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		return nil, genltest.Error(int(syscall.ENOENT))
+	}
+	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdNewService, netlink.Request|netlink.Acknowledge, fn))
+	defer client.Close()
+
+	err := client.CreateService(context.Background(), Service{
+		Address:   netip.MustParseAddr("192.0.2.1"),
+		Port:      80,
+		Family:    INET,
+		Protocol:  TCP,
+		Scheduler: MaglevHashing,
+	})
+	assert.ErrorContains(t, err, `scheduler "mh" not available on this kernel`)
+	assert.Assert(t, errors.Is(err, ErrSchedulerNotAvailable))
+}
+
+// recordingInstrumentation records the operations reported to it.
+type recordingInstrumentation struct {
+	started []string
+	results []OpResult
+}
+
+func (r *recordingInstrumentation) StartOp(ctx context.Context, op string) (context.Context, func(OpResult)) {
+	r.started = append(r.started, op)
+	return ctx, func(res OpResult) {
+		r.results = append(r.results, res)
+	}
+}
+
+func TestClient_Instrumentation(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		if gerq.Header.Command == cipvs.CmdDelService {
+			return nil, genltest.Error(int(syscall.ESRCH))
+		}
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	r := &recordingInstrumentation{}
+	client.instrumentation = r
+
+	assert.NilError(t, client.Flush(context.Background()))
+
+	err := client.RemoveService(context.Background(), Service{FWMark: 42, Family: INET})
+	assert.Assert(t, errors.Is(err, ErrServiceNotFound))
+
+	assert.DeepEqual(t, r.started, []string{"flush", "del_service"})
+	assert.Equal(t, len(r.results), 2)
+	assert.Equal(t, r.results[0].Op, "flush")
+	assert.NilError(t, r.results[0].Err)
+	assert.Equal(t, r.results[0].Errno, syscall.Errno(0))
+	assert.Equal(t, r.results[1].Op, "del_service")
+	assert.Assert(t, errors.Is(r.results[1].Err, ErrServiceNotFound))
+	assert.Equal(t, r.results[1].Errno, syscall.ESRCH)
+}
+
+func TestClient_Audit(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		switch gerq.Header.Command {
+		case cipvs.CmdGetService, cipvs.CmdDelService:
+			return nil, genltest.Error(int(syscall.ESRCH))
+		}
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	var records []AuditRecord
+	client.audit = AuditFunc(func(_ context.Context, r AuditRecord) {
+		records = append(records, r)
+	})
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+
+	ctx := WithAuditReason(context.Background(), "rollout")
+	assert.NilError(t, client.CreateService(ctx, svc))
+	assert.Assert(t, errors.Is(client.RemoveService(ctx, svc), ErrServiceNotFound))
+	assert.NilError(t, client.Flush(context.Background()))
+
+	assert.Equal(t, len(records), 2)
+	assert.Equal(t, records[0].Op, "create_service")
+	assert.Equal(t, records[0].Reason, "rollout")
+	assert.Equal(t, *records[0].Service, svc.Key())
+	assert.Equal(t, records[0].Request, any(svc))
+	assert.Equal(t, records[0].Result, nil)
+	assert.Equal(t, records[1].Op, "flush")
+	assert.Equal(t, records[1].Reason, "")
+}
+
+func TestClient_Metrics(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		switch gerq.Header.Command {
+		case cipvs.CmdGetService:
+			return nil, io.EOF
+		case cipvs.CmdDelService:
+			return nil, genltest.Error(int(syscall.ESRCH))
+		}
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	assert.NilError(t, client.Flush(context.Background()))
+	_, err := client.Services(context.Background())
+	assert.Assert(t, os.IsNotExist(err))
+	err = client.RemoveService(context.Background(), Service{FWMark: 42, Family: INET})
+	assert.Assert(t, errors.Is(err, ErrServiceNotFound))
+
+	m := client.Metrics()
+	assert.DeepEqual(t, m.Requests, map[string]uint64{"flush": 1, "get_service": 1, "del_service": 1})
+	assert.DeepEqual(t, m.Errors, map[syscall.Errno]uint64{syscall.ESRCH: 1})
+	assert.DeepEqual(t, m.DumpMessages, map[string]uint64{"get_service": 0})
+	assert.Equal(t, len(m.Durations), 3)
+}
+
+func TestClient_Retry(t *testing.T) {
+	type testCase struct {
+		name     string
+		policy   RetryPolicy
+		errs     []error
+		call     func(c *client) error
+		attempts int
+		check    func(t *testing.T, err error)
+	}
+
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Microsecond}
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	getService := func(c *client) error {
+		_, err := c.Service(context.Background(), svc)
+		return err
+	}
+	createService := func(c *client) error {
+		return c.CreateService(context.Background(), svc)
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var attempts int
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			attempts++
+			if attempts <= len(tc.errs) {
+				return nil, tc.errs[attempts-1]
+			}
+			return []genetlink.Message{{}}, nil
+		}
+		client := testClient(t, fn)
+		defer client.Close()
+		client.retry = tc.policy
+
+		err := tc.call(client)
+		assert.Equal(t, attempts, tc.attempts)
+		assert.Equal(t, client.Metrics().Retries, uint64(tc.attempts-1))
+		tc.check(t, err)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "read succeeds",
+			policy:   policy,
+			errs:     []error{genltest.Error(int(syscall.EBUSY)), syscall.ENOBUFS},
+			call:     getService,
+			attempts: 3,
+			check:    func(t *testing.T, err error) { assert.NilError(t, err) },
+		},
+		{
+			name:     "read exhausted",
+			policy:   policy,
+			errs:     []error{syscall.EINTR, syscall.EINTR, syscall.EINTR},
+			call:     getService,
+			attempts: 3,
+			check: func(t *testing.T, err error) {
+				var retryErr *RetryError
+				assert.Assert(t, errors.As(err, &retryErr))
+				assert.Equal(t, retryErr.Op, "get_service")
+				assert.Equal(t, retryErr.Attempts, 3)
+				assert.Assert(t, errors.Is(err, syscall.EINTR))
+			},
+		},
+		{
+			name:     "change rejected",
+			policy:   policy,
+			errs:     []error{genltest.Error(int(syscall.EBUSY))},
+			call:     createService,
+			attempts: 2,
+			check:    func(t *testing.T, err error) { assert.NilError(t, err) },
+		},
+		{
+			name:     "change acknowledgement lost",
+			policy:   policy,
+			errs:     []error{syscall.ENOBUFS},
+			call:     createService,
+			attempts: 1,
+			check:    func(t *testing.T, err error) { assert.Assert(t, errors.Is(err, syscall.ENOBUFS)) },
+		},
+		{
+			name:     "not transient",
+			policy:   policy,
+			errs:     []error{genltest.Error(int(syscall.EEXIST))},
+			call:     createService,
+			attempts: 1,
+			check:    func(t *testing.T, err error) { assert.Assert(t, errors.Is(err, ErrServiceExists)) },
+		},
+		{
+			name:     "no policy",
+			errs:     []error{genltest.Error(int(syscall.EBUSY))},
+			call:     getService,
+			attempts: 1,
+			check: func(t *testing.T, err error) {
+				var retryErr *RetryError
+				assert.Assert(t, !errors.As(err, &retryErr))
+				assert.Assert(t, errors.Is(err, syscall.EBUSY))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestClient_DumpInterrupted(t *testing.T) {
+	var attempts int
+	client := nltestClient(t, func(reqs []netlink.Message) ([]netlink.Message, error) {
+		attempts++
+
+		reply := netlink.Message{Header: reqs[0].Header}
+		reply.Header.Flags = 0
+		if attempts <= 2 {
+			reply.Header.Flags = netlink.DumpInterrupted
+		}
+		reply.Data, _ = (&genetlink.Message{Header: genetlink.Header{Command: cipvs.CmdNewService}}).MarshalBinary()
+
+		done := netlink.Message{Header: reqs[0].Header}
+		return nltest.Multipart([]netlink.Message{reply, done})
+	})
+	defer client.Close()
+
+	_, err := client.Services(context.Background())
+	assert.Assert(t, errors.Is(err, ErrDumpInterrupted), "got error: %v", err)
+	assert.Equal(t, attempts, 1)
+
+	client.retry = RetryPolicy{Attempts: 2}
+	svcs, err := client.Services(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(svcs), 1)
+	assert.Equal(t, attempts, 3)
+}
+
+func TestService_FWMarkMissingFamily(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		t.Fatal("unexpected request")
+		return nil, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	err := client.CreateService(context.Background(), Service{Scheduler: "rr", FWMark: 42})
+	assert.ErrorContains(t, err, "firewall mark service requires")
+}
+
+func TestService_UnpackMissingAddress(t *testing.T) {
+	b := nltest.MustMarshalAttributes([]netlink.Attribute{
+		{Type: cipvs.SvcAttrAf, Data: []byte{0x02, 0x00}},
+		{Type: cipvs.SvcAttrProtocol, Data: []byte{0x06, 0x00}},
+		{Type: cipvs.SvcAttrFlags, Data: []byte{0x00, 0x00, 0x00, 0x00, 0xFF, 0xFF, 0xFF, 0xFF}},
+	})
+
+	var svc ServiceExtended
+	assert.NilError(t, unpackService(&svc)(b))
+	assert.Equal(t, svc.Address, netip.Addr{})
+	assert.Equal(t, svc.Family, INET)
+}
+
+func TestFlush(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		assert.Equal(t, len(gerq.Data), 0)
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdFlush, netlink.Request|netlink.Acknowledge, fn))
+	defer client.Close()
+
+	assert.NilError(t, client.Flush(context.Background()))
+}
+
 func TestDestinations_Pack(t *testing.T) {
 	type testCase struct {
 		name        string
@@ -350,7 +1103,7 @@ func TestDestinations_Pack(t *testing.T) {
 		client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdNewDest, netlink.Request|netlink.Acknowledge, fn))
 		defer client.Close()
 
-		err := client.CreateDestination(Service{
+		err := client.CreateDestination(context.Background(), Service{
 			Address:   netip.MustParseAddr("127.0.1.1"),
 			Netmask:   netmask.MaskFrom(31, 32),
 			Scheduler: "wlc",
@@ -473,10 +1226,10 @@ func TestDestinations_Pack(t *testing.T) {
 			},
 		},
 		{
-			name: "direct IPv6 destination",
+			name: "tunnel IPv6 destination",
 			destination: Destination{
 				Address:   netip.MustParseAddr("2004:db8::3"),
-				FwdMethod: DirectRoute,
+				FwdMethod: Tunnel,
 				Weight:    1,
 				Port:      80,
 				Family:    INET6,
@@ -508,7 +1261,7 @@ func TestDestinations_Pack(t *testing.T) {
 							},
 							{
 								Type: cipvs.DestAttrFwdMethod,
-								Data: []byte{0x03, 0x00, 0x00, 0x00},
+								Data: []byte{0x02, 0x00, 0x00, 0x00},
 							},
 							{
 								Type: cipvs.DestAttrWeight,
@@ -619,6 +1372,145 @@ func TestDestinations_Pack(t *testing.T) {
 	}
 }
 
+func TestDestinations_PackUnpack(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		dest := rapid.Custom[Destination](func(t *rapid.T) Destination {
+			family := rapid.SampledFrom([]AddressFamily{INET, INET6}).Draw(t, "Family")
+			var addr netip.Addr
+
+			switch family {
+			case INET:
+				addr, _ = netip.AddrFromSlice(rapid.SliceOfN(rapid.Byte(), net.IPv4len, net.IPv4len).Draw(t, "Address"))
+			case INET6:
+				addr, _ = netip.AddrFromSlice(rapid.SliceOfN(rapid.Byte(), net.IPv6len, net.IPv6len).Draw(t, "Address"))
+			}
+
+			return Destination{
+				Address:        addr,
+				FwdMethod:      rapid.SampledFrom([]ForwardType{Masquerade, Local, Tunnel, DirectRoute, Bypass}).Draw(t, "FwdMethod"),
+				Weight:         rapid.Uint32().Draw(t, "Weight"),
+				UpperThreshold: rapid.Uint32().Draw(t, "UpperThreshold"),
+				LowerThreshold: rapid.Uint32().Draw(t, "LowerThreshold"),
+				Port:           rapid.Uint16().Draw(t, "Port"),
+				Family:         family,
+				TunnelType:     rapid.SampledFrom([]TunnelType{IPIP, GUE, GRE}).Draw(t, "TunnelType"),
+				TunnelPort:     rapid.Uint16().Draw(t, "TunnelPort"),
+				TunnelFlags:    TunnelFlags(rapid.Uint16().Draw(t, "TunnelFlags")),
+			}
+		}).Draw(t, "dest")
+
+		ae := netlink.NewAttributeEncoder()
+		ae.Do(cipvs.CmdAttrDest, packDest(dest))
+		p, err := ae.Encode()
+		assert.NilError(t, err)
+
+		ad, err := netlink.NewAttributeDecoder(p)
+		assert.NilError(t, err)
+
+		var out DestinationExtended
+		for ad.Next() {
+			if ad.Type() == cipvs.CmdAttrDest {
+				ad.Do(unpackDestination(&out))
+			}
+		}
+
+		assert.NilError(t, ad.Err())
+		assert.DeepEqual(t, out.Destination, dest, cmp.Comparer(NetipAddrCompare))
+	})
+}
+
+func TestDestinations_Commands(t *testing.T) {
+	type testCase struct {
+		name    string
+		command uint8
+		flags   netlink.HeaderFlags
+		call    func(c *client, ctx context.Context, svc Service, dest Destination) error
+	}
+
+	svc := Service{Address: netip.MustParseAddr("127.0.1.1"), Port: 80, Family: INET, Protocol: TCP}
+	dest := Destination{Address: netip.MustParseAddr("127.0.2.1"), Port: 8080, Family: INET, Weight: 1}
+
+	run := func(t *testing.T, tc testCase) {
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			return []genetlink.Message{{}}, nil
+		}
+
+		client := testClient(t, genltest.CheckRequest(familyID, tc.command, tc.flags, fn))
+		defer client.Close()
+
+		assert.NilError(t, tc.call(client, context.Background(), svc, dest))
+	}
+
+	testCases := []testCase{
+		{
+			name:    "create",
+			command: cipvs.CmdNewDest,
+			flags:   netlink.Request | netlink.Acknowledge,
+			call:    (*client).CreateDestination,
+		},
+		{
+			name:    "update",
+			command: cipvs.CmdSetDest,
+			flags:   netlink.Request | netlink.Acknowledge,
+			call:    (*client).UpdateDestination,
+		},
+		{
+			name:    "remove",
+			command: cipvs.CmdDelDest,
+			flags:   netlink.Request | netlink.Acknowledge,
+			call: func(c *client, ctx context.Context, svc Service, dest Destination) error {
+				return c.RemoveDestination(ctx, svc, dest)
+			},
+		},
+		{
+			name:    "list",
+			command: cipvs.CmdGetDest,
+			flags:   netlink.Request | netlink.Dump,
+			call: func(c *client, ctx context.Context, svc Service, _ Destination) error {
+				_, err := c.Destinations(ctx, svc)
+				return err
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestDestinations_MixedFamily(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		t.Fatal("unexpected request")
+		return nil, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	svc := Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6, Protocol: TCP}
+	dest := Destination{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, FwdMethod: Masquerade}
+
+	err := client.CreateDestination(context.Background(), svc, dest)
+	assert.ErrorContains(t, err, "requires the Tunnel forwarding method")
+
+	err = client.UpdateDestination(context.Background(), svc, dest)
+	assert.ErrorContains(t, err, "requires the Tunnel forwarding method")
+}
+
+func TestDestinations_UnpackMissingAddress(t *testing.T) {
+	b := nltest.MustMarshalAttributes([]netlink.Attribute{
+		{Type: cipvs.DestAttrAddrFamily, Data: []byte{0x02, 0x00}},
+		{Type: cipvs.DestAttrWeight, Data: []byte{0x01, 0x00, 0x00, 0x00}},
+	})
+
+	var dest DestinationExtended
+	assert.NilError(t, unpackDestination(&dest)(b))
+	assert.Equal(t, dest.Address, netip.Addr{})
+	assert.Equal(t, dest.Weight, uint32(1))
+}
+
 func TestDestinations_Unpack(t *testing.T) {
 	type testCase struct {
 		name     string
@@ -634,7 +1526,7 @@ func TestDestinations_Unpack(t *testing.T) {
 		client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdGetDest, netlink.Request|netlink.Dump, fn))
 		defer client.Close()
 
-		result, err := client.Destinations(Service{
+		result, err := client.Destinations(context.Background(), Service{
 			Family: INET,
 		})
 		assert.NilError(t, err)
@@ -823,7 +1715,7 @@ func TestConfig(t *testing.T) {
 	client := testClient(t, fn)
 	defer client.Close()
 
-	actualConfig, err := client.Config()
+	actualConfig, err := client.Config(context.Background())
 	assert.NilError(t, err)
 	assert.DeepEqual(t, actualConfig, Config{
 		TCPTimeout:    70,
@@ -860,13 +1752,182 @@ func TestSetConfig(t *testing.T) {
 	client := testClient(t, fn)
 	defer client.Close()
 
-	assert.NilError(t, client.SetConfig(Config{
+	assert.NilError(t, client.SetConfig(context.Background(), Config{
 		TCPTimeout:    900,
 		TCPFinTimeout: 901,
 		UDPTimeout:    902,
 	}))
 }
 
+func TestCommandErrors(t *testing.T) {
+	type testCase struct {
+		name     string
+		command  uint8
+		errno    syscall.Errno
+		call     func(c *client) error
+		expected error
+	}
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 80, Family: INET}
+	daemon := Daemon{State: DaemonMaster, MulticastInterface: "eth0", SyncID: 1}
+
+	run := func(t *testing.T, tc testCase) {
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			assert.Equal(t, gerq.Header.Command, tc.command)
+			return nil, genltest.Error(int(tc.errno))
+		}
+		client := testClient(t, fn)
+		defer client.Close()
+
+		err := tc.call(client)
+		assert.Assert(t, errors.Is(err, tc.expected), "got error: %v", err)
+		assert.Assert(t, errors.Is(err, tc.errno), "got error: %v", err)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "service not found",
+			command:  cipvs.CmdSetService,
+			errno:    syscall.ESRCH,
+			call:     func(c *client) error { return c.UpdateService(context.Background(), svc) },
+			expected: ErrServiceNotFound,
+		},
+		{
+			name:     "service exists",
+			command:  cipvs.CmdNewService,
+			errno:    syscall.EEXIST,
+			call:     func(c *client) error { return c.CreateService(context.Background(), svc) },
+			expected: ErrServiceExists,
+		},
+		{
+			name:     "scheduler not available",
+			command:  cipvs.CmdNewService,
+			errno:    syscall.ENOENT,
+			call:     func(c *client) error { return c.CreateService(context.Background(), svc) },
+			expected: ErrSchedulerNotAvailable,
+		},
+		{
+			name:     "destination exists",
+			command:  cipvs.CmdNewDest,
+			errno:    syscall.EEXIST,
+			call:     func(c *client) error { return c.CreateDestination(context.Background(), svc, dest) },
+			expected: ErrDestinationExists,
+		},
+		{
+			name:     "destination not found",
+			command:  cipvs.CmdDelDest,
+			errno:    syscall.ENOENT,
+			call:     func(c *client) error { return c.RemoveDestination(context.Background(), svc, dest) },
+			expected: ErrDestinationNotFound,
+		},
+		{
+			name:     "destination of missing service",
+			command:  cipvs.CmdNewDest,
+			errno:    syscall.ESRCH,
+			call:     func(c *client) error { return c.CreateDestination(context.Background(), svc, dest) },
+			expected: ErrServiceNotFound,
+		},
+		{
+			name:     "daemon running",
+			command:  cipvs.CmdNewDaemon,
+			errno:    syscall.EEXIST,
+			call:     func(c *client) error { return c.StartDaemon(context.Background(), daemon) },
+			expected: ErrDaemonRunning,
+		},
+		{
+			name:     "daemon not running",
+			command:  cipvs.CmdDelDaemon,
+			errno:    syscall.ESRCH,
+			call:     func(c *client) error { return c.StopDaemon(context.Background(), DaemonBackup) },
+			expected: ErrDaemonNotRunning,
+		},
+		{
+			name:    "not permitted",
+			command: cipvs.CmdGetInfo,
+			errno:   syscall.EPERM,
+			call: func(c *client) error {
+				_, err := c.Info(context.Background())
+				return err
+			},
+			expected: ErrNotPermitted,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestClient_Canceled(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		t.Fatal("unexpected request")
+		return nil, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.Services(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClient_Concurrent(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		return []genetlink.Message{
+			{
+				Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+					{Type: cipvs.InfoAttrVersion, Data: []byte{0x01, 0x02, 0x01, 0x00}},
+					{Type: cipvs.InfoAttrConnTabSize, Data: []byte{0x00, 0x10, 0x00, 0x00}},
+				}),
+			},
+		}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	const n = 16
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			info, err := client.Info(ctx)
+			if err == nil && info.ConnectionTableSize != 4096 {
+				err = fmt.Errorf("unexpected info: %v", info)
+			}
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		assert.NilError(t, <-errs)
+	}
+}
+
+func TestNewClient_NetNSPathNotExist(t *testing.T) {
+	_, err := NewClient(WithNetNSPath("/var/run/netns/does-not-exist"))
+	assert.Assert(t, errors.Is(err, os.ErrNotExist), "got error: %v", err)
+}
+
+func TestClient_WithNetNSNotExist(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		t.Fatal("unexpected request")
+		return nil, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	_, err := client.WithNetNS(NetNS{Path: "/var/run/netns/does-not-exist"})
+	assert.Assert(t, errors.Is(err, os.ErrNotExist), "got error: %v", err)
+}
+
 func testClient(t *testing.T, fn genltest.Func) *client {
 	t.Helper()
 
@@ -877,7 +1938,7 @@ func testClient(t *testing.T, fn genltest.Func) *client {
 	}
 
 	conn := genltest.Dial(genltest.ServeFamily(family, fn))
-	client, err := initClient(conn)
+	client, err := initClient(conn, nil)
 	if err != nil {
 		t.Fatalf("failed to open client: %v", err)
 	}
@@ -885,6 +1946,18 @@ func testClient(t *testing.T, fn genltest.Func) *client {
 	return client
 }
 
+// nltestClient returns a client for the IPVS family, serving requests with
+// fn, for tests which need control over the netlink headers of replies.
+func nltestClient(t *testing.T, fn nltest.Func) *client {
+	t.Helper()
+
+	return &client{
+		c:      genetlink.NewConn(nltest.Dial(fn)),
+		family: genetlink.Family{ID: familyID, Version: cipvs.GenlVersion, Name: cipvs.GenlName},
+		sem:    make(chan struct{}, 1),
+	}
+}
+
 func NetipAddrCompare(x, y netip.Addr) bool {
 	return x == y
 }
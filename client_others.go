@@ -4,6 +4,7 @@
 package ipvs
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 )
@@ -15,54 +16,130 @@ var (
 
 type client struct{}
 
-func newClient() (*client, error) {
+func newClient(options) (*client, error) {
 	return nil, errUnimplemented
 }
 
-func (c *client) Info() (Info, error) {
+func newClientConn(Conn, options) (*client, error) {
+	return nil, errUnimplemented
+}
+
+func (c *client) Info(context.Context) (Info, error) {
 	return Info{}, errUnimplemented
 }
 
-func (c *client) Config() (Config, error) {
+func (c *client) Config(context.Context) (Config, error) {
 	return Config{}, errUnimplemented
 }
 
-func (c *client) SetConfig(config Config) error {
+func (c *client) SetConfig(context.Context, Config) error {
 	return errUnimplemented
 }
 
-func (c *client) Services() ([]ServiceExtended, error) {
+func (c *client) Services(context.Context, ...ListOption) ([]ServiceExtended, error) {
 	return nil, errUnimplemented
 }
 
-func (c *client) Service(Service) (ServiceExtended, error) {
+func (c *client) Service(context.Context, Service) (ServiceExtended, error) {
 	return ServiceExtended{}, errUnimplemented
 }
 
-func (c *client) CreateService(Service) error {
+func (c *client) CreateService(context.Context, Service) error {
+	return errUnimplemented
+}
+
+func (c *client) UpdateService(context.Context, Service) error {
+	return errUnimplemented
+}
+
+func (c *client) RemoveService(context.Context, Service) error {
+	return errUnimplemented
+}
+
+func (c *client) Flush(context.Context) error {
+	return errUnimplemented
+}
+
+func (c *client) ServicesFunc(context.Context, func(ServiceExtended) error, ...ListOption) error {
+	return errUnimplemented
+}
+
+func (c *client) DestinationsFunc(context.Context, Service, func(DestinationExtended) error) error {
+	return errUnimplemented
+}
+
+func (c *client) Batch(context.Context, func(*Batch)) error {
+	return errUnimplemented
+}
+
+func (c *client) Destinations(context.Context, Service) ([]DestinationExtended, error) {
+	return nil, errUnimplemented
+}
+
+func (c *client) CreateDestination(context.Context, Service, Destination) error {
 	return errUnimplemented
 }
 
-func (c *client) UpdateService(Service) error {
+func (c *client) UpdateDestination(context.Context, Service, Destination) error {
 	return errUnimplemented
 }
 
-func (c *client) RemoveService(Service) error {
+func (c *client) RemoveDestination(context.Context, Service, Destination, ...RemoveOption) error {
 	return errUnimplemented
 }
 
-func (c *client) Destinations(Service) ([]DestinationExtended, error) {
+func (c *client) GlobalStats(context.Context) (Stats, error) {
+	return Stats{}, errUnimplemented
+}
+
+func (c *client) PerCPUStats(context.Context) (PerCPUStats, error) {
+	return PerCPUStats{}, errUnimplemented
+}
+
+func (c *client) Connections(context.Context, ...ConnectionOption) ([]Connection, error) {
 	return nil, errUnimplemented
 }
 
-func (c *client) CreateDestination(Service, Destination) error {
+func (c *client) ConnectionSummary(context.Context, Service) (ConnectionSummary, error) {
+	return nil, errUnimplemented
+}
+
+func (c *client) StartDaemon(context.Context, Daemon) error {
+	return errUnimplemented
+}
+
+func (c *client) StopDaemon(context.Context, DaemonState) error {
 	return errUnimplemented
 }
 
-func (c *client) UpdateDestination(Service, Destination) error {
+func (c *client) Daemons(context.Context) ([]Daemon, error) {
+	return nil, errUnimplemented
+}
+
+func (c *client) SyncConfig(context.Context) (SyncConfig, error) {
+	return SyncConfig{}, errUnimplemented
+}
+
+func (c *client) SetSyncConfig(context.Context, SyncConfig) error {
 	return errUnimplemented
 }
 
-func (c *client) RemoveDestination(Service, Destination) error {
+func (c *client) AvailableSchedulers(context.Context) ([]Scheduler, error) {
+	return nil, errUnimplemented
+}
+
+func (c *client) Capabilities(context.Context) (Capabilities, error) {
+	return Capabilities{}, errUnimplemented
+}
+
+func (c *client) Metrics() Metrics {
+	return Metrics{}
+}
+
+func (c *client) WithNetNS(NetNS) (Client, error) {
+	return nil, errUnimplemented
+}
+
+func (c *client) Close() error {
 	return errUnimplemented
 }
@@ -0,0 +1,82 @@
+package ipvs
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDestinationExtended_Overloaded(t *testing.T) {
+	type testCase struct {
+		name     string
+		dest     DestinationExtended
+		expected bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.dest.Overloaded(), tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name: "no threshold",
+			dest: DestinationExtended{ActiveConnections: 1000},
+		},
+		{
+			name: "below threshold",
+			dest: DestinationExtended{Destination: Destination{UpperThreshold: 100}, ActiveConnections: 60, InactiveConnections: 39},
+		},
+		{
+			name:     "at threshold",
+			dest:     DestinationExtended{Destination: Destination{UpperThreshold: 100}, ActiveConnections: 60, InactiveConnections: 40},
+			expected: true,
+		},
+		{
+			name:     "above threshold",
+			dest:     DestinationExtended{Destination: Destination{UpperThreshold: 100}, ActiveConnections: 150},
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestInfo_AtLeast(t *testing.T) {
+	type testCase struct {
+		name     string
+		version  [3]int
+		expected bool
+	}
+
+	info := Info{Version: [3]int{1, 2, 1}}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, info.AtLeast(tc.version[0], tc.version[1], tc.version[2]), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "equal", version: [3]int{1, 2, 1}, expected: true},
+		{name: "older patch", version: [3]int{1, 2, 0}, expected: true},
+		{name: "older major", version: [3]int{0, 9, 9}, expected: true},
+		{name: "newer patch", version: [3]int{1, 2, 2}},
+		{name: "newer minor", version: [3]int{1, 3, 0}},
+		{name: "newer major", version: [3]int{2, 0, 0}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestInfo_String(t *testing.T) {
+	info := Info{Version: [3]int{1, 2, 1}, ConnectionTableSize: 4096}
+	assert.Equal(t, info.String(), "IP Virtual Server version 1.2.1 (size=4096)")
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/ipvs"
+	"github.com/cloudflare/ipvs/netmask"
+)
+
+// command is the command line of a command, with flags interleaved with
+// its arguments.
+type command struct {
+	fs   *flag.FlagSet
+	ipv6 bool
+	// names lists the names of each flag, in the order registered.
+	names [][]string
+}
+
+// newCommand returns the command named name, taking the arguments args,
+// such as "SERVICE DEST", in its usage.
+func (e *env) newCommand(name, args string) *command {
+	cmd := &command{fs: flag.NewFlagSet(name, flag.ContinueOnError)}
+	cmd.fs.SetOutput(e.stderr)
+	cmd.fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: ipvsctl %s %s [FLAGS]\n", name, args)
+		if len(cmd.names) > 0 {
+			fmt.Fprintln(e.stderr, "\nFlags:")
+		}
+		for _, names := range cmd.names {
+			f := cmd.fs.Lookup(names[0])
+			arg, usage := flag.UnquoteUsage(f)
+			if arg != "" {
+				arg = " " + arg
+				if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+					arg = "[=" + arg[1:] + "]"
+				}
+			}
+
+			dashed := make([]string, 0, len(names))
+			for _, name := range names {
+				if len(name) > 1 {
+					dashed = append(dashed, "--"+name)
+				} else {
+					dashed = append(dashed, "-"+name)
+				}
+			}
+			fmt.Fprintf(e.stderr, "  %s%s\n    \t%s\n", strings.Join(dashed, ", "), arg, usage)
+		}
+	}
+
+	return cmd
+}
+
+// register records the names of a flag, for the usage.
+func (cmd *command) register(names string) []string {
+	ns := strings.Split(names, ",")
+	cmd.names = append(cmd.names, ns)
+	return ns
+}
+
+// serviceFlag registers the --ipv6 flag of the SERVICE argument.
+func (cmd *command) serviceFlag() {
+	cmd.boolVar(&cmd.ipv6, "6,ipv6", "the firewall mark service is IPv6")
+}
+
+// parse parses the flags of cmd, which may be interleaved with the
+// arguments of args, like "tcp 192.0.2.1:80 -s mh". It returns the
+// arguments, checking that there are between min and max of them.
+func (cmd *command) parse(e *env, args []string, min, max int) ([]string, error) {
+	var pos []string
+	for {
+		if err := cmd.fs.Parse(args); err != nil {
+			return nil, e.usageError(err)
+		}
+
+		args = cmd.fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+
+	if len(pos) < min || len(pos) > max {
+		fmt.Fprintf(e.stderr, "ipvsctl %s: wrong number of arguments\n", cmd.fs.Name())
+		cmd.fs.Usage()
+		return nil, errUsage
+	}
+
+	return pos, nil
+}
+
+// boolVar registers the boolean flag p under the comma-separated names.
+func (cmd *command) boolVar(p *bool, names, usage string) {
+	for _, name := range cmd.register(names) {
+		cmd.fs.BoolVar(p, name, false, usage)
+	}
+}
+
+// edit registers the flag named by the comma-separated names which edits
+// a T, such as an ipvs.Service, with the function set returns for its
+// value. The edits are recorded in order, to apply once the T is known.
+func edit[T any](cmd *command, edits *[]func(*T), names, usage string, isBool bool, set func(string) (func(*T), error)) {
+	v := &editValue[T]{edits: edits, isBool: isBool, set: set}
+	for _, name := range cmd.register(names) {
+		cmd.fs.Var(v, name, usage)
+	}
+}
+
+// editValue is a flag.Value recording edits of a T.
+type editValue[T any] struct {
+	edits  *[]func(*T)
+	isBool bool
+	set    func(string) (func(*T), error)
+}
+
+func (v *editValue[T]) String() string   { return "" }
+func (v *editValue[T]) IsBoolFlag() bool { return v.isBool }
+
+func (v *editValue[T]) Set(s string) error {
+	fn, err := v.set(s)
+	if err != nil {
+		return err
+	}
+
+	*v.edits = append(*v.edits, fn)
+	return nil
+}
+
+var (
+	protocols = map[string]ipvs.Protocol{
+		"tcp":  ipvs.TCP,
+		"udp":  ipvs.UDP,
+		"sctp": ipvs.SCTP,
+	}
+	families = map[string]ipvs.AddressFamily{
+		"inet":  ipvs.INET,
+		"inet6": ipvs.INET6,
+	}
+	schedulerFlags = map[string]ipvs.Flags{
+		"flag-1":      ipvs.ServiceSchedulerOpt1,
+		"flag-2":      ipvs.ServiceSchedulerOpt2,
+		"flag-3":      ipvs.ServiceSchedulerOpt3,
+		"sh-fallback": ipvs.SourceHashFallback,
+		"sh-port":     ipvs.SourceHashPort,
+		"mh-fallback": ipvs.MaglevFallback,
+		"mh-port":     ipvs.MaglevPort,
+	}
+	forwardings = map[string]ipvs.ForwardType{
+		"route":      ipvs.DirectRoute,
+		"masquerade": ipvs.Masquerade,
+		"tunnel":     ipvs.Tunnel,
+		"local":      ipvs.Local,
+	}
+	tunnelTypes = map[string]ipvs.TunnelType{
+		"ipip": ipvs.IPIP,
+		"gue":  ipvs.GUE,
+		"gre":  ipvs.GRE,
+	}
+	tunnelChecksums = map[string]ipvs.TunnelFlags{
+		"none":     ipvs.TunnelEncapNoChecksum,
+		"checksum": ipvs.TunnelEncapChecksum,
+		"remote":   ipvs.TunnelEncapRemoteChecksum,
+	}
+)
+
+// defaultPersistence is the persistence timeout of ipvsadm.
+const defaultPersistence = 360
+
+// serviceFlags registers the flags editing a service, in the form of the
+// options of ipvsadm -A.
+func (cmd *command) serviceFlags(edits *[]func(*ipvs.Service)) {
+	edit(cmd, edits, "s,scheduler", "the `name` of the scheduler, wlc by default", false, func(v string) (func(*ipvs.Service), error) {
+		return func(svc *ipvs.Service) { svc.Scheduler = ipvs.Scheduler(v) }, nil
+	})
+
+	edit(cmd, edits, "p,persistent", "makes the service persistent, with an optional `timeout` in seconds, 360 by default", true, func(v string) (func(*ipvs.Service), error) {
+		timeout, persistent := uint32(defaultPersistence), true
+		switch v {
+		case "true":
+		case "false":
+			persistent = false
+		default:
+			var err error
+			if timeout, err = parseSeconds(v); err != nil {
+				return nil, err
+			}
+		}
+
+		return func(svc *ipvs.Service) {
+			if !persistent {
+				svc.Flags &^= ipvs.ServicePersistent
+				return
+			}
+			svc.Flags |= ipvs.ServicePersistent
+			svc.Timeout = timeout
+		}, nil
+	})
+
+	edit(cmd, edits, "M,netmask", "the `netmask` grouping the clients of a persistent service", false, func(v string) (func(*ipvs.Service), error) {
+		mask, err := netmask.ParseMask(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(svc *ipvs.Service) { svc.Netmask = mask }, nil
+	})
+
+	edit(cmd, edits, "pe", "the `name` of the persistence engine, such as sip", false, func(v string) (func(*ipvs.Service), error) {
+		return func(svc *ipvs.Service) { svc.PEName = v }, nil
+	})
+
+	edit(cmd, edits, "o,ops", "schedules UDP packets individually", true, func(v string) (func(*ipvs.Service), error) {
+		ops, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(svc *ipvs.Service) {
+			svc.Flags &^= ipvs.ServiceOnePacket
+			if ops {
+				svc.Flags |= ipvs.ServiceOnePacket
+			}
+		}, nil
+	})
+
+	edit(cmd, edits, "b,sched-flags", "the comma-separated `flags` of the scheduler, such as sh-port, replacing the others", false, func(v string) (func(*ipvs.Service), error) {
+		var flags ipvs.Flags
+		if v != "" {
+			for _, name := range strings.Split(v, ",") {
+				f, ok := schedulerFlags[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown scheduler flag %q", name)
+				}
+				flags |= f
+			}
+		}
+
+		return func(svc *ipvs.Service) {
+			svc.Flags &^= ipvs.ServiceSchedulerOpt1 | ipvs.ServiceSchedulerOpt2 | ipvs.ServiceSchedulerOpt3
+			svc.Flags |= flags
+		}, nil
+	})
+}
+
+// destinationFlags registers the flags editing a destination, in the form
+// of the options of ipvsadm -a.
+func (cmd *command) destinationFlags(edits *[]func(*ipvs.Destination)) {
+	forward := func(fwd ipvs.ForwardType) func(string) (func(*ipvs.Destination), error) {
+		return func(string) (func(*ipvs.Destination), error) {
+			return func(dest *ipvs.Destination) { dest.FwdMethod = fwd }, nil
+		}
+	}
+	edit(cmd, edits, "g,gatewaying", "forwards by direct routing, the default", true, forward(ipvs.DirectRoute))
+	edit(cmd, edits, "m,masquerading", "forwards by masquerading", true, forward(ipvs.Masquerade))
+	edit(cmd, edits, "i,ipip", "forwards by tunneling", true, forward(ipvs.Tunnel))
+	edit(cmd, edits, "f,forward", "the forwarding `method`: route, masquerade, tunnel or local", false, func(v string) (func(*ipvs.Destination), error) {
+		fwd, ok := forwardings[v]
+		if !ok {
+			return nil, fmt.Errorf("unknown forwarding method %q", v)
+		}
+
+		return forward(fwd)(v)
+	})
+
+	uint32Flag := func(names, usage string, field func(*ipvs.Destination) *uint32) {
+		edit(cmd, edits, names, usage, false, func(v string) (func(*ipvs.Destination), error) {
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				return nil, errors.New("invalid value")
+			}
+
+			return func(dest *ipvs.Destination) { *field(dest) = uint32(n) }, nil
+		})
+	}
+	uint32Flag("w,weight", "the `weight`, 1 by default", func(dest *ipvs.Destination) *uint32 { return &dest.Weight })
+	uint32Flag("x,u-threshold", "the upper `threshold` of connections", func(dest *ipvs.Destination) *uint32 { return &dest.UpperThreshold })
+	uint32Flag("y,l-threshold", "the lower `threshold` of connections", func(dest *ipvs.Destination) *uint32 { return &dest.LowerThreshold })
+
+	edit(cmd, edits, "tun-type", "the `type` of tunnel: ipip, gue or gre", false, func(v string) (func(*ipvs.Destination), error) {
+		t, ok := tunnelTypes[v]
+		if !ok {
+			return nil, fmt.Errorf("unknown tunnel type %q", v)
+		}
+
+		return func(dest *ipvs.Destination) { dest.TunnelType = t }, nil
+	})
+	edit(cmd, edits, "tun-port", "the destination `port` of GUE tunnels", false, func(v string) (func(*ipvs.Destination), error) {
+		port, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return nil, errors.New("invalid value")
+		}
+
+		return func(dest *ipvs.Destination) { dest.TunnelPort = uint16(port) }, nil
+	})
+
+	checksum := func(flags ipvs.TunnelFlags) func(string) (func(*ipvs.Destination), error) {
+		return func(string) (func(*ipvs.Destination), error) {
+			return func(dest *ipvs.Destination) { dest.TunnelFlags = flags }, nil
+		}
+	}
+	edit(cmd, edits, "tun-nocsum", "disables the checksum of tunnels, the default", true, checksum(ipvs.TunnelEncapNoChecksum))
+	edit(cmd, edits, "tun-csum", "enables the checksum of tunnels", true, checksum(ipvs.TunnelEncapChecksum))
+	edit(cmd, edits, "tun-remcsum", "enables the remote checksum offload of tunnels", true, checksum(ipvs.TunnelEncapRemoteChecksum))
+}
+
+// parseSeconds parses v as a timeout in seconds, or as a duration such as
+// "5m".
+func parseSeconds(v string) (uint32, error) {
+	if n, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < time.Second || d%time.Second != 0 || d/time.Second > 1<<32-1 {
+		return 0, errors.New("invalid timeout")
+	}
+
+	return uint32(d / time.Second), nil
+}
+
+// parseService parses the SERVICE of args, returning the arguments after
+// it. The family of firewall mark services is IPv6 when ipv6 is set.
+func parseService(args []string, ipv6 bool) (ipvs.Service, []string, error) {
+	if len(args) < 2 {
+		return ipvs.Service{}, nil, errors.New("missing service")
+	}
+
+	proto, target := strings.ToLower(args[0]), args[1]
+	if proto == "fwmark" || proto == "fwm" {
+		mark, err := strconv.ParseUint(target, 0, 32)
+		if err != nil || mark == 0 {
+			return ipvs.Service{}, nil, fmt.Errorf("invalid firewall mark %q", target)
+		}
+
+		svc := ipvs.Service{FWMark: uint32(mark), Family: ipvs.INET}
+		if ipv6 {
+			svc.Family = ipvs.INET6
+		}
+		return svc, args[2:], nil
+	}
+
+	protocol, ok := protocols[proto]
+	if !ok {
+		return ipvs.Service{}, nil, fmt.Errorf("unknown protocol %q", args[0])
+	}
+
+	ap, err := netip.ParseAddrPort(target)
+	if err != nil {
+		return ipvs.Service{}, nil, fmt.Errorf("invalid service address %q", target)
+	}
+
+	svc := ipvs.Service{Address: ap.Addr().Unmap(), Port: ap.Port(), Protocol: protocol, Family: ipvs.INET}
+	if svc.Address.Is6() {
+		svc.Family = ipvs.INET6
+	}
+	return svc, args[2:], nil
+}
+
+// parseDestination parses the DEST s of svc, which is on the port of svc
+// when s has no port.
+func parseDestination(s string, svc ipvs.Service) (ipvs.Destination, error) {
+	ap, err := netip.ParseAddrPort(s)
+	if err != nil {
+		addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+		if err != nil {
+			return ipvs.Destination{}, fmt.Errorf("invalid destination address %q", s)
+		}
+		ap = netip.AddrPortFrom(addr, svc.Port)
+	}
+
+	dest := ipvs.Destination{Address: ap.Addr(), Port: ap.Port()}
+	return dest.Normalize(), nil
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cloudflare/ipvs"
+	"github.com/cloudflare/ipvs/config"
+)
+
+// configFlags are the flags of the commands taking a configuration file.
+type configFlags struct {
+	file string
+	// ownersFile keeps the owners of the services between runs.
+	ownersFile string
+	opts       ipvs.ApplyOptions
+}
+
+// register registers the flags of f, including the flags of Apply when
+// apply is set.
+func (f *configFlags) register(cmd *command, apply bool) {
+	for _, name := range cmd.register("f,file") {
+		cmd.fs.StringVar(&f.file, name, "", "the configuration `file`, or - for the standard input")
+	}
+	if !apply {
+		return
+	}
+
+	cmd.boolVar(&f.opts.Prune, "prune", "removes the services missing from the file")
+	for _, name := range cmd.register("owner") {
+		cmd.fs.StringVar(&f.opts.Owner, name, "", "only manages the services owned by `name`, see ipvs.ApplyOptions")
+	}
+	for _, name := range cmd.register("owners") {
+		cmd.fs.StringVar(&f.ownersFile, name, "", "the `file` keeping the owners of the services, updated by apply")
+	}
+	cmd.boolVar(&f.opts.PruneUnowned, "prune-unowned", "also prunes the services owned by no one")
+}
+
+// read reads and parses the configuration file of f.
+func (f *configFlags) read(e *env) (*config.File, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if f.file == "-" {
+		data, err = io.ReadAll(e.stdin)
+	} else {
+		data, err = os.ReadFile(f.file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := config.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s:\n%w", f.file, err)
+	}
+
+	return file, nil
+}
+
+// applyOptions returns the ApplyOptions of f, with the owners read from
+// its owners file.
+func (f *configFlags) applyOptions() (ipvs.ApplyOptions, error) {
+	opts := f.opts
+	if f.ownersFile != "" {
+		owners, err := ipvs.ReadOwners(f.ownersFile)
+		if err != nil {
+			return ipvs.ApplyOptions{}, err
+		}
+		opts.Owners = owners
+	}
+
+	return opts, nil
+}
+
+// parseConfigCommand parses the command line of a command taking a
+// configuration file.
+func (e *env) parseConfigCommand(cmd *command, f *configFlags, args []string) error {
+	if _, err := cmd.parse(e, args, 0, 0); err != nil {
+		return err
+	}
+
+	if f.file == "" {
+		fmt.Fprintf(e.stderr, "ipvsctl %s: missing --file\n", cmd.fs.Name())
+		cmd.fs.Usage()
+		return errUsage
+	}
+
+	return nil
+}
+
+// validate implements "ipvsctl validate -f FILE".
+func (e *env) validate(args []string) error {
+	var (
+		f      configFlags
+		kernel bool
+	)
+	cmd := e.newCommand("validate", "-f FILE")
+	f.register(cmd, false)
+	cmd.boolVar(&kernel, "kernel", "also checks that the running kernel supports the configuration")
+	if err := e.parseConfigCommand(cmd, &f, args); err != nil {
+		return err
+	}
+
+	file, err := f.read(e)
+	if err != nil {
+		return err
+	}
+
+	if kernel {
+		c, err := e.Client()
+		if err != nil {
+			return err
+		}
+
+		caps, err := c.Capabilities(e.ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := file.Table.Validate(caps); err != nil {
+			return fmt.Errorf("%s:\n%w", f.file, err)
+		}
+	}
+
+	fmt.Fprintf(e.stdout, "%s: valid, %d services\n", f.file, len(file.Table.Services))
+	return nil
+}
+
+// diff implements "ipvsctl diff -f FILE".
+func (e *env) diff(args []string) error {
+	var (
+		f        configFlags
+		exitCode bool
+	)
+	cmd := e.newCommand("diff", "-f FILE")
+	f.register(cmd, true)
+	cmd.boolVar(&exitCode, "exit-code", "exits with status 1 when there are changes")
+	cmd.outputFlag(e)
+	if err := e.parseConfigCommand(cmd, &f, args); err != nil {
+		return err
+	}
+
+	file, err := f.read(e)
+	if err != nil {
+		return err
+	}
+
+	opts, err := f.applyOptions()
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	cs, err := c.Plan(e.ctx, file.Table, opts)
+	if err != nil {
+		return err
+	}
+
+	switch e.output {
+	case "json", "yaml":
+		if cs == nil {
+			cs = ipvs.Changeset{}
+		}
+		if err := e.encode(cs); err != nil {
+			return err
+		}
+	default:
+		fmt.Fprint(e.stdout, cs.Format())
+	}
+
+	if exitCode && len(cs) > 0 {
+		return errChanges
+	}
+	return nil
+}
+
+// apply implements "ipvsctl apply -f FILE".
+func (e *env) apply(args []string) error {
+	var (
+		f        configFlags
+		interval time.Duration
+	)
+	cmd := e.newCommand("apply", "-f FILE")
+	f.register(cmd, true)
+	for _, name := range cmd.register("interval") {
+		cmd.fs.DurationVar(&interval, name, 0, "keeps reapplying the file, rereading it, at this `interval`")
+	}
+	if err := e.parseConfigCommand(cmd, &f, args); err != nil {
+		return err
+	}
+
+	// The standard input cannot be reread on each interval.
+	if interval > 0 && f.file == "-" {
+		fmt.Fprintf(e.stderr, "ipvsctl %s: --interval requires a --file other than -\n", cmd.fs.Name())
+		cmd.fs.Usage()
+		return errUsage
+	}
+
+	if interval <= 0 {
+		return e.applyOnce(&f)
+	}
+
+	ctx, stop := signal.NotifyContext(e.ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	e.ctx = ctx
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		// Failures are reported, but the next interval tries again.
+		if err := e.applyOnce(&f); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(e.stderr, "ipvsctl: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// applyOnce applies the configuration file of f: the services and
+// destinations with Apply, then the timeouts, and the daemons when the file
+// lists any. The file is reinstated like an ipvs.Snapshot.
+func (e *env) applyOnce(f *configFlags) error {
+	file, err := f.read(e)
+	if err != nil {
+		return err
+	}
+
+	opts, err := f.applyOptions()
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	r, err := c.Restore(e.ctx, ipvs.Snapshot{
+		Table:    file.Table,
+		Timeouts: file.Timeouts,
+		Daemons:  file.Daemons,
+	}, ipvs.RestoreOptions{
+		ApplyOptions: opts,
+		SkipDaemons:  len(file.Daemons) == 0,
+	})
+
+	// The owners are kept even when Apply fails, as it may have created
+	// services.
+	if f.ownersFile != "" && r.Owners != nil {
+		if werr := ipvs.WriteOwners(f.ownersFile, r.Owners); werr != nil {
+			if err != nil {
+				fmt.Fprintf(e.stderr, "ipvsctl: %v\n", werr)
+			} else {
+				err = werr
+			}
+		}
+	}
+
+	if r.Changed() {
+		fmt.Fprintf(e.stdout, "%s: %s\n", f.file, summary(r))
+	}
+	return err
+}
+
+// summary describes the changes of r, such as "created 1 service, updated
+// 2 destinations".
+func summary(r ipvs.ApplyResult) string {
+	var parts []string
+	add := func(verb string, n int, what string) {
+		if n == 0 {
+			return
+		}
+		if n > 1 {
+			what += "s"
+		}
+		parts = append(parts, fmt.Sprintf("%s %d %s", verb, n, what))
+	}
+
+	add("created", len(r.CreatedServices), "service")
+	add("updated", len(r.UpdatedServices), "service")
+	add("removed", len(r.RemovedServices), "service")
+	add("created", r.CreatedDestinations, "destination")
+	add("updated", r.UpdatedDestinations, "destination")
+	add("removed", r.RemovedDestinations, "destination")
+
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/ipvs"
+	"gotest.tools/v3/assert"
+)
+
+func TestConfigCommands(t *testing.T) {
+	type testCase struct {
+		name     string
+		args     string
+		config   string
+		expected []string
+		out      string
+		err      string
+		owners   string
+	}
+
+	const cfg = `
+version: 1
+services:
+  - protocol: tcp
+    address: 192.0.2.1
+    port: 80
+    scheduler: rr
+    persistence:
+      timeout: 300
+      netmask: 255.255.255.255
+    destinations:
+      - address: 198.51.100.1
+        port: 8080
+        forward: masquerade
+        weight: 10
+  - protocol: udp
+    address: 192.0.2.1
+    port: 53
+timeouts:
+  tcp: 15m
+`
+
+	run := func(t *testing.T, tc testCase) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "ipvs.yaml")
+		assert.NilError(t, os.WriteFile(file, []byte(tc.config), 0o644))
+		args := strings.Fields(strings.ReplaceAll(tc.args, "DIR", dir))
+
+		c := newFakeClient()
+		var stdout, stderr bytes.Buffer
+		err := run(context.Background(), args, nil, &stdout, &stderr, func(...ipvs.Option) (ipvs.Client, error) {
+			return c, nil
+		})
+		switch {
+		case tc.err == "changes":
+			assert.Assert(t, errors.Is(err, errChanges))
+		case tc.err == "usage":
+			assert.Assert(t, errors.Is(err, errUsage))
+		case tc.err != "":
+			assert.ErrorContains(t, err, tc.err)
+		default:
+			assert.NilError(t, err)
+		}
+
+		assert.DeepEqual(t, c.ops, tc.expected)
+		assert.Equal(t, strings.ReplaceAll(stdout.String(), dir, "DIR"), tc.out)
+
+		if tc.owners != "" {
+			b, err := os.ReadFile(filepath.Join(dir, "owners.json"))
+			assert.NilError(t, err)
+			assert.Equal(t, string(b), tc.owners)
+		}
+	}
+
+	testCases := []testCase{
+		{
+			name:   "validate",
+			args:   "validate -f DIR/ipvs.yaml",
+			config: cfg,
+			out:    "DIR/ipvs.yaml: valid, 2 services\n",
+		},
+		{
+			name:   "validate kernel",
+			args:   "validate --kernel -f DIR/ipvs.yaml",
+			config: strings.Replace(cfg, "scheduler: rr", "scheduler: mh", 1),
+			err:    `ipvs: service TCP 192.0.2.1:80: scheduler mh is not available`,
+		},
+		{
+			name:   "validate invalid",
+			args:   "validate -f DIR/ipvs.yaml",
+			config: strings.Replace(cfg, "weight: 10", "weight: heavy", 1),
+			err:    "config: line 15: services[0].destinations[0].weight",
+		},
+		{
+			name: "validate missing file",
+			args: "validate",
+			err:  "usage",
+		},
+		{
+			name:   "diff",
+			args:   "diff -f DIR/ipvs.yaml",
+			config: cfg,
+			out: " TCP  192.0.2.1:80 rr persistent 300\n" +
+				"-  -> 198.51.100.1:8080 Masq 5\n" +
+				"+  -> 198.51.100.1:8080 Masq 10\n" +
+				"+UDP  192.0.2.1:53 wlc\n",
+		},
+		{
+			name:   "diff exit code",
+			args:   "diff --exit-code -f DIR/ipvs.yaml",
+			config: cfg,
+			out: " TCP  192.0.2.1:80 rr persistent 300\n" +
+				"-  -> 198.51.100.1:8080 Masq 5\n" +
+				"+  -> 198.51.100.1:8080 Masq 10\n" +
+				"+UDP  192.0.2.1:53 wlc\n",
+			err: "changes",
+		},
+		{
+			name:   "diff json",
+			args:   "--json diff -f DIR/ipvs.yaml",
+			config: strings.Replace(cfg, "weight: 10", "weight: 5", 1),
+			out: `[
+  {
+    "op": "create",
+    "service": "UDP 192.0.2.1:53"
+  }
+]
+`,
+		},
+		{
+			name:   "diff yaml",
+			args:   "diff -f DIR/ipvs.yaml -o yaml",
+			config: strings.Replace(cfg, "weight: 10", "weight: 5", 1),
+			out:    "- op: create\n  service: UDP 192.0.2.1:53\n",
+		},
+		{
+			name:   "diff unknown output",
+			args:   "diff -f DIR/ipvs.yaml -o xml",
+			config: cfg,
+			err:    "usage",
+		},
+		{
+			name:   "apply",
+			args:   "apply -f DIR/ipvs.yaml --prune --owner lb --owners DIR/owners.json",
+			config: cfg,
+			expected: []string{
+				`restore 2 services, prune true, owner "lb", timeouts 15m0s, skip daemons true`,
+			},
+			out:    "DIR/ipvs.yaml: created 2 services, created 1 destination\n",
+			owners: "{\n\t\"TCP 192.0.2.1:80\": \"lb\",\n\t\"UDP 192.0.2.1:53\": \"lb\"\n}\n",
+		},
+		{
+			name: "apply interval of stdin",
+			args: "apply -f - --interval 1s",
+			err:  "usage",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/cloudflare/ipvs"
+)
+
+// destArgs parses the SERVICE DEST arguments of a dest command.
+func destArgs(cmd *command, args []string) (ipvs.Service, ipvs.Destination, error) {
+	svc, args, err := parseService(args, cmd.ipv6)
+	if err != nil {
+		return ipvs.Service{}, ipvs.Destination{}, err
+	}
+
+	dest, err := parseDestination(args[0], svc)
+	if err != nil {
+		return ipvs.Service{}, ipvs.Destination{}, err
+	}
+
+	return svc, dest, nil
+}
+
+// destAdd implements "ipvsctl dest add SERVICE DEST [FLAGS]".
+func (e *env) destAdd(args []string) error {
+	var edits []func(*ipvs.Destination)
+	cmd := e.newCommand("dest add", "SERVICE DEST")
+	cmd.serviceFlag()
+	cmd.destinationFlags(&edits)
+	args, err := cmd.parse(e, args, 3, 3)
+	if err != nil {
+		return err
+	}
+
+	svc, dest, err := destArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	dest.FwdMethod = ipvs.DirectRoute
+	dest.Weight = 1
+	for _, fn := range edits {
+		fn(&dest)
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	err = c.CreateDestination(e.ctx, svc, dest)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("service %s not found", svc.Key())
+	}
+	return err
+}
+
+// destSet implements "ipvsctl dest set SERVICE DEST [FLAGS]", changing
+// only the settings given by flags.
+func (e *env) destSet(args []string) error {
+	var edits []func(*ipvs.Destination)
+	cmd := e.newCommand("dest set", "SERVICE DEST")
+	cmd.serviceFlag()
+	cmd.destinationFlags(&edits)
+	args, err := cmd.parse(e, args, 3, 3)
+	if err != nil {
+		return err
+	}
+
+	svc, dest, err := destArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	if _, err := e.service(c, svc); err != nil {
+		return err
+	}
+
+	dests, err := c.Destinations(e.ctx, svc)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	found := false
+	for _, d := range dests {
+		if d.Address == dest.Address && d.Port == dest.Port {
+			dest, found = d.Destination.Normalize(), true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("destination %s of service %s not found", netip.AddrPortFrom(dest.Address, dest.Port), svc.Key())
+	}
+
+	for _, fn := range edits {
+		fn(&dest)
+	}
+
+	return c.UpdateDestination(e.ctx, svc, dest)
+}
+
+// destDel implements "ipvsctl dest del SERVICE DEST".
+func (e *env) destDel(args []string) error {
+	cmd := e.newCommand("dest del", "SERVICE DEST")
+	cmd.serviceFlag()
+	args, err := cmd.parse(e, args, 3, 3)
+	if err != nil {
+		return err
+	}
+
+	svc, dest, err := destArgs(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	err = c.RemoveDestination(e.ctx, svc, dest)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("destination %s of service %s not found", netip.AddrPortFrom(dest.Address, dest.Port), svc.Key())
+	}
+	return err
+}
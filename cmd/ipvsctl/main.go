@@ -0,0 +1,244 @@
+// Command ipvsctl manages the services and destinations of IPVS, with the
+// semantics of ipvsadm, using only the ipvs package.
+//
+// Usage:
+//
+//	ipvsctl [--netns NAME|PATH] [--json] [--timeout DURATION] COMMAND [ARGS]
+//
+// The commands are:
+//
+//	list [SERVICE]                 list the services and their destinations
+//	service get SERVICE            show a service and its destinations
+//	service add SERVICE [FLAGS]    add a service
+//	service set SERVICE [FLAGS]    change a service
+//	service del SERVICE            delete a service
+//	dest add SERVICE DEST [FLAGS]  add a destination to a service
+//	dest set SERVICE DEST [FLAGS]  change a destination
+//	dest del SERVICE DEST          delete a destination
+//	apply -f FILE [FLAGS]          make IPVS match a configuration file
+//	diff -f FILE [FLAGS]           show the changes apply would make
+//	validate -f FILE [FLAGS]       check a configuration file
+//
+// SERVICE is a protocol, tcp, udp or sctp, followed by ADDRESS:PORT, such
+// as "tcp 192.0.2.1:80", or fwmark followed by a firewall mark, such as
+// "fwmark 42", with --ipv6 for IPv6. DEST is ADDRESS[:PORT], on the port
+// of the service by default.
+//
+// Flags follow the long and short options of ipvsadm, such as --scheduler
+// or -s, and may be given before or after the arguments:
+//
+//	ipvsctl service add tcp 192.0.2.1:80 --scheduler mh --sched-flags mh-port
+//	ipvsctl dest add tcp 192.0.2.1:80 198.51.100.1:8080 -m -w 10
+//
+// Unlike ipvsadm -E and -e, set only changes the settings given by flags,
+// keeping the others.
+//
+// Configuration files, in the YAML or JSON schema of the config package,
+// describe the desired state of IPVS, which apply reaches with the Apply of
+// the ipvs package, making only the changes needed. With --interval, apply
+// keeps reapplying the file, making ipvsctl a minimal GitOps agent:
+//
+//	ipvsctl validate -f ipvs.yaml
+//	ipvsctl diff -f ipvs.yaml --prune
+//	ipvsctl apply -f ipvs.yaml --prune --owner lb --owners /var/lib/ipvsctl/owners.json --interval 30s
+//
+// The read commands, list, service get and diff, print tables by default,
+// and take -o or --output to print:
+//
+//   - json or yaml: services with the field names of the config package,
+//     along with the connection counts and statistics, or the changes of
+//     diff, so that scripts need not scrape columns.
+//   - wide: a table of all the settings and statistics of the services and
+//     destinations, one per line.
+//
+// The global flags are --netns, the network namespace, either a name of
+// "ip netns" or a path, --json, which is -o json for all commands, and
+// --timeout, the timeout of each request.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/ipvs"
+)
+
+const usage = `Usage: ipvsctl [--netns NAME|PATH] [--json] [--timeout DURATION] COMMAND [ARGS]
+
+Commands:
+  list [SERVICE]                 list the services and their destinations
+  service get SERVICE            show a service and its destinations
+  service add SERVICE [FLAGS]    add a service
+  service set SERVICE [FLAGS]    change a service
+  service del SERVICE            delete a service
+  dest add SERVICE DEST [FLAGS]  add a destination to a service
+  dest set SERVICE DEST [FLAGS]  change a destination
+  dest del SERVICE DEST          delete a destination
+  apply -f FILE [FLAGS]          make IPVS match a configuration file
+  diff -f FILE [FLAGS]           show the changes apply would make
+  validate -f FILE [FLAGS]       check a configuration file
+
+SERVICE is PROTOCOL ADDRESS:PORT, with PROTOCOL tcp, udp or sctp, or
+fwmark MARK, with --ipv6 for IPv6. DEST is ADDRESS[:PORT], on the port of
+the service by default. The read commands, list, service get and diff,
+print JSON, YAML or a wide table with -o json, yaml or wide.
+
+Run "ipvsctl COMMAND --help" for the flags of a command.
+`
+
+func main() {
+	err := run(context.Background(), os.Args[1:], os.Stdin, os.Stdout, os.Stderr, ipvs.NewClient)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errChanges):
+		os.Exit(1)
+	case errors.Is(err, errUsage):
+		os.Exit(2)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "ipvsctl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+var (
+	// errUsage reports that the command line is invalid, once the error
+	// and the usage were printed.
+	errUsage = errors.New("usage")
+	// errChanges reports that diff --exit-code found changes.
+	errChanges = errors.New("changes")
+)
+
+// env is the environment of a command.
+type env struct {
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	// output is the output format of read commands, empty for tables.
+	output string
+
+	opts   []ipvs.Option
+	open   func(...ipvs.Option) (ipvs.Client, error)
+	client ipvs.Client
+}
+
+// run runs the ipvsctl command line args, opening the ipvs.Client with open
+// once the arguments are parsed.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, open func(...ipvs.Option) (ipvs.Client, error)) error {
+	e := &env{ctx: ctx, stdin: stdin, stdout: stdout, stderr: stderr, open: open}
+
+	fs := flag.NewFlagSet("ipvsctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprint(stderr, usage) }
+
+	var (
+		netns      string
+		jsonOutput bool
+		timeout    time.Duration
+	)
+	fs.StringVar(&netns, "netns", "", "the name or `path` of the network namespace")
+	fs.BoolVar(&jsonOutput, "json", false, "print JSON, like -o json")
+	fs.DurationVar(&timeout, "timeout", 0, "the timeout of each request to IPVS")
+
+	if err := fs.Parse(args); err != nil {
+		return e.usageError(err)
+	}
+
+	if jsonOutput {
+		e.output = "json"
+	}
+	if netns != "" {
+		if !strings.Contains(netns, "/") {
+			netns = "/var/run/netns/" + netns
+		}
+		e.opts = append(e.opts, ipvs.WithNetNSPath(netns))
+	}
+	if timeout > 0 {
+		e.opts = append(e.opts, ipvs.WithTimeout(timeout))
+	}
+
+	args = fs.Args()
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errUsage
+	}
+
+	defer func() {
+		if e.client != nil {
+			e.client.Close()
+		}
+	}()
+
+	switch args[0] {
+	case "list", "ls":
+		return e.list(args[1:])
+	case "service", "svc":
+		return e.subcommand("service", args[1:], map[string]func([]string) error{
+			"get": e.serviceGet,
+			"add": e.serviceAdd,
+			"set": e.serviceSet,
+			"del": e.serviceDel,
+		})
+	case "dest", "destination":
+		return e.subcommand("dest", args[1:], map[string]func([]string) error{
+			"add": e.destAdd,
+			"set": e.destSet,
+			"del": e.destDel,
+		})
+	case "apply":
+		return e.apply(args[1:])
+	case "diff":
+		return e.diff(args[1:])
+	case "validate":
+		return e.validate(args[1:])
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+
+	fmt.Fprintf(stderr, "ipvsctl: unknown command %q\n\n%s", args[0], usage)
+	return errUsage
+}
+
+// subcommand runs the subcommand of cmd named by args[0].
+func (e *env) subcommand(cmd string, args []string, cmds map[string]func([]string) error) error {
+	if len(args) > 0 {
+		if fn, ok := cmds[args[0]]; ok {
+			return fn(args[1:])
+		}
+		fmt.Fprintf(e.stderr, "ipvsctl: unknown command %q\n\n", cmd+" "+args[0])
+	}
+
+	fmt.Fprint(e.stderr, usage)
+	return errUsage
+}
+
+// Client returns the ipvs.Client, opening it on first use, so that invalid
+// command lines fail before connecting to IPVS.
+func (e *env) Client() (ipvs.Client, error) {
+	if e.client == nil {
+		c, err := e.open(e.opts...)
+		if err != nil {
+			return nil, err
+		}
+		e.client = c
+	}
+
+	return e.client, nil
+}
+
+// usageError returns err, an error parsing the command line, once printed
+// along with the usage by the flag package.
+func (e *env) usageError(err error) error {
+	if errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+
+	return errUsage
+}
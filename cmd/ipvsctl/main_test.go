@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/ipvs"
+	"github.com/cloudflare/ipvs/netmask"
+	"gotest.tools/v3/assert"
+)
+
+// fakeClient is an ipvs.Client holding a single service, recording the
+// changes made.
+type fakeClient struct {
+	ipvs.Client
+	svc   ipvs.Service
+	dests []ipvs.Destination
+	ops   []string
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		svc: ipvs.Service{
+			Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: ipvs.INET, Protocol: ipvs.TCP,
+			Scheduler: ipvs.RoundRobin, Flags: ipvs.ServicePersistent, Timeout: 300, Netmask: netmask.MaskFrom(32, 32),
+		},
+		dests: []ipvs.Destination{{
+			Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: ipvs.INET,
+			FwdMethod: ipvs.Masquerade, Weight: 5,
+		}},
+	}
+}
+
+func (c *fakeClient) Close() error { return nil }
+
+func (c *fakeClient) ServicesWithDestinations(context.Context, ...ipvs.ListOption) ([]ipvs.ServiceWithDestinations, error) {
+	stats := ipvs.Stats{Connections: 10, IncomingPackets: 200, OutgoingPackets: 100, IncomingBytes: 20000, OutgoingBytes: 10000}
+	s := ipvs.ServiceWithDestinations{ServiceExtended: ipvs.ServiceExtended{Service: c.svc, Stats: stats}}
+	for _, dest := range c.dests {
+		s.Destinations = append(s.Destinations, ipvs.DestinationExtended{Destination: dest, ActiveConnections: 3, Stats: stats})
+	}
+
+	return []ipvs.ServiceWithDestinations{s}, nil
+}
+
+func (c *fakeClient) Service(_ context.Context, svc ipvs.Service) (ipvs.ServiceExtended, error) {
+	if svc.Key() != c.svc.Key() {
+		return ipvs.ServiceExtended{}, os.ErrNotExist
+	}
+
+	return ipvs.ServiceExtended{Service: c.svc}, nil
+}
+
+func (c *fakeClient) Destinations(_ context.Context, svc ipvs.Service) ([]ipvs.DestinationExtended, error) {
+	var dests []ipvs.DestinationExtended
+	for _, dest := range c.dests {
+		dests = append(dests, ipvs.DestinationExtended{Destination: dest})
+	}
+
+	return dests, nil
+}
+
+func (c *fakeClient) CreateService(_ context.Context, svc ipvs.Service) error {
+	c.ops = append(c.ops, fmt.Sprintf("create_service %s", svc))
+	return nil
+}
+
+func (c *fakeClient) UpdateService(_ context.Context, svc ipvs.Service) error {
+	c.ops = append(c.ops, fmt.Sprintf("update_service %s", svc))
+	return nil
+}
+
+func (c *fakeClient) RemoveService(_ context.Context, svc ipvs.Service) error {
+	c.ops = append(c.ops, fmt.Sprintf("remove_service %s", svc.Key()))
+	return nil
+}
+
+func (c *fakeClient) CreateDestination(_ context.Context, svc ipvs.Service, dest ipvs.Destination) error {
+	c.ops = append(c.ops, fmt.Sprintf("create_destination %s %s", svc.Key(), dest))
+	return nil
+}
+
+func (c *fakeClient) UpdateDestination(_ context.Context, svc ipvs.Service, dest ipvs.Destination) error {
+	c.ops = append(c.ops, fmt.Sprintf("update_destination %s %s thresholds %d/%d", svc.Key(), dest, dest.UpperThreshold, dest.LowerThreshold))
+	return nil
+}
+
+func (c *fakeClient) RemoveDestination(_ context.Context, svc ipvs.Service, dest ipvs.Destination, _ ...ipvs.RemoveOption) error {
+	c.ops = append(c.ops, fmt.Sprintf("remove_destination %s %s", svc.Key(), netip.AddrPortFrom(dest.Address, dest.Port)))
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	type testCase struct {
+		name     string
+		args     string
+		expected []string
+		out      string
+		err      string
+		noClient bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		c := newFakeClient()
+		var opened bool
+		open := func(...ipvs.Option) (ipvs.Client, error) {
+			opened = true
+			return c, nil
+		}
+
+		var stdout, stderr bytes.Buffer
+		err := run(context.Background(), strings.Fields(tc.args), nil, &stdout, &stderr, open)
+		switch {
+		case tc.err == "usage":
+			assert.Assert(t, errors.Is(err, errUsage))
+		case tc.err != "":
+			assert.Error(t, err, tc.err)
+		default:
+			assert.NilError(t, err)
+		}
+
+		assert.DeepEqual(t, c.ops, tc.expected)
+		assert.Equal(t, opened, !tc.noClient)
+		if tc.out != "" {
+			assert.Equal(t, stdout.String(), tc.out)
+		}
+	}
+
+	testCases := []testCase{
+		{
+			name: "list",
+			args: "list",
+			out: "Prot LocalAddress:Port Scheduler Flags\n" +
+				"  -> RemoteAddress:Port           Forward Weight ActiveConn InActConn\n" +
+				"TCP  192.0.2.1:80 rr persistent 300\n" +
+				"  -> 198.51.100.1:8080            Masq    5      3          0         \n",
+		},
+		{
+			name: "list json",
+			args: "list -o json",
+			out: `[
+  {
+    "protocol": "tcp",
+    "address": "192.0.2.1",
+    "port": 80,
+    "family": "inet",
+    "scheduler": "rr",
+    "persistence": {
+      "timeout": 300,
+      "netmask": "255.255.255.255"
+    },
+    "stats": {
+      "connections": 10,
+      "incoming_packets": 200,
+      "outgoing_packets": 100,
+      "incoming_bytes": 20000,
+      "outgoing_bytes": 10000,
+      "connection_rate": 0,
+      "incoming_packet_rate": 0,
+      "outgoing_packet_rate": 0,
+      "incoming_byte_rate": 0,
+      "outgoing_byte_rate": 0
+    },
+    "destinations": [
+      {
+        "address": "198.51.100.1",
+        "port": 8080,
+        "forward": "masquerade",
+        "weight": 5,
+        "active_connections": 3,
+        "inactive_connections": 0,
+        "persistent_connections": 0,
+        "stats": {
+          "connections": 10,
+          "incoming_packets": 200,
+          "outgoing_packets": 100,
+          "incoming_bytes": 20000,
+          "outgoing_bytes": 10000,
+          "connection_rate": 0,
+          "incoming_packet_rate": 0,
+          "outgoing_packet_rate": 0,
+          "incoming_byte_rate": 0,
+          "outgoing_byte_rate": 0
+        }
+      }
+    ]
+  }
+]
+`,
+		},
+		{
+			name: "list yaml",
+			args: "list -o yaml",
+			out: `- protocol: tcp
+  address: 192.0.2.1
+  port: 80
+  family: inet
+  scheduler: rr
+  persistence:
+    timeout: 300
+    netmask: 255.255.255.255
+  stats:
+    connections: 10
+    incoming_packets: 200
+    outgoing_packets: 100
+    incoming_bytes: 20000
+    outgoing_bytes: 10000
+    connection_rate: 0
+    incoming_packet_rate: 0
+    outgoing_packet_rate: 0
+    incoming_byte_rate: 0
+    outgoing_byte_rate: 0
+  destinations:
+  - address: 198.51.100.1
+    port: 8080
+    forward: masquerade
+    weight: 5
+    active_connections: 3
+    inactive_connections: 0
+    persistent_connections: 0
+    stats:
+      connections: 10
+      incoming_packets: 200
+      outgoing_packets: 100
+      incoming_bytes: 20000
+      outgoing_bytes: 10000
+      connection_rate: 0
+      incoming_packet_rate: 0
+      outgoing_packet_rate: 0
+      incoming_byte_rate: 0
+      outgoing_byte_rate: 0
+`,
+		},
+		{
+			name: "list wide",
+			args: "list -o wide",
+			out: "" +
+				"PROT  SERVICE       SCHEDULER  FLAGS                                DESTINATION        FORWARD     WEIGHT  UTHRESH  LTHRESH  TUNNEL  ACTIVE  INACTIVE  PERSIST  CONNS  INPKTS  OUTPKTS  INBYTES  OUTBYTES  CPS  INPPS  OUTPPS  INBPS  OUTBPS\n" +
+				"TCP   192.0.2.1:80  rr         persistent=300,mask=255.255.255.255  -                  -           -       -        -        -       -       -         -        10     200     100      20000    10000     0    0      0       0      0\n" +
+				"TCP   192.0.2.1:80  -          -                                    198.51.100.1:8080  masquerade  5       0        0        -       3       0         0        10     200     100      20000    10000     0    0      0       0      0\n",
+		},
+		{
+			name: "get",
+			args: "service get tcp 192.0.2.1:80",
+		},
+		{
+			name: "get missing",
+			args: "service get udp 192.0.2.1:80",
+			err:  "service UDP 192.0.2.1:80 not found",
+		},
+		{
+			name:     "add",
+			args:     "service add tcp 192.0.2.2:80 --scheduler mh -b mh-port,mh-fallback",
+			expected: []string{"create_service TCP  192.0.2.2:80 mh (mh-fallback,mh-port)"},
+		},
+		{
+			name:     "add defaults",
+			args:     "service add -p udp [2001:db8::1]:53 -o",
+			expected: []string{"create_service UDP  [2001:db8::1]:53 wlc persistent 360 ops"},
+		},
+		{
+			name:     "add fwmark",
+			args:     "service add fwmark 42 --ipv6 --persistent=60 -M 64",
+			expected: []string{"create_service FWM  42 IPv6 wlc persistent 60 mask 64"},
+		},
+		{
+			name:     "set",
+			args:     "service set tcp 192.0.2.1:80 -s wrr",
+			expected: []string{"update_service TCP  192.0.2.1:80 wrr persistent 300"},
+		},
+		{
+			name:     "set not persistent",
+			args:     "service set tcp 192.0.2.1:80 --persistent=false",
+			expected: []string{"update_service TCP  192.0.2.1:80 rr"},
+		},
+		{
+			name:     "del",
+			args:     "service del tcp 192.0.2.1:80",
+			expected: []string{"remove_service TCP 192.0.2.1:80"},
+		},
+		{
+			name:     "dest add",
+			args:     "dest add tcp 192.0.2.1:80 198.51.100.2",
+			expected: []string{"create_destination TCP 192.0.2.1:80 198.51.100.2:80 Route 1"},
+		},
+		{
+			name:     "dest add flags",
+			args:     "dest add tcp 192.0.2.1:80 198.51.100.2:8080 -m -w 10",
+			expected: []string{"create_destination TCP 192.0.2.1:80 198.51.100.2:8080 Masq 10"},
+		},
+		{
+			name:     "dest add last forwarding wins",
+			args:     "dest add tcp 192.0.2.1:80 198.51.100.2:8080 -m --forward tunnel",
+			expected: []string{"create_destination TCP 192.0.2.1:80 198.51.100.2:8080 Tunnel 1"},
+		},
+		{
+			name:     "dest set",
+			args:     "dest set tcp 192.0.2.1:80 198.51.100.1:8080 -x 100 -y 50",
+			expected: []string{"update_destination TCP 192.0.2.1:80 198.51.100.1:8080 Masq 5 thresholds 100/50"},
+		},
+		{
+			name: "dest set missing",
+			args: "dest set tcp 192.0.2.1:80 198.51.100.2:8080 -w 2",
+			err:  "destination 198.51.100.2:8080 of service TCP 192.0.2.1:80 not found",
+		},
+		{
+			name:     "dest del",
+			args:     "dest del tcp 192.0.2.1:80 198.51.100.1:8080",
+			expected: []string{"remove_destination TCP 192.0.2.1:80 198.51.100.1:8080"},
+		},
+		{
+			name:     "unknown command",
+			args:     "frobnicate",
+			err:      "usage",
+			noClient: true,
+		},
+		{
+			name:     "missing arguments",
+			args:     "service add tcp",
+			err:      "usage",
+			noClient: true,
+		},
+		{
+			name:     "invalid flag",
+			args:     "dest add tcp 192.0.2.1:80 198.51.100.2 -w heavy",
+			err:      "usage",
+			noClient: true,
+		},
+		{
+			name:     "invalid service",
+			args:     "service add icmp 192.0.2.1:80",
+			err:      `unknown protocol "icmp"`,
+			noClient: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func (c *fakeClient) Plan(ctx context.Context, cfg ipvs.TableConfig, _ ipvs.ApplyOptions) (ipvs.Changeset, error) {
+	svcs, _ := c.ServicesWithDestinations(ctx)
+	return ipvs.Diff(ipvs.TableConfigOf(svcs), cfg)
+}
+
+func (c *fakeClient) Restore(_ context.Context, snap ipvs.Snapshot, opts ipvs.RestoreOptions) (ipvs.ApplyResult, error) {
+	c.ops = append(c.ops, fmt.Sprintf("restore %d services, prune %t, owner %q, timeouts %v, skip daemons %t",
+		len(snap.Table.Services), opts.Prune, opts.Owner, snap.Timeouts.TCP, opts.SkipDaemons))
+
+	r := ipvs.ApplyResult{Owners: make(map[ipvs.ServiceKey]string)}
+	for _, sc := range snap.Table.Services {
+		r.CreatedServices = append(r.CreatedServices, sc.Key())
+		r.CreatedDestinations += len(sc.Destinations)
+		if opts.Owner != "" {
+			r.Owners[sc.Key()] = opts.Owner
+		}
+	}
+	return r, nil
+}
+
+func (c *fakeClient) Capabilities(context.Context) (ipvs.Capabilities, error) {
+	return ipvs.Capabilities{Schedulers: []ipvs.Scheduler{ipvs.RoundRobin, ipvs.WeightedLeastConnection}}, nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cloudflare/ipvs"
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormats are the formats of -o, besides the default table.
+var outputFormats = []string{"json", "yaml", "wide"}
+
+// outputFlag registers the -o flag of a read command.
+func (cmd *command) outputFlag(e *env) {
+	v := &outputValue{output: &e.output}
+	for _, name := range cmd.register("o,output") {
+		cmd.fs.Var(v, name, "the output `format`: "+strings.Join(outputFormats, ", "))
+	}
+}
+
+// outputValue is the flag.Value of -o.
+type outputValue struct {
+	output *string
+}
+
+func (v *outputValue) String() string { return "" }
+
+func (v *outputValue) Set(s string) error {
+	for _, format := range outputFormats {
+		if s == format {
+			*v.output = s
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown output format %q", s)
+}
+
+// print prints svcs in the output format of e, by default in the table
+// layout of ipvsadm.
+func (e *env) print(svcs []ipvs.ServiceWithDestinations) error {
+	switch e.output {
+	case "json", "yaml":
+		out := make([]jsonService, 0, len(svcs))
+		for _, svc := range svcs {
+			out = append(out, jsonServiceOf(svc))
+		}
+		return e.encode(out)
+	case "wide":
+		return printWide(e.stdout, svcs)
+	}
+
+	_, err := fmt.Fprint(e.stdout, ipvs.FormatTable(svcs))
+	return err
+}
+
+// encode prints v as JSON, or as YAML with the same fields in the same
+// order.
+func (e *env) encode(v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if e.output == "yaml" {
+		// JSON is YAML, which the node keeps in order, only dropping the
+		// quotes and braces of JSON.
+		var doc yaml.Node
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return err
+		}
+		resetStyle(&doc)
+
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&doc); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	} else {
+		b = append(b, '\n')
+	}
+
+	_, err = e.stdout.Write(b)
+	return err
+}
+
+// resetStyle resets the style of n and its children, to the block style
+// of YAML.
+func resetStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		resetStyle(c)
+	}
+}
+
+// jsonService is the JSON form of a service, with the field names and
+// values of the config package, along with its statistics and the
+// connection counts of its destinations.
+type jsonService struct {
+	Protocol       string            `json:"protocol,omitempty"`
+	Address        *netip.Addr       `json:"address,omitempty"`
+	Port           uint16            `json:"port,omitempty"`
+	FWMark         uint32            `json:"fwmark,omitempty"`
+	Family         string            `json:"family"`
+	Scheduler      ipvs.Scheduler    `json:"scheduler"`
+	SchedulerFlags []string          `json:"scheduler_flags,omitempty"`
+	Persistence    *jsonPersistence  `json:"persistence,omitempty"`
+	PE             string            `json:"pe,omitempty"`
+	OnePacket      bool              `json:"one_packet,omitempty"`
+	Stats          jsonStats         `json:"stats"`
+	Destinations   []jsonDestination `json:"destinations"`
+}
+
+type jsonPersistence struct {
+	Timeout uint32 `json:"timeout"`
+	Netmask string `json:"netmask,omitempty"`
+}
+
+type jsonDestination struct {
+	Address               netip.Addr  `json:"address"`
+	Port                  uint16      `json:"port"`
+	Forward               string      `json:"forward"`
+	Weight                uint32      `json:"weight"`
+	UpperThreshold        uint32      `json:"upper_threshold,omitempty"`
+	LowerThreshold        uint32      `json:"lower_threshold,omitempty"`
+	Tunnel                *jsonTunnel `json:"tunnel,omitempty"`
+	ActiveConnections     uint32      `json:"active_connections"`
+	InactiveConnections   uint32      `json:"inactive_connections"`
+	PersistentConnections uint32      `json:"persistent_connections"`
+	Stats                 jsonStats   `json:"stats"`
+}
+
+type jsonTunnel struct {
+	Type     string `json:"type"`
+	Port     uint16 `json:"port,omitempty"`
+	Checksum string `json:"checksum"`
+}
+
+type jsonStats struct {
+	Connections        uint64 `json:"connections"`
+	IncomingPackets    uint64 `json:"incoming_packets"`
+	OutgoingPackets    uint64 `json:"outgoing_packets"`
+	IncomingBytes      uint64 `json:"incoming_bytes"`
+	OutgoingBytes      uint64 `json:"outgoing_bytes"`
+	ConnectionRate     uint64 `json:"connection_rate"`
+	IncomingPacketRate uint64 `json:"incoming_packet_rate"`
+	OutgoingPacketRate uint64 `json:"outgoing_packet_rate"`
+	IncomingByteRate   uint64 `json:"incoming_byte_rate"`
+	OutgoingByteRate   uint64 `json:"outgoing_byte_rate"`
+}
+
+// jsonServiceOf returns the JSON form of svc.
+func jsonServiceOf(svc ipvs.ServiceWithDestinations) jsonService {
+	s := svc.Service.Normalize()
+	out := jsonService{
+		Family:         nameOf(families, s.Family),
+		Scheduler:      s.Scheduler,
+		SchedulerFlags: schedulerFlagNames(s),
+		PE:             s.PEName,
+		OnePacket:      s.Flags&ipvs.ServiceOnePacket != 0,
+		Stats:          jsonStats(svc.Stats),
+		Destinations:   make([]jsonDestination, 0, len(svc.Destinations)),
+	}
+
+	if s.FWMark != 0 {
+		out.FWMark = s.FWMark
+	} else {
+		out.Protocol = nameOf(protocols, s.Protocol)
+		out.Address = &s.Address
+		out.Port = s.Port
+	}
+
+	if s.Flags&ipvs.ServicePersistent != 0 {
+		out.Persistence = &jsonPersistence{Timeout: s.Timeout}
+		if s.Netmask.IsValid() {
+			out.Persistence.Netmask = s.Netmask.String()
+		}
+	}
+
+	for _, dest := range svc.Destinations {
+		d := dest.Destination.Normalize()
+		jd := jsonDestination{
+			Address:               d.Address,
+			Port:                  d.Port,
+			Forward:               nameOf(forwardings, d.FwdMethod),
+			Weight:                d.Weight,
+			UpperThreshold:        d.UpperThreshold,
+			LowerThreshold:        d.LowerThreshold,
+			ActiveConnections:     dest.ActiveConnections,
+			InactiveConnections:   dest.InactiveConnections,
+			PersistentConnections: dest.PersistentConnections,
+			Stats:                 jsonStats(dest.Stats),
+		}
+		if d.FwdMethod == ipvs.Tunnel {
+			jd.Tunnel = &jsonTunnel{
+				Type:     nameOf(tunnelTypes, d.TunnelType),
+				Port:     d.TunnelPort,
+				Checksum: nameOf(tunnelChecksums, d.TunnelFlags),
+			}
+		}
+		out.Destinations = append(out.Destinations, jd)
+	}
+
+	return out
+}
+
+// printWide prints svcs as a table of all their settings and statistics,
+// with a line per service and per destination.
+func printWide(w io.Writer, svcs []ipvs.ServiceWithDestinations) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROT\tSERVICE\tSCHEDULER\tFLAGS\tDESTINATION\tFORWARD\tWEIGHT\tUTHRESH\tLTHRESH\tTUNNEL\t"+
+		"ACTIVE\tINACTIVE\tPERSIST\tCONNS\tINPKTS\tOUTPKTS\tINBYTES\tOUTBYTES\tCPS\tINPPS\tOUTPPS\tINBPS\tOUTBPS")
+
+	for _, svc := range svcs {
+		s := svc.Service.Normalize()
+		prot, target := "FWM", strconv.FormatUint(uint64(s.FWMark), 10)
+		if s.FWMark == 0 {
+			prot, target = s.Protocol.String(), netip.AddrPortFrom(s.Address, s.Port).String()
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t-\t-\t-\t-\t-\t-\t-\t-\t-\t%s\n",
+			prot, target, s.Scheduler, serviceFlagsColumn(s), statsColumns(svc.Stats))
+
+		for _, dest := range svc.Destinations {
+			d := dest.Destination.Normalize()
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t%s\t%s\t%d\t%d\t%d\t%s\t%d\t%d\t%d\t%s\n",
+				prot, target, netip.AddrPortFrom(d.Address, d.Port), nameOf(forwardings, d.FwdMethod),
+				d.Weight, d.UpperThreshold, d.LowerThreshold, tunnelColumn(d),
+				dest.ActiveConnections, dest.InactiveConnections, dest.PersistentConnections, statsColumns(dest.Stats))
+		}
+	}
+
+	return tw.Flush()
+}
+
+// serviceFlagsColumn returns the settings of svc besides its scheduler,
+// such as "persistent=300,mask=255.255.255.0", or "-" if it has none.
+func serviceFlagsColumn(svc ipvs.Service) string {
+	var flags []string
+	if svc.FWMark != 0 && svc.Family == ipvs.INET6 {
+		flags = append(flags, "ipv6")
+	}
+	flags = append(flags, schedulerFlagNames(svc)...)
+	if svc.Flags&ipvs.ServicePersistent != 0 {
+		flags = append(flags, fmt.Sprintf("persistent=%d", svc.Timeout))
+		if svc.Netmask.IsValid() {
+			flags = append(flags, "mask="+svc.Netmask.String())
+		}
+	}
+	if svc.PEName != "" {
+		flags = append(flags, "pe="+svc.PEName)
+	}
+	if svc.Flags&ipvs.ServiceOnePacket != 0 {
+		flags = append(flags, "ops")
+	}
+
+	if len(flags) == 0 {
+		return "-"
+	}
+	return strings.Join(flags, ",")
+}
+
+// tunnelColumn returns the tunnel of dest, such as "gue:6080,checksum", or
+// "-" if it does not forward by tunneling.
+func tunnelColumn(dest ipvs.Destination) string {
+	if dest.FwdMethod != ipvs.Tunnel {
+		return "-"
+	}
+
+	s := nameOf(tunnelTypes, dest.TunnelType)
+	if dest.TunnelPort != 0 {
+		s += ":" + strconv.FormatUint(uint64(dest.TunnelPort), 10)
+	}
+	if dest.TunnelFlags != ipvs.TunnelEncapNoChecksum {
+		s += "," + nameOf(tunnelChecksums, dest.TunnelFlags)
+	}
+
+	return s
+}
+
+// statsColumns returns the columns of stats.
+func statsColumns(stats ipvs.Stats) string {
+	return fmt.Sprintf("%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d",
+		stats.Connections, stats.IncomingPackets, stats.OutgoingPackets, stats.IncomingBytes, stats.OutgoingBytes,
+		stats.ConnectionRate, stats.IncomingPacketRate, stats.OutgoingPacketRate, stats.IncomingByteRate, stats.OutgoingByteRate)
+}
+
+// schedulerFlagNames returns the names of the scheduler flags of svc, in
+// the form of ipvsadm.
+func schedulerFlagNames(svc ipvs.Service) []string {
+	var names []string
+	for i, flag := range [...]ipvs.Flags{ipvs.ServiceSchedulerOpt1, ipvs.ServiceSchedulerOpt2, ipvs.ServiceSchedulerOpt3} {
+		if svc.Flags&flag == 0 {
+			continue
+		}
+		name := fmt.Sprintf("flag-%d", i+1)
+		switch {
+		case svc.Scheduler == ipvs.SourceHashing && flag == ipvs.SourceHashFallback:
+			name = "sh-fallback"
+		case svc.Scheduler == ipvs.SourceHashing && flag == ipvs.SourceHashPort:
+			name = "sh-port"
+		case svc.Scheduler == ipvs.MaglevHashing && flag == ipvs.MaglevFallback:
+			name = "mh-fallback"
+		case svc.Scheduler == ipvs.MaglevHashing && flag == ipvs.MaglevPort:
+			name = "mh-port"
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// nameOf returns the name of v in names, or v formatted when it has none.
+func nameOf[T comparable](names map[string]T, v T) string {
+	for name, n := range names {
+		if n == v {
+			return name
+		}
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cloudflare/ipvs"
+)
+
+// list implements "ipvsctl list [SERVICE]".
+func (e *env) list(args []string) error {
+	cmd := e.newCommand("list", "[SERVICE]")
+	cmd.serviceFlag()
+	cmd.outputFlag(e)
+	args, err := cmd.parse(e, args, 0, 2)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		return e.show(cmd, args)
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	svcs, err := c.ServicesWithDestinations(e.ctx)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return e.print(svcs)
+}
+
+// serviceGet implements "ipvsctl service get SERVICE".
+func (e *env) serviceGet(args []string) error {
+	cmd := e.newCommand("service get", "SERVICE")
+	cmd.serviceFlag()
+	cmd.outputFlag(e)
+	args, err := cmd.parse(e, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	return e.show(cmd, args)
+}
+
+// show prints the SERVICE of args with its destinations.
+func (e *env) show(cmd *command, args []string) error {
+	svc, _, err := parseService(args, cmd.ipv6)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	s, err := e.service(c, svc)
+	if err != nil {
+		return err
+	}
+
+	dests, err := c.Destinations(e.ctx, svc)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return e.print([]ipvs.ServiceWithDestinations{{ServiceExtended: s, Destinations: dests}})
+}
+
+// serviceAdd implements "ipvsctl service add SERVICE [FLAGS]".
+func (e *env) serviceAdd(args []string) error {
+	var edits []func(*ipvs.Service)
+	cmd := e.newCommand("service add", "SERVICE")
+	cmd.serviceFlag()
+	cmd.serviceFlags(&edits)
+	args, err := cmd.parse(e, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	svc, _, err := parseService(args, cmd.ipv6)
+	if err != nil {
+		return err
+	}
+
+	svc.Scheduler = ipvs.WeightedLeastConnection
+	for _, fn := range edits {
+		fn(&svc)
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	return c.CreateService(e.ctx, svc)
+}
+
+// serviceSet implements "ipvsctl service set SERVICE [FLAGS]", changing
+// only the settings given by flags.
+func (e *env) serviceSet(args []string) error {
+	var edits []func(*ipvs.Service)
+	cmd := e.newCommand("service set", "SERVICE")
+	cmd.serviceFlag()
+	cmd.serviceFlags(&edits)
+	args, err := cmd.parse(e, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	svc, _, err := parseService(args, cmd.ipv6)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	s, err := e.service(c, svc)
+	if err != nil {
+		return err
+	}
+
+	svc = s.Service.Normalize()
+	for _, fn := range edits {
+		fn(&svc)
+	}
+
+	return c.UpdateService(e.ctx, svc)
+}
+
+// serviceDel implements "ipvsctl service del SERVICE".
+func (e *env) serviceDel(args []string) error {
+	cmd := e.newCommand("service del", "SERVICE")
+	cmd.serviceFlag()
+	args, err := cmd.parse(e, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	svc, _, err := parseService(args, cmd.ipv6)
+	if err != nil {
+		return err
+	}
+
+	c, err := e.Client()
+	if err != nil {
+		return err
+	}
+
+	err = c.RemoveService(e.ctx, svc)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("service %s not found", svc.Key())
+	}
+	return err
+}
+
+// service fetches svc through c.
+func (e *env) service(c ipvs.Client, svc ipvs.Service) (ipvs.ServiceExtended, error) {
+	s, err := c.Service(e.ctx, svc)
+	if errors.Is(err, os.ErrNotExist) {
+		return ipvs.ServiceExtended{}, fmt.Errorf("service %s not found", svc.Key())
+	}
+
+	return s, err
+}
@@ -0,0 +1,162 @@
+// Package config defines the YAML and JSON schema of files describing the
+// desired state of IPVS, so that IPVS can be managed as files, such as in
+// GitOps workflows.
+//
+// A file looks like:
+//
+//	version: 1
+//	services:
+//	  - protocol: tcp
+//	    address: 192.0.2.1
+//	    port: 80
+//	    scheduler: wrr
+//	    owner: web
+//	    destinations:
+//	      - address: 198.51.100.1
+//	        port: 8080
+//	        forward: masquerade
+//	        weight: 10
+//	  - fwmark: 42
+//	    family: inet6
+//	    persistence:
+//	      timeout: 5m
+//	      netmask: 64
+//	timeouts:
+//	  tcp: 15m
+//	  udp: 300
+//	daemons:
+//	  - state: master
+//	    interface: eth0
+//	    sync_id: 1
+//
+// JSON files follow the same schema. Decoding is strict: unknown fields,
+// duplicate keys and values of the wrong type are errors, reported along
+// with the path of the value, such as "services[0].destinations[1].weight".
+//
+// The version field is required, and is 1 for the schema described here.
+//
+// Services are identified either by protocol (tcp, udp or sctp), address
+// and port, or by fwmark and family (inet, the default, or inet6). Their
+// other fields are:
+//
+//   - scheduler: the name of the scheduler, wlc by default.
+//   - scheduler_flags: a list of the flags of the scheduler, in the form of
+//     ipvsadm, such as sh-fallback and sh-port, or flag-1 to flag-3.
+//   - persistence: makes connections of a client persistent, with a timeout,
+//     360 seconds by default, and a netmask grouping clients.
+//   - pe: the name of the persistence engine, such as sip.
+//   - one_packet: schedules UDP packets individually.
+//   - owner: labels the service with its owner, see ipvs.ApplyOptions.
+//   - destinations: the list of destinations.
+//
+// Destinations are identified by address and port, which defaults to the
+// port of their service. Their other fields are:
+//
+//   - forward: the forwarding method, route (the default), masquerade,
+//     tunnel or local.
+//   - weight: the weight, 1 by default.
+//   - upper_threshold and lower_threshold: the connection thresholds.
+//   - tunnel: the type (ipip, the default, gue or gre), port and checksum
+//     (none, the default, checksum or remote) of the Tunnel forwarding
+//     method.
+//
+// The timeouts of tcp, tcp_fin and udp connections, like the other
+// durations, are either integer seconds or duration strings such as "15m".
+// Omitted timeouts are left unchanged.
+//
+// Daemons are the connection synchronization daemons, of which the state
+// (master or backup) and interface are required. Their other fields are
+// sync_id, max_len, group, port and ttl.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudflare/ipvs"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the version of the schema understood by Parse.
+const Version = 1
+
+// File is the desired state of IPVS described by a file.
+type File struct {
+	// Version is the version of the schema of the file.
+	Version int
+	// Table holds the services and their destinations, to pass to
+	// ipvs.Client.Apply.
+	Table ipvs.TableConfig
+	// Timeouts are the connection timeouts, to pass to
+	// ipvs.Client.SetTimeouts. Zero timeouts are left unchanged.
+	Timeouts ipvs.Timeouts
+	// Daemons are the connection synchronization daemons.
+	Daemons []ipvs.Daemon
+}
+
+// Parse decodes data, a file in YAML or JSON, applying the defaults of the
+// schema and validating the result. Errors are of type ErrorList, listing
+// all the errors found.
+func Parse(data []byte) (*File, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, ErrorList{{Err: err}}
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, ErrorList{{Err: errors.New("empty file")}}
+	}
+
+	var d decoder
+	f := d.file(doc.Content[0])
+	if len(d.errs) > 0 {
+		return nil, d.errs
+	}
+
+	return f, nil
+}
+
+// Error is an error at a path of a file.
+type Error struct {
+	// Path locates the value in error, such as
+	// "services[0].destinations[1].weight". It is empty for errors about
+	// the whole file.
+	Path string
+	// Line is the line of the value in error, or zero when unknown.
+	Line int
+	Err  error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("config: ")
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList lists the errors of a file, in the order of the file.
+type ErrorList []*Error
+
+// Error implements the error interface, listing one error per line.
+func (l ErrorList) Error() string {
+	msgs := make([]string, 0, len(l))
+	for _, e := range l {
+		msgs = append(msgs, e.Error())
+	}
+
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/ipvs"
+	"github.com/cloudflare/ipvs/netmask"
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+)
+
+// cmpFile compares decoded files.
+var cmpFile = []cmp.Option{
+	cmp.Comparer(func(x, y netip.Addr) bool { return x == y }),
+	cmp.Comparer(netmask.Mask.Equal),
+}
+
+const testYAML = `
+version: 1
+services:
+  - protocol: tcp
+    address: 192.0.2.1
+    port: 80
+    scheduler: wrr
+    owner: web
+    destinations:
+      - address: 198.51.100.1
+        port: 8080
+        forward: masquerade
+        weight: 10
+      - address: 198.51.100.2
+        upper_threshold: 100
+        lower_threshold: 50
+  - fwmark: 42
+    family: inet6
+    scheduler: mh
+    scheduler_flags: [mh-port]
+    persistence:
+      timeout: 5m
+      netmask: 64
+    destinations:
+      - address: 198.51.100.3
+        forward: tunnel
+        tunnel:
+          type: gue
+          port: 6080
+          checksum: remote
+  - protocol: udp
+    address: 2001:db8::1
+    port: 53
+    persistence: {}
+    pe: sip
+    one_packet: true
+timeouts:
+  tcp: 15m
+  udp: 300
+daemons:
+  - state: master
+    interface: eth0
+    sync_id: 1
+    group: 224.0.0.81
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(testYAML))
+	assert.NilError(t, err)
+
+	expected := &File{
+		Version: 1,
+		Table: ipvs.TableConfig{Services: []ipvs.ServiceConfig{
+			{
+				Service: ipvs.Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: ipvs.INET, Protocol: ipvs.TCP, Scheduler: ipvs.WeightedRoundRobin},
+				Owner:   "web",
+				Destinations: []ipvs.Destination{
+					{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: ipvs.INET, FwdMethod: ipvs.Masquerade, Weight: 10},
+					{Address: netip.MustParseAddr("198.51.100.2"), Port: 80, Family: ipvs.INET, FwdMethod: ipvs.DirectRoute, Weight: 1, UpperThreshold: 100, LowerThreshold: 50},
+				},
+			},
+			{
+				Service: ipvs.Service{
+					FWMark: 42, Family: ipvs.INET6, Scheduler: ipvs.MaglevHashing,
+					Flags: ipvs.MaglevPort | ipvs.ServicePersistent, Timeout: 300, Netmask: netmask.MaskFrom(64, 128),
+				},
+				Destinations: []ipvs.Destination{
+					{
+						Address: netip.MustParseAddr("198.51.100.3"), Family: ipvs.INET, FwdMethod: ipvs.Tunnel, Weight: 1,
+						TunnelType: ipvs.GUE, TunnelPort: 6080, TunnelFlags: ipvs.TunnelEncapRemoteChecksum,
+					},
+				},
+			},
+			{
+				Service: ipvs.Service{
+					Address: netip.MustParseAddr("2001:db8::1"), Port: 53, Family: ipvs.INET6, Protocol: ipvs.UDP, Scheduler: ipvs.WeightedLeastConnection,
+					Flags: ipvs.ServicePersistent | ipvs.ServiceOnePacket, Timeout: 360, PEName: "sip",
+				},
+			},
+		}},
+		Timeouts: ipvs.Timeouts{TCP: 15 * time.Minute, UDP: 300 * time.Second},
+		Daemons: []ipvs.Daemon{
+			{State: ipvs.DaemonMaster, MulticastInterface: "eth0", SyncID: 1, MulticastGroup: netip.MustParseAddr("224.0.0.81")},
+		},
+	}
+	assert.DeepEqual(t, f, expected, cmpFile...)
+}
+
+func TestParse_JSON(t *testing.T) {
+	f, err := Parse([]byte(`{
+		"version": 1,
+		"services": [
+			{"protocol": "tcp", "address": "192.0.2.1", "port": 443, "destinations": [{"address": "198.51.100.1", "weight": 0}]}
+		]
+	}`))
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, f.Table, ipvs.TableConfig{Services: []ipvs.ServiceConfig{
+		{
+			Service: ipvs.Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 443, Family: ipvs.INET, Protocol: ipvs.TCP, Scheduler: ipvs.WeightedLeastConnection},
+			Destinations: []ipvs.Destination{
+				{Address: netip.MustParseAddr("198.51.100.1"), Port: 443, Family: ipvs.INET, FwdMethod: ipvs.DirectRoute},
+			},
+		},
+	}}, cmpFile...)
+}
+
+func TestParse_Errors(t *testing.T) {
+	type testCase struct {
+		name string
+		data string
+		errs []string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		_, err := Parse([]byte(tc.data))
+
+		var list ErrorList
+		assert.Assert(t, errors.As(err, &list), "error %v", err)
+
+		var msgs []string
+		for _, e := range list {
+			msgs = append(msgs, e.Error())
+		}
+		assert.DeepEqual(t, msgs, tc.errs)
+	}
+
+	testCases := []testCase{
+		{
+			name: "empty",
+			data: "",
+			errs: []string{"config: empty file"},
+		},
+		{
+			name: "syntax",
+			data: "version: [1",
+			errs: []string{"config: yaml: line 1: did not find expected ',' or ']'"},
+		},
+		{
+			name: "root",
+			data: "- 1",
+			errs: []string{"config: line 1: expected a mapping, got a list"},
+		},
+		{
+			name: "version",
+			data: "services: []",
+			errs: []string{"config: line 1: version: missing field"},
+		},
+		{
+			name: "unsupported version",
+			data: "version: 2",
+			errs: []string{"config: line 1: version: unsupported version 2, expected 1"},
+		},
+		{
+			name: "unknown and duplicate fields",
+			data: "version: 1\nversion: 1\nserivces: []",
+			errs: []string{
+				"config: line 2: version: duplicate field",
+				"config: line 3: serivces: unknown field",
+			},
+		},
+		{
+			name: "types",
+			data: `version: 1
+services:
+  - protocol: tcp
+    address: 192.0.2.1
+    port: "80"
+    one_packet: yes please
+    destinations: {}
+timeouts:
+  tcp: 1.5s
+  udp: -1
+`,
+			errs: []string{
+				"config: line 5: services[0].port: expected an integer, got a string",
+				"config: line 6: services[0].one_packet: expected a boolean, got a string",
+				"config: line 9: timeouts.tcp: duration 1.5s is not a whole number of seconds",
+				"config: line 10: timeouts.udp: invalid unsigned 32-bit integer -1",
+			},
+		},
+		{
+			name: "values",
+			data: `version: 1
+services:
+  - protocol: icmp
+    address: 192.0.2.300
+  - fwmark: 1
+    scheduler: nope
+  - fwmark: 2
+    address: 192.0.2.1
+  - scheduler: rr
+`,
+			errs: []string{
+				`config: line 3: services[0].protocol: unknown protocol "icmp"`,
+				`config: line 4: services[0].address: invalid IP address "192.0.2.300"`,
+				"config: line 3: services[0]: requires either protocol and address, or fwmark",
+				`config: line 6: services[1].scheduler: unknown scheduler "nope"`,
+				"config: line 7: services[2]: fwmark excludes protocol, address and port",
+				"config: line 9: services[3]: requires either protocol and address, or fwmark",
+			},
+		},
+		{
+			name: "validation",
+			data: `version: 1
+services:
+  - protocol: tcp
+    address: 192.0.2.1
+    port: 80
+    family: inet6
+  - protocol: tcp
+    address: 192.0.2.2
+    port: 80
+    destinations:
+      - port: 8080
+      - address: 2001:db8::1
+      - address: 198.51.100.1
+        upper_threshold: 1
+        lower_threshold: 2
+      - address: 198.51.100.2
+      - address: 198.51.100.2
+  - protocol: tcp
+    address: 192.0.2.2
+    port: 80
+daemons:
+  - interface: eth0
+  - state: backup
+    interface: eth0
+    group: 192.0.2.1
+`,
+			errs: []string{
+				"config: line 3: services[0].family: family INET6 does not match address 192.0.2.1",
+				"config: line 11: services[1].destinations[0].address: missing field",
+				"config: line 12: services[1].destinations[1]: ipvs: destination family INET6 differs from service family INET, which requires the Tunnel forwarding method",
+				"config: line 13: services[1].destinations[2]: ipvs: lower threshold 2 exceeds upper threshold 1",
+				"config: line 17: services[1].destinations[4]: destination 198.51.100.2:80 already defined at services[1].destinations[3]",
+				"config: line 18: services[2]: service TCP 192.0.2.2:80 already defined at services[1]",
+				"config: line 22: daemons[0].state: missing field",
+				"config: line 23: daemons[1]: ipvs: multicast group 192.0.2.1 is not a multicast address",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -0,0 +1,586 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudflare/ipvs"
+	"github.com/cloudflare/ipvs/netmask"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultPersistence is the persistence timeout of services which do not
+// set one, as in ipvsadm.
+const defaultPersistence = 360 * time.Second
+
+// decoder decodes a file, collecting the errors found.
+type decoder struct {
+	errs ErrorList
+}
+
+// fields maps the names of the fields of a mapping to the functions
+// decoding their value at a path.
+type fields map[string]func(n *yaml.Node, path string)
+
+// errorf records an error about the value n at path.
+func (d *decoder) errorf(n *yaml.Node, path, format string, args ...any) {
+	d.errs = append(d.errs, &Error{Path: path, Line: n.Line, Err: fmt.Errorf(format, args...)})
+}
+
+// mapping decodes the mapping n at path with fs, ignoring null values. It
+// reports whether n is a mapping.
+func (d *decoder) mapping(n *yaml.Node, path string, fs fields) bool {
+	if n.Kind != yaml.MappingNode {
+		d.errorf(n, path, "expected a mapping, got %s", describe(n))
+		return false
+	}
+
+	seen := make(map[string]bool, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], resolve(n.Content[i+1])
+
+		p := k.Value
+		if path != "" {
+			p = path + "." + k.Value
+		}
+
+		switch {
+		case seen[k.Value]:
+			d.errorf(k, p, "duplicate field")
+		case fs[k.Value] == nil:
+			d.errorf(k, p, "unknown field")
+		case v.ShortTag() == "!!null":
+		default:
+			fs[k.Value](v, p)
+		}
+		seen[k.Value] = true
+	}
+
+	return true
+}
+
+// sequence decodes each item of the sequence n at path with fn.
+func (d *decoder) sequence(n *yaml.Node, path string, fn func(n *yaml.Node, path string)) {
+	if n.Kind != yaml.SequenceNode {
+		d.errorf(n, path, "expected a list, got %s", describe(n))
+		return
+	}
+
+	for i, item := range n.Content {
+		fn(resolve(item), fmt.Sprintf("%s[%d]", path, i))
+	}
+}
+
+// scalar returns the value of the scalar n with the tag, such as "!!str".
+func (d *decoder) scalar(n *yaml.Node, path, tag string) (string, bool) {
+	if n.Kind != yaml.ScalarNode || n.ShortTag() != tag {
+		d.errorf(n, path, "expected %s, got %s", describeTag(tag), describe(n))
+		return "", false
+	}
+
+	return n.Value, true
+}
+
+// str decodes the string n.
+func (d *decoder) str(n *yaml.Node, path string) (string, bool) {
+	return d.scalar(n, path, "!!str")
+}
+
+// unsigned decodes the unsigned integer n of bitSize bits.
+func (d *decoder) unsigned(n *yaml.Node, path string, bitSize int) (uint64, bool) {
+	s, ok := d.scalar(n, path, "!!int")
+	if !ok {
+		return 0, false
+	}
+
+	v, err := strconv.ParseUint(s, 10, bitSize)
+	if err != nil {
+		d.errorf(n, path, "invalid unsigned %d-bit integer %s", bitSize, s)
+		return 0, false
+	}
+
+	return v, true
+}
+
+// duration decodes the duration n, either integer seconds or a duration
+// string, as a non-negative whole number of seconds.
+func (d *decoder) duration(n *yaml.Node, path string) (time.Duration, bool) {
+	if n.ShortTag() == "!!int" {
+		v, ok := d.unsigned(n, path, 32)
+		return time.Duration(v) * time.Second, ok
+	}
+
+	s, ok := d.scalar(n, path, "!!str")
+	if !ok {
+		return 0, false
+	}
+
+	v, err := time.ParseDuration(s)
+	switch {
+	case err != nil:
+		d.errorf(n, path, "invalid duration %q", s)
+		return 0, false
+	case v < 0 || v%time.Second != 0 || v/time.Second > 1<<32-1:
+		d.errorf(n, path, "duration %s is not a whole number of seconds", v)
+		return 0, false
+	}
+
+	return v, true
+}
+
+// addr decodes the IP address n.
+func (d *decoder) addr(n *yaml.Node, path string) (netip.Addr, bool) {
+	s, ok := d.str(n, path)
+	if !ok {
+		return netip.Addr{}, false
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		d.errorf(n, path, "invalid IP address %q", s)
+		return netip.Addr{}, false
+	}
+
+	return addr, true
+}
+
+// enum decodes the string n as one of the values of names.
+func enum[T any](d *decoder, n *yaml.Node, path, what string, names map[string]T) (T, bool) {
+	var zero T
+	s, ok := d.str(n, path)
+	if !ok {
+		return zero, false
+	}
+
+	v, ok := names[strings.ToLower(s)]
+	if !ok {
+		d.errorf(n, path, "unknown %s %q", what, s)
+		return zero, false
+	}
+
+	return v, true
+}
+
+// uintField returns a field decoding an unsigned integer of bitSize bits
+// into dst.
+func uintField[T uint8 | uint16 | uint32](d *decoder, dst *T, bitSize int) func(*yaml.Node, string) {
+	return func(n *yaml.Node, path string) {
+		if v, ok := d.unsigned(n, path, bitSize); ok {
+			*dst = T(v)
+		}
+	}
+}
+
+// stringField returns a field decoding a string into dst.
+func (d *decoder) stringField(dst *string) func(*yaml.Node, string) {
+	return func(n *yaml.Node, path string) {
+		if v, ok := d.str(n, path); ok {
+			*dst = v
+		}
+	}
+}
+
+// durationField returns a field decoding a duration into dst.
+func (d *decoder) durationField(dst *time.Duration) func(*yaml.Node, string) {
+	return func(n *yaml.Node, path string) {
+		if v, ok := d.duration(n, path); ok {
+			*dst = v
+		}
+	}
+}
+
+// file decodes the root n of a file.
+func (d *decoder) file(n *yaml.Node) *File {
+	var (
+		f        File
+		version  bool
+		services = make(map[ipvs.ServiceKey]string)
+		daemons  = make(map[ipvs.DaemonState]string)
+	)
+
+	ok := d.mapping(resolve(n), "", fields{
+		"version": func(n *yaml.Node, path string) {
+			v, ok := d.unsigned(n, path, 32)
+			if !ok {
+				return
+			}
+			version = true
+			if v != Version {
+				d.errorf(n, path, "unsupported version %d, expected %d", v, Version)
+			}
+			f.Version = int(v)
+		},
+		"services": func(n *yaml.Node, path string) {
+			d.sequence(n, path, func(n *yaml.Node, path string) {
+				sc, ok := d.service(n, path)
+				if !ok {
+					return
+				}
+
+				key := sc.Normalize().Key()
+				if prev, ok := services[key]; ok {
+					d.errorf(n, path, "service %s already defined at %s", key, prev)
+					return
+				}
+				services[key] = path
+
+				f.Table.Services = append(f.Table.Services, sc)
+			})
+		},
+		"timeouts": func(n *yaml.Node, path string) {
+			d.mapping(n, path, fields{
+				"tcp":     d.durationField(&f.Timeouts.TCP),
+				"tcp_fin": d.durationField(&f.Timeouts.TCPFin),
+				"udp":     d.durationField(&f.Timeouts.UDP),
+			})
+		},
+		"daemons": func(n *yaml.Node, path string) {
+			d.sequence(n, path, func(n *yaml.Node, path string) {
+				dm, ok := d.daemon(n, path)
+				if !ok {
+					return
+				}
+
+				if prev, ok := daemons[dm.State]; ok {
+					d.errorf(n, path, "%s daemon already defined at %s", dm.State, prev)
+					return
+				}
+				daemons[dm.State] = path
+
+				f.Daemons = append(f.Daemons, dm)
+			})
+		},
+	})
+	if ok && !version {
+		d.errorf(n, "version", "missing field")
+	}
+
+	return &f
+}
+
+var (
+	protocols = map[string]ipvs.Protocol{
+		"tcp":  ipvs.TCP,
+		"udp":  ipvs.UDP,
+		"sctp": ipvs.SCTP,
+	}
+	families = map[string]ipvs.AddressFamily{
+		"inet":  ipvs.INET,
+		"inet6": ipvs.INET6,
+	}
+	schedulerFlags = map[string]ipvs.Flags{
+		"flag-1":      ipvs.ServiceSchedulerOpt1,
+		"flag-2":      ipvs.ServiceSchedulerOpt2,
+		"flag-3":      ipvs.ServiceSchedulerOpt3,
+		"sh-fallback": ipvs.SourceHashFallback,
+		"sh-port":     ipvs.SourceHashPort,
+		"mh-fallback": ipvs.MaglevFallback,
+		"mh-port":     ipvs.MaglevPort,
+	}
+	forwardings = map[string]ipvs.ForwardType{
+		"route":      ipvs.DirectRoute,
+		"masquerade": ipvs.Masquerade,
+		"tunnel":     ipvs.Tunnel,
+		"local":      ipvs.Local,
+	}
+	tunnelTypes = map[string]ipvs.TunnelType{
+		"ipip": ipvs.IPIP,
+		"gue":  ipvs.GUE,
+		"gre":  ipvs.GRE,
+	}
+	tunnelChecksums = map[string]ipvs.TunnelFlags{
+		"none":     ipvs.TunnelEncapNoChecksum,
+		"checksum": ipvs.TunnelEncapChecksum,
+		"remote":   ipvs.TunnelEncapRemoteChecksum,
+	}
+	daemonStates = map[string]ipvs.DaemonState{
+		"master": ipvs.DaemonMaster,
+		"backup": ipvs.DaemonBackup,
+	}
+)
+
+// service decodes the service n at path, reporting whether the service is
+// valid, not counting its destinations.
+func (d *decoder) service(n *yaml.Node, path string) (ipvs.ServiceConfig, bool) {
+	var (
+		errs  = len(d.errs)
+		sc    = ipvs.ServiceConfig{Service: ipvs.Service{Scheduler: ipvs.WeightedLeastConnection}}
+		svc   = &sc.Service
+		mask  *yaml.Node
+		dests *yaml.Node
+	)
+
+	ok := d.mapping(n, path, fields{
+		"protocol": func(n *yaml.Node, path string) {
+			svc.Protocol, _ = enum(d, n, path, "protocol", protocols)
+		},
+		"address": func(n *yaml.Node, path string) {
+			svc.Address, _ = d.addr(n, path)
+		},
+		"port":   uintField(d, &svc.Port, 16),
+		"fwmark": uintField(d, &svc.FWMark, 32),
+		"family": func(n *yaml.Node, path string) {
+			svc.Family, _ = enum(d, n, path, "family", families)
+		},
+		"scheduler": func(n *yaml.Node, path string) {
+			s, ok := d.str(n, path)
+			if !ok {
+				return
+			}
+			if !ipvs.Scheduler(s).IsValid() {
+				d.errorf(n, path, "unknown scheduler %q", s)
+				return
+			}
+			svc.Scheduler = ipvs.Scheduler(s)
+		},
+		"scheduler_flags": func(n *yaml.Node, path string) {
+			d.sequence(n, path, func(n *yaml.Node, path string) {
+				flag, _ := enum(d, n, path, "scheduler flag", schedulerFlags)
+				svc.Flags |= flag
+			})
+		},
+		"persistence": func(n *yaml.Node, path string) {
+			svc.Flags |= ipvs.ServicePersistent
+			timeout := defaultPersistence
+			d.mapping(n, path, fields{
+				"timeout": d.durationField(&timeout),
+				"netmask": func(n *yaml.Node, path string) {
+					mask = n
+				},
+			})
+			svc.Timeout = uint32(timeout / time.Second)
+		},
+		"pe": d.stringField(&svc.PEName),
+		"one_packet": func(n *yaml.Node, path string) {
+			if s, ok := d.scalar(n, path, "!!bool"); ok && s != "false" {
+				svc.Flags |= ipvs.ServiceOnePacket
+			}
+		},
+		"owner": d.stringField(&sc.Owner),
+		"destinations": func(n *yaml.Node, path string) {
+			dests = n
+		},
+	})
+	if !ok {
+		return sc, false
+	}
+
+	switch {
+	case svc.FWMark != 0 && (svc.Protocol != 0 || svc.Address.IsValid() || svc.Port != 0):
+		d.errorf(n, path, "fwmark excludes protocol, address and port")
+	case svc.FWMark != 0:
+		if svc.Family == 0 {
+			svc.Family = ipvs.INET
+		}
+	case !svc.Address.IsValid() || svc.Protocol == 0:
+		d.errorf(n, path, "requires either protocol and address, or fwmark")
+	default:
+		family := ipvs.INET
+		if svc.Address.Is6() && !svc.Address.Is4In6() {
+			family = ipvs.INET6
+		}
+		if svc.Family != 0 && svc.Family != family {
+			d.errorf(n, path+".family", "family %s does not match address %s", svc.Family, svc.Address)
+		}
+		svc.Family = family
+	}
+
+	if mask != nil {
+		d.netmask(mask, path+".persistence.netmask", svc)
+	}
+
+	if len(d.errs) > errs {
+		return sc, false
+	}
+
+	if err := svc.Validate(); err != nil {
+		d.errorf(n, path, "%v", err)
+		return sc, false
+	}
+
+	if dests != nil {
+		seen := make(map[netip.AddrPort]string)
+		d.sequence(dests, path+".destinations", func(n *yaml.Node, path string) {
+			dest, ok := d.destination(n, path, *svc)
+			if !ok {
+				return
+			}
+
+			ap := netip.AddrPortFrom(dest.Address, dest.Port)
+			if prev, ok := seen[ap]; ok {
+				d.errorf(n, path, "destination %s already defined at %s", ap, prev)
+				return
+			}
+			seen[ap] = path
+
+			sc.Destinations = append(sc.Destinations, dest)
+		})
+	}
+
+	// Errors of the destinations are recorded, but the service itself is
+	// valid.
+	return sc, true
+}
+
+// netmask decodes the persistence netmask n of svc, where IPv6 masks may be
+// given as an integer prefix length.
+func (d *decoder) netmask(n *yaml.Node, path string, svc *ipvs.Service) {
+	var s string
+	if n.ShortTag() == "!!int" && svc.Family == ipvs.INET6 {
+		s = n.Value
+	} else if v, ok := d.str(n, path); ok {
+		s = v
+	} else {
+		return
+	}
+
+	mask, err := netmask.ParseMask(s)
+	if err != nil {
+		d.errorf(n, path, "invalid netmask %q", s)
+		return
+	}
+
+	svc.Netmask = mask
+}
+
+// destination decodes the destination n of svc at path, reporting whether
+// it is valid.
+func (d *decoder) destination(n *yaml.Node, path string, svc ipvs.Service) (ipvs.Destination, bool) {
+	errs := len(d.errs)
+	dest := ipvs.Destination{
+		Port:      svc.Port,
+		FwdMethod: ipvs.DirectRoute,
+		Weight:    1,
+	}
+
+	ok := d.mapping(n, path, fields{
+		"address": func(n *yaml.Node, path string) {
+			dest.Address, _ = d.addr(n, path)
+		},
+		"port": uintField(d, &dest.Port, 16),
+		"forward": func(n *yaml.Node, path string) {
+			dest.FwdMethod, _ = enum(d, n, path, "forwarding method", forwardings)
+		},
+		"weight":          uintField(d, &dest.Weight, 32),
+		"upper_threshold": uintField(d, &dest.UpperThreshold, 32),
+		"lower_threshold": uintField(d, &dest.LowerThreshold, 32),
+		"tunnel": func(n *yaml.Node, path string) {
+			d.mapping(n, path, fields{
+				"type": func(n *yaml.Node, path string) {
+					dest.TunnelType, _ = enum(d, n, path, "tunnel type", tunnelTypes)
+				},
+				"port": uintField(d, &dest.TunnelPort, 16),
+				"checksum": func(n *yaml.Node, path string) {
+					dest.TunnelFlags, _ = enum(d, n, path, "tunnel checksum", tunnelChecksums)
+				},
+			})
+		},
+	})
+	if !ok {
+		return dest, false
+	}
+
+	if !dest.Address.IsValid() {
+		d.errorf(n, path+".address", "missing field")
+	}
+
+	if len(d.errs) > errs {
+		return dest, false
+	}
+
+	dest = dest.Normalize()
+	if err := dest.Validate(svc); err != nil {
+		d.errorf(n, path, "%v", err)
+		return dest, false
+	}
+
+	return dest, true
+}
+
+// daemon decodes the synchronization daemon n at path, reporting whether it
+// is valid.
+func (d *decoder) daemon(n *yaml.Node, path string) (ipvs.Daemon, bool) {
+	var (
+		errs = len(d.errs)
+		dm   ipvs.Daemon
+	)
+
+	ok := d.mapping(n, path, fields{
+		"state": func(n *yaml.Node, path string) {
+			dm.State, _ = enum(d, n, path, "daemon state", daemonStates)
+		},
+		"interface": d.stringField(&dm.MulticastInterface),
+		"sync_id":   uintField(d, &dm.SyncID, 8),
+		"max_len":   uintField(d, &dm.SyncMaxLen, 16),
+		"group": func(n *yaml.Node, path string) {
+			dm.MulticastGroup, _ = d.addr(n, path)
+		},
+		"port": uintField(d, &dm.MulticastPort, 16),
+		"ttl":  uintField(d, &dm.MulticastTTL, 8),
+	})
+	if !ok {
+		return dm, false
+	}
+
+	if dm.State == 0 {
+		d.errorf(n, path+".state", "missing field")
+	}
+	if dm.MulticastInterface == "" {
+		d.errorf(n, path+".interface", "missing field")
+	}
+
+	if len(d.errs) > errs {
+		return dm, false
+	}
+
+	if err := dm.Validate(); err != nil {
+		d.errorf(n, path, "%v", err)
+		return dm, false
+	}
+
+	return dm, true
+}
+
+// resolve returns the node aliased by n, if n is an alias.
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+
+	return n
+}
+
+// describe returns the kind of value of n, for errors.
+func describe(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.ScalarNode:
+		return describeTag(n.ShortTag())
+	}
+
+	return "an unexpected value"
+}
+
+// describeTag returns the kind of value of the scalars of tag.
+func describeTag(tag string) string {
+	switch tag {
+	case "!!str":
+		return "a string"
+	case "!!int":
+		return "an integer"
+	case "!!bool":
+		return "a boolean"
+	case "!!float":
+		return "a number"
+	case "!!null":
+		return "null"
+	}
+
+	return "a " + strings.TrimPrefix(tag, "!!")
+}
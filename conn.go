@@ -0,0 +1,40 @@
+package ipvs
+
+import (
+	"time"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+// Conn is the transport a Client sends its generic netlink requests over,
+// and receives their replies from. Dumps are requests with the netlink.Dump
+// flag, whose replies are all returned by a single Receive.
+//
+// *genetlink.Conn implements Conn, and is what NewClient uses. Other
+// implementations, such as one recording the requests made or an in-memory
+// fake of IPVS, can be used with NewClientConn.
+type Conn interface {
+	// GetFamily returns the generic netlink family with name, which is
+	// called once for the IPVS family when creating the Client.
+	GetFamily(name string) (genetlink.Family, error)
+
+	// Send sends the request m to family with flags, returning the netlink
+	// message sent, whose sequence number the replies must match.
+	Send(m genetlink.Message, family uint16, flags netlink.HeaderFlags) (netlink.Message, error)
+
+	// Receive returns the replies to the oldest pending request, both
+	// decoded and as netlink messages. Errors returned by IPVS are reported
+	// as a *netlink.OpError wrapping the errno.
+	Receive() ([]genetlink.Message, []netlink.Message, error)
+
+	// SetDeadline sets the deadline of Send and Receive, with the zero time
+	// meaning no deadline. It may be called concurrently with Receive, to
+	// abort it when the context of a request is cancelled.
+	SetDeadline(t time.Time) error
+
+	// Close closes the transport.
+	Close() error
+}
+
+var _ Conn = (*genetlink.Conn)(nil)
@@ -0,0 +1,211 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/genetlink/genltest"
+	"github.com/mdlayher/netlink"
+	"gotest.tools/v3/assert"
+)
+
+// fakeConn is an in-memory Conn, acknowledging every request except those
+// failing with the errno in errs.
+type fakeConn struct {
+	errs    map[uint8]syscall.Errno
+	seq     uint32
+	pending []fakeReply
+	closed  bool
+}
+
+// fakeReply is the reply of fakeConn to a request.
+type fakeReply struct {
+	msg   netlink.Message
+	errno syscall.Errno
+}
+
+func (c *fakeConn) GetFamily(name string) (genetlink.Family, error) {
+	if name != cipvs.GenlName {
+		return genetlink.Family{}, os.ErrNotExist
+	}
+
+	return genetlink.Family{ID: familyID, Version: cipvs.GenlVersion, Name: name}, nil
+}
+
+func (c *fakeConn) Send(m genetlink.Message, family uint16, flags netlink.HeaderFlags) (netlink.Message, error) {
+	c.seq++
+	req := netlink.Message{
+		Header: netlink.Header{Type: netlink.HeaderType(family), Flags: flags, Sequence: c.seq, PID: 1},
+	}
+
+	reply := fakeReply{msg: netlink.Message{Header: req.Header}, errno: c.errs[m.Header.Command]}
+	reply.msg.Header.Type = netlink.Error
+	c.pending = append(c.pending, reply)
+
+	return req, nil
+}
+
+func (c *fakeConn) Receive() ([]genetlink.Message, []netlink.Message, error) {
+	if len(c.pending) == 0 {
+		return nil, nil, errors.New("no pending request")
+	}
+
+	reply := c.pending[0]
+	c.pending = c.pending[1:]
+	if reply.errno != 0 {
+		return nil, nil, &netlink.OpError{Op: "receive", Err: reply.errno}
+	}
+
+	return []genetlink.Message{{}}, []netlink.Message{reply.msg}, nil
+}
+
+func (c *fakeConn) SetDeadline(time.Time) error { return nil }
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+// recordingConn is a Conn recording the commands sent over it.
+type recordingConn struct {
+	Conn
+	commands []uint8
+}
+
+func (c *recordingConn) Send(m genetlink.Message, family uint16, flags netlink.HeaderFlags) (netlink.Message, error) {
+	c.commands = append(c.commands, m.Header.Command)
+	return c.Conn.Send(m, family, flags)
+}
+
+func TestNewClientConn(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+
+	fake := &fakeConn{errs: map[uint8]syscall.Errno{cipvs.CmdNewDest: syscall.EEXIST}}
+	conn := &recordingConn{Conn: fake}
+
+	c, err := NewClientConn(conn)
+	assert.NilError(t, err)
+
+	assert.NilError(t, c.CreateService(context.Background(), svc))
+	err = c.CreateDestination(context.Background(), svc, dest)
+	assert.Assert(t, errors.Is(err, ErrDestinationExists))
+	assert.NilError(t, c.RemoveService(context.Background(), svc))
+
+	assert.DeepEqual(t, conn.commands, []uint8{cipvs.CmdNewService, cipvs.CmdNewDest, cipvs.CmdDelService})
+	assert.NilError(t, c.Close())
+	assert.Assert(t, fake.closed)
+}
+
+func TestNewClientConn_Recording(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		return []genetlink.Message{{}}, nil
+	}
+	family := genetlink.Family{ID: familyID, Version: cipvs.GenlVersion, Name: cipvs.GenlName}
+	conn := &recordingConn{Conn: genltest.Dial(genltest.ServeFamily(family, fn))}
+
+	c, err := NewClientConn(conn)
+	assert.NilError(t, err)
+	defer c.Close()
+
+	assert.NilError(t, c.Flush(context.Background()))
+	assert.DeepEqual(t, conn.commands, []uint8{cipvs.CmdFlush})
+}
+
+func TestNewClientConn_NotLoaded(t *testing.T) {
+	fake := &fakeConn{}
+	_, err := NewClientConn(&familyConn{Conn: fake})
+	assert.Assert(t, errors.Is(err, ErrNotLoaded))
+	assert.Assert(t, fake.closed)
+}
+
+// familyConn is a Conn missing the IPVS family.
+type familyConn struct {
+	Conn
+}
+
+func (c *familyConn) GetFamily(string) (genetlink.Family, error) {
+	return genetlink.Family{}, os.ErrNotExist
+}
+
+// overrunConn is a Conn whose Receive returns the errors of script in turn,
+// replying to the oldest pending request when the error is nil, and timing
+// out once the script is exhausted.
+type overrunConn struct {
+	fakeConn
+	script    []error
+	sends     int
+	deadlines []time.Time
+}
+
+func (c *overrunConn) Send(m genetlink.Message, family uint16, flags netlink.HeaderFlags) (netlink.Message, error) {
+	c.sends++
+	return c.fakeConn.Send(m, family, flags)
+}
+
+func (c *overrunConn) Receive() ([]genetlink.Message, []netlink.Message, error) {
+	if len(c.script) == 0 {
+		return nil, nil, &netlink.OpError{Op: "receive", Err: os.ErrDeadlineExceeded}
+	}
+
+	err := c.script[0]
+	c.script = c.script[1:]
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.fakeConn.Receive()
+}
+
+func (c *overrunConn) SetDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+func TestClient_DumpOverrun(t *testing.T) {
+	overrun := &netlink.OpError{Op: "receive", Err: os.NewSyscallError("recvmsg", syscall.ENOBUFS)}
+	timeout := &netlink.OpError{Op: "receive", Err: os.ErrDeadlineExceeded}
+
+	// The first dump overruns, leaving its reply to drain.
+	conn := &overrunConn{script: []error{overrun, nil, timeout, nil}}
+	c, err := NewClientConn(conn)
+	assert.NilError(t, err)
+	defer c.Close()
+
+	svcs, err := c.Services(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(svcs), 1)
+	assert.Equal(t, conn.sends, 2)
+	assert.Equal(t, c.Metrics().Retries, uint64(1))
+	assert.Assert(t, conn.deadlines[len(conn.deadlines)-1].IsZero(), "deadline not restored")
+
+	// Dumps overrunning every time fail after dumpResyncs resyncs.
+	conn = &overrunConn{script: []error{overrun, nil, timeout, overrun, nil, timeout, overrun, nil, timeout, overrun}}
+	c, err = NewClientConn(conn)
+	assert.NilError(t, err)
+	defer c.Close()
+
+	_, err = c.Services(context.Background())
+	assert.Assert(t, errors.Is(err, syscall.ENOBUFS), "got error: %v", err)
+	assert.Equal(t, conn.sends, dumpResyncs+1)
+
+	// Other requests are not made again.
+	conn = &overrunConn{script: []error{overrun}}
+	c, err = NewClientConn(conn)
+	assert.NilError(t, err)
+	defer c.Close()
+
+	err = c.Flush(context.Background())
+	assert.Assert(t, errors.Is(err, syscall.ENOBUFS), "got error: %v", err)
+	assert.Equal(t, conn.sends, 1)
+}
@@ -0,0 +1,277 @@
+package ipvs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Connection is an entry of the IPVS connection table, which tracks the
+// Destination chosen for each flow. Persistence templates, which pin a
+// client to a Destination, are listed as connections as well.
+type Connection struct {
+	Protocol Protocol
+	// Client is the address the connection originates from.
+	Client netip.AddrPort
+	// Virtual is the address of the Service the client connected to.
+	Virtual netip.AddrPort
+	// Destination is the address of the real server.
+	Destination netip.AddrPort
+	// State is the name of the protocol state, such as "ESTABLISHED" or
+	// "FIN_WAIT" for TCP.
+	State string
+	// Expires is the time until the connection expires, unless refreshed by
+	// new packets.
+	Expires time.Duration
+	// PEName and PEData are the persistence engine of the connection, and
+	// the data it is pinned by, such as the Call-ID of SIP.
+	PEName string
+	PEData string
+	// Synced reports whether the connection was received from a master
+	// synchronization daemon, rather than created locally.
+	Synced bool
+}
+
+// ConnectionSummary holds the connection counts of a Service, keyed by the
+// address of each real server.
+type ConnectionSummary map[netip.AddrPort]ConnectionCounts
+
+// ConnectionCounts aggregates the connections to a real server.
+type ConnectionCounts struct {
+	// Total is the number of connections, including persistence templates.
+	Total int
+	// States counts the connections by the name of their protocol state,
+	// such as "ESTABLISHED" or "FIN_WAIT". Persistence templates are in
+	// the "NONE" state.
+	States map[string]int
+	// MaxExpires is the longest time until one of the connections expires,
+	// bounding how long the real server stays in use once it no longer
+	// receives new connections.
+	MaxExpires time.Duration
+}
+
+// add counts conn.
+func (s ConnectionSummary) add(conn Connection) {
+	counts := s[conn.Destination]
+	if counts.States == nil {
+		counts.States = map[string]int{}
+	}
+
+	counts.Total++
+	counts.States[conn.State]++
+	if conn.Expires > counts.MaxExpires {
+		counts.MaxExpires = conn.Expires
+	}
+
+	s[conn.Destination] = counts
+}
+
+// ConnectionOption filters the Connections returned by Client.Connections.
+type ConnectionOption func(*connectionOptions)
+
+// connectionOptions holds the filters built from a list of
+// ConnectionOptions.
+type connectionOptions struct {
+	services []Service
+	dests    []Destination
+}
+
+// buildConnectionOptions applies opts on top of the defaults, which match
+// every Connection.
+func buildConnectionOptions(opts []ConnectionOption) connectionOptions {
+	var o connectionOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// match reports whether conn passes the filters.
+func (o connectionOptions) match(conn Connection) bool {
+	return o.matchService(conn) && o.matchDestination(conn)
+}
+
+// matchService reports whether conn belongs to any of the Services.
+func (o connectionOptions) matchService(conn Connection) bool {
+	if o.services == nil {
+		return true
+	}
+
+	for _, svc := range o.services {
+		if svc.FWMark == 0 && svc.Protocol == conn.Protocol &&
+			svc.Address.Unmap() == conn.Virtual.Addr() && svc.Port == conn.Virtual.Port() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchDestination reports whether conn goes to any of the Destinations.
+func (o connectionOptions) matchDestination(conn Connection) bool {
+	if o.dests == nil {
+		return true
+	}
+
+	for _, dest := range o.dests {
+		if dest.Address.Unmap() == conn.Destination.Addr() && dest.Port == conn.Destination.Port() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchService only lists Connections to the Service svc. It can be repeated
+// to list the Connections of several Services. The connection table does not
+// record firewall marks, so firewall mark Services never match.
+func MatchService(svc Service) ConnectionOption {
+	return func(o *connectionOptions) {
+		o.services = append(o.services, svc)
+	}
+}
+
+// MatchDestination only lists Connections to the real server of dest, by its
+// address and port. It can be repeated to list the Connections of several
+// Destinations.
+func MatchDestination(dest Destination) ConnectionOption {
+	return func(o *connectionOptions) {
+		o.dests = append(o.dests, dest)
+	}
+}
+
+// connectionKey identifies a Connection across the connection tables.
+type connectionKey struct {
+	protocol                     Protocol
+	client, virtual, destination netip.AddrPort
+}
+
+// key returns the identity of conn.
+func (conn Connection) key() connectionKey {
+	return connectionKey{conn.Protocol, conn.Client, conn.Virtual, conn.Destination}
+}
+
+// parseConnections parses the connection table in the format of
+// /proc/net/ip_vs_conn, calling fn for each Connection. The format of
+// /proc/net/ip_vs_conn_sync is parsed when sync is set, which reports the
+// origin of each connection instead of its persistence engine.
+func parseConnections(r io.Reader, sync bool, fn func(Connection)) error {
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		// The first line is the header.
+		if line == 1 {
+			continue
+		}
+
+		conn, err := parseConnection(strings.Fields(s.Text()), sync)
+		if err != nil {
+			return fmt.Errorf("ipvs: parsing connection table line %d: %w", line, err)
+		}
+
+		fn(conn)
+	}
+
+	return s.Err()
+}
+
+// parseConnection parses the fields of a line of the connection table.
+func parseConnection(fields []string, sync bool) (Connection, error) {
+	var conn Connection
+
+	if len(fields) < 9 {
+		return conn, fmt.Errorf("%d fields, want at least 9", len(fields))
+	}
+
+	var err error
+	if conn.Protocol, err = parseConnectionProtocol(fields[0]); err != nil {
+		return conn, err
+	}
+
+	for i, ap := range []*netip.AddrPort{&conn.Client, &conn.Virtual, &conn.Destination} {
+		if *ap, err = parseConnectionAddrPort(fields[1+2*i], fields[2+2*i]); err != nil {
+			return conn, err
+		}
+	}
+
+	conn.State = fields[7]
+
+	expires := fields[8]
+	if sync {
+		if len(fields) != 10 {
+			return conn, fmt.Errorf("%d fields, want 10", len(fields))
+		}
+
+		conn.Synced = fields[8] == "SYNC"
+		expires = fields[9]
+	} else if len(fields) > 9 {
+		conn.PEName = fields[9]
+		conn.PEData = strings.Join(fields[10:], " ")
+	}
+
+	seconds, err := strconv.ParseUint(expires, 10, 32)
+	if err != nil {
+		return conn, err
+	}
+	conn.Expires = time.Duration(seconds) * time.Second
+
+	return conn, nil
+}
+
+// parseConnectionProtocol parses the name of a protocol, which is "IP" for
+// the persistence templates of firewall mark Services, and "IP_<n>" for
+// protocols unknown to IPVS.
+func parseConnectionProtocol(s string) (Protocol, error) {
+	switch s {
+	case "IP":
+		return 0, nil
+	case "TCP":
+		return TCP, nil
+	case "UDP":
+		return UDP, nil
+	case "SCTP":
+		return SCTP, nil
+	case "ICMP":
+		return 0x01, nil
+	case "ICMPv6":
+		return 0x3A, nil
+	}
+
+	if strings.HasPrefix(s, "IP_") {
+		p, err := strconv.ParseUint(strings.TrimPrefix(s, "IP_"), 10, 8)
+		if err == nil {
+			return Protocol(p), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown protocol %q", s)
+}
+
+// parseConnectionAddrPort parses an address, either IPv6 or IPv4 as 8 hex
+// digits, and a port as 4 hex digits.
+func parseConnectionAddrPort(addr, port string) (netip.AddrPort, error) {
+	var a netip.Addr
+	if strings.Contains(addr, ":") {
+		var err error
+		if a, err = netip.ParseAddr(addr); err != nil {
+			return netip.AddrPort{}, err
+		}
+	} else {
+		v, err := strconv.ParseUint(addr, 16, 32)
+		if err != nil || len(addr) != 8 {
+			return netip.AddrPort{}, fmt.Errorf("invalid address %q", addr)
+		}
+		a = netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+	}
+
+	p, err := strconv.ParseUint(port, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid port %q", port)
+	}
+
+	return netip.AddrPortFrom(a, uint16(p)), nil
+}
@@ -0,0 +1,110 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// procNetDir is the directory of the IPVS tables in /proc. Unlike /proc/net,
+// which follows the namespace of the main thread, it is that of the network
+// namespace of the calling thread, so that inNetNS switches it.
+const procNetDir = "/proc/thread-self/net"
+
+// Connections returns the entries of the connection table matching opts. The
+// table is that of the network namespace of c.
+//
+// IPVS does not expose the connection table over netlink, so it is parsed
+// from /proc/net/ip_vs_conn, and the origin of each connection from
+// /proc/net/ip_vs_conn_sync. The tables are read one after another, so
+// connections created in between are not known to be synced.
+func (c *client) Connections(ctx context.Context, opts ...ConnectionOption) ([]Connection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var conns []Connection
+	err := inNetNS(c.opts, func() error {
+		var err error
+		conns, err = readConnections(procNetDir, buildConnectionOptions(opts))
+		return err
+	})
+	return conns, err
+}
+
+// ConnectionSummary counts the connections of svc per real server and state,
+// in the connection table of the network namespace of c. The table is
+// aggregated while it is parsed, so that its entries are never materialized.
+func (c *client) ConnectionSummary(ctx context.Context, svc Service) (ConnectionSummary, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var summary ConnectionSummary
+	err := inNetNS(c.opts, func() error {
+		var err error
+		summary, err = summarizeConnections(procNetDir, svc)
+		return err
+	})
+	return summary, err
+}
+
+// summarizeConnections implements ConnectionSummary with the table in dir.
+func summarizeConnections(dir string, svc Service) (ConnectionSummary, error) {
+	o := buildConnectionOptions([]ConnectionOption{MatchService(svc)})
+
+	summary := ConnectionSummary{}
+	err := readConnectionTable(filepath.Join(dir, "ip_vs_conn"), false, func(conn Connection) {
+		if o.match(conn) {
+			summary.add(conn)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// readConnections implements Connections with the tables in dir.
+func readConnections(dir string, o connectionOptions) ([]Connection, error) {
+	synced := map[connectionKey]bool{}
+	err := readConnectionTable(filepath.Join(dir, "ip_vs_conn_sync"), true, func(conn Connection) {
+		if conn.Synced && o.match(conn) {
+			synced[conn.key()] = true
+		}
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var conns []Connection
+	err = readConnectionTable(filepath.Join(dir, "ip_vs_conn"), false, func(conn Connection) {
+		if o.match(conn) {
+			conn.Synced = synced[conn.key()]
+			conns = append(conns, conn)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conns, nil
+}
+
+// readConnectionTable parses the connection table in the file name, calling
+// fn for each Connection.
+func readConnectionTable(name string, sync bool, fn func(Connection)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("ipvs: reading connection table: %w", err)
+	}
+	defer f.Close()
+
+	return parseConnections(f, sync, fn)
+}
@@ -0,0 +1,64 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReadConnections(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "ip_vs_conn"), []byte(testConnTable), 0o644))
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "ip_vs_conn_sync"), []byte(testConnSyncTable), 0o644))
+
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080}
+	actual, err := readConnections(dir, buildConnectionOptions([]ConnectionOption{MatchDestination(dest)}))
+	assert.NilError(t, err)
+	assert.Equal(t, len(actual), 1)
+	assert.Equal(t, actual[0].Client, netip.MustParseAddrPort("192.0.2.100:54000"))
+	assert.Assert(t, actual[0].Synced)
+
+	all, err := readConnections(dir, connectionOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, len(all), 5)
+	assert.Assert(t, !all[1].Synced)
+}
+
+func TestReadConnections_NotLoaded(t *testing.T) {
+	_, err := readConnections(t.TempDir(), connectionOptions{})
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestConnections_NetNSNotExist(t *testing.T) {
+	c := &client{opts: options{netNSPath: "/var/run/netns/does-not-exist"}}
+
+	_, err := c.Connections(context.Background())
+	assert.ErrorContains(t, err, "ipvs: opening network namespace")
+
+	_, err = c.ConnectionSummary(context.Background(), Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Protocol: TCP})
+	assert.ErrorContains(t, err, "ipvs: opening network namespace")
+}
+
+func TestSummarizeConnections(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "ip_vs_conn"), []byte(testConnTable), 0o644))
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP}
+	actual, err := summarizeConnections(dir, svc)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, ConnectionSummary{
+		netip.MustParseAddrPort("198.51.100.1:8080"): {
+			Total:      1,
+			States:     map[string]int{"ESTABLISHED": 1},
+			MaxExpires: 899 * time.Second,
+		},
+	})
+}
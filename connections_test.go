@@ -0,0 +1,225 @@
+package ipvs
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+)
+
+const testConnTable = `Pro FromIP   FPrt ToIP     TPrt DestIP   DPrt State       Expires PEName PEData
+TCP C0000264 D2F0 C0000201 0050 C6336401 1F90 ESTABLISHED     899
+UDP C0000265 A411 C0000201 0035 C6336402 0035 UDP             287
+UDP C0000266 13C4 C0000201 13C4 C6336401 13C4 UDP             155 sip 4d6e2f@192.0.2.102
+TCP 2001:0db8:0000:0000:0000:0000:0000:0064 C350 2001:0db8:0000:0000:0000:0000:0000:0001 01BB C6336401 01BB FIN_WAIT        100
+IP  C0000264 0000 C0000201 0000 C6336401 0000 NONE            360
+`
+
+const testConnSyncTable = `Pro FromIP   FPrt ToIP     TPrt DestIP   DPrt State       Origin Expires
+TCP C0000264 D2F0 C0000201 0050 C6336401 1F90 ESTABLISHED SYNC       899
+UDP C0000265 A411 C0000201 0035 C6336402 0035 UDP         LOCAL      287
+`
+
+func TestParseConnections(t *testing.T) {
+	var actual []Connection
+	err := parseConnections(strings.NewReader(testConnTable), false, func(conn Connection) {
+		actual = append(actual, conn)
+	})
+	assert.NilError(t, err)
+
+	expected := []Connection{
+		{
+			Protocol:    TCP,
+			Client:      netip.MustParseAddrPort("192.0.2.100:54000"),
+			Virtual:     netip.MustParseAddrPort("192.0.2.1:80"),
+			Destination: netip.MustParseAddrPort("198.51.100.1:8080"),
+			State:       "ESTABLISHED",
+			Expires:     899 * time.Second,
+		},
+		{
+			Protocol:    UDP,
+			Client:      netip.MustParseAddrPort("192.0.2.101:42001"),
+			Virtual:     netip.MustParseAddrPort("192.0.2.1:53"),
+			Destination: netip.MustParseAddrPort("198.51.100.2:53"),
+			State:       "UDP",
+			Expires:     287 * time.Second,
+		},
+		{
+			Protocol:    UDP,
+			Client:      netip.MustParseAddrPort("192.0.2.102:5060"),
+			Virtual:     netip.MustParseAddrPort("192.0.2.1:5060"),
+			Destination: netip.MustParseAddrPort("198.51.100.1:5060"),
+			State:       "UDP",
+			Expires:     155 * time.Second,
+			PEName:      "sip",
+			PEData:      "4d6e2f@192.0.2.102",
+		},
+		{
+			Protocol:    TCP,
+			Client:      netip.MustParseAddrPort("[2001:db8::64]:50000"),
+			Virtual:     netip.MustParseAddrPort("[2001:db8::1]:443"),
+			Destination: netip.MustParseAddrPort("198.51.100.1:443"),
+			State:       "FIN_WAIT",
+			Expires:     100 * time.Second,
+		},
+		{
+			Protocol:    0,
+			Client:      netip.MustParseAddrPort("192.0.2.100:0"),
+			Virtual:     netip.MustParseAddrPort("192.0.2.1:0"),
+			Destination: netip.MustParseAddrPort("198.51.100.1:0"),
+			State:       "NONE",
+			Expires:     360 * time.Second,
+		},
+	}
+	assert.DeepEqual(t, actual, expected, cmp.Comparer(func(x, y netip.AddrPort) bool { return x == y }))
+}
+
+func TestParseConnections_Sync(t *testing.T) {
+	var actual []bool
+	err := parseConnections(strings.NewReader(testConnSyncTable), true, func(conn Connection) {
+		actual = append(actual, conn.Synced)
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, []bool{true, false})
+}
+
+func TestParseConnections_Errors(t *testing.T) {
+	type testCase struct {
+		name     string
+		line     string
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		err := parseConnections(strings.NewReader("header\n"+tc.line+"\n"), false, func(Connection) {})
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "short",
+			line:     "TCP C0000264 D2F0",
+			expected: "line 2: 3 fields, want at least 9",
+		},
+		{
+			name:     "protocol",
+			line:     "FOO C0000264 D2F0 C0000201 0050 C6336401 1F90 ESTABLISHED 899",
+			expected: `unknown protocol "FOO"`,
+		},
+		{
+			name:     "address",
+			line:     "TCP C00002 D2F0 C0000201 0050 C6336401 1F90 ESTABLISHED 899",
+			expected: `invalid address "C00002"`,
+		},
+		{
+			name:     "port",
+			line:     "TCP C0000264 D2F0 C0000201 10050 C6336401 1F90 ESTABLISHED 899",
+			expected: `invalid port "10050"`,
+		},
+		{
+			name:     "expires",
+			line:     "TCP C0000264 D2F0 C0000201 0050 C6336401 1F90 ESTABLISHED soon",
+			expected: `parsing "soon"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestConnectionOptions_Match(t *testing.T) {
+	type testCase struct {
+		name     string
+		opts     []ConnectionOption
+		expected bool
+	}
+
+	conn := Connection{
+		Protocol:    TCP,
+		Client:      netip.MustParseAddrPort("192.0.2.100:54000"),
+		Virtual:     netip.MustParseAddrPort("192.0.2.1:80"),
+		Destination: netip.MustParseAddrPort("198.51.100.1:8080"),
+	}
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, buildConnectionOptions(tc.opts).match(conn), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "none", expected: true},
+		{name: "service", opts: []ConnectionOption{MatchService(svc)}, expected: true},
+		{
+			name:     "mapped service",
+			opts:     []ConnectionOption{MatchService(Service{Address: netip.MustParseAddr("::ffff:192.0.2.1"), Port: 80, Protocol: TCP})},
+			expected: true,
+		},
+		{
+			name:     "other protocol",
+			opts:     []ConnectionOption{MatchService(Service{Address: svc.Address, Port: 80, Protocol: UDP})},
+			expected: false,
+		},
+		{
+			name:     "firewall mark",
+			opts:     []ConnectionOption{MatchService(Service{FWMark: 1, Family: INET})},
+			expected: false,
+		},
+		{
+			name:     "any service",
+			opts:     []ConnectionOption{MatchService(Service{Address: svc.Address, Port: 443, Protocol: TCP}), MatchService(svc)},
+			expected: true,
+		},
+		{name: "destination", opts: []ConnectionOption{MatchDestination(dest)}, expected: true},
+		{
+			name:     "other destination",
+			opts:     []ConnectionOption{MatchDestination(Destination{Address: dest.Address, Port: 80})},
+			expected: false,
+		},
+		{
+			name:     "service and other destination",
+			opts:     []ConnectionOption{MatchService(svc), MatchDestination(Destination{Address: netip.MustParseAddr("198.51.100.2"), Port: 8080})},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestConnectionSummary_Add(t *testing.T) {
+	dest := netip.MustParseAddrPort("198.51.100.1:80")
+	summary := ConnectionSummary{}
+	for _, conn := range []Connection{
+		{Destination: dest, State: "ESTABLISHED", Expires: 900 * time.Second},
+		{Destination: dest, State: "ESTABLISHED", Expires: 10 * time.Second},
+		{Destination: dest, State: "FIN_WAIT", Expires: 60 * time.Second},
+		{Destination: netip.MustParseAddrPort("198.51.100.2:80"), State: "TIME_WAIT", Expires: 30 * time.Second},
+	} {
+		summary.add(conn)
+	}
+
+	assert.DeepEqual(t, summary, ConnectionSummary{
+		dest: {
+			Total:      3,
+			States:     map[string]int{"ESTABLISHED": 2, "FIN_WAIT": 1},
+			MaxExpires: 900 * time.Second,
+		},
+		netip.MustParseAddrPort("198.51.100.2:80"): {
+			Total:      1,
+			States:     map[string]int{"TIME_WAIT": 1},
+			MaxExpires: 30 * time.Second,
+		},
+	})
+}
@@ -0,0 +1,32 @@
+package ipvs
+
+// RemoveOption configures Client.RemoveDestination.
+type RemoveOption func(*removeOptions)
+
+// removeOptions holds the configuration built from a list of RemoveOptions.
+type removeOptions struct {
+	flushConntrack bool
+}
+
+// buildRemoveOptions applies opts on top of the defaults.
+func buildRemoveOptions(opts []RemoveOption) removeOptions {
+	var o removeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// FlushConntrack deletes the netfilter conntrack entries of the connections
+// to a Destination once it is removed.
+//
+// With the net.ipv4.vs.conntrack sysctl set, IPVS records its connections in
+// conntrack. In NAT mode, the entries of a removed Destination outlive it,
+// and keep translating the packets of their clients to the removed real
+// server, blackholing them until the entries expire.
+func FlushConntrack() RemoveOption {
+	return func(o *removeOptions) {
+		o.flushConntrack = true
+	}
+}
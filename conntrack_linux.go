@@ -0,0 +1,254 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Constants of ctnetlink, from linux/netfilter/nfnetlink_conntrack.h.
+const (
+	ipctnlMsgCtGet    = 1
+	ipctnlMsgCtDelete = 2
+
+	ctaTupleOrig  = 1
+	ctaTupleReply = 2
+	ctaZone       = 18
+
+	ctaTupleIP    = 1
+	ctaTupleProto = 2
+
+	ctaIPV4Src = 1
+	ctaIPV4Dst = 2
+	ctaIPV6Src = 3
+	ctaIPV6Dst = 4
+
+	ctaProtoNum     = 1
+	ctaProtoSrcPort = 2
+	ctaProtoDstPort = 3
+)
+
+// flushConntrack deletes the conntrack entries of the connections of svc to
+// dest, over a ctnetlink connection in the network namespace of c. Dry runs
+// leave conntrack untouched.
+func (c *client) flushConntrack(ctx context.Context, svc Service, dest Destination) error {
+	if isDryRun(ctx, c.dryRun) {
+		return nil
+	}
+
+	var conn *netlink.Conn
+	err := withNetlinkConfig(c.opts, func(cfg *netlink.Config) error {
+		var err error
+		conn, err = netlink.Dial(unix.NETLINK_NETFILTER, cfg)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("ipvs: flushing conntrack: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return fmt.Errorf("ipvs: flushing conntrack: %w", err)
+		}
+	}
+
+	if _, err := flushConntrack(conn, svc, dest); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("ipvs: flushing conntrack: %w", err)
+	}
+
+	return nil
+}
+
+// conntrackTuple is the address tuple of one direction of a conntrack entry.
+type conntrackTuple struct {
+	protocol Protocol
+	src, dst netip.AddrPort
+}
+
+// conntrackEntry is the part of a conntrack entry needed to match and delete
+// it.
+type conntrackEntry struct {
+	// orig is the encoded original tuple, sent back to delete the entry.
+	orig  []byte
+	zone  []byte
+	tuple struct{ orig, reply conntrackTuple }
+}
+
+// matches reports whether e tracks a connection of svc to dest. IPVS rewrites
+// the reply tuple to come from the real server, on the port the client
+// connected to when the port of dest is 0.
+func (e conntrackEntry) matches(svc Service, dest Destination) bool {
+	orig, reply := e.tuple.orig, e.tuple.reply
+	if reply.src.Addr() != dest.Address.Unmap() || (dest.Port != 0 && reply.src.Port() != dest.Port) {
+		return false
+	}
+
+	if svc.FWMark != 0 {
+		return true
+	}
+
+	if orig.protocol != svc.Protocol || orig.dst.Addr() != svc.Address.Unmap() {
+		return false
+	}
+
+	// Port 0 services accept connections to any port.
+	if svc.Port != 0 && orig.dst.Port() != svc.Port {
+		return false
+	}
+
+	return dest.Port != 0 || reply.src.Port() == orig.dst.Port()
+}
+
+// flushConntrack deletes the conntrack entries of the connections of svc to
+// dest over the ctnetlink connection c, returning the number of deleted
+// entries.
+func flushConntrack(c *netlink.Conn, svc Service, dest Destination) (int, error) {
+	// Mixed family destinations are tracked in their own family.
+	family := dest.Normalize().Family
+
+	msgs, err := c.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  netlink.HeaderType(unix.NFNL_SUBSYS_CTNETLINK<<8 | ipctnlMsgCtGet),
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: nfgenmsg(family),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, msg := range msgs {
+		if len(msg.Data) < 4 {
+			return n, errors.New("conntrack message too short")
+		}
+
+		e, err := unpackConntrackEntry(msg.Data[4:])
+		if err != nil {
+			return n, err
+		}
+
+		if !e.matches(svc, dest) {
+			continue
+		}
+
+		ae := netlink.NewAttributeEncoder()
+		ae.Bytes(ctaTupleOrig|netlink.Nested, e.orig)
+		if e.zone != nil {
+			ae.Bytes(ctaZone, e.zone)
+		}
+
+		b, err := ae.Encode()
+		if err != nil {
+			return n, err
+		}
+
+		_, err = c.Execute(netlink.Message{
+			Header: netlink.Header{
+				Type:  netlink.HeaderType(unix.NFNL_SUBSYS_CTNETLINK<<8 | ipctnlMsgCtDelete),
+				Flags: netlink.Request | netlink.Acknowledge,
+			},
+			Data: append(nfgenmsg(family), b...),
+		})
+		// The entry may have expired since the dump.
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, nil
+}
+
+// nfgenmsg returns the nfnetlink header for family.
+func nfgenmsg(family AddressFamily) []byte {
+	return []byte{uint8(family), unix.NFNETLINK_V0, 0, 0}
+}
+
+// unpackConntrackEntry unpacks the tuples and zone of a conntrack entry.
+func unpackConntrackEntry(b []byte) (conntrackEntry, error) {
+	var e conntrackEntry
+
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return e, err
+	}
+
+	for ad.Next() {
+		switch ad.Type() {
+		case ctaTupleOrig:
+			e.orig = ad.Bytes()
+			ad.Nested(unpackConntrackTuple(&e.tuple.orig))
+		case ctaTupleReply:
+			ad.Nested(unpackConntrackTuple(&e.tuple.reply))
+		case ctaZone:
+			e.zone = ad.Bytes()
+		}
+	}
+
+	if err := ad.Err(); err != nil {
+		return e, fmt.Errorf("decoding conntrack entry: %w", err)
+	}
+
+	return e, nil
+}
+
+// unpackConntrackTuple unpacks a conntrack tuple into t.
+func unpackConntrackTuple(t *conntrackTuple) func(ad *netlink.AttributeDecoder) error {
+	return func(ad *netlink.AttributeDecoder) error {
+		var src, dst netip.Addr
+		var sport, dport uint16
+		for ad.Next() {
+			switch ad.Type() {
+			case ctaTupleIP:
+				ad.Nested(func(ad *netlink.AttributeDecoder) error {
+					for ad.Next() {
+						switch ad.Type() {
+						case ctaIPV4Src, ctaIPV6Src:
+							src, _ = netip.AddrFromSlice(ad.Bytes())
+						case ctaIPV4Dst, ctaIPV6Dst:
+							dst, _ = netip.AddrFromSlice(ad.Bytes())
+						}
+					}
+					return nil
+				})
+			case ctaTupleProto:
+				ad.Nested(func(ad *netlink.AttributeDecoder) error {
+					ad.ByteOrder = binary.BigEndian
+					for ad.Next() {
+						switch ad.Type() {
+						case ctaProtoNum:
+							t.protocol = Protocol(ad.Uint8())
+						case ctaProtoSrcPort:
+							sport = ad.Uint16()
+						case ctaProtoDstPort:
+							dport = ad.Uint16()
+						}
+					}
+					return nil
+				})
+			}
+		}
+
+		t.src = netip.AddrPortFrom(src, sport)
+		t.dst = netip.AddrPortFrom(dst, dport)
+		return nil
+	}
+}
@@ -0,0 +1,241 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+	"gotest.tools/v3/assert"
+)
+
+// testConntrackTuple encodes a conntrack tuple.
+func testConntrackTuple(proto Protocol, src, dst netip.AddrPort) []byte {
+	port := func(p uint16) []byte {
+		return binary.BigEndian.AppendUint16(nil, p)
+	}
+
+	return nltest.MustMarshalAttributes([]netlink.Attribute{
+		{
+			Type: ctaTupleIP | netlink.Nested,
+			Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: ctaIPV4Src, Data: src.Addr().AsSlice()},
+				{Type: ctaIPV4Dst, Data: dst.Addr().AsSlice()},
+			}),
+		},
+		{
+			Type: ctaTupleProto | netlink.Nested,
+			Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: ctaProtoNum, Data: []byte{uint8(proto)}},
+				{Type: ctaProtoSrcPort, Data: port(src.Port())},
+				{Type: ctaProtoDstPort, Data: port(dst.Port())},
+			}),
+		},
+	})
+}
+
+func TestFlushConntrack(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET}
+
+	client := netip.MustParseAddrPort("203.0.113.1:40000")
+	vip := netip.MustParseAddrPort("192.0.2.1:80")
+	rs := netip.MustParseAddrPort("198.51.100.1:8080")
+
+	entry := func(orig []byte, reply []byte, zone []byte) netlink.Message {
+		attrs := []netlink.Attribute{
+			{Type: ctaTupleOrig | netlink.Nested, Data: orig},
+			{Type: ctaTupleReply | netlink.Nested, Data: reply},
+		}
+		if zone != nil {
+			attrs = append(attrs, netlink.Attribute{Type: ctaZone, Data: zone})
+		}
+
+		return netlink.Message{Data: append(nfgenmsg(INET), nltest.MustMarshalAttributes(attrs)...)}
+	}
+
+	matching := testConntrackTuple(TCP, client, vip)
+	gone := testConntrackTuple(TCP, netip.MustParseAddrPort("203.0.113.2:40000"), vip)
+	entries := []netlink.Message{
+		entry(matching, testConntrackTuple(TCP, rs, client), []byte{0x00, 0x01}),
+		entry(gone, testConntrackTuple(TCP, rs, netip.MustParseAddrPort("203.0.113.2:40000")), nil),
+		// Another real server.
+		entry(testConntrackTuple(TCP, client, vip), testConntrackTuple(TCP, netip.MustParseAddrPort("198.51.100.2:8080"), client), nil),
+		// Another service of the same real server.
+		entry(testConntrackTuple(TCP, client, netip.MustParseAddrPort("192.0.2.1:8080")), testConntrackTuple(TCP, rs, client), nil),
+		// Another protocol.
+		entry(testConntrackTuple(UDP, client, vip), testConntrackTuple(UDP, rs, client), nil),
+	}
+
+	var deleted [][]byte
+	fn := func(reqs []netlink.Message) ([]netlink.Message, error) {
+		req := reqs[0]
+		assert.DeepEqual(t, req.Data[:4], nfgenmsg(INET))
+
+		switch req.Header.Type {
+		case netlink.HeaderType(unix.NFNL_SUBSYS_CTNETLINK<<8 | ipctnlMsgCtGet):
+			assert.Equal(t, req.Header.Flags, netlink.Request|netlink.Dump)
+			msgs := append(append([]netlink.Message{}, entries...), netlink.Message{})
+			for i := range msgs {
+				msgs[i].Header.Sequence = req.Header.Sequence
+				msgs[i].Header.PID = req.Header.PID
+			}
+			return nltest.Multipart(msgs)
+		case netlink.HeaderType(unix.NFNL_SUBSYS_CTNETLINK<<8 | ipctnlMsgCtDelete):
+			assert.Equal(t, req.Header.Flags, netlink.Request|netlink.Acknowledge)
+			deleted = append(deleted, req.Data[4:])
+			if len(deleted) == 2 {
+				return nltest.Error(int(syscall.ENOENT), reqs)
+			}
+			return nltest.Error(0, reqs)
+		}
+
+		t.Fatalf("unexpected request type %d", req.Header.Type)
+		return nil, nil
+	}
+
+	c := nltest.Dial(fn)
+	defer c.Close()
+
+	n, err := flushConntrack(c, svc, dest)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+	assert.DeepEqual(t, deleted, [][]byte{
+		nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: ctaTupleOrig | netlink.Nested, Data: matching},
+			{Type: ctaZone, Data: []byte{0x00, 0x01}},
+		}),
+		nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: ctaTupleOrig | netlink.Nested, Data: gone},
+		}),
+	})
+}
+
+func TestConntrackEntry_FWMark(t *testing.T) {
+	client := netip.MustParseAddrPort("203.0.113.1:40000")
+	rs := netip.MustParseAddrPort("198.51.100.1:8080")
+
+	e, err := unpackConntrackEntry(nltest.MustMarshalAttributes([]netlink.Attribute{
+		{Type: ctaTupleOrig | netlink.Nested, Data: testConntrackTuple(UDP, client, netip.MustParseAddrPort("192.0.2.1:53"))},
+		{Type: ctaTupleReply | netlink.Nested, Data: testConntrackTuple(UDP, rs, client)},
+	}))
+	assert.NilError(t, err)
+
+	svc := Service{FWMark: 1, Family: INET}
+	assert.Assert(t, e.matches(svc, Destination{Address: netip.MustParseAddr("::ffff:198.51.100.1"), Port: 8080}))
+	assert.Assert(t, !e.matches(svc, Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 80}))
+}
+
+func TestConntrackEntry_PortZero(t *testing.T) {
+	type testCase struct {
+		name     string
+		svc      Service
+		orig     netip.AddrPort
+		reply    netip.AddrPort
+		expected bool
+	}
+
+	client := netip.MustParseAddrPort("203.0.113.1:40000")
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Family: INET, FwdMethod: Masquerade}
+	web := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP}
+	allPorts := Service{Address: netip.MustParseAddr("192.0.2.1"), Family: INET, Protocol: TCP}
+
+	run := func(t *testing.T, tc testCase) {
+		e, err := unpackConntrackEntry(nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: ctaTupleOrig | netlink.Nested, Data: testConntrackTuple(TCP, client, tc.orig)},
+			{Type: ctaTupleReply | netlink.Nested, Data: testConntrackTuple(TCP, tc.reply, client)},
+		}))
+		assert.NilError(t, err)
+		assert.Equal(t, e.matches(tc.svc, dest), tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "firewall mark",
+			svc:      Service{FWMark: 1, Family: INET},
+			orig:     netip.MustParseAddrPort("192.0.2.1:8443"),
+			reply:    netip.MustParseAddrPort("198.51.100.1:8443"),
+			expected: true,
+		},
+		{
+			name:  "firewall mark of other real server",
+			svc:   Service{FWMark: 1, Family: INET},
+			orig:  netip.MustParseAddrPort("192.0.2.1:8443"),
+			reply: netip.MustParseAddrPort("198.51.100.2:8443"),
+		},
+		{
+			name:     "virtual port",
+			svc:      web,
+			orig:     netip.MustParseAddrPort("192.0.2.1:80"),
+			reply:    netip.MustParseAddrPort("198.51.100.1:80"),
+			expected: true,
+		},
+		{
+			name:  "other port",
+			svc:   web,
+			orig:  netip.MustParseAddrPort("192.0.2.1:80"),
+			reply: netip.MustParseAddrPort("198.51.100.1:8080"),
+		},
+		{
+			name:     "port 0 service",
+			svc:      allPorts,
+			orig:     netip.MustParseAddrPort("192.0.2.1:443"),
+			reply:    netip.MustParseAddrPort("198.51.100.1:443"),
+			expected: true,
+		},
+		{
+			name:  "port 0 service to other port",
+			svc:   allPorts,
+			orig:  netip.MustParseAddrPort("192.0.2.1:443"),
+			reply: netip.MustParseAddrPort("198.51.100.1:8443"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestFlushConntrack_MixedFamily(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6, Protocol: TCP}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, FwdMethod: Tunnel}
+
+	var dumped bool
+	fn := func(reqs []netlink.Message) ([]netlink.Message, error) {
+		req := reqs[0]
+		assert.DeepEqual(t, req.Data[:4], nfgenmsg(INET))
+		dumped = true
+
+		// An entry of another real server.
+		client := netip.MustParseAddrPort("203.0.113.1:40000")
+		msgs := []netlink.Message{
+			{Data: append(nfgenmsg(INET), nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: ctaTupleOrig | netlink.Nested, Data: testConntrackTuple(TCP, client, netip.MustParseAddrPort("192.0.2.1:80"))},
+				{Type: ctaTupleReply | netlink.Nested, Data: testConntrackTuple(TCP, netip.MustParseAddrPort("198.51.100.2:8080"), client)},
+			})...)},
+			{},
+		}
+		for i := range msgs {
+			msgs[i].Header.Sequence = req.Header.Sequence
+			msgs[i].Header.PID = req.Header.PID
+		}
+		return nltest.Multipart(msgs)
+	}
+
+	c := nltest.Dial(fn)
+	defer c.Close()
+
+	n, err := flushConntrack(c, svc, dest)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 0)
+	assert.Assert(t, dumped)
+}
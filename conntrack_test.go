@@ -0,0 +1,12 @@
+package ipvs
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBuildRemoveOptions(t *testing.T) {
+	assert.Equal(t, buildRemoveOptions(nil), removeOptions{})
+	assert.Equal(t, buildRemoveOptions([]RemoveOption{FlushConntrack()}), removeOptions{flushConntrack: true})
+}
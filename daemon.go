@@ -0,0 +1,80 @@
+package ipvs
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+)
+
+// DaemonState is the role of a connection synchronization daemon.
+type DaemonState uint32
+
+// States of the synchronization daemons. A machine can run both a master
+// daemon and a backup daemon.
+const (
+	// DaemonMaster sends the connections of this machine to the backups.
+	DaemonMaster DaemonState = cipvs.StateMaster
+	// DaemonBackup receives the connections of a master.
+	DaemonBackup DaemonState = cipvs.StateBackup
+)
+
+// Daemon represents a connection synchronization daemon, which replicates
+// the connection table of IPVS between machines over multicast, so that
+// connections survive a failover.
+//
+// Only the State, MulticastInterface and SyncID are required when starting a
+// Daemon. IPVS picks defaults for the remaining fields when left unset, such
+// as the multicast group 224.0.0.81 and port 8848.
+type Daemon struct {
+	State DaemonState
+	// MulticastInterface is the name of the network interface the
+	// synchronization messages are sent or received on.
+	MulticastInterface string
+	// SyncID identifies the group of daemons a master sends to, and backups
+	// accept messages from. Zero accepts messages of any group.
+	SyncID uint8
+	// SyncMaxLen limits the size of synchronization messages in bytes.
+	SyncMaxLen uint16
+	// MulticastGroup is an IPv4 or IPv6 multicast address.
+	MulticastGroup netip.Addr
+	MulticastPort  uint16
+	MulticastTTL   uint8
+}
+
+// validate checks that IPVS can start d.
+func (d Daemon) validate() error {
+	if err := d.State.validate(); err != nil {
+		return err
+	}
+
+	if d.MulticastInterface == "" {
+		return fmt.Errorf("ipvs: daemon requires a multicast interface")
+	}
+
+	if len(d.MulticastInterface) >= cipvs.IfnameMaxlen {
+		return fmt.Errorf("ipvs: interface name %q is longer than %d bytes", d.MulticastInterface, cipvs.IfnameMaxlen-1)
+	}
+
+	if d.MulticastGroup.IsValid() && !d.MulticastGroup.IsMulticast() {
+		return fmt.Errorf("ipvs: multicast group %s is not a multicast address", d.MulticastGroup)
+	}
+
+	return nil
+}
+
+// Validate reports whether IPVS can start d, without a round trip to the
+// kernel. It does not check that the interface exists.
+func (d Daemon) Validate() error {
+	return d.validate()
+}
+
+// validate checks that s is a known state.
+func (s DaemonState) validate() error {
+	switch s {
+	case DaemonMaster, DaemonBackup:
+		return nil
+	}
+
+	return fmt.Errorf("ipvs: unknown daemon state %s", s)
+}
@@ -0,0 +1,181 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+// StartDaemon starts a connection synchronization daemon.
+func (c *client) StartDaemon(ctx context.Context, d Daemon) (err error) {
+	defer func() {
+		c.recordMutation(ctx, mutation{op: "start_daemon", after: d, err: err})
+	}()
+
+	if err := d.validate(); err != nil {
+		return err
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Do(cipvs.CmdAttrDaemon, packDaemon(d))
+	b, err := ae.Encode()
+
+	if err != nil {
+		return err
+	}
+
+	msg := genetlink.Message{
+		Header: genetlink.Header{
+			Command: cipvs.CmdNewDaemon,
+			Version: cipvs.GenlVersion,
+		},
+		Data: b,
+	}
+	flags := netlink.Request | netlink.Acknowledge
+
+	_, err = c.execute(ctx, msg, flags)
+	return err
+}
+
+// StopDaemon stops the connection synchronization daemon of state.
+func (c *client) StopDaemon(ctx context.Context, state DaemonState) (err error) {
+	defer func() {
+		c.recordMutation(ctx, mutation{op: "stop_daemon", before: state, err: err})
+	}()
+
+	if err := state.validate(); err != nil {
+		return err
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Do(cipvs.CmdAttrDaemon, func() ([]byte, error) {
+		nae := netlink.NewAttributeEncoder()
+		nae.Uint32(cipvs.DaemonAttrState, uint32(state))
+		return nae.Encode()
+	})
+	b, err := ae.Encode()
+
+	if err != nil {
+		return err
+	}
+
+	msg := genetlink.Message{
+		Header: genetlink.Header{
+			Command: cipvs.CmdDelDaemon,
+			Version: cipvs.GenlVersion,
+		},
+		Data: b,
+	}
+	flags := netlink.Request | netlink.Acknowledge
+
+	_, err = c.execute(ctx, msg, flags)
+	return err
+}
+
+// Daemons returns the running connection synchronization daemons.
+func (c *client) Daemons(ctx context.Context) ([]Daemon, error) {
+	msg := genetlink.Message{
+		Header: genetlink.Header{
+			Command: cipvs.CmdGetDaemon,
+			Version: cipvs.GenlVersion,
+		},
+	}
+	flags := netlink.Request | netlink.Dump
+
+	msgs, err := c.execute(ctx, msg, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	daemons := make([]Daemon, 0, len(msgs))
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		var d Daemon
+		for ad.Next() {
+			if ad.Type() == cipvs.CmdAttrDaemon {
+				ad.Do(unpackDaemon(&d))
+			}
+		}
+
+		if err := ad.Err(); err != nil {
+			return nil, err
+		}
+
+		daemons = append(daemons, d)
+	}
+
+	return daemons, nil
+}
+
+// packDaemon packs a Daemon into a netlink-encoded message. The optional
+// fields are left out when unset, for IPVS to pick its defaults.
+func packDaemon(d Daemon) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		ae := netlink.NewAttributeEncoder()
+		ae.Uint32(cipvs.DaemonAttrState, uint32(d.State))
+		ae.String(cipvs.DaemonAttrMcastIfn, d.MulticastInterface)
+		ae.Uint32(cipvs.DaemonAttrSyncId, uint32(d.SyncID))
+
+		if d.SyncMaxLen != 0 {
+			ae.Uint16(cipvs.DaemonAttrSyncMaxlen, d.SyncMaxLen)
+		}
+
+		switch {
+		case d.MulticastGroup.Is4():
+			ae.Bytes(cipvs.DaemonAttrMcastGroup, d.MulticastGroup.AsSlice())
+		case d.MulticastGroup.Is6():
+			ae.Bytes(cipvs.DaemonAttrMcastGroup6, d.MulticastGroup.AsSlice())
+		}
+
+		if d.MulticastPort != 0 {
+			ae.Uint16(cipvs.DaemonAttrMcastPort, d.MulticastPort)
+		}
+
+		if d.MulticastTTL != 0 {
+			ae.Uint8(cipvs.DaemonAttrMcastTtl, d.MulticastTTL)
+		}
+
+		return ae.Encode()
+	}
+}
+
+// unpackDaemon unpacks a Daemon from a netlink-encoded message.
+func unpackDaemon(d *Daemon) func(b []byte) error {
+	return func(b []byte) error {
+		ad, err := netlink.NewAttributeDecoder(b)
+		if err != nil {
+			return err
+		}
+
+		for ad.Next() {
+			switch ad.Type() {
+			case cipvs.DaemonAttrState:
+				d.State = DaemonState(ad.Uint32())
+			case cipvs.DaemonAttrMcastIfn:
+				d.MulticastInterface = ad.String()
+			case cipvs.DaemonAttrSyncId:
+				d.SyncID = uint8(ad.Uint32())
+			case cipvs.DaemonAttrSyncMaxlen:
+				d.SyncMaxLen = ad.Uint16()
+			case cipvs.DaemonAttrMcastGroup, cipvs.DaemonAttrMcastGroup6:
+				d.MulticastGroup, _ = netip.AddrFromSlice(ad.Bytes())
+			case cipvs.DaemonAttrMcastPort:
+				d.MulticastPort = ad.Uint16()
+			case cipvs.DaemonAttrMcastTtl:
+				d.MulticastTTL = ad.Uint8()
+			}
+		}
+
+		return ad.Err()
+	}
+}
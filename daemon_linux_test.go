@@ -0,0 +1,184 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/genetlink/genltest"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"github.com/mdlayher/netlink/nltest"
+	"gotest.tools/v3/assert"
+)
+
+func TestStartDaemon(t *testing.T) {
+	type testCase struct {
+		name     string
+		daemon   Daemon
+		expected []netlink.Attribute
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		expected := nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: cipvs.CmdAttrDaemon, Data: nltest.MustMarshalAttributes(tc.expected)},
+		})
+
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			assert.DeepEqual(t, gerq.Data, expected)
+			return []genetlink.Message{{}}, nil
+		}
+		client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdNewDaemon, netlink.Request|netlink.Acknowledge, fn))
+		defer client.Close()
+
+		assert.NilError(t, client.StartDaemon(context.Background(), tc.daemon))
+	}
+
+	testCases := []testCase{
+		{
+			name:   "defaults",
+			daemon: Daemon{State: DaemonBackup, MulticastInterface: "eth0", SyncID: 7},
+			expected: []netlink.Attribute{
+				{Type: cipvs.DaemonAttrState, Data: nlenc.Uint32Bytes(cipvs.StateBackup)},
+				{Type: cipvs.DaemonAttrMcastIfn, Data: nlenc.Bytes("eth0")},
+				{Type: cipvs.DaemonAttrSyncId, Data: nlenc.Uint32Bytes(7)},
+			},
+		},
+		{
+			name: "ipv4 group",
+			daemon: Daemon{
+				State:              DaemonMaster,
+				MulticastInterface: "eth1",
+				SyncID:             1,
+				SyncMaxLen:         1472,
+				MulticastGroup:     netip.MustParseAddr("224.0.0.81"),
+				MulticastPort:      8848,
+				MulticastTTL:       2,
+			},
+			expected: []netlink.Attribute{
+				{Type: cipvs.DaemonAttrState, Data: nlenc.Uint32Bytes(cipvs.StateMaster)},
+				{Type: cipvs.DaemonAttrMcastIfn, Data: nlenc.Bytes("eth1")},
+				{Type: cipvs.DaemonAttrSyncId, Data: nlenc.Uint32Bytes(1)},
+				{Type: cipvs.DaemonAttrSyncMaxlen, Data: nlenc.Uint16Bytes(1472)},
+				{Type: cipvs.DaemonAttrMcastGroup, Data: []byte{224, 0, 0, 81}},
+				{Type: cipvs.DaemonAttrMcastPort, Data: nlenc.Uint16Bytes(8848)},
+				{Type: cipvs.DaemonAttrMcastTtl, Data: []byte{2}},
+			},
+		},
+		{
+			name: "ipv6 group",
+			daemon: Daemon{
+				State:              DaemonMaster,
+				MulticastInterface: "eth1",
+				MulticastGroup:     netip.MustParseAddr("ff02::81"),
+			},
+			expected: []netlink.Attribute{
+				{Type: cipvs.DaemonAttrState, Data: nlenc.Uint32Bytes(cipvs.StateMaster)},
+				{Type: cipvs.DaemonAttrMcastIfn, Data: nlenc.Bytes("eth1")},
+				{Type: cipvs.DaemonAttrSyncId, Data: nlenc.Uint32Bytes(0)},
+				{Type: cipvs.DaemonAttrMcastGroup6, Data: netip.MustParseAddr("ff02::81").AsSlice()},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestStopDaemon(t *testing.T) {
+	expected := nltest.MustMarshalAttributes([]netlink.Attribute{
+		{
+			Type: cipvs.CmdAttrDaemon,
+			Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: cipvs.DaemonAttrState, Data: nlenc.Uint32Bytes(cipvs.StateBackup)},
+			}),
+		},
+	})
+
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		assert.DeepEqual(t, gerq.Data, expected)
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdDelDaemon, netlink.Request|netlink.Acknowledge, fn))
+	defer client.Close()
+
+	assert.NilError(t, client.StopDaemon(context.Background(), DaemonBackup))
+	assert.ErrorContains(t, client.StopDaemon(context.Background(), 0), "unknown daemon state")
+}
+
+func TestDaemons(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		return []genetlink.Message{
+			{
+				Data: nltest.MustMarshalAttributes([]netlink.Attribute{{
+					Type: cipvs.CmdAttrDaemon,
+					Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+						{Type: cipvs.DaemonAttrState, Data: nlenc.Uint32Bytes(cipvs.StateMaster)},
+						{Type: cipvs.DaemonAttrMcastIfn, Data: nlenc.Bytes("eth0")},
+						{Type: cipvs.DaemonAttrSyncId, Data: nlenc.Uint32Bytes(1)},
+						{Type: cipvs.DaemonAttrSyncMaxlen, Data: nlenc.Uint16Bytes(1472)},
+						{Type: cipvs.DaemonAttrMcastPort, Data: nlenc.Uint16Bytes(8848)},
+						{Type: cipvs.DaemonAttrMcastTtl, Data: []byte{1}},
+						{Type: cipvs.DaemonAttrMcastGroup, Data: []byte{224, 0, 0, 81}},
+					}),
+				}}),
+			},
+			{
+				Data: nltest.MustMarshalAttributes([]netlink.Attribute{{
+					Type: cipvs.CmdAttrDaemon,
+					Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+						{Type: cipvs.DaemonAttrState, Data: nlenc.Uint32Bytes(cipvs.StateBackup)},
+						{Type: cipvs.DaemonAttrMcastIfn, Data: nlenc.Bytes("eth1")},
+						{Type: cipvs.DaemonAttrSyncId, Data: nlenc.Uint32Bytes(2)},
+						{Type: cipvs.DaemonAttrMcastGroup6, Data: netip.MustParseAddr("ff02::81").AsSlice()},
+					}),
+				}}),
+			},
+		}, nil
+	}
+	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdGetDaemon, netlink.Request|netlink.Dump, fn))
+	defer client.Close()
+
+	actual, err := client.Daemons(context.Background())
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, []Daemon{
+		{
+			State:              DaemonMaster,
+			MulticastInterface: "eth0",
+			SyncID:             1,
+			SyncMaxLen:         1472,
+			MulticastGroup:     netip.MustParseAddr("224.0.0.81"),
+			MulticastPort:      8848,
+			MulticastTTL:       1,
+		},
+		{
+			State:              DaemonBackup,
+			MulticastInterface: "eth1",
+			SyncID:             2,
+			MulticastGroup:     netip.MustParseAddr("ff02::81"),
+		},
+	}, cmp.Comparer(NetipAddrCompare))
+}
+
+func TestDaemons_None(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		return nil, io.EOF
+	}
+	client := testClient(t, genltest.CheckRequest(familyID, cipvs.CmdGetDaemon, netlink.Request|netlink.Dump, fn))
+	defer client.Close()
+
+	actual, err := client.Daemons(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(actual), 0)
+}
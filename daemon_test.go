@@ -0,0 +1,71 @@
+package ipvs
+
+import (
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDaemon_Validate(t *testing.T) {
+	type testCase struct {
+		name     string
+		daemon   Daemon
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		err := tc.daemon.validate()
+		if tc.expected == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:   "minimal",
+			daemon: Daemon{State: DaemonMaster, MulticastInterface: "eth0"},
+		},
+		{
+			name: "ipv6 group",
+			daemon: Daemon{
+				State:              DaemonBackup,
+				MulticastInterface: "eth0",
+				MulticastGroup:     netip.MustParseAddr("ff02::81"),
+			},
+		},
+		{
+			name:     "unknown state",
+			daemon:   Daemon{State: 3, MulticastInterface: "eth0"},
+			expected: "unknown daemon state DaemonState(3)",
+		},
+		{
+			name:     "missing interface",
+			daemon:   Daemon{State: DaemonMaster},
+			expected: "requires a multicast interface",
+		},
+		{
+			name:     "long interface",
+			daemon:   Daemon{State: DaemonMaster, MulticastInterface: "0123456789abcdef"},
+			expected: "longer than 15 bytes",
+		},
+		{
+			name: "unicast group",
+			daemon: Daemon{
+				State:              DaemonMaster,
+				MulticastInterface: "eth0",
+				MulticastGroup:     netip.MustParseAddr("192.0.2.1"),
+			},
+			expected: "192.0.2.1 is not a multicast address",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
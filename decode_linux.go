@@ -0,0 +1,192 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"runtime"
+	"sync"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+)
+
+const (
+	// parallelDecodeThreshold is the number of messages from which dumps
+	// are decoded in parallel, below which the overhead outweighs the gain.
+	parallelDecodeThreshold = 1024
+	// decodeChunkSize is the number of messages decoded by a worker at a
+	// time.
+	decodeChunkSize = 256
+)
+
+// decodeMessages decodes msgs with decode, calling fn with the results in
+// the order of msgs, and releasing each message once decoded. If decode or
+// fn return an error, decodeMessages stops and returns it.
+//
+// Large dumps are decoded in chunks by a pool of workers, one per CPU, while
+// fn is called with the chunks already decoded. Only a few chunks are
+// decoded ahead of fn, bounding the decoded results held at once.
+func decodeMessages[T any](msgs []genetlink.Message, decode func(genetlink.Message) (T, error), fn func(T) error) error {
+	workers := runtime.GOMAXPROCS(0)
+	if len(msgs) < parallelDecodeThreshold {
+		workers = 1
+	}
+
+	return decodeMessagesWorkers(msgs, workers, decode, fn)
+}
+
+// decodeChunk is a chunk of messages decoded by a worker of decodeMessages.
+type decodeChunk[T any] struct {
+	msgs []genetlink.Message
+	out  []T
+	err  error
+	done chan struct{}
+}
+
+// decodeMessagesWorkers implements decodeMessages with a pool of workers,
+// decoding in the calling goroutine when there is a single one.
+func decodeMessagesWorkers[T any](msgs []genetlink.Message, workers int, decode func(genetlink.Message) (T, error), fn func(T) error) error {
+	if workers <= 1 {
+		for i, msg := range msgs {
+			msgs[i] = genetlink.Message{}
+
+			v, err := decode(msg)
+			if err != nil {
+				return err
+			}
+
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var (
+		jobs    = make(chan *decodeChunk[T])
+		ordered = make(chan *decodeChunk[T], 2*workers)
+		stop    = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				c.decode(decode)
+			}
+		}()
+	}
+
+	// Queue the chunks in order, blocking once the window of chunks not yet
+	// consumed is full.
+	go func() {
+		defer close(ordered)
+		defer close(jobs)
+
+		for start := 0; start < len(msgs); start += decodeChunkSize {
+			end := start + decodeChunkSize
+			if end > len(msgs) {
+				end = len(msgs)
+			}
+
+			c := &decodeChunk[T]{msgs: msgs[start:end], done: make(chan struct{})}
+			select {
+			case ordered <- c:
+			case <-stop:
+				return
+			}
+			jobs <- c
+		}
+	}()
+
+	defer wg.Wait()
+	defer close(stop)
+
+	for c := range ordered {
+		<-c.done
+		if c.err != nil {
+			return c.err
+		}
+
+		for _, v := range c.out {
+			if err := fn(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// decode decodes the messages of c, releasing them.
+func (c *decodeChunk[T]) decode(decode func(genetlink.Message) (T, error)) {
+	defer close(c.done)
+
+	c.out = make([]T, 0, len(c.msgs))
+	for i, msg := range c.msgs {
+		c.msgs[i] = genetlink.Message{}
+
+		v, err := decode(msg)
+		if err != nil {
+			c.err = err
+			return
+		}
+
+		c.out = append(c.out, v)
+	}
+}
+
+// decodeService decodes a Service dumped by IPVS.
+func decodeService(msg genetlink.Message) (ServiceExtended, error) {
+	var s ServiceExtended
+	ad, err := netlink.NewAttributeDecoder(msg.Data)
+	if err != nil {
+		return ServiceExtended{}, err
+	}
+
+	for ad.Next() {
+		if ad.Type() == cipvs.CmdAttrService {
+			ad.Do(unpackService(&s))
+		}
+	}
+
+	if err := ad.Err(); err != nil {
+		return ServiceExtended{}, err
+	}
+
+	return s, nil
+}
+
+// destinationDecoder returns a func decoding a Destination of a Service of
+// family dumped by IPVS.
+func destinationDecoder(family AddressFamily) func(genetlink.Message) (DestinationExtended, error) {
+	return func(msg genetlink.Message) (DestinationExtended, error) {
+		var dest DestinationExtended
+		// In Linux kernels before 3.18, the address family of a destination
+		// could not differ from the service. Pass down the service's address
+		// family, which will be overridden by the kernel, if available.
+		dest.Family = family
+
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			return DestinationExtended{}, err
+		}
+
+		for ad.Next() {
+			if ad.Type() == cipvs.CmdAttrDest {
+				ad.Do(unpackDestination(&dest))
+			}
+		}
+
+		if err := ad.Err(); err != nil {
+			return DestinationExtended{}, err
+		}
+
+		return dest, nil
+	}
+}
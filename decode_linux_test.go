@@ -0,0 +1,208 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"runtime"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/cloudflare/ipvs/internal/nlattr"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"gotest.tools/v3/assert"
+)
+
+// indexMessages returns n messages carrying their index.
+func indexMessages(n int) []genetlink.Message {
+	msgs := make([]genetlink.Message, n)
+	for i := range msgs {
+		msgs[i].Data = nlenc.Uint32Bytes(uint32(i))
+	}
+
+	return msgs
+}
+
+func decodeIndex(msg genetlink.Message) (int, error) {
+	if len(msg.Data) != 4 {
+		return 0, errors.New("released message")
+	}
+
+	return int(nlenc.Uint32(msg.Data)), nil
+}
+
+func TestDecodeMessages(t *testing.T) {
+	type testCase struct {
+		name    string
+		n       int
+		workers int
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		msgs := indexMessages(tc.n)
+
+		var got []int
+		err := decodeMessagesWorkers(msgs, tc.workers, decodeIndex, func(i int) error {
+			got = append(got, i)
+			return nil
+		})
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(got), tc.n)
+		for i, v := range got {
+			assert.Equal(t, v, i)
+		}
+		for _, msg := range msgs {
+			assert.Assert(t, msg.Data == nil, "message not released")
+		}
+	}
+
+	testCases := []testCase{
+		{name: "empty", n: 0, workers: 4},
+		{name: "sequential", n: 1000, workers: 1},
+		{name: "partial chunk", n: 10, workers: 4},
+		{name: "many chunks", n: 10*decodeChunkSize + 7, workers: 4},
+		{name: "more workers than chunks", n: 2 * decodeChunkSize, workers: 16},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestDecodeMessages_Errors(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			// An error decoding is returned without delivering the messages
+			// after it, while workers may withhold those of its chunk.
+			var delivered int
+			err := decodeMessagesWorkers(indexMessages(5*decodeChunkSize), workers, func(msg genetlink.Message) (int, error) {
+				i, _ := decodeIndex(msg)
+				if i == 3*decodeChunkSize+1 {
+					return 0, errBoom
+				}
+				return i, nil
+			}, func(int) error {
+				delivered++
+				return nil
+			})
+			assert.Assert(t, errors.Is(err, errBoom))
+			assert.Assert(t, delivered >= 3*decodeChunkSize && delivered <= 3*decodeChunkSize+1, "delivered %d", delivered)
+
+			// An error of fn stops decoding.
+			delivered = 0
+			err = decodeMessagesWorkers(indexMessages(50*decodeChunkSize), workers, decodeIndex, func(i int) error {
+				delivered++
+				if i == 10 {
+					return errBoom
+				}
+				return nil
+			})
+			assert.Assert(t, errors.Is(err, errBoom))
+			assert.Equal(t, delivered, 11)
+		})
+	}
+}
+
+// destinationMessages returns the messages of a dump of n destinations.
+func destinationMessages(tb testing.TB, n int) []genetlink.Message {
+	tb.Helper()
+
+	msgs := make([]genetlink.Message, n)
+	for i := range msgs {
+		dest := Destination{
+			Address: netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}),
+			Port:    8080,
+			Family:  INET,
+			Weight:  1,
+		}
+
+		stats := netlink.NewAttributeEncoder()
+		for typ := uint16(cipvs.StatsAttrConns); typ <= cipvs.StatsAttrOutbps; typ++ {
+			stats.Uint64(typ, uint64(i)*uint64(typ))
+		}
+
+		sb, err := stats.Encode()
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		ae := netlink.NewAttributeEncoder()
+		ae.Do(cipvs.CmdAttrDest, func() ([]byte, error) {
+			b, err := packDest(dest)()
+			if err != nil {
+				return nil, err
+			}
+
+			return nlattr.AppendBytes(b, cipvs.DestAttrStats64, sb), nil
+		})
+
+		b, err := ae.Encode()
+		if err != nil {
+			tb.Fatal(err)
+		}
+		msgs[i].Data = b
+	}
+
+	return msgs
+}
+
+func TestDecodeMessages_Destinations(t *testing.T) {
+	msgs := destinationMessages(t, 3*decodeChunkSize)
+	want := make([]genetlink.Message, len(msgs))
+	copy(want, msgs)
+
+	var got []DestinationExtended
+	err := decodeMessagesWorkers(msgs, 4, destinationDecoder(INET), func(dest DestinationExtended) error {
+		got = append(got, dest)
+		return nil
+	})
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(got), len(want))
+	assert.Equal(t, got[5].Stats.Connections, uint64(5))
+	for i, msg := range want {
+		expected, err := destinationDecoder(INET)(msg)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, got[i], expected)
+	}
+}
+
+// BenchmarkDecodeDestinations decodes a dump of 50k destinations with a
+// single worker and with one per CPU, e.g. with -cpu 1,8.
+func BenchmarkDecodeDestinations(b *testing.B) {
+	msgs := destinationMessages(b, 50000)
+	work := make([]genetlink.Message, len(msgs))
+
+	for _, bc := range []struct {
+		name    string
+		workers int
+	}{
+		{name: "sequential", workers: 1},
+		{name: "parallel", workers: runtime.GOMAXPROCS(0)},
+	} {
+		workers := bc.workers
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				copy(work, msgs)
+				err := decodeMessagesWorkers(work, workers, destinationDecoder(INET), func(DestinationExtended) error {
+					return nil
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
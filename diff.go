@@ -0,0 +1,265 @@
+package ipvs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Changeset is the ordered list of changes turning one IPVS configuration
+// into another, as returned by Diff. A service is created before its
+// destinations, and deleted after them.
+type Changeset []Change
+
+// Change is a change to a Service or one of its Destinations, described by
+// an Event along with the fields it changes.
+type Change struct {
+	Event
+	// Fields lists the fields changed by ServiceUpdated, DestinationUpdated
+	// and DestinationWeightChanged changes.
+	Fields []FieldChange
+}
+
+// FieldChange is a field changed by an update, with its old and new values
+// formatted as strings.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Diff returns the changes turning actual into desired, such as the
+// configuration of IPVS, obtained with TableConfigOf, into the one of a
+// configuration file. Services are ordered by key, and destinations by
+// address. It fails if a configuration holds a service or destination twice.
+func Diff(actual, desired TableConfig) (Changeset, error) {
+	cur, err := actual.watched()
+	if err != nil {
+		return nil, err
+	}
+
+	want, err := desired.watched()
+	if err != nil {
+		return nil, err
+	}
+
+	return diff(cur, want), nil
+}
+
+// TableConfigOf returns the configuration of svcs, such as listed by
+// Client.ServicesWithDestinations, ignoring their statistics.
+func TableConfigOf(svcs []ServiceWithDestinations) TableConfig {
+	cfg := TableConfig{Services: make([]ServiceConfig, 0, len(svcs))}
+	for _, svc := range svcs {
+		sc := ServiceConfig{Service: svc.Service}
+		if len(svc.Destinations) > 0 {
+			sc.Destinations = make([]Destination, 0, len(svc.Destinations))
+		}
+		for _, dest := range svc.Destinations {
+			sc.Destinations = append(sc.Destinations, dest.Destination)
+		}
+		cfg.Services = append(cfg.Services, sc)
+	}
+
+	return cfg
+}
+
+// diff returns the changes turning the snapshot cur into want.
+func diff(cur, want map[ServiceKey]watchedService) Changeset {
+	events := diffWatched(cur, want)
+	cs := make(Changeset, 0, len(events))
+	for _, ev := range events {
+		cs = append(cs, Change{Event: ev, Fields: changedFields(ev)})
+	}
+
+	return cs
+}
+
+// Creates returns the ServiceAdded and DestinationAdded changes of cs.
+func (cs Changeset) Creates() Changeset {
+	return cs.filter(ServiceAdded, DestinationAdded)
+}
+
+// Updates returns the ServiceUpdated, DestinationUpdated and
+// DestinationWeightChanged changes of cs.
+func (cs Changeset) Updates() Changeset {
+	return cs.filter(ServiceUpdated, DestinationUpdated, DestinationWeightChanged)
+}
+
+// Deletes returns the ServiceRemoved and DestinationRemoved changes of cs.
+func (cs Changeset) Deletes() Changeset {
+	return cs.filter(ServiceRemoved, DestinationRemoved)
+}
+
+// filter returns the changes of cs of the given types, in order.
+func (cs Changeset) filter(types ...EventType) Changeset {
+	var out Changeset
+	for _, c := range cs {
+		for _, t := range types {
+			if c.Type == t {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+
+	return out
+}
+
+// Format returns cs as text in the style of a unified diff over the table
+// layout of "ipvsadm -Ln": created services and destinations are prefixed
+// with "+", deleted ones with "-", and updated ones are listed before and
+// after the change. Services of which only destinations change are listed
+// unprefixed, as context.
+func (cs Changeset) Format() string {
+	var (
+		b     strings.Builder
+		order []ServiceKey
+		byKey = make(map[ServiceKey]Changeset)
+	)
+
+	for _, c := range cs {
+		key := c.Service.Normalize().Key()
+		if _, ok := byKey[key]; !ok {
+			order = append(order, key)
+		}
+		byKey[key] = append(byKey[key], c)
+	}
+
+	for _, key := range order {
+		changes := byKey[key]
+
+		// The destinations of a deleted service are deleted before it, but
+		// are listed under it.
+		header := Change{Event: Event{Service: changes[0].Service}}
+		for _, c := range changes {
+			switch c.Type {
+			case ServiceAdded, ServiceUpdated, ServiceRemoved:
+				header = c
+			}
+		}
+
+		switch header.Type {
+		case ServiceAdded:
+			fmt.Fprintf(&b, "+%s\n", header.Service)
+		case ServiceUpdated:
+			fmt.Fprintf(&b, "-%s\n+%s\n", header.PreviousService, header.Service)
+		case ServiceRemoved:
+			fmt.Fprintf(&b, "-%s\n", header.Service)
+		default:
+			fmt.Fprintf(&b, " %s\n", header.Service)
+		}
+
+		for _, c := range changes {
+			switch c.Type {
+			case DestinationAdded:
+				fmt.Fprintf(&b, "+  -> %s\n", c.Destination)
+			case DestinationUpdated, DestinationWeightChanged:
+				fmt.Fprintf(&b, "-  -> %s\n+  -> %s\n", c.PreviousDestination, c.Destination)
+			case DestinationRemoved:
+				fmt.Fprintf(&b, "-  -> %s\n", c.Destination)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// jsonChange is the JSON representation of a Change.
+type jsonChange struct {
+	Op          string        `json:"op"`
+	Service     ServiceKey    `json:"service"`
+	Destination string        `json:"destination,omitempty"`
+	Fields      []FieldChange `json:"fields,omitempty"`
+}
+
+// MarshalJSON implements the [json.Marshaler] interface. A change is
+// represented by its operation, "create", "update" or "delete", the key of
+// its service, the address of its destination, if any, and the fields it
+// changes.
+func (c Change) MarshalJSON() ([]byte, error) {
+	obj := jsonChange{
+		Op:          c.op(),
+		Service:     c.Service.Normalize().Key(),
+		Destination: c.destination(),
+		Fields:      c.Fields,
+	}
+	if obj.Op == "" {
+		return nil, fmt.Errorf("ipvs: unknown change type %v", c.Type)
+	}
+
+	return json.Marshal(obj)
+}
+
+// String returns the operation of c along with the service and destination
+// it changes, such as "create destination 198.51.100.1:8080 of service
+// TCP 192.0.2.1:80".
+func (c Change) String() string {
+	key := c.Service.Normalize().Key()
+	if dest := c.destination(); dest != "" {
+		return fmt.Sprintf("%s destination %s of service %s", c.op(), dest, key)
+	}
+
+	return fmt.Sprintf("%s service %s", c.op(), key)
+}
+
+// op returns the operation of c, "create", "update" or "delete", or the
+// empty string for an unknown change type.
+func (c Change) op() string {
+	switch c.Type {
+	case ServiceAdded, DestinationAdded:
+		return "create"
+	case ServiceUpdated, DestinationUpdated, DestinationWeightChanged:
+		return "update"
+	case ServiceRemoved, DestinationRemoved:
+		return "delete"
+	}
+
+	return ""
+}
+
+// destination returns the address of the destination changed by c, or the
+// empty string if c changes a service.
+func (c Change) destination() string {
+	switch c.Type {
+	case DestinationAdded, DestinationUpdated, DestinationWeightChanged, DestinationRemoved:
+		dest := c.Destination.Normalize()
+		return netip.AddrPortFrom(dest.Address, dest.Port).String()
+	}
+
+	return ""
+}
+
+// changedFields returns the fields changed by ev, compared after normalizing
+// the states.
+func changedFields(ev Event) []FieldChange {
+	var fields []FieldChange
+	add := func(field string, prev, cur any) {
+		o, n := fmt.Sprint(prev), fmt.Sprint(cur)
+		if o != n {
+			fields = append(fields, FieldChange{Field: field, Old: o, New: n})
+		}
+	}
+
+	switch ev.Type {
+	case ServiceUpdated:
+		old, svc := ev.PreviousService.Normalize(), ev.Service.Normalize()
+		add("Scheduler", old.Scheduler, svc.Scheduler)
+		add("Flags", old.Flags, svc.Flags)
+		add("Timeout", old.Timeout, svc.Timeout)
+		add("Netmask", old.Netmask, svc.Netmask)
+		add("PEName", old.PEName, svc.PEName)
+	case DestinationUpdated, DestinationWeightChanged:
+		old, dest := ev.PreviousDestination.Normalize(), ev.Destination.Normalize()
+		add("FwdMethod", old.FwdMethod, dest.FwdMethod)
+		add("Weight", old.Weight, dest.Weight)
+		add("UpperThreshold", old.UpperThreshold, dest.UpperThreshold)
+		add("LowerThreshold", old.LowerThreshold, dest.LowerThreshold)
+		add("TunnelType", old.TunnelType, dest.TunnelType)
+		add("TunnelPort", old.TunnelPort, dest.TunnelPort)
+		add("TunnelFlags", old.TunnelFlags, dest.TunnelFlags)
+	}
+
+	return fields
+}
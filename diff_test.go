@@ -0,0 +1,147 @@
+package ipvs
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDiff(t *testing.T) {
+	svc := func(last byte, s Scheduler) Service {
+		return Service{Address: netip.AddrFrom4([4]byte{192, 0, 2, last}), Port: 80, Family: INET, Protocol: TCP, Scheduler: s}
+	}
+	dest := func(last byte, weight uint32) Destination {
+		return Destination{Address: netip.AddrFrom4([4]byte{198, 51, 100, last}), Port: 8080, Family: INET, FwdMethod: Masquerade, Weight: weight}
+	}
+	routed := dest(3, 1)
+	routed.FwdMethod = DirectRoute
+
+	actual := TableConfigOf([]ServiceWithDestinations{
+		{
+			ServiceExtended: ServiceExtended{Service: svc(1, RoundRobin)},
+			Destinations: []DestinationExtended{
+				{Destination: dest(1, 1), ActiveConnections: 5},
+				{Destination: dest(2, 1)},
+				{Destination: dest(3, 1)},
+			},
+		},
+		{
+			ServiceExtended: ServiceExtended{Service: svc(2, RoundRobin)},
+			Destinations:    []DestinationExtended{{Destination: dest(1, 1)}},
+		},
+		{
+			ServiceExtended: ServiceExtended{Service: svc(3, RoundRobin)},
+			Destinations:    []DestinationExtended{{Destination: dest(1, 1)}},
+		},
+	})
+	desired := TableConfig{Services: []ServiceConfig{
+		{Service: svc(1, RoundRobin), Destinations: []Destination{dest(1, 5), routed, dest(4, 1)}},
+		{Service: svc(2, WeightedRoundRobin), Destinations: []Destination{dest(1, 1)}},
+		{Service: svc(4, RoundRobin), Destinations: []Destination{dest(1, 1)}},
+	}}
+
+	cs, err := Diff(actual, desired)
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(cs.Creates()), 3)
+	assert.Equal(t, len(cs.Updates()), 3)
+	assert.Equal(t, len(cs.Deletes()), 3)
+
+	assert.Equal(t, cs.Format(), ` TCP  192.0.2.1:80 rr
+-  -> 198.51.100.1:8080 Masq 1
++  -> 198.51.100.1:8080 Masq 5
+-  -> 198.51.100.3:8080 Masq 1
++  -> 198.51.100.3:8080 Route 1
++  -> 198.51.100.4:8080 Masq 1
+-  -> 198.51.100.2:8080 Masq 1
+-TCP  192.0.2.2:80 rr
++TCP  192.0.2.2:80 wrr
++TCP  192.0.2.4:80 rr
++  -> 198.51.100.1:8080 Masq 1
+-TCP  192.0.2.3:80 rr
+-  -> 198.51.100.1:8080 Masq 1
+`)
+
+	b, err := json.Marshal(cs)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), `[`+
+		`{"op":"update","service":"TCP 192.0.2.1:80","destination":"198.51.100.1:8080","fields":[{"field":"Weight","old":"1","new":"5"}]},`+
+		`{"op":"update","service":"TCP 192.0.2.1:80","destination":"198.51.100.3:8080","fields":[{"field":"FwdMethod","old":"Masquerade","new":"DirectRoute"}]},`+
+		`{"op":"create","service":"TCP 192.0.2.1:80","destination":"198.51.100.4:8080"},`+
+		`{"op":"delete","service":"TCP 192.0.2.1:80","destination":"198.51.100.2:8080"},`+
+		`{"op":"update","service":"TCP 192.0.2.2:80","fields":[{"field":"Scheduler","old":"rr","new":"wrr"}]},`+
+		`{"op":"create","service":"TCP 192.0.2.4:80"},`+
+		`{"op":"create","service":"TCP 192.0.2.4:80","destination":"198.51.100.1:8080"},`+
+		`{"op":"delete","service":"TCP 192.0.2.3:80","destination":"198.51.100.1:8080"},`+
+		`{"op":"delete","service":"TCP 192.0.2.3:80"}`+
+		`]`)
+
+	cs, err = Diff(desired, desired)
+	assert.NilError(t, err)
+	assert.Equal(t, len(cs), 0)
+	assert.Equal(t, cs.Format(), "")
+}
+
+func TestDiff_Fields(t *testing.T) {
+	type testCase struct {
+		name     string
+		ev       Event
+		expected []FieldChange
+	}
+
+	base := Service{Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	persistent := base
+	persistent.Flags = ServicePersistent
+	persistent.Timeout = 300
+
+	tunnel := Destination{Family: INET, FwdMethod: Tunnel, Weight: 1, TunnelType: GUE, TunnelPort: 6080}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.DeepEqual(t, changedFields(tc.ev), tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name: "service",
+			ev:   Event{Type: ServiceUpdated, PreviousService: base, Service: persistent},
+			expected: []FieldChange{
+				{Field: "Flags", Old: "", New: "ServicePersistent"},
+				{Field: "Timeout", Old: "0", New: "300"},
+			},
+		},
+		{
+			name: "hashed flag ignored",
+			ev:   Event{Type: ServiceUpdated, PreviousService: Service{Family: INET, Flags: ServiceHashed}, Service: Service{Family: INET}},
+		},
+		{
+			name: "destination",
+			ev:   Event{Type: DestinationUpdated, PreviousDestination: Destination{Family: INET, FwdMethod: Masquerade}, Destination: tunnel},
+			expected: []FieldChange{
+				{Field: "FwdMethod", Old: "Masquerade", New: "Tunnel"},
+				{Field: "Weight", Old: "0", New: "1"},
+				{Field: "TunnelType", Old: "IPIP", New: "GUE"},
+				{Field: "TunnelPort", Old: "0", New: "6080"},
+			},
+		},
+		{
+			name: "added",
+			ev:   Event{Type: DestinationAdded, Destination: tunnel},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestDiff_Duplicate(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+
+	_, err := Diff(TableConfig{Services: []ServiceConfig{{Service: svc}, {Service: svc}}}, TableConfig{})
+	assert.ErrorContains(t, err, "configured twice")
+}
@@ -0,0 +1,83 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// DrainOptions configure Client.DrainDestination.
+type DrainOptions struct {
+	// Interval is the time between polls of the connection counts, which
+	// defaults to one second.
+	Interval time.Duration
+
+	// Timeout is the time after which the Destination is removed even if it
+	// still has connections. When zero, it is only removed once drained.
+	Timeout time.Duration
+
+	// Progress is called with the Destination after every poll, if set.
+	Progress func(DestinationExtended)
+}
+
+// DrainDestination gracefully removes a Destination of svc, identified by the
+// Address, Port and Family of dest like SetDestinationWeight. Its weight is
+// set to zero, so that no new connections are scheduled to it, and its
+// connection counts are polled until both reach zero or the timeout of opts
+// passes, after which it is removed. Cancelling ctx stops draining without
+// removing the Destination.
+// A Destination which is removed by another party while draining is not
+// treated as an error.
+func (c *client) DrainDestination(ctx context.Context, svc Service, dest Destination, opts DrainOptions) error {
+	if err := c.SetDestinationWeight(ctx, svc, dest, 0); err != nil {
+		return err
+	}
+
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+poll:
+	for {
+		d, err := c.destination(ctx, svc, dest)
+		if errors.Is(err, ErrDestinationNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(d)
+		}
+
+		if d.ActiveConnections == 0 && d.InactiveConnections == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			break poll
+		case <-ticker.C:
+		}
+	}
+
+	err := c.RemoveDestination(ctx, svc, dest)
+	if errors.Is(err, ErrDestinationNotFound) {
+		return nil
+	}
+
+	return err
+}
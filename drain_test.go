@@ -0,0 +1,143 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"github.com/mdlayher/netlink/nltest"
+	"gotest.tools/v3/assert"
+)
+
+func TestDrainDestination(t *testing.T) {
+	type testCase struct {
+		name        string
+		conns       []uint32
+		polls       int
+		unsetFamily bool
+	}
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 10}
+
+	run := func(t *testing.T, tc testCase) {
+		var commands []uint8
+		var gets int
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			commands = append(commands, gerq.Header.Command)
+			if gerq.Header.Command != cipvs.CmdGetDest {
+				return []genetlink.Message{{}}, nil
+			}
+
+			conns := tc.conns[len(tc.conns)-1]
+			if gets < len(tc.conns) {
+				conns = tc.conns[gets]
+			}
+			gets++
+
+			return []genetlink.Message{{
+				Data: nltest.MustMarshalAttributes([]netlink.Attribute{{
+					Type: cipvs.CmdAttrDest,
+					Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+						{Type: cipvs.DestAttrAddrFamily, Data: []byte{0x02, 0x00}},
+						{Type: cipvs.DestAttrAddr, Data: []byte{198, 51, 100, 1}},
+						{Type: cipvs.DestAttrPort, Data: []byte{0x1F, 0x90}},
+						{Type: cipvs.DestAttrActiveConns, Data: nlenc.Uint32Bytes(conns)},
+					}),
+				}}),
+			}}, nil
+		}
+		client := testClient(t, fn)
+		defer client.Close()
+
+		dest := dest
+		if tc.unsetFamily {
+			dest.Family = 0
+		}
+
+		var polls int
+		err := client.DrainDestination(context.Background(), svc, dest, DrainOptions{
+			Interval: time.Millisecond,
+			Progress: func(d DestinationExtended) {
+				polls++
+				assert.Equal(t, d.Address, dest.Address)
+			},
+		})
+		assert.NilError(t, err)
+		assert.Equal(t, polls, tc.polls)
+
+		// The weight is set before polling, and the destination removed
+		// last.
+		assert.DeepEqual(t, commands[:2], []uint8{cipvs.CmdGetDest, cipvs.CmdSetDest})
+		assert.Equal(t, commands[len(commands)-1], uint8(cipvs.CmdDelDest))
+	}
+
+	testCases := []testCase{
+		{
+			name:  "drained",
+			conns: []uint32{5, 5, 2, 0},
+			polls: 3,
+		},
+		{
+			name:  "idle",
+			conns: []uint32{0},
+			polls: 1,
+		},
+		{
+			name:        "unset family",
+			conns:       []uint32{5, 2, 0},
+			polls:       2,
+			unsetFamily: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestDrainDestination_Timeout(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 10}
+
+	var removed bool
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		switch gerq.Header.Command {
+		case cipvs.CmdGetDest:
+			return []genetlink.Message{{
+				Data: nltest.MustMarshalAttributes([]netlink.Attribute{{
+					Type: cipvs.CmdAttrDest,
+					Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+						{Type: cipvs.DestAttrAddrFamily, Data: []byte{0x02, 0x00}},
+						{Type: cipvs.DestAttrAddr, Data: []byte{198, 51, 100, 1}},
+						{Type: cipvs.DestAttrPort, Data: []byte{0x1F, 0x90}},
+						{Type: cipvs.DestAttrActiveConns, Data: []byte{0x01, 0x00, 0x00, 0x00}},
+					}),
+				}}),
+			}}, nil
+		case cipvs.CmdDelDest:
+			removed = true
+		}
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	err := client.DrainDestination(context.Background(), svc, dest, DrainOptions{
+		Interval: time.Millisecond,
+		Timeout:  10 * time.Millisecond,
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, removed)
+}
@@ -0,0 +1,32 @@
+package ipvs
+
+import "context"
+
+// WithDryRun makes the Client validate and encode the changes it is asked to
+// make, and log them with the logger set by WithLogger, without sending them
+// to IPVS. Operators can then preview what a controller would change against
+// production. Reads are still made, and changes are reported as successful,
+// but are not audited, so helpers waiting on the effect of a change, such as
+// DrainDestination, may never complete.
+//
+// WithDryRunContext makes a dry run of the changes made with a context
+// instead.
+func WithDryRun() Option {
+	return func(o *options) {
+		o.dryRun = true
+	}
+}
+
+type dryRunKey struct{}
+
+// WithDryRunContext returns a copy of ctx making dry runs of the changes
+// made with it, as if the Client was created with WithDryRun.
+func WithDryRunContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// isDryRun reports whether changes made with ctx are dry runs, given
+// whether the Client was created with WithDryRun.
+func isDryRun(ctx context.Context, always bool) bool {
+	return always || ctx.Value(dryRunKey{}) != nil
+}
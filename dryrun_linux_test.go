@@ -0,0 +1,76 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"gotest.tools/v3/assert"
+)
+
+func TestClient_DryRun(t *testing.T) {
+	var sent []uint8
+	fn := func(gerq genetlink.Message, nreq netlink.Message) ([]genetlink.Message, error) {
+		sent = append(sent, gerq.Header.Command)
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+	client.opts.netNSPath = "/nonexistent"
+
+	var audited []AuditRecord
+	client.audit = AuditFunc(func(_ context.Context, r AuditRecord) {
+		audited = append(audited, r)
+	})
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+
+	changes := func(ctx context.Context) {
+		assert.NilError(t, client.CreateService(ctx, svc))
+		assert.NilError(t, client.UpdateDestination(ctx, svc, dest))
+		assert.NilError(t, client.RemoveService(ctx, svc))
+		assert.NilError(t, client.Flush(ctx))
+		assert.NilError(t, client.Batch(ctx, func(b *Batch) {
+			b.CreateDestination(svc, dest)
+		}))
+
+		// Neither conntrack nor sysctls are touched, which fails in /nonexistent.
+		assert.NilError(t, client.RemoveDestination(ctx, svc, dest, FlushConntrack()))
+		assert.NilError(t, client.SetSyncConfig(ctx, SyncConfig{Version: 1, Threshold: 3, Period: 50, QueueLenMax: 8192, Ports: 1}))
+
+		// Changes are still validated.
+		err := client.CreateService(ctx, Service{Scheduler: RoundRobin, FWMark: 42})
+		assert.ErrorContains(t, err, "firewall mark service requires")
+	}
+
+	// Dry runs of a single context.
+	changes(WithDryRunContext(context.Background()))
+	assert.Equal(t, len(sent), 0)
+	assert.Equal(t, len(audited), 0)
+	assert.Equal(t, len(client.Metrics().Requests), 0)
+
+	// Reads are still made.
+	_, err := client.Service(WithDryRunContext(context.Background()), svc)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, sent, []uint8{cipvs.CmdGetService})
+
+	// Dry runs of the whole Client.
+	sent = nil
+	client.dryRun = true
+	changes(context.Background())
+	assert.Equal(t, len(sent), 0)
+	assert.Equal(t, len(audited), 0)
+
+	// Changes are sent again otherwise.
+	client.dryRun = false
+	assert.NilError(t, client.Flush(context.Background()))
+	assert.DeepEqual(t, sent, []uint8{cipvs.CmdFlush})
+	assert.Equal(t, len(audited), 1)
+}
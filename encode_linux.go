@@ -0,0 +1,128 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"net/netip"
+	"sync"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/cloudflare/ipvs/internal/nlattr"
+	"github.com/josharian/native"
+)
+
+// maxPooledRequest is the capacity above which request buffers are not
+// returned to the pool, so that a rare large request does not pin memory.
+const maxPooledRequest = 4096
+
+// requestBuffers pools the buffers that service and destination requests are
+// encoded into, so that reconcile loops issuing many updates do not allocate
+// a buffer per request.
+var requestBuffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// encodeRequest encodes the attributes of a request on svc, and on dest if
+// set, into a pooled buffer. The buffer must be released with releaseRequest
+// once the request is sent.
+func encodeRequest(svc Service, dest *Destination) *[]byte {
+	buf := requestBuffers.Get().(*[]byte)
+
+	b, off := nlattr.Begin((*buf)[:0], cipvs.CmdAttrService)
+	b = nlattr.End(appendService(b, svc), off)
+
+	if dest != nil {
+		b, off = nlattr.Begin(b, cipvs.CmdAttrDest)
+		b = nlattr.End(appendDest(b, *dest), off)
+	}
+
+	*buf = b
+	return buf
+}
+
+// releaseRequest returns a buffer from encodeRequest to the pool.
+func releaseRequest(buf *[]byte) {
+	if cap(*buf) > maxPooledRequest {
+		return
+	}
+
+	requestBuffers.Put(buf)
+}
+
+// appendService appends the attributes of svc to b.
+func appendService(b []byte, svc Service) []byte {
+	var flags [8]byte
+	native.Endian.PutUint32(flags[:4], uint32(svc.Flags))
+	native.Endian.PutUint32(flags[4:], 0xFFFFFFFF)
+
+	b = nlattr.AppendUint16(b, cipvs.SvcAttrAf, uint16(svc.Family))
+	b = nlattr.AppendString(b, cipvs.SvcAttrSchedName, string(svc.Scheduler))
+	if svc.PEName != "" {
+		b = nlattr.AppendString(b, cipvs.SvcAttrPeName, svc.PEName)
+	}
+	b = nlattr.AppendBytes(b, cipvs.SvcAttrFlags, flags[:])
+	b = nlattr.AppendUint32(b, cipvs.SvcAttrTimeout, svc.Timeout)
+
+	// The kernel requires a netmask for every service it creates or
+	// updates, so default to a mask matching a single client.
+	mask := svc.Netmask
+	if !mask.IsValid() {
+		mask = defaultNetmask(svc.Family)
+	}
+
+	if mask.IsValid() {
+		var m [4]byte
+		if n, err := mask.PutTo(m[:]); err == nil {
+			b = nlattr.AppendBytes(b, cipvs.SvcAttrNetmask, m[:n])
+		}
+	}
+
+	if svc.FWMark != 0 {
+		return nlattr.AppendUint32(b, cipvs.SvcAttrFwmark, svc.FWMark)
+	}
+
+	b = nlattr.AppendUint16(b, cipvs.SvcAttrProtocol, uint16(svc.Protocol))
+	b = appendAddr(b, cipvs.SvcAttrAddr, svc.Family, svc.Address)
+	return nlattr.AppendBigEndianUint16(b, cipvs.SvcAttrPort, svc.Port)
+}
+
+// appendDest appends the attributes of dest to b.
+func appendDest(b []byte, dest Destination) []byte {
+	b = nlattr.AppendUint16(b, cipvs.DestAttrAddrFamily, uint16(dest.Family))
+	b = appendAddr(b, cipvs.DestAttrAddr, dest.Family, dest.Address)
+	b = nlattr.AppendBigEndianUint16(b, cipvs.DestAttrPort, dest.Port)
+	b = nlattr.AppendUint32(b, cipvs.DestAttrFwdMethod, uint32(dest.FwdMethod))
+	b = nlattr.AppendUint32(b, cipvs.DestAttrWeight, dest.Weight)
+	b = nlattr.AppendUint32(b, cipvs.DestAttrUThresh, dest.UpperThreshold)
+	b = nlattr.AppendUint32(b, cipvs.DestAttrLThresh, dest.LowerThreshold)
+	b = nlattr.AppendUint8(b, cipvs.DestAttrTunType, uint8(dest.TunnelType))
+	b = nlattr.AppendBigEndianUint16(b, cipvs.DestAttrTunPort, dest.TunnelPort)
+	return nlattr.AppendUint16(b, cipvs.DestAttrTunFlags, uint16(dest.TunnelFlags))
+}
+
+// appendAddr appends an attribute of typ holding addr, encoded like packAddr
+// without allocating.
+func appendAddr(b []byte, typ uint16, family AddressFamily, addr netip.Addr) []byte {
+	switch {
+	case family == INET && addr.Is4In6():
+		addr = addr.Unmap()
+	case family == INET6 && addr.Is4():
+		a := addr.As16()
+		return nlattr.AppendBytes(b, typ, a[:])
+	}
+
+	switch {
+	case addr.Is4():
+		a := addr.As4()
+		return nlattr.AppendBytes(b, typ, a[:])
+	case addr.Is6():
+		a := addr.As16()
+		return nlattr.AppendBytes(b, typ, a[:])
+	}
+
+	return nlattr.AppendBytes(b, typ, nil)
+}
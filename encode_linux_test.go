@@ -0,0 +1,181 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/cloudflare/ipvs/netmask"
+	"github.com/josharian/native"
+	"github.com/mdlayher/netlink"
+	"gotest.tools/v3/assert"
+)
+
+// encodeRequestWithEncoder encodes a request like encodeRequest, with
+// netlink.AttributeEncoder as a reference.
+func encodeRequestWithEncoder(svc Service, dest *Destination) ([]byte, error) {
+	port := func(port uint16) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			return binary.BigEndian.AppendUint16(nil, port), nil
+		}
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Do(cipvs.CmdAttrService, func() ([]byte, error) {
+		flags := make([]byte, 4)
+		native.Endian.PutUint32(flags, uint32(svc.Flags))
+		flags = append(flags, 0xFF, 0xFF, 0xFF, 0xFF)
+
+		ae := netlink.NewAttributeEncoder()
+		ae.Uint16(cipvs.SvcAttrAf, uint16(svc.Family))
+		ae.String(cipvs.SvcAttrSchedName, string(svc.Scheduler))
+		if svc.PEName != "" {
+			ae.String(cipvs.SvcAttrPeName, svc.PEName)
+		}
+		ae.Bytes(cipvs.SvcAttrFlags, flags)
+		ae.Uint32(cipvs.SvcAttrTimeout, svc.Timeout)
+
+		mask := svc.Netmask
+		if !mask.IsValid() {
+			mask = defaultNetmask(svc.Family)
+		}
+		if mask.IsValid() {
+			b := make([]byte, 4)
+			if n, err := mask.PutTo(b); err == nil {
+				ae.Bytes(cipvs.SvcAttrNetmask, b[:n])
+			}
+		}
+
+		if svc.FWMark != 0 {
+			ae.Uint32(cipvs.SvcAttrFwmark, svc.FWMark)
+		} else {
+			ae.Uint16(cipvs.SvcAttrProtocol, uint16(svc.Protocol))
+			ae.Bytes(cipvs.SvcAttrAddr, packAddr(svc.Family, svc.Address))
+			ae.Do(cipvs.SvcAttrPort, port(svc.Port))
+		}
+
+		return ae.Encode()
+	})
+
+	if dest != nil {
+		ae.Do(cipvs.CmdAttrDest, func() ([]byte, error) {
+			ae := netlink.NewAttributeEncoder()
+			ae.Uint16(cipvs.DestAttrAddrFamily, uint16(dest.Family))
+			ae.Bytes(cipvs.DestAttrAddr, packAddr(dest.Family, dest.Address))
+			ae.Do(cipvs.DestAttrPort, port(dest.Port))
+			ae.Uint32(cipvs.DestAttrFwdMethod, uint32(dest.FwdMethod))
+			ae.Uint32(cipvs.DestAttrWeight, dest.Weight)
+			ae.Uint32(cipvs.DestAttrUThresh, dest.UpperThreshold)
+			ae.Uint32(cipvs.DestAttrLThresh, dest.LowerThreshold)
+			ae.Uint8(cipvs.DestAttrTunType, uint8(dest.TunnelType))
+			ae.Do(cipvs.DestAttrTunPort, port(dest.TunnelPort))
+			ae.Uint16(cipvs.DestAttrTunFlags, uint16(dest.TunnelFlags))
+			return ae.Encode()
+		})
+	}
+
+	return ae.Encode()
+}
+
+func TestEncodeRequest(t *testing.T) {
+	type testCase struct {
+		name string
+		svc  Service
+		dest *Destination
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		expected, err := encodeRequestWithEncoder(tc.svc, tc.dest)
+		assert.NilError(t, err)
+
+		buf := encodeRequest(tc.svc, tc.dest)
+		defer releaseRequest(buf)
+		assert.DeepEqual(t, *buf, expected)
+	}
+
+	testCases := []testCase{
+		{
+			name: "ipv4",
+			svc:  Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin, Timeout: 300, Flags: 0x1},
+		},
+		{
+			name: "ipv6 with netmask and persistence engine",
+			svc: Service{
+				Address:   netip.MustParseAddr("2001:db8::1"),
+				Netmask:   netmask.MaskFrom(64, 128),
+				Port:      5060,
+				Family:    INET6,
+				Protocol:  UDP,
+				Scheduler: WeightedLeastConnection,
+				PEName:    "sip",
+			},
+		},
+		{
+			name: "firewall mark",
+			svc:  Service{FWMark: 42, Family: INET, Scheduler: "mh"},
+		},
+		{
+			name: "destination",
+			svc:  Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin},
+			dest: &Destination{
+				Address:        netip.MustParseAddr("198.51.100.1"),
+				Port:           8080,
+				Family:         INET,
+				FwdMethod:      Tunnel,
+				Weight:         10,
+				UpperThreshold: 1000,
+				LowerThreshold: 500,
+				TunnelType:     GUE,
+				TunnelPort:     6080,
+			},
+		},
+		{
+			name: "mapped destination",
+			svc:  Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6, Protocol: TCP, Scheduler: RoundRobin},
+			dest: &Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET6, Weight: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestEncodeRequest_Allocs(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 8080, Family: INET6, Weight: 1}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		releaseRequest(encodeRequest(svc, &dest))
+	})
+	// Under the race detector, the pool drops some buffers on purpose.
+	assert.Assert(t, allocs < 1, "allocs %v", allocs)
+}
+
+func BenchmarkEncodeRequest(b *testing.B) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			releaseRequest(encodeRequest(svc, &dest))
+		}
+	})
+
+	b.Run("AttributeEncoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := encodeRequestWithEncoder(svc, &dest); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
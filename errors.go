@@ -0,0 +1,128 @@
+package ipvs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by IPVS, which wrap the original error of the kernel. They
+// can be distinguished with errors.Is.
+var (
+	// ErrServiceNotFound indicates that the referenced Service does not exist.
+	ErrServiceNotFound = errors.New("ipvs: service not found")
+
+	// ErrServiceExists indicates that a created Service already exists.
+	ErrServiceExists = errors.New("ipvs: service already exists")
+
+	// ErrDestinationNotFound indicates that the referenced Destination does
+	// not exist.
+	ErrDestinationNotFound = errors.New("ipvs: destination not found")
+
+	// ErrDestinationExists indicates that a created Destination already
+	// exists.
+	ErrDestinationExists = errors.New("ipvs: destination already exists")
+
+	// ErrSchedulerNotAvailable indicates that the scheduler, or persistence
+	// engine, of a Service is not available in the kernel.
+	ErrSchedulerNotAvailable = errors.New("ipvs: scheduler not available")
+
+	// ErrNotPermitted indicates that the caller lacks the CAP_NET_ADMIN
+	// capability.
+	ErrNotPermitted = errors.New("ipvs: operation not permitted")
+
+	// ErrDaemonRunning indicates that a synchronization daemon of the same
+	// state is already running.
+	ErrDaemonRunning = errors.New("ipvs: daemon already running")
+
+	// ErrDaemonNotRunning indicates that no synchronization daemon of the
+	// state is running.
+	ErrDaemonNotRunning = errors.New("ipvs: daemon not running")
+
+	// ErrNotLoaded indicates that IPVS is missing from the kernel, because
+	// the ip_vs module is not loaded. It can be loaded with "modprobe ip_vs",
+	// or by creating the Client with WithModprobe.
+	ErrNotLoaded = errors.New(`ipvs: kernel module ip_vs is not loaded, load it with "modprobe ip_vs"`)
+)
+
+// kernelError wraps an error returned by the kernel with the sentinel error it
+// corresponds to.
+type kernelError struct {
+	sentinel error
+	err      error
+}
+
+// Error implements the error interface.
+func (e *kernelError) Error() string {
+	return fmt.Sprintf("%v: %v", e.sentinel, e.err)
+}
+
+// Is reports whether target is the sentinel error.
+func (e *kernelError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+// Unwrap returns the error returned by the kernel.
+func (e *kernelError) Unwrap() error {
+	return e.err
+}
+
+// PersistenceEngineError is returned when IPVS rejects a Service because its
+// persistence engine is not available, usually because the ip_vs_pe_<name>
+// kernel module is not loaded. IPVS reports a missing scheduler module in the
+// same way, so the scheduler may be at fault instead. It matches
+// ErrSchedulerNotAvailable.
+type PersistenceEngineError struct {
+	// Name is the persistence engine of the Service.
+	Name string
+	// Err is the error returned by IPVS.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *PersistenceEngineError) Error() string {
+	return fmt.Sprintf("ipvs: persistence engine %q not available: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the error returned by IPVS.
+func (e *PersistenceEngineError) Unwrap() error {
+	return e.Err
+}
+
+// ExtendedError is returned when the kernel explains why IPVS rejected a
+// request, which it does on kernels supporting extended acknowledgements. It
+// wraps the error returned by IPVS, so that errnos and the sentinel errors
+// still match.
+type ExtendedError struct {
+	// Message is the explanation of the kernel, which may be empty.
+	Message string
+	// Offset is the offset in bytes of the offending attribute from the
+	// start of the request, or zero if the kernel did not report one.
+	Offset int
+	// Attribute is the name of the offending attribute, such as
+	// "IPVS_SVC_ATTR_SCHED_NAME", or empty if it is unknown.
+	Attribute string
+	// Err is the error returned by IPVS.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ExtendedError) Error() string {
+	s := e.Err.Error()
+	if e.Message != "" {
+		s += ": " + e.Message
+	}
+
+	switch {
+	case e.Attribute != "":
+		s += fmt.Sprintf(" (attribute %s at offset %d)", e.Attribute, e.Offset)
+	case e.Offset != 0:
+		s += fmt.Sprintf(" (attribute at offset %d)", e.Offset)
+	}
+
+	return s
+}
+
+// Unwrap returns the error returned by IPVS.
+func (e *ExtendedError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,42 @@
+package ipvs
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestPersistenceEngineError(t *testing.T) {
+	err := error(&PersistenceEngineError{Name: "sip", Err: syscall.ENOENT})
+
+	assert.Error(t, err, `ipvs: persistence engine "sip" not available: no such file or directory`)
+	assert.Assert(t, errors.Is(err, syscall.ENOENT))
+}
+
+func TestKernelError(t *testing.T) {
+	err := error(&kernelError{sentinel: ErrServiceNotFound, err: syscall.ESRCH})
+
+	assert.Error(t, err, "ipvs: service not found: no such process")
+	assert.Assert(t, errors.Is(err, ErrServiceNotFound))
+	assert.Assert(t, !errors.Is(err, ErrDestinationNotFound))
+	assert.Assert(t, errors.Is(err, syscall.ESRCH))
+}
+
+func TestExtendedError(t *testing.T) {
+	err := error(&ExtendedError{
+		Message:   "scheduler not found",
+		Offset:    44,
+		Attribute: "IPVS_SVC_ATTR_SCHED_NAME",
+		Err:       syscall.ENOENT,
+	})
+	assert.Error(t, err, "no such file or directory: scheduler not found (attribute IPVS_SVC_ATTR_SCHED_NAME at offset 44)")
+	assert.Assert(t, errors.Is(err, syscall.ENOENT))
+
+	err = &ExtendedError{Offset: 44, Err: syscall.EINVAL}
+	assert.Error(t, err, "invalid argument (attribute at offset 44)")
+
+	err = &ExtendedError{Message: "missing attribute", Err: syscall.EINVAL}
+	assert.Error(t, err, "invalid argument: missing attribute")
+}
@@ -1,7 +1,10 @@
 package ipvs_test
 
 import (
+	"context"
 	"log"
+	"net/netip"
+	"time"
 
 	"github.com/cloudflare/ipvs"
 )
@@ -12,18 +15,58 @@ func Example() {
 		log.Fatalf("error updating service: %v", err)
 	}
 
-	services, err := c.Services()
+	services, err := c.Services(context.Background())
 	if err != nil {
 		log.Fatalf("error fetching services: %v", err)
 	}
 
 	for _, svc := range services {
 		log.Printf("%s:%d/%s %s", svc.Address, svc.Port, svc.Protocol, svc.Scheduler)
-		svc.Scheduler = "rr"
+		svc.Scheduler = ipvs.RoundRobin
 
-		err := c.UpdateService(svc.Service)
+		err := c.UpdateService(context.Background(), svc.Service)
 		if err != nil {
 			log.Fatalf("error updating service: %v", err)
 		}
 	}
 }
+
+func ExampleNewClient() {
+	c, err := ipvs.NewClient(
+		ipvs.WithNetNSPath("/var/run/netns/blue"),
+		ipvs.WithTimeout(5*time.Second),
+		ipvs.WithReadBuffer(1<<20),
+	)
+	if err != nil {
+		log.Fatalf("error connecting to ipvs: %v", err)
+	}
+	defer c.Close()
+
+	info, err := c.Info(context.Background())
+	if err != nil {
+		log.Fatalf("error fetching info: %v", err)
+	}
+
+	log.Printf("IPVS version %d.%d.%d", info.Version[0], info.Version[1], info.Version[2])
+}
+
+func ExampleFlags() {
+	c, err := ipvs.New()
+	if err != nil {
+		log.Fatalf("error connecting to ipvs: %v", err)
+	}
+	defer c.Close()
+
+	svc := ipvs.Service{
+		Address:   netip.MustParseAddr("192.0.2.1"),
+		Port:      80,
+		Family:    ipvs.INET,
+		Protocol:  ipvs.TCP,
+		Scheduler: ipvs.MaglevHashing,
+		Flags:     ipvs.MaglevFallback | ipvs.MaglevPort,
+	}
+
+	if err := c.CreateService(context.Background(), svc); err != nil {
+		log.Fatalf("error creating service: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"net/netip"
+	"os"
+)
+
+// PublishExpvar publishes a snapshot of all services, their destinations and
+// counters as an expvar variable called name, served as JSON under
+// /debug/vars. Like expvar.Publish, it panics if name is already registered.
+func PublishExpvar(name string, c Client) {
+	expvar.Publish(name, ExpvarFunc(c))
+}
+
+// ExpvarFunc returns an expvar.Func taking a snapshot of all services, their
+// destinations and counters through c each time it is read. Failures to list
+// the services are reported in the "error" field of the snapshot.
+func ExpvarFunc(c Client) expvar.Func {
+	return func() any {
+		return expvarSnapshotOf(c)
+	}
+}
+
+// expvarSnapshot is the JSON representation published by ExpvarFunc.
+type expvarSnapshot struct {
+	Services []expvarService `json:"services"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type expvarService struct {
+	Service      ServiceKey          `json:"service"`
+	Scheduler    Scheduler           `json:"scheduler"`
+	Stats        expvarStats         `json:"stats"`
+	Destinations []expvarDestination `json:"destinations"`
+}
+
+type expvarDestination struct {
+	Address             netip.AddrPort `json:"address"`
+	Weight              uint32         `json:"weight"`
+	ActiveConnections   uint32         `json:"active_connections"`
+	InactiveConnections uint32         `json:"inactive_connections"`
+	Stats               expvarStats    `json:"stats"`
+}
+
+type expvarStats struct {
+	Connections        uint64 `json:"connections"`
+	IncomingPackets    uint64 `json:"incoming_packets"`
+	OutgoingPackets    uint64 `json:"outgoing_packets"`
+	IncomingBytes      uint64 `json:"incoming_bytes"`
+	OutgoingBytes      uint64 `json:"outgoing_bytes"`
+	ConnectionRate     uint64 `json:"connection_rate"`
+	IncomingPacketRate uint64 `json:"incoming_packet_rate"`
+	OutgoingPacketRate uint64 `json:"outgoing_packet_rate"`
+	IncomingByteRate   uint64 `json:"incoming_byte_rate"`
+	OutgoingByteRate   uint64 `json:"outgoing_byte_rate"`
+}
+
+// expvarSnapshotOf lists the services through c as an expvarSnapshot. An
+// empty table lists no services.
+func expvarSnapshotOf(c Client) expvarSnapshot {
+	svcs, err := c.ServicesWithDestinations(context.Background())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return expvarSnapshot{Services: []expvarService{}, Error: err.Error()}
+	}
+
+	snapshot := expvarSnapshot{Services: make([]expvarService, 0, len(svcs))}
+	for _, svc := range svcs {
+		s := expvarService{
+			Service:      svc.Key(),
+			Scheduler:    svc.Scheduler,
+			Stats:        expvarStats(svc.Stats),
+			Destinations: make([]expvarDestination, 0, len(svc.Destinations)),
+		}
+
+		for _, dest := range svc.Destinations {
+			s.Destinations = append(s.Destinations, expvarDestination{
+				Address:             netip.AddrPortFrom(dest.Address, dest.Port),
+				Weight:              dest.Weight,
+				ActiveConnections:   dest.ActiveConnections,
+				InactiveConnections: dest.InactiveConnections,
+				Stats:               expvarStats(dest.Stats),
+			})
+		}
+
+		snapshot.Services = append(snapshot.Services, s)
+	}
+
+	return snapshot
+}
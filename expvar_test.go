@@ -0,0 +1,40 @@
+package ipvs
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestExpvarFunc(t *testing.T) {
+	c := &statsClient{polls: [][]ServiceWithDestinations{{{
+		ServiceExtended: ServiceExtended{
+			Service: Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin},
+			Stats:   Stats{Connections: 3, IncomingBytes: 300, ConnectionRate: 1},
+		},
+		Destinations: []DestinationExtended{{
+			Destination:       Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 10},
+			ActiveConnections: 2,
+			Stats:             Stats{Connections: 3},
+		}},
+	}}, nil}, notExist: true}
+
+	f := ExpvarFunc(c)
+
+	b, err := json.Marshal(f.Value())
+	assert.NilError(t, err)
+
+	expected := `{"services":[{"service":"TCP 192.0.2.1:80","scheduler":"rr",` +
+		`"stats":{"connections":3,"incoming_packets":0,"outgoing_packets":0,"incoming_bytes":300,"outgoing_bytes":0,` +
+		`"connection_rate":1,"incoming_packet_rate":0,"outgoing_packet_rate":0,"incoming_byte_rate":0,"outgoing_byte_rate":0},` +
+		`"destinations":[{"address":"198.51.100.1:8080","weight":10,"active_connections":2,"inactive_connections":0,` +
+		`"stats":{"connections":3,"incoming_packets":0,"outgoing_packets":0,"incoming_bytes":0,"outgoing_bytes":0,` +
+		`"connection_rate":0,"incoming_packet_rate":0,"outgoing_packet_rate":0,"incoming_byte_rate":0,"outgoing_byte_rate":0}}]}]}`
+	assert.Equal(t, string(b), expected)
+
+	// Empty tables list no services.
+	assert.Equal(t, f.String(), `{"services":[]}`)
+	assert.Equal(t, f.String(), `{"services":[],"error":"no more polls"}`)
+}
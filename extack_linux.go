@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"errors"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/josharian/native"
+	"github.com/mdlayher/netlink"
+)
+
+// Sizes of the headers preceding the attributes of a request, which the
+// offsets reported in extended acknowledgements account for.
+const (
+	nlmsgHeaderLen = 16
+	genlHeaderLen  = 4
+)
+
+// Names of the request attributes, as in the kernel headers, for reporting
+// the attribute IPVS rejected.
+var (
+	cmdAttrNames = map[uint16]string{
+		cipvs.CmdAttrService:       "IPVS_CMD_ATTR_SERVICE",
+		cipvs.CmdAttrDest:          "IPVS_CMD_ATTR_DEST",
+		cipvs.CmdAttrDaemon:        "IPVS_CMD_ATTR_DAEMON",
+		cipvs.CmdAttrTimeoutTcp:    "IPVS_CMD_ATTR_TIMEOUT_TCP",
+		cipvs.CmdAttrTimeoutTcpFin: "IPVS_CMD_ATTR_TIMEOUT_TCP_FIN",
+		cipvs.CmdAttrTimeoutUdp:    "IPVS_CMD_ATTR_TIMEOUT_UDP",
+	}
+	svcAttrNames = map[uint16]string{
+		cipvs.SvcAttrAf:        "IPVS_SVC_ATTR_AF",
+		cipvs.SvcAttrProtocol:  "IPVS_SVC_ATTR_PROTOCOL",
+		cipvs.SvcAttrAddr:      "IPVS_SVC_ATTR_ADDR",
+		cipvs.SvcAttrPort:      "IPVS_SVC_ATTR_PORT",
+		cipvs.SvcAttrFwmark:    "IPVS_SVC_ATTR_FWMARK",
+		cipvs.SvcAttrSchedName: "IPVS_SVC_ATTR_SCHED_NAME",
+		cipvs.SvcAttrFlags:     "IPVS_SVC_ATTR_FLAGS",
+		cipvs.SvcAttrTimeout:   "IPVS_SVC_ATTR_TIMEOUT",
+		cipvs.SvcAttrNetmask:   "IPVS_SVC_ATTR_NETMASK",
+		cipvs.SvcAttrPeName:    "IPVS_SVC_ATTR_PE_NAME",
+	}
+	destAttrNames = map[uint16]string{
+		cipvs.DestAttrAddr:       "IPVS_DEST_ATTR_ADDR",
+		cipvs.DestAttrPort:       "IPVS_DEST_ATTR_PORT",
+		cipvs.DestAttrFwdMethod:  "IPVS_DEST_ATTR_FWD_METHOD",
+		cipvs.DestAttrWeight:     "IPVS_DEST_ATTR_WEIGHT",
+		cipvs.DestAttrUThresh:    "IPVS_DEST_ATTR_U_THRESH",
+		cipvs.DestAttrLThresh:    "IPVS_DEST_ATTR_L_THRESH",
+		cipvs.DestAttrAddrFamily: "IPVS_DEST_ATTR_ADDR_FAMILY",
+		cipvs.DestAttrTunType:    "IPVS_DEST_ATTR_TUN_TYPE",
+		cipvs.DestAttrTunPort:    "IPVS_DEST_ATTR_TUN_PORT",
+		cipvs.DestAttrTunFlags:   "IPVS_DEST_ATTR_TUN_FLAGS",
+	}
+	nestedAttrNames = map[uint16]map[uint16]string{
+		cipvs.CmdAttrService: svcAttrNames,
+		cipvs.CmdAttrDest:    destAttrNames,
+	}
+)
+
+// extendedError returns err, returned by IPVS for a request with the
+// attributes data, with the explanation of the kernel if it gave one.
+func extendedError(err error, data []byte) error {
+	var opErr *netlink.OpError
+	if !errors.As(err, &opErr) || (opErr.Message == "" && opErr.Offset == 0) {
+		return err
+	}
+
+	// ExtendedError reports the explanation, so strip it from the wrapped
+	// error to avoid repeating it.
+	stripped := *opErr
+	stripped.Message, stripped.Offset = "", 0
+
+	return &ExtendedError{
+		Message:   opErr.Message,
+		Offset:    opErr.Offset,
+		Attribute: attributeName(data, opErr.Offset-nlmsgHeaderLen-genlHeaderLen, cmdAttrNames, nestedAttrNames),
+		Err:       &stripped,
+	}
+}
+
+// attributeName returns the name of the attribute starting at off in the
+// attributes b, looking up nested attributes in nested, or "" if unknown.
+func attributeName(b []byte, off int, names map[uint16]string, nested map[uint16]map[uint16]string) string {
+	for pos := 0; pos+4 <= len(b) && pos <= off; {
+		length := int(native.Endian.Uint16(b[pos:]))
+		typ := native.Endian.Uint16(b[pos+2:]) &^ (netlink.Nested | netlink.NetByteOrder)
+		if length < 4 || pos+length > len(b) {
+			return ""
+		}
+
+		switch {
+		case off == pos:
+			return names[typ]
+		case off < pos+length:
+			if inner, ok := nested[typ]; ok {
+				return attributeName(b[pos+4:pos+length], off-pos-4, inner, nil)
+			}
+			return ""
+		}
+
+		pos += (length + 3) &^ 3
+	}
+
+	return ""
+}
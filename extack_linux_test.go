@@ -0,0 +1,116 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"github.com/mdlayher/netlink/nltest"
+	"gotest.tools/v3/assert"
+)
+
+// extAckError returns an error acknowledgement of req with errno, explained
+// by msg and the attribute at off, as sent by kernels supporting extended
+// acknowledgements.
+func extAckError(req netlink.Message, errno syscall.Errno, msg string, off int) netlink.Message {
+	// The request is echoed capped to its header, followed by the TLVs.
+	hdr := req.Header
+	hdr.Length = nlmsgHeaderLen
+	echo := make([]byte, 0, nlmsgHeaderLen)
+	echo = append(echo, nlenc.Uint32Bytes(hdr.Length)...)
+	echo = append(echo, nlenc.Uint16Bytes(uint16(hdr.Type))...)
+	echo = append(echo, nlenc.Uint16Bytes(uint16(hdr.Flags))...)
+	echo = append(echo, nlenc.Uint32Bytes(hdr.Sequence)...)
+	echo = append(echo, nlenc.Uint32Bytes(hdr.PID)...)
+
+	data := nlenc.Int32Bytes(-int32(errno))
+	data = append(data, echo...)
+	data = append(data, nltest.MustMarshalAttributes([]netlink.Attribute{
+		{Type: 1, Data: nlenc.Bytes(msg)},
+		{Type: 2, Data: nlenc.Uint32Bytes(uint32(off))},
+	})...)
+
+	return netlink.Message{
+		Header: netlink.Header{
+			Type:     netlink.Error,
+			Flags:    netlink.Capped | netlink.AcknowledgeTLVs,
+			Sequence: req.Header.Sequence,
+			PID:      req.Header.PID,
+		},
+		Data: data,
+	}
+}
+
+func TestClient_ExtendedError(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+
+	client := nltestClient(t, func(reqs []netlink.Message) ([]netlink.Message, error) {
+		// Point at the scheduler name, preceded by its attribute header.
+		off := nlmsgHeaderLen + bytes.Index(reqs[0].Data, []byte("rr\x00")) - 4
+		return []netlink.Message{extAckError(reqs[0], syscall.ENOENT, "scheduler not found", off)}, nil
+	})
+	defer client.Close()
+
+	err := client.CreateService(context.Background(), svc)
+
+	var extErr *ExtendedError
+	assert.Assert(t, errors.As(err, &extErr), "got error: %v", err)
+	assert.Equal(t, extErr.Message, "scheduler not found")
+	assert.Equal(t, extErr.Attribute, "IPVS_SVC_ATTR_SCHED_NAME")
+	assert.Assert(t, errors.Is(err, ErrSchedulerNotAvailable))
+	assert.Assert(t, errors.Is(err, syscall.ENOENT))
+	assert.ErrorContains(t, err, "netlink receive: no such file or directory: scheduler not found (attribute IPVS_SVC_ATTR_SCHED_NAME at offset")
+}
+
+func TestAttributeName(t *testing.T) {
+	type testCase struct {
+		name     string
+		off      int
+		expected string
+	}
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+	buf := encodeRequest(svc, &dest)
+	defer releaseRequest(buf)
+	b := *buf
+
+	ad, err := netlink.NewAttributeDecoder(b)
+	assert.NilError(t, err)
+	assert.Assert(t, ad.Next())
+	svcLen := len(ad.Bytes()) + 4
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, attributeName(b, tc.off, cmdAttrNames, nestedAttrNames), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "service", off: 0, expected: "IPVS_CMD_ATTR_SERVICE"},
+		{name: "service family", off: 4, expected: "IPVS_SVC_ATTR_AF"},
+		{
+			name:     "scheduler",
+			off:      bytes.Index(b, []byte("rr\x00")) - 4,
+			expected: "IPVS_SVC_ATTR_SCHED_NAME",
+		},
+		{name: "destination", off: svcLen, expected: "IPVS_CMD_ATTR_DEST"},
+		{name: "destination family", off: svcLen + 4, expected: "IPVS_DEST_ATTR_ADDR_FAMILY"},
+		{name: "inside attribute", off: 6, expected: ""},
+		{name: "out of range", off: len(b) + 4, expected: ""},
+		{name: "negative", off: -4, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package ipvs
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// String returns the service in the form listed by "ipvsadm -Ln", such as
+// "TCP  192.0.2.1:80 rr persistent 300".
+func (svc Service) String() string {
+	var b strings.Builder
+	if svc.FWMark != 0 {
+		b.WriteString("FWM  ")
+		b.WriteString(strconv.FormatUint(uint64(svc.FWMark), 10))
+		if svc.Family == INET6 {
+			b.WriteString(" IPv6")
+		}
+	} else {
+		b.WriteString(svc.Protocol.String())
+		b.WriteString("  ")
+		b.WriteString(netip.AddrPortFrom(svc.Address, svc.Port).String())
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(string(svc.Scheduler))
+
+	if flags := schedulerFlags(svc.Scheduler, svc.Flags); len(flags) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(flags, ","))
+	}
+
+	if svc.Flags&ServicePersistent != 0 {
+		fmt.Fprintf(&b, " persistent %d", svc.Timeout)
+		if mask := svc.Netmask; mask.IsValid() && mask != defaultNetmask(svc.Family) {
+			b.WriteString(" mask ")
+			if mask.Is6() {
+				b.WriteString(strconv.Itoa(mask.Bits()))
+			} else {
+				b.WriteString(mask.String())
+			}
+		}
+	}
+
+	if svc.PEName != "" {
+		b.WriteString(" pe ")
+		b.WriteString(svc.PEName)
+	}
+
+	if svc.Flags&ServiceOnePacket != 0 {
+		b.WriteString(" ops")
+	}
+
+	return b.String()
+}
+
+// schedulerFlags returns the names ipvsadm uses for the scheduler flags of a
+// service.
+func schedulerFlags(s Scheduler, flags Flags) []string {
+	names := [3]string{"flag-1", "flag-2", "flag-3"}
+	switch s {
+	case SourceHashing:
+		names[0], names[1] = "sh-fallback", "sh-port"
+	case MaglevHashing:
+		names[0], names[1] = "mh-fallback", "mh-port"
+	}
+
+	var out []string
+	for i, flag := range [3]Flags{ServiceSchedulerOpt1, ServiceSchedulerOpt2, ServiceSchedulerOpt3} {
+		if flags&flag != 0 {
+			out = append(out, names[i])
+		}
+	}
+
+	return out
+}
+
+// String returns the destination in the form listed by "ipvsadm -Ln", such
+// as "198.51.100.1:8080 Route 1".
+func (dest Destination) String() string {
+	return fmt.Sprintf("%s %s %d", netip.AddrPortFrom(dest.Address, dest.Port), forwardName(dest.FwdMethod), dest.Weight)
+}
+
+// forwardName returns the name ipvsadm uses for a forwarding method.
+func forwardName(fwd ForwardType) string {
+	switch fwd {
+	case Masquerade:
+		return "Masq"
+	case Local:
+		return "Local"
+	case Tunnel:
+		return "Tunnel"
+	case DirectRoute:
+		return "Route"
+	case Bypass:
+		return "Bypass"
+	}
+
+	return fwd.String()
+}
+
+// FormatTable returns svcs in the table layout of "ipvsadm -Ln", without its
+// version line, which can be obtained from Info.String.
+func FormatTable(svcs []ServiceWithDestinations) string {
+	var b strings.Builder
+	b.WriteString("Prot LocalAddress:Port Scheduler Flags\n")
+	b.WriteString("  -> RemoteAddress:Port           Forward Weight ActiveConn InActConn\n")
+
+	for _, svc := range svcs {
+		b.WriteString(svc.Service.String())
+		b.WriteByte('\n')
+
+		for _, dest := range svc.Destinations {
+			fmt.Fprintf(&b, "  -> %-28s %-7s %-6d %-10d %-10d\n",
+				netip.AddrPortFrom(dest.Address, dest.Port), forwardName(dest.FwdMethod), dest.Weight,
+				dest.ActiveConnections, dest.InactiveConnections)
+		}
+	}
+
+	return b.String()
+}
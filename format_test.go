@@ -0,0 +1,100 @@
+package ipvs
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/ipvs/netmask"
+	"gotest.tools/v3/assert"
+)
+
+func TestService_String(t *testing.T) {
+	type testCase struct {
+		name     string
+		svc      Service
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.svc.String(), tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "tcp",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin},
+			expected: "TCP  192.0.2.1:80 rr",
+		},
+		{
+			name:     "ipv6",
+			svc:      Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 53, Family: INET6, Protocol: UDP, Scheduler: WeightedLeastConnection},
+			expected: "UDP  [2001:db8::1]:53 wlc",
+		},
+		{
+			name:     "fwmark",
+			svc:      Service{FWMark: 42, Family: INET6, Scheduler: MaglevHashing, Flags: MaglevFallback | MaglevPort},
+			expected: "FWM  42 IPv6 mh (mh-fallback,mh-port)",
+		},
+		{
+			name:     "generic scheduler flags",
+			svc:      Service{FWMark: 1, Family: INET, Scheduler: RoundRobin, Flags: ServiceSchedulerOpt3},
+			expected: "FWM  1 rr (flag-3)",
+		},
+		{
+			name:     "persistent",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 443, Family: INET, Protocol: TCP, Scheduler: SourceHashing, Flags: ServicePersistent, Timeout: 300, Netmask: netmask.MaskFrom(32, 32)},
+			expected: "TCP  192.0.2.1:443 sh persistent 300",
+		},
+		{
+			name:     "persistent with mask",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 443, Family: INET, Protocol: TCP, Scheduler: RoundRobin, Flags: ServicePersistent, Timeout: 300, Netmask: netmask.MaskFrom(24, 32)},
+			expected: "TCP  192.0.2.1:443 rr persistent 300 mask 255.255.255.0",
+		},
+		{
+			name:     "persistent with ipv6 mask",
+			svc:      Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 443, Family: INET6, Protocol: TCP, Scheduler: RoundRobin, Flags: ServicePersistent, Timeout: 60, Netmask: netmask.MaskFrom(64, 128)},
+			expected: "TCP  [2001:db8::1]:443 rr persistent 60 mask 64",
+		},
+		{
+			name:     "persistence engine and one packet",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 5060, Family: INET, Protocol: UDP, Scheduler: RoundRobin, Flags: ServicePersistent | ServiceOnePacket, Timeout: 360, PEName: "sip"},
+			expected: "UDP  192.0.2.1:5060 rr persistent 360 pe sip ops",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestDestination_String(t *testing.T) {
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, FwdMethod: DirectRoute, Weight: 5}
+	assert.Equal(t, dest.String(), "198.51.100.1:8080 Route 5")
+}
+
+func TestFormatTable(t *testing.T) {
+	svcs := []ServiceWithDestinations{
+		{
+			ServiceExtended: ServiceExtended{Service: Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}},
+			Destinations: []DestinationExtended{
+				{Destination: Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, FwdMethod: Masquerade, Weight: 1}, ActiveConnections: 12, InactiveConnections: 3},
+				{Destination: Destination{Address: netip.MustParseAddr("198.51.100.2"), Port: 8080, Family: INET, FwdMethod: Tunnel, Weight: 0}},
+			},
+		},
+		{
+			ServiceExtended: ServiceExtended{Service: Service{FWMark: 7, Family: INET, Scheduler: WeightedLeastConnection}},
+		},
+	}
+
+	expected := `Prot LocalAddress:Port Scheduler Flags
+  -> RemoteAddress:Port           Forward Weight ActiveConn InActConn
+TCP  192.0.2.1:80 rr
+  -> 198.51.100.1:8080            Masq    1      12         3         
+  -> 198.51.100.2:8080            Tunnel  0      0          0         
+FWM  7 wlc
+`
+	assert.Equal(t, FormatTable(svcs), expected)
+}
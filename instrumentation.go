@@ -0,0 +1,48 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"time"
+)
+
+// Instrumentation observes the netlink operations made by a Client, so that
+// they can be traced, or counted by operation, duration and errno.
+// Implementations must be safe for concurrent use.
+type Instrumentation interface {
+	// StartOp is called when the Client starts the operation op, the name of
+	// the IPVS command such as "new_service" or "get_dest". The returned
+	// context is used for the operation, so a span can be attached to it,
+	// and the returned func is called once the operation completes.
+	StartOp(ctx context.Context, op string) (context.Context, func(OpResult))
+}
+
+// OpResult describes a completed operation.
+type OpResult struct {
+	// Op is the name of the IPVS command.
+	Op string
+	// Duration includes waiting for operations made concurrently on the
+	// same Client.
+	Duration time.Duration
+	// Err is the error of the operation, if any.
+	Err error
+	// Errno is the errno returned by IPVS, or zero if the operation
+	// succeeded or failed for another reason.
+	Errno syscall.Errno
+}
+
+// WithInstrumentation makes the Client report each netlink operation to i.
+func WithInstrumentation(i Instrumentation) Option {
+	return func(o *options) {
+		o.instrumentation = i
+	}
+}
+
+// newOpResult returns the OpResult of op, which took d and returned err.
+func newOpResult(op string, d time.Duration, err error) OpResult {
+	r := OpResult{Op: op, Duration: d, Err: err}
+	errors.As(err, &r.Errno)
+
+	return r
+}
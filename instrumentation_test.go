@@ -0,0 +1,26 @@
+package ipvs
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNewOpResult(t *testing.T) {
+	r := newOpResult("get_service", time.Second, nil)
+	assert.Equal(t, r.Op, "get_service")
+	assert.Equal(t, r.Duration, time.Second)
+	assert.Equal(t, r.Errno, syscall.Errno(0))
+
+	err := fmt.Errorf("wrapped: %w", syscall.ENOENT)
+	r = newOpResult("new_service", time.Second, err)
+	assert.Equal(t, r.Err, err)
+	assert.Equal(t, r.Errno, syscall.ENOENT)
+
+	r = newOpResult("new_service", time.Second, errors.New("other"))
+	assert.Equal(t, r.Errno, syscall.Errno(0))
+}
@@ -0,0 +1,3 @@
+// Package netns runs functions in another network namespace, for the files of
+// /proc which, unlike netlink sockets, cannot be opened in one directly.
+package netns
@@ -0,0 +1,56 @@
+//go:build linux
+// +build linux
+
+package netns
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// Do calls fn on a thread in the network namespace at path, or of the open
+// file descriptor fd, or in the current namespace if neither is set. The
+// files of /proc/sys/net and /proc/thread-self/net opened by fn are those of
+// that namespace.
+func Do(path string, fd int, fn func() error) error {
+	if path == "" && fd == 0 {
+		return fn()
+	}
+
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening network namespace: %w", err)
+		}
+		defer f.Close()
+
+		fd = int(f.Fd())
+	}
+
+	runtime.LockOSThread()
+
+	orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+	if err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("opening network namespace: %w", err)
+	}
+	defer orig.Close()
+
+	if err := unix.Setns(fd, unix.CLONE_NEWNET); err != nil {
+		runtime.UnlockOSThread()
+		return fmt.Errorf("entering network namespace: %w", err)
+	}
+
+	defer func() {
+		// A thread that cannot return to its namespace stays locked, so
+		// that it exits with the goroutine.
+		if unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread()
+		}
+	}()
+
+	return fn()
+}
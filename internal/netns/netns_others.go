@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package netns
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Do calls fn, as network namespaces only exist on Linux.
+func Do(path string, fd int, fn func() error) error {
+	if path != "" || fd != 0 {
+		return fmt.Errorf("network namespaces are not implemented on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	return fn()
+}
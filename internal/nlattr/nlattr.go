@@ -0,0 +1,87 @@
+// Package nlattr appends netlink attributes to byte slices. Unlike
+// netlink.AttributeEncoder, it builds no intermediate list of attributes, so
+// encoding into a reused buffer does not allocate.
+package nlattr
+
+import "github.com/josharian/native"
+
+// headerLen is the length of the header of an attribute.
+const headerLen = 4
+
+// align rounds n up to the alignment of netlink attributes.
+func align(n int) int {
+	return (n + 3) &^ 3
+}
+
+// appendHeader appends the header of an attribute of typ holding n bytes.
+func appendHeader(b []byte, typ uint16, n int) []byte {
+	var h [headerLen]byte
+	native.Endian.PutUint16(h[0:2], uint16(headerLen+n))
+	native.Endian.PutUint16(h[2:4], typ)
+	return append(b, h[:]...)
+}
+
+// pad appends the padding of an attribute holding n bytes.
+func pad(b []byte, n int) []byte {
+	var zero [3]byte
+	return append(b, zero[:align(n)-n]...)
+}
+
+// AppendBytes appends an attribute of typ holding v.
+func AppendBytes(b []byte, typ uint16, v []byte) []byte {
+	b = appendHeader(b, typ, len(v))
+	b = append(b, v...)
+	return pad(b, len(v))
+}
+
+// AppendString appends an attribute of typ holding v, terminated by a NUL
+// byte.
+func AppendString(b []byte, typ uint16, v string) []byte {
+	b = appendHeader(b, typ, len(v)+1)
+	b = append(b, v...)
+	b = append(b, 0)
+	return pad(b, len(v)+1)
+}
+
+// AppendUint8 appends an attribute of typ holding v.
+func AppendUint8(b []byte, typ uint16, v uint8) []byte {
+	b = appendHeader(b, typ, 1)
+	b = append(b, v)
+	return pad(b, 1)
+}
+
+// AppendUint16 appends an attribute of typ holding v in native byte order.
+func AppendUint16(b []byte, typ uint16, v uint16) []byte {
+	var buf [2]byte
+	native.Endian.PutUint16(buf[:], v)
+	return AppendBytes(b, typ, buf[:])
+}
+
+// AppendUint32 appends an attribute of typ holding v in native byte order.
+func AppendUint32(b []byte, typ uint16, v uint32) []byte {
+	var buf [4]byte
+	native.Endian.PutUint32(buf[:], v)
+	return AppendBytes(b, typ, buf[:])
+}
+
+// AppendBigEndianUint16 appends an attribute of typ holding v in network byte
+// order, as used for ports.
+func AppendBigEndianUint16(b []byte, typ uint16, v uint16) []byte {
+	buf := [2]byte{byte(v >> 8), byte(v)}
+	return AppendBytes(b, typ, buf[:])
+}
+
+// Begin appends the header of an attribute of typ holding nested attributes,
+// which are appended next. It returns the offset of the attribute, to be
+// passed to End once the nested attributes are appended.
+//
+// Like netlink.AttributeEncoder.Do, the NLA_F_NESTED flag is not set.
+func Begin(b []byte, typ uint16) ([]byte, int) {
+	return appendHeader(b, typ, 0), len(b)
+}
+
+// End completes the attribute begun at off, setting its length.
+func End(b []byte, off int) []byte {
+	native.Endian.PutUint16(b[off:off+2], uint16(len(b)-off))
+	return b
+}
@@ -0,0 +1,53 @@
+package nlattr
+
+import (
+	"testing"
+
+	"github.com/mdlayher/netlink"
+	"gotest.tools/v3/assert"
+)
+
+func TestAppend(t *testing.T) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint8(1, 0x12)
+	ae.Uint16(2, 0x1234)
+	ae.Uint32(3, 0x12345678)
+	ae.String(4, "wlc")
+	ae.String(5, "")
+	ae.Bytes(6, []byte{1, 2, 3, 4, 5})
+	ae.Bytes(7, []byte{0x00, 0x50})
+	ae.Do(8, func() ([]byte, error) {
+		nae := netlink.NewAttributeEncoder()
+		nae.Uint16(1, 2)
+		nae.String(2, "rr")
+		return nae.Encode()
+	})
+	expected, err := ae.Encode()
+	assert.NilError(t, err)
+
+	b := AppendUint8(nil, 1, 0x12)
+	b = AppendUint16(b, 2, 0x1234)
+	b = AppendUint32(b, 3, 0x12345678)
+	b = AppendString(b, 4, "wlc")
+	b = AppendString(b, 5, "")
+	b = AppendBytes(b, 6, []byte{1, 2, 3, 4, 5})
+	b = AppendBigEndianUint16(b, 7, 80)
+	b, off := Begin(b, 8)
+	b = AppendUint16(b, 1, 2)
+	b = AppendString(b, 2, "rr")
+	b = End(b, off)
+
+	assert.DeepEqual(t, b, expected)
+}
+
+func TestAppend_Allocs(t *testing.T) {
+	buf := make([]byte, 0, 256)
+	allocs := testing.AllocsPerRun(100, func() {
+		b, off := Begin(buf[:0], 1)
+		b = AppendUint32(b, 1, 42)
+		b = AppendString(b, 2, "wlc")
+		b = AppendBigEndianUint16(b, 3, 80)
+		End(b, off)
+	})
+	assert.Equal(t, allocs, float64(0))
+}
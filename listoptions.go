@@ -0,0 +1,74 @@
+package ipvs
+
+// ListOption filters the Services returned by Client.Services and
+// Client.ServicesWithDestinations. Filters are applied while decoding, so
+// Services which do not match are never materialized.
+type ListOption func(*listOptions)
+
+// listOptions holds the filters built from a list of ListOptions.
+type listOptions struct {
+	family    AddressFamily
+	protocol  Protocol
+	ports     bool
+	minPort   uint16
+	maxPort   uint16
+	scheduler Scheduler
+}
+
+// buildListOptions applies opts on top of the defaults, which match every
+// Service.
+func buildListOptions(opts []ListOption) listOptions {
+	var o listOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// match reports whether svc passes the filters.
+func (o listOptions) match(svc Service) bool {
+	switch {
+	case o.family != 0 && svc.Family != o.family:
+		return false
+	case o.protocol != 0 && (svc.FWMark != 0 || svc.Protocol != o.protocol):
+		return false
+	case o.ports && (svc.FWMark != 0 || svc.Port < o.minPort || svc.Port > o.maxPort):
+		return false
+	case o.scheduler != "" && svc.Scheduler != o.scheduler:
+		return false
+	}
+
+	return true
+}
+
+// MatchFamily only lists Services of the address family f.
+func MatchFamily(f AddressFamily) ListOption {
+	return func(o *listOptions) {
+		o.family = f
+	}
+}
+
+// MatchProtocol only lists Services of the protocol p. Firewall mark Services
+// have no protocol, so they never match.
+func MatchProtocol(p Protocol) ListOption {
+	return func(o *listOptions) {
+		o.protocol = p
+	}
+}
+
+// MatchPortRange only lists Services with a port between first and last,
+// inclusive. Firewall mark Services have no port, so they never match.
+func MatchPortRange(first, last uint16) ListOption {
+	return func(o *listOptions) {
+		o.ports = true
+		o.minPort, o.maxPort = first, last
+	}
+}
+
+// MatchScheduler only lists Services using the scheduler s.
+func MatchScheduler(s Scheduler) ListOption {
+	return func(o *listOptions) {
+		o.scheduler = s
+	}
+}
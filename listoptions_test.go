@@ -0,0 +1,47 @@
+package ipvs
+
+import (
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestListOptions_Match(t *testing.T) {
+	type testCase struct {
+		name     string
+		opts     []ListOption
+		svc      Service
+		expected bool
+	}
+
+	web := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	fwm := Service{FWMark: 1, Family: INET6, Scheduler: MaglevHashing}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, buildListOptions(tc.opts).match(tc.svc), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "no filters", svc: web, expected: true},
+		{name: "family", opts: []ListOption{MatchFamily(INET)}, svc: web, expected: true},
+		{name: "other family", opts: []ListOption{MatchFamily(INET6)}, svc: web},
+		{name: "protocol", opts: []ListOption{MatchProtocol(TCP)}, svc: web, expected: true},
+		{name: "other protocol", opts: []ListOption{MatchProtocol(UDP)}, svc: web},
+		{name: "protocol of fwmark", opts: []ListOption{MatchProtocol(TCP)}, svc: fwm},
+		{name: "port range", opts: []ListOption{MatchPortRange(80, 443)}, svc: web, expected: true},
+		{name: "single port", opts: []ListOption{MatchPortRange(80, 80)}, svc: web, expected: true},
+		{name: "outside port range", opts: []ListOption{MatchPortRange(81, 443)}, svc: web},
+		{name: "port range of fwmark", opts: []ListOption{MatchPortRange(0, 65535)}, svc: fwm},
+		{name: "scheduler", opts: []ListOption{MatchScheduler(MaglevHashing)}, svc: fwm, expected: true},
+		{name: "other scheduler", opts: []ListOption{MatchScheduler(MaglevHashing)}, svc: web},
+		{name: "combined", opts: []ListOption{MatchFamily(INET), MatchProtocol(TCP), MatchPortRange(1, 1024)}, svc: web, expected: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package ipvs
+
+import "context"
+
+// mutationLogger logs the changes a Client makes to IPVS, and the requests it
+// retries. It keeps the Client independent of log/slog, which requires Go
+// 1.21, see WithLogger.
+type mutationLogger interface {
+	// enabled reports whether mutations are logged, so that the state before
+	// an update is only fetched when needed.
+	enabled(ctx context.Context) bool
+	mutation(ctx context.Context, m mutation)
+	retry(ctx context.Context, op string, err error)
+}
+
+// mutation describes a change made by a Client.
+type mutation struct {
+	op string
+	// service is the service changed, or that the destination changed
+	// belongs to, if any.
+	service *ServiceKey
+	// before and after are the state before and after the change, nil when
+	// there is none or it is unknown.
+	before, after any
+	err           error
+	// dryRun reports whether the change was not sent to IPVS, see
+	// WithDryRun.
+	dryRun bool
+	// result, if set, fetches the state in IPVS after the change, for
+	// auditing.
+	result func() (any, error)
+}
@@ -0,0 +1,98 @@
+//go:build linux && go1.21
+// +build linux,go1.21
+
+package ipvs
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/genetlink/genltest"
+	"github.com/mdlayher/netlink"
+	"gotest.tools/v3/assert"
+)
+
+func TestClient_Logger(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		switch gerq.Header.Command {
+		case cipvs.CmdGetService:
+			return nil, genltest.Error(int(syscall.ESRCH))
+		case cipvs.CmdDelService:
+			return nil, genltest.Error(int(syscall.ESRCH))
+		}
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	var buf bytes.Buffer
+	removeTime := func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{ReplaceAttr: removeTime}))
+
+	var o options
+	WithLogger(l, LogLevels{Mutation: slog.LevelInfo, Retry: slog.LevelWarn})(&o)
+	client.logger = o.logger
+
+	svc := Service{
+		Address:   netip.MustParseAddr("192.0.2.1"),
+		Port:      80,
+		Family:    INET,
+		Protocol:  TCP,
+		Scheduler: RoundRobin,
+	}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+
+	ctx := context.Background()
+	assert.NilError(t, client.CreateService(ctx, svc))
+	assert.NilError(t, client.CreateDestination(ctx, svc, dest))
+	assert.NilError(t, client.UpdateService(ctx, svc))
+	assert.ErrorIs(t, client.RemoveService(ctx, svc), ErrServiceNotFound)
+	assert.NilError(t, client.Flush(ctx))
+	assert.NilError(t, client.Flush(WithDryRunContext(ctx)))
+	o.logger.retry(ctx, "get_family", ErrNotLoaded)
+
+	expected := []string{
+		`level=INFO msg="ipvs mutation" op=create_service service="TCP 192.0.2.1:80" after="TCP  192.0.2.1:80 rr"`,
+		`level=INFO msg="ipvs mutation" op=create_destination service="TCP 192.0.2.1:80" after="198.51.100.1:8080 Masq 1"`,
+		`level=INFO msg="ipvs mutation" op=update_service service="TCP 192.0.2.1:80" after="TCP  192.0.2.1:80 rr"`,
+		`level=INFO msg="ipvs mutation" op=remove_service service="TCP 192.0.2.1:80" before="TCP  192.0.2.1:80 rr" error="ipvs: service not found: netlink receive: no such process"`,
+		`level=INFO msg="ipvs mutation" op=flush`,
+		`level=INFO msg="ipvs mutation" op=flush dry_run=true`,
+		`level=WARN msg="ipvs retry" op=get_family error="ipvs: kernel module ip_vs is not loaded, load it with \"modprobe ip_vs\""`,
+	}
+	assert.DeepEqual(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), expected)
+}
+
+func TestClient_LoggerDisabled(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		if gerq.Header.Command == cipvs.CmdGetService {
+			t.Fatal("unexpected request for the state before the update")
+		}
+		return []genetlink.Message{{}}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	var o options
+	WithLogger(l, LogLevels{})(&o)
+	client.logger = o.logger
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	assert.NilError(t, client.UpdateService(context.Background(), svc))
+	assert.Equal(t, buf.String(), "")
+}
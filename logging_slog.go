@@ -0,0 +1,66 @@
+//go:build go1.21
+// +build go1.21
+
+package ipvs
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogLevels sets the levels at which a Client logs. The zero value logs at
+// slog.LevelInfo.
+type LogLevels struct {
+	// Mutation is the level of the changes made to IPVS, such as creating a
+	// service or updating a destination. Failed changes are logged at the
+	// same level, with their error.
+	Mutation slog.Level
+	// Retry is the level of the requests the Client retries, such as after
+	// loading the ip_vs module with WithModprobe.
+	Retry slog.Level
+}
+
+// WithLogger makes the Client log the changes it makes to IPVS to l, with
+// the state before and after each change, so that changes to the
+// load-balancing table can be audited. Logging updates costs an additional
+// request, to fetch the state before the change.
+func WithLogger(l *slog.Logger, levels LogLevels) Option {
+	return func(o *options) {
+		o.logger = &slogLogger{l: l, levels: levels}
+	}
+}
+
+// slogLogger implements mutationLogger with log/slog.
+type slogLogger struct {
+	l      *slog.Logger
+	levels LogLevels
+}
+
+func (s *slogLogger) enabled(ctx context.Context) bool {
+	return s.l.Enabled(ctx, s.levels.Mutation)
+}
+
+func (s *slogLogger) mutation(ctx context.Context, m mutation) {
+	attrs := []slog.Attr{slog.String("op", m.op)}
+	if m.service != nil {
+		attrs = append(attrs, slog.String("service", m.service.String()))
+	}
+	if m.before != nil {
+		attrs = append(attrs, slog.Any("before", m.before))
+	}
+	if m.after != nil {
+		attrs = append(attrs, slog.Any("after", m.after))
+	}
+	if m.err != nil {
+		attrs = append(attrs, slog.Any("error", m.err))
+	}
+	if m.dryRun {
+		attrs = append(attrs, slog.Bool("dry_run", true))
+	}
+
+	s.l.LogAttrs(ctx, s.levels.Mutation, "ipvs mutation", attrs...)
+}
+
+func (s *slogLogger) retry(ctx context.Context, op string, err error) {
+	s.l.LogAttrs(ctx, s.levels.Retry, "ipvs retry", slog.String("op", op), slog.Any("error", err))
+}
@@ -0,0 +1,96 @@
+package ipvs
+
+import (
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Metrics is a snapshot of the counters a Client keeps about its netlink
+// requests, for monitoring the health of the control-plane path itself.
+// Requests are keyed by the name of their IPVS command, as reported to
+// Instrumentation.
+type Metrics struct {
+	// Requests counts the requests made.
+	Requests map[string]uint64
+	// Durations sums the time spent on the requests, including waiting for
+	// requests made concurrently on the same Client.
+	Durations map[string]time.Duration
+	// Errors counts the failed requests by the errno IPVS returned, with
+	// zero for failures without an errno, such as timeouts.
+	Errors map[syscall.Errno]uint64
+	// DumpMessages counts the messages received in reply to dumps, such as
+	// the services or destinations listed.
+	DumpMessages map[string]uint64
+	// Retries counts the requests which were retried.
+	Retries uint64
+}
+
+// clientMetrics keeps the counters of a Client. It is safe for concurrent
+// use.
+type clientMetrics struct {
+	mu sync.Mutex
+	m  Metrics
+}
+
+// request records the completed request r, which received n messages in
+// reply to a dump.
+func (cm *clientMetrics) request(r OpResult, dump bool, n int) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.m.Requests == nil {
+		cm.m = Metrics{
+			Requests:     make(map[string]uint64),
+			Durations:    make(map[string]time.Duration),
+			Errors:       make(map[syscall.Errno]uint64),
+			DumpMessages: make(map[string]uint64),
+			Retries:      cm.m.Retries,
+		}
+	}
+
+	cm.m.Requests[r.Op]++
+	cm.m.Durations[r.Op] += r.Duration
+	if r.Err != nil {
+		cm.m.Errors[r.Errno]++
+	}
+	if dump {
+		cm.m.DumpMessages[r.Op] += uint64(n)
+	}
+}
+
+// retry records a retried request.
+func (cm *clientMetrics) retry() {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cm.m.Retries++
+}
+
+// snapshot returns a copy of the counters.
+func (cm *clientMetrics) snapshot() Metrics {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	m := Metrics{
+		Requests:     make(map[string]uint64, len(cm.m.Requests)),
+		Durations:    make(map[string]time.Duration, len(cm.m.Durations)),
+		Errors:       make(map[syscall.Errno]uint64, len(cm.m.Errors)),
+		DumpMessages: make(map[string]uint64, len(cm.m.DumpMessages)),
+		Retries:      cm.m.Retries,
+	}
+	for k, v := range cm.m.Requests {
+		m.Requests[k] = v
+	}
+	for k, v := range cm.m.Durations {
+		m.Durations[k] = v
+	}
+	for k, v := range cm.m.Errors {
+		m.Errors[k] = v
+	}
+	for k, v := range cm.m.DumpMessages {
+		m.DumpMessages[k] = v
+	}
+
+	return m
+}
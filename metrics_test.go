@@ -0,0 +1,39 @@
+package ipvs
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestClientMetrics(t *testing.T) {
+	var cm clientMetrics
+	assert.DeepEqual(t, cm.snapshot(), Metrics{
+		Requests:     map[string]uint64{},
+		Durations:    map[string]time.Duration{},
+		Errors:       map[syscall.Errno]uint64{},
+		DumpMessages: map[string]uint64{},
+	})
+
+	cm.retry()
+	cm.request(newOpResult("get_service", time.Millisecond, nil), true, 3)
+	cm.request(newOpResult("get_service", 2*time.Millisecond, nil), true, 2)
+	cm.request(newOpResult("new_service", time.Millisecond, syscall.EEXIST), false, 0)
+	cm.request(newOpResult("new_service", time.Millisecond, errors.New("timeout")), false, 0)
+
+	m := cm.snapshot()
+	assert.DeepEqual(t, m, Metrics{
+		Requests:     map[string]uint64{"get_service": 2, "new_service": 2},
+		Durations:    map[string]time.Duration{"get_service": 3 * time.Millisecond, "new_service": 2 * time.Millisecond},
+		Errors:       map[syscall.Errno]uint64{syscall.EEXIST: 1, 0: 1},
+		DumpMessages: map[string]uint64{"get_service": 5},
+		Retries:      1,
+	})
+
+	// Snapshots do not share the counters.
+	m.Requests["get_service"] = 100
+	assert.Equal(t, cm.snapshot().Requests["get_service"], uint64(2))
+}
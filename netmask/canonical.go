@@ -0,0 +1,155 @@
+package netmask
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Warning describes a problem with an input accepted by Canonicalize.
+type Warning struct {
+	// Offset is the byte offset in the input the warning applies to.
+	Offset int
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the warning in the form "offset N: message".
+func (w Warning) String() string {
+	return fmt.Sprintf("offset %d: %s", w.Offset, w.Message)
+}
+
+// Canonicalize parses a loosely written mask, returning the Mask together
+// with warnings for every deviation from its canonical form, as returned by
+// String. It is intended for linting configuration files. On top of the forms
+// accepted by ParseMask it accepts:
+//
+//   - Surrounding whitespace ("  255.255.255.0 ").
+//   - A hexadecimal form of 8 digits for IPv4 or 32 digits for IPv6 ("0xffffff00").
+//   - A mask followed by its redundant prefix length ("255.255.255.0/24"), in
+//     which case both must agree.
+//
+// Warnings are also returned for octets with leading zeros, wildcard masks,
+// IPv6 prefixes in address form and non-prefix masks. Errors are always of
+// type *ParseError.
+func Canonicalize(s string) (Mask, []Warning, error) {
+	var warnings []Warning
+
+	trimmed := strings.TrimSpace(s)
+	lead := strings.Index(s, trimmed)
+	if trimmed != s {
+		warnings = append(warnings, Warning{Offset: 0, Message: "surrounding whitespace"})
+	}
+
+	mask, ws, err := canonicalize(trimmed)
+	if err != nil {
+		err.Input = s
+		err.Offset += lead
+		return Mask{}, nil, err
+	}
+
+	for _, w := range ws {
+		w.Offset += lead
+		warnings = append(warnings, w)
+	}
+
+	return mask, warnings, nil
+}
+
+// canonicalize implements Canonicalize on a trimmed input.
+func canonicalize(s string) (Mask, []Warning, *ParseError) {
+	// A leading slash, or one following the tilde of a wildcard, is part of
+	// an IPv6 prefix length rather than a redundant one.
+	if i := strings.LastIndexByte(s, '/'); i > 0 && s[i-1] != '~' {
+		return canonicalizeRedundant(s[:i], s[i+1:], i+1)
+	}
+
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		mask, err := parseHex(s[2:])
+		if err != nil {
+			err.Offset += 2
+			return Mask{}, nil, err
+		}
+		return mask, []Warning{{Message: fmt.Sprintf("hexadecimal form, use %s", mask)}}, nil
+	}
+
+	mask, err := parseMask(s)
+	if err != nil {
+		return Mask{}, nil, err
+	}
+
+	var warnings []Warning
+	off := 0
+	if s[0] == '~' {
+		off = 1
+		warnings = append(warnings, Warning{Message: fmt.Sprintf("wildcard form, use %s", mask)})
+	}
+
+	switch {
+	case mask.Is4():
+		if err := checkLeadingZeros(s[off:]); err != nil {
+			warnings = append(warnings, Warning{Offset: off + err.Offset, Message: "octet with leading zeros"})
+		}
+	case mask.IsPrefix() && strings.IndexByte(s, ':') >= 0:
+		warnings = append(warnings, Warning{Offset: off, Message: fmt.Sprintf("prefix in address form, use %s", mask)})
+	}
+
+	if !mask.IsPrefix() {
+		warnings = append(warnings, Warning{Offset: off, Message: "non-contiguous mask"})
+	}
+
+	return mask, warnings, nil
+}
+
+// canonicalizeRedundant handles a mask followed by a prefix length, where off
+// is the offset of the prefix length in the input.
+func canonicalizeRedundant(s, prefix string, off int) (Mask, []Warning, *ParseError) {
+	mask, warnings, err := canonicalize(s)
+	if err != nil {
+		return Mask{}, nil, err
+	}
+
+	bits := 32
+	if mask.Is6() {
+		bits = 128
+	}
+
+	v, perr := strconv.ParseUint(prefix, 10, 8)
+	switch {
+	case prefix == "" || perr != nil && numError(perr) == ErrSyntax:
+		return Mask{}, nil, &ParseError{Offset: off, Err: ErrSyntax}
+	case perr != nil || v > uint64(bits):
+		return Mask{}, nil, &ParseError{Offset: off, Err: ErrRange}
+	case int(v) != mask.Bits():
+		return Mask{}, nil, &ParseError{Offset: off, Err: fmt.Errorf("%w: prefix length does not match mask", ErrSyntax)}
+	}
+
+	return mask, append(warnings, Warning{Offset: off - 1, Message: "redundant prefix length"}), nil
+}
+
+// parseHex parses the hexadecimal form of a mask without its 0x prefix.
+func parseHex(s string) (Mask, *ParseError) {
+	var u uint128
+	switch len(s) {
+	case 8, 32:
+	default:
+		return Mask{}, &ParseError{Err: ErrSyntax}
+	}
+
+	for i := 0; i < len(s); i++ {
+		v, err := strconv.ParseUint(s[i:i+1], 16, 8)
+		if err != nil {
+			return Mask{}, &ParseError{Offset: i, Err: ErrSyntax}
+		}
+		if i < 16 {
+			u.hi |= v << (60 - 4*i)
+		} else {
+			u.lo |= v << (60 - 4*(i-16))
+		}
+	}
+
+	if len(s) == 8 {
+		return Mask{mask: u, z: z4}, nil
+	}
+	return Mask{mask: u, z: z6}, nil
+}
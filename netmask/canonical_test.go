@@ -0,0 +1,139 @@
+package netmask
+
+import (
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNetmask_Canonicalize(t *testing.T) {
+	type testCase struct {
+		name     string
+		in       string
+		expected Mask
+		warnings []Warning
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask, warnings, err := Canonicalize(tc.in)
+		assert.NilError(t, err)
+		assert.Equal(t, mask, tc.expected)
+		assert.DeepEqual(t, warnings, tc.warnings)
+	}
+
+	testCases := []testCase{
+		{name: "canonical ipv4", in: "255.255.255.0", expected: MaskFrom(24, 32)},
+		{name: "canonical ipv6", in: "64", expected: MaskFrom(64, 128)},
+		{name: "slash ipv6", in: "/64", expected: MaskFrom(64, 128)},
+		{
+			name:     "whitespace",
+			in:       "  255.255.255.0\n",
+			expected: MaskFrom(24, 32),
+			warnings: []Warning{{Offset: 0, Message: "surrounding whitespace"}},
+		},
+		{
+			name:     "ipv4 hex",
+			in:       "0xffffff00",
+			expected: MaskFrom(24, 32),
+			warnings: []Warning{{Offset: 0, Message: "hexadecimal form, use 255.255.255.0"}},
+		},
+		{
+			name:     "ipv6 hex",
+			in:       "0XFFFFFFFFFFFFFFFF0000000000000000",
+			expected: MaskFrom(64, 128),
+			warnings: []Warning{{Offset: 0, Message: "hexadecimal form, use 64"}},
+		},
+		{
+			name:     "redundant prefix",
+			in:       "255.255.255.0/24",
+			expected: MaskFrom(24, 32),
+			warnings: []Warning{{Offset: 13, Message: "redundant prefix length"}},
+		},
+		{
+			name:     "redundant ipv6 prefix",
+			in:       "ffff:ffff::/32",
+			expected: MaskFrom(32, 128),
+			warnings: []Warning{
+				{Offset: 0, Message: "prefix in address form, use 32"},
+				{Offset: 11, Message: "redundant prefix length"},
+			},
+		},
+		{
+			name:     "leading zeros",
+			in:       " 255.255.255.000",
+			expected: MaskFrom(24, 32),
+			warnings: []Warning{
+				{Offset: 0, Message: "surrounding whitespace"},
+				{Offset: 13, Message: "octet with leading zeros"},
+			},
+		},
+		{
+			name:     "wildcard",
+			in:       "~0.0.0.255",
+			expected: MaskFrom(24, 32),
+			warnings: []Warning{{Offset: 0, Message: "wildcard form, use 255.255.255.0"}},
+		},
+		{
+			name:     "wildcard prefix",
+			in:       "~/96",
+			expected: MaskFrom(96, 128).Hostmask(),
+			warnings: []Warning{
+				{Offset: 0, Message: "wildcard form, use ::ffff:ffff"},
+				{Offset: 1, Message: "non-contiguous mask"},
+			},
+		},
+		{
+			name:     "non-contiguous",
+			in:       "255.0.255.0",
+			expected: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}),
+			warnings: []Warning{{Offset: 0, Message: "non-contiguous mask"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_CanonicalizeError(t *testing.T) {
+	type testCase struct {
+		name     string
+		in       string
+		offset   int
+		expected error
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask, warnings, err := Canonicalize(tc.in)
+		assert.Equal(t, mask, Mask{})
+		assert.Assert(t, warnings == nil)
+
+		var pe *ParseError
+		assert.Assert(t, errors.As(err, &pe), "got error: %v", err)
+		assert.Equal(t, pe.Input, tc.in)
+		assert.Equal(t, pe.Offset, tc.offset)
+		assert.Assert(t, errors.Is(err, tc.expected), "got error: %v", err)
+	}
+
+	testCases := []testCase{
+		{name: "empty", in: "  ", offset: 0, expected: ErrSyntax},
+		{name: "bad octet", in: " 255.256.0.0", offset: 5, expected: ErrRange},
+		{name: "short hex", in: "0xffff", offset: 2, expected: ErrSyntax},
+		{name: "bad hex", in: "0xfffgff00", offset: 5, expected: ErrSyntax},
+		{name: "mismatched prefix", in: "255.255.255.0/16", offset: 14, expected: ErrSyntax},
+		{name: "prefix range", in: "255.255.255.0/33", offset: 14, expected: ErrRange},
+		{name: "empty prefix", in: "255.255.255.0/", offset: 14, expected: ErrSyntax},
+		{name: "non-contiguous prefix", in: "255.0.255.0/8", offset: 12, expected: ErrSyntax},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package netmask
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+// ipMaskCompat describes the observable behavior of a mask as the net
+// package sees it, so the behavior of a Mask and a net.IPMask can be compared
+// field by field.
+type ipMaskCompat struct {
+	Ones  int
+	Bits  int
+	Bytes []byte
+	Hex   string
+}
+
+// compatFromIPMask returns the behavior of a net.IPMask.
+func compatFromIPMask(ipm net.IPMask) ipMaskCompat {
+	ones, bits := ipm.Size()
+	return ipMaskCompat{Ones: ones, Bits: bits, Bytes: []byte(ipm), Hex: ipm.String()}
+}
+
+// compatFromMask returns the behavior of a Mask.
+func compatFromMask(m Mask) ipMaskCompat {
+	ones, bits := m.Size()
+	return ipMaskCompat{Ones: ones, Bits: bits, Bytes: m.AsSlice(), Hex: fmt.Sprintf("%x", m)}
+}
+
+func TestNetmask_EquivalentToIPMask(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		ipm      net.IPMask
+		expected bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, EquivalentToIPMask(tc.mask, tc.ipm), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, ipm: nil, expected: true},
+		{name: "zero mask empty", mask: Mask{}, ipm: net.IPMask{}, expected: true},
+		{name: "zero mask ipv4", mask: Mask{}, ipm: net.CIDRMask(0, 32)},
+		{name: "ipv4", mask: MaskFrom(24, 32), ipm: net.CIDRMask(24, 32), expected: true},
+		{name: "ipv4 mismatch", mask: MaskFrom(24, 32), ipm: net.CIDRMask(16, 32)},
+		{name: "ipv4 16-byte", mask: MaskFrom(24, 32), ipm: net.CIDRMask(120, 128), expected: true},
+		{name: "ipv4 16-byte mismatch", mask: MaskFrom(24, 32), ipm: net.CIDRMask(24, 128)},
+		{name: "weird ipv4", mask: MaskFrom4([...]byte{0, 0xFF, 0, 0}), ipm: net.IPv4Mask(0, 0xFF, 0, 0), expected: true},
+		{name: "ipv6", mask: MaskFrom(64, 128), ipm: net.CIDRMask(64, 128), expected: true},
+		{name: "ipv6 as ipv4", mask: MaskFrom(120, 128), ipm: net.CIDRMask(24, 32)},
+		{name: "bad length", mask: MaskFrom(16, 32), ipm: net.IPMask{0xFF, 0xFF}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func FuzzMaskFromIPMask(f *testing.F) {
+	for _, ipm := range []net.IPMask{
+		nil,
+		net.IPv4Mask(0, 0xFF, 0, 0),
+		net.IPv4Mask(0xFF, 0, 0xFF, 0),
+		net.CIDRMask(0, 32),
+		net.CIDRMask(24, 32),
+		net.CIDRMask(120, 128),
+		net.IPMask(weird6.AsSlice()),
+		{0xFF, 0xFF},
+	} {
+		f.Add([]byte(ipm))
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		ipm := net.IPMask(b)
+
+		m, ok := MaskFromIPMask(ipm)
+		if !ok {
+			assert.Assert(t, len(b) != 4 && len(b) != 16)
+			return
+		}
+
+		assert.Assert(t, EquivalentToIPMask(m, ipm))
+		assert.DeepEqual(t, compatFromMask(m), compatFromIPMask(ipm))
+		assert.Assert(t, bytes.Equal(m.IPMask(), ipm))
+
+		if ones, _ := ipm.Size(); ones > 0 || m.IsZeroBits() {
+			assert.Equal(t, m.Bits(), ones)
+			assert.Equal(t, m.mask.prefixLength(), ones)
+		} else {
+			assert.Equal(t, m.Bits(), -1)
+		}
+	})
+}
+
+func FuzzMaskFrom(f *testing.F) {
+	f.Add(24, 32)
+	f.Add(0, 128)
+	f.Add(129, 128)
+	f.Add(-1, 32)
+
+	f.Fuzz(func(t *testing.T, ones, bits int) {
+		ipm := net.CIDRMask(ones, bits)
+		m := MaskFrom(ones, bits)
+
+		if ipm == nil {
+			assert.Equal(t, m, Mask{})
+			return
+		}
+
+		assert.Assert(t, EquivalentToIPMask(m, ipm))
+		assert.DeepEqual(t, compatFromMask(m), compatFromIPMask(ipm))
+	})
+}
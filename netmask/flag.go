@@ -0,0 +1,24 @@
+package netmask
+
+import "flag"
+
+var _ flag.Value = (*Mask)(nil)
+
+// Set implements the [flag.Value] interface, parsing s with ParseMask. This
+// allows a Mask to be used directly as a command-line flag, with invalid
+// masks rejected when the flags are parsed.
+func (mask *Mask) Set(s string) error {
+	m, err := ParseMask(s)
+	if err != nil {
+		return err
+	}
+
+	*mask = m
+	return nil
+}
+
+// Type returns the name of the flag type, as required by the pflag.Value
+// interface.
+func (mask *Mask) Type() string {
+	return "netmask"
+}
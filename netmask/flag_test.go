@@ -0,0 +1,59 @@
+package netmask
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNetmask_FlagSet(t *testing.T) {
+	type testCase struct {
+		name     string
+		args     []string
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask := MaskFrom(32, 32)
+
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Var(&mask, "netmask", "persistence netmask")
+		assert.NilError(t, fs.Parse(tc.args))
+		assert.Equal(t, mask, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "default", args: nil, expected: MaskFrom(32, 32)},
+		{name: "ipv4 mask", args: []string{"--netmask=255.255.255.0"}, expected: MaskFrom(24, 32)},
+		{name: "ipv6 prefix", args: []string{"-netmask", "/64"}, expected: MaskFrom(64, 128)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_FlagSetError(t *testing.T) {
+	mask := MaskFrom(32, 32)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Var(&mask, "netmask", "persistence netmask")
+
+	err := fs.Parse([]string{"--netmask=255.255.255"})
+	assert.ErrorContains(t, err, "invalid syntax")
+	assert.Equal(t, mask, MaskFrom(32, 32))
+}
+
+func TestNetmask_FlagString(t *testing.T) {
+	mask := MaskFrom(24, 32)
+
+	var value flag.Value = &mask
+	assert.Equal(t, value.String(), "255.255.255.0")
+	assert.Equal(t, mask.Type(), "netmask")
+}
@@ -0,0 +1,92 @@
+package netmask
+
+import (
+	"fmt"
+	"strconv"
+)
+
+var _ fmt.Formatter = Mask{}
+
+// Format implements the [fmt.Formatter] interface. The following verbs are
+// supported:
+//
+//	%s, %v  the text form, as returned by String
+//	%q      the text form, double-quoted
+//	%b      the bit pattern, 32 digits for IPv4 and 128 digits for IPv6
+//	%x, %X  the hexadecimal form, 8 digits for IPv4 and 32 digits for IPv6
+//
+// The '#' flag adds a leading 0b or 0x to %b and %x. Width and the '-' flag
+// pad the result as usual. The zero Mask prints as String for every verb.
+func (mask Mask) Format(f fmt.State, verb rune) {
+	var b []byte
+	switch {
+	case verb == 's' || verb == 'v':
+		b = append(b, mask.String()...)
+	case verb == 'q':
+		b = strconv.AppendQuote(b, mask.String())
+	case mask.z == z0 && (verb == 'b' || verb == 'x' || verb == 'X'):
+		b = append(b, mask.String()...)
+	case verb == 'b':
+		if f.Flag('#') {
+			b = append(b, "0b"...)
+		}
+		b = mask.appendBase(b, 2, false)
+	case verb == 'x' || verb == 'X':
+		if f.Flag('#') {
+			b = append(b, '0', byte(verb))
+		}
+		b = mask.appendBase(b, 16, verb == 'X')
+	default:
+		fmt.Fprintf(f, "%%!%c(netmask.Mask=%s)", verb, mask.String())
+		return
+	}
+
+	pad := 0
+	if w, ok := f.Width(); ok && w > len(b) {
+		pad = w - len(b)
+	}
+	if !f.Flag('-') {
+		writePadding(f, pad)
+	}
+	f.Write(b)
+	if f.Flag('-') {
+		writePadding(f, pad)
+	}
+}
+
+// appendBase appends the bits of the mask in base 2 or 16, zero-padded to
+// the full width of the mask's family.
+func (mask Mask) appendBase(b []byte, base int, upper bool) []byte {
+	digits := "0123456789abcdef"
+	if upper {
+		digits = "0123456789ABCDEF"
+	}
+
+	shift := 1
+	if base == 16 {
+		shift = 4
+	}
+
+	n := 32
+	if mask.z == z6 {
+		n = 128
+	}
+
+	for i := 0; i < n; i += shift {
+		v := mask.mask.hi
+		if i >= 64 {
+			v = mask.mask.lo
+		}
+		d := v >> (64 - shift - i%64) & (1<<shift - 1)
+		b = append(b, digits[d])
+	}
+
+	return b
+}
+
+// writePadding writes n spaces to f.
+func writePadding(f fmt.State, n int) {
+	for ; n > 0; n-- {
+		f.Write([]byte{' '})
+	}
+}
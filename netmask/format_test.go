@@ -0,0 +1,49 @@
+package netmask
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNetmask_Format(t *testing.T) {
+	type testCase struct {
+		name     string
+		format   string
+		mask     Mask
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, fmt.Sprintf(tc.format, tc.mask), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "ipv4 string", format: "%s", mask: MaskFrom(24, 32), expected: "255.255.255.0"},
+		{name: "ipv4 value", format: "%v", mask: MaskFrom(24, 32), expected: "255.255.255.0"},
+		{name: "ipv4 quoted", format: "%q", mask: MaskFrom(24, 32), expected: `"255.255.255.0"`},
+		{name: "ipv4 binary", format: "%b", mask: MaskFrom(24, 32), expected: "11111111111111111111111100000000"},
+		{name: "ipv4 binary prefixed", format: "%#b", mask: MaskFrom(1, 32), expected: "0b10000000000000000000000000000000"},
+		{name: "weird ipv4 binary", format: "%b", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xF0, 0x0F}), expected: "11111111000000001111000000001111"},
+		{name: "ipv4 hex", format: "%x", mask: MaskFrom(20, 32), expected: "fffff000"},
+		{name: "ipv4 upper hex", format: "%X", mask: MaskFrom(20, 32), expected: "FFFFF000"},
+		{name: "ipv4 hex prefixed", format: "%#x", mask: MaskFrom(8, 32), expected: "0xff000000"},
+		{name: "ipv4 width", format: "%16s|", mask: MaskFrom(24, 32), expected: "   255.255.255.0|"},
+		{name: "ipv4 left width", format: "%-16s|", mask: MaskFrom(24, 32), expected: "255.255.255.0   |"},
+		{name: "ipv6 string", format: "%s", mask: MaskFrom(64, 128), expected: "64"},
+		{name: "ipv6 hex", format: "%x", mask: MaskFrom(68, 128), expected: "fffffffffffffffff000000000000000"},
+		{name: "weird ipv6 hex", format: "%x", mask: weird6, expected: "ffffffff00000000ffffffff00000000"},
+		{name: "ipv6 binary", format: "%b", mask: MaskFrom(127, 128), expected: "11111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111111110"},
+		{name: "zero mask binary", format: "%b", mask: Mask{}, expected: "invalid Mask"},
+		{name: "zero mask hex", format: "%x", mask: Mask{}, expected: "invalid Mask"},
+		{name: "bad verb", format: "%d", mask: MaskFrom(24, 32), expected: "%!d(netmask.Mask=255.255.255.0)"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
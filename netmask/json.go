@@ -0,0 +1,110 @@
+package netmask
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	_ json.Marshaler   = Object{}
+	_ json.Unmarshaler = (*Object)(nil)
+)
+
+// Object wraps a Mask to encode it as a self-describing JSON object carrying
+// the address family, instead of the text form used by Mask itself. Prefix
+// masks are encoded with their prefix length and non-prefix masks with their
+// text form:
+//
+//	{"family":"ipv4","bits":24}
+//	{"family":"ipv6","bits":64}
+//	{"family":"ipv4","mask":"255.0.255.0"}
+//
+// The zero Mask is encoded as null.
+type Object struct {
+	Mask
+}
+
+// jsonObject is the JSON representation of an Object.
+type jsonObject struct {
+	Family string  `json:"family"`
+	Bits   *int    `json:"bits,omitempty"`
+	Mask   *string `json:"mask,omitempty"`
+}
+
+// MarshalJSON implements the [json.Marshaler] interface.
+func (o Object) MarshalJSON() ([]byte, error) {
+	if !o.IsValid() {
+		return []byte("null"), nil
+	}
+
+	obj := jsonObject{Family: "ipv4"}
+	if o.Is6() {
+		obj.Family = "ipv6"
+	}
+
+	if bits := o.Bits(); bits >= 0 {
+		obj.Bits = &bits
+	} else {
+		s := o.String()
+		obj.Mask = &s
+	}
+
+	return json.Marshal(obj)
+}
+
+// UnmarshalJSON implements the [json.Unmarshaler] interface. Exactly one of
+// bits and mask must be present, and a mask must be of the given family.
+func (o *Object) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		o.Mask = Mask{}
+		return nil
+	}
+
+	var obj jsonObject
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return err
+	}
+
+	mask, err := obj.mask()
+	if err != nil {
+		return fmt.Errorf("netmask: invalid JSON object: %w", err)
+	}
+
+	o.Mask = mask
+	return nil
+}
+
+// mask returns the Mask described by the object.
+func (obj jsonObject) mask() (Mask, error) {
+	var bits int
+	switch obj.Family {
+	case "ipv4":
+		bits = 32
+	case "ipv6":
+		bits = 128
+	default:
+		return Mask{}, fmt.Errorf("unknown family %q", obj.Family)
+	}
+
+	switch {
+	case obj.Bits != nil && obj.Mask != nil:
+		return Mask{}, errors.New("both bits and mask are set")
+	case obj.Bits != nil:
+		if *obj.Bits < 0 || *obj.Bits > bits {
+			return Mask{}, ErrRange
+		}
+		return MaskFrom(*obj.Bits, bits), nil
+	case obj.Mask != nil:
+		mask, err := parseMask(*obj.Mask)
+		if err != nil {
+			return Mask{}, fmt.Errorf("mask %q: %w", *obj.Mask, err.Err)
+		}
+		if mask.Is4() != (bits == 32) {
+			return Mask{}, fmt.Errorf("mask %q is not of family %s", *obj.Mask, obj.Family)
+		}
+		return mask, nil
+	}
+
+	return Mask{}, errors.New("missing bits or mask")
+}
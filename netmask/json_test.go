@@ -0,0 +1,138 @@
+package netmask
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"pgregory.net/rapid"
+)
+
+func TestNetmask_ObjectMarshalJSON(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		b, err := json.Marshal(Object{tc.mask})
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, expected: `null`},
+		{name: "ipv4 prefix", mask: MaskFrom(24, 32), expected: `{"family":"ipv4","bits":24}`},
+		{name: "ipv4 zero prefix", mask: MaskFrom(0, 32), expected: `{"family":"ipv4","bits":0}`},
+		{name: "ipv6 prefix", mask: MaskFrom(64, 128), expected: `{"family":"ipv6","bits":64}`},
+		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: `{"family":"ipv4","mask":"255.0.255.0"}`},
+		{name: "weird ipv6 mask", mask: weird6, expected: `{"family":"ipv6","mask":"ffff:ffff::ffff:ffff:0:0"}`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ObjectUnmarshalJSON(t *testing.T) {
+	type testCase struct {
+		name     string
+		in       string
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		o := Object{MaskFrom(8, 32)}
+		assert.NilError(t, json.Unmarshal([]byte(tc.in), &o))
+		assert.Equal(t, o.Mask, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "null", in: `null`, expected: Mask{}},
+		{name: "ipv4 prefix", in: `{"family":"ipv4","bits":24}`, expected: MaskFrom(24, 32)},
+		{name: "ipv6 prefix", in: `{"family":"ipv6","bits":24}`, expected: MaskFrom(24, 128)},
+		{name: "ipv4 mask", in: `{"family":"ipv4","mask":"255.255.0.0"}`, expected: MaskFrom(16, 32)},
+		{name: "ipv6 mask", in: `{"family":"ipv6","mask":"/48"}`, expected: MaskFrom(48, 128)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ObjectUnmarshalJSONError(t *testing.T) {
+	type testCase struct {
+		name string
+		in   string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var o Object
+		assert.Assert(t, json.Unmarshal([]byte(tc.in), &o) != nil)
+		assert.Equal(t, o.Mask, Mask{})
+	}
+
+	testCases := []testCase{
+		{name: "not an object", in: `"255.255.255.0"`},
+		{name: "missing family", in: `{"bits":24}`},
+		{name: "unknown family", in: `{"family":"ipx","bits":24}`},
+		{name: "missing bits", in: `{"family":"ipv4"}`},
+		{name: "bits and mask", in: `{"family":"ipv4","bits":24,"mask":"255.255.255.0"}`},
+		{name: "ipv4 bits range", in: `{"family":"ipv4","bits":33}`},
+		{name: "negative bits", in: `{"family":"ipv6","bits":-1}`},
+		{name: "family mismatch", in: `{"family":"ipv6","mask":"255.255.255.0"}`},
+		{name: "bad mask", in: `{"family":"ipv4","mask":"255.255.255"}`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ObjectUnmarshalJSONRange(t *testing.T) {
+	var o Object
+	err := json.Unmarshal([]byte(`{"family":"ipv4","bits":64}`), &o)
+	assert.Assert(t, errors.Is(err, ErrRange), "got error: %v", err)
+}
+
+func TestNetmask_ObjectRoundtrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		mask := rapid.Custom[Mask](func(t *rapid.T) Mask {
+			z := rapid.SampledFrom([]int8{z0, z4, z6}).Draw(t, "z")
+
+			switch z {
+			case z4:
+				return MaskFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "mask")))
+			case z6:
+				return rapid.OneOf(
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+					}),
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+					}),
+				).Draw(t, "mask6")
+			default:
+				return Mask{}
+			}
+		}).Draw(t, "mask")
+
+		b, err := json.Marshal(Object{mask})
+		assert.NilError(t, err)
+
+		var out Object
+		assert.NilError(t, json.Unmarshal(b, &out))
+		assert.Equal(t, out.Mask, mask)
+	})
+}
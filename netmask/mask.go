@@ -13,20 +13,26 @@ package netmask
 import (
 	"encoding/binary"
 	"errors"
+	"io"
+	"net"
+	"net/netip"
 	"strconv"
-	"strings"
+	"unsafe"
+
+	"github.com/josharian/native"
 )
 
-// Mask represents an IPv4 mask or an IPv6 prefix, similar to net.IPMask or netip.Prefix.
+// Mask represents an IPv4 or IPv6 mask, similar to net.IPMask or netip.Prefix.
 //
 // Unlike net.IPMask, Mask is a comparable value type (it supports == and can be map key) and
 // is immutable.
 //
-// Unlike netip.Prefix, Mask is not attached to an IP address, and does not require IPv4 masks
+// Unlike netip.Prefix, Mask is not attached to an IP address, and does not require masks
 // to be a prefix.
 type Mask struct {
-	// mask
-	mask uint32
+	// mask holds the mask bits in big-endian order. IPv4 masks occupy
+	// the 32 most significant bits, with all other bits set to zero.
+	mask uint128
 
 	// z is the mask's address family
 	//
@@ -46,27 +52,23 @@ const (
 // MaskFrom4 returns the IPv4 mask given by the bytes in mask.
 func MaskFrom4(mask [4]byte) Mask {
 	return Mask{
-		mask: uint32(uint32(mask[0])<<24 | uint32(mask[1])<<16 | uint32(mask[2])<<8 | uint32(mask[3])),
+		mask: uint128{hi: uint64(binary.BigEndian.Uint32(mask[:])) << 32},
 		z:    z4,
 	}
 }
 
-// MaskFrom16 returns the IPv6 prefix given by the prefix in mask.
-// Note that if the prefix is not one bits followed by all zero bits
-// the invalid Mask is returned.
+// MaskFrom16 returns the IPv6 mask given by the bytes in mask.
 func MaskFrom16(mask [16]byte) Mask {
-	ones := prefixLength(mask[:])
-	if ones == -1 {
-		return Mask{}
-	}
-
 	return Mask{
-		mask: uint32(ones),
-		z:    z6,
+		mask: uint128{
+			hi: binary.BigEndian.Uint64(mask[:8]),
+			lo: binary.BigEndian.Uint64(mask[8:]),
+		},
+		z: z6,
 	}
 }
 
-// MaskFromSlice parses the 4- or 16-byte slices as an IPv4 netmask or IPv6 prefix.
+// MaskFromSlice parses the 4- or 16-byte slices as an IPv4 or IPv6 netmask.
 // Note that a net.IPMask can by passed directly as the []byte argument. IIf slice's
 // length is not 4 or 16, MaskFromSlice returns Mask{}, false.
 func MaskFromSlice(mask []byte) (Mask, bool) {
@@ -80,30 +82,41 @@ func MaskFromSlice(mask []byte) (Mask, bool) {
 	return Mask{}, false
 }
 
-// prefixLength returns the number of leading one bits in mask. If the prefix isn't
-// followed entirely by zero bits, -1 is returned.
-func prefixLength(mask []byte) int {
-	var n int
-	for i, v := range mask {
-		if v == 0xFF {
-			n += 8
-			continue
-		}
-		for v&0x80 != 0 {
-			n++
-			v <<= 1
-		}
-		if v != 0 {
-			return -1
-		}
-		for i++; i < len(mask); i++ {
-			if mask[i] != 0 {
-				return -1
-			}
-		}
-		break
+// MaskFromIPMask converts a net.IPMask to a Mask. Like MaskFromSlice, it
+// reports false if the mask is not 4 or 16 bytes long.
+func MaskFromIPMask(mask net.IPMask) (Mask, bool) {
+	return MaskFromSlice(mask)
+}
+
+// IPMask returns the mask as a net.IPMask of 4 or 16 bytes. The zero Mask
+// returns nil.
+func (mask Mask) IPMask() net.IPMask {
+	return net.IPMask(mask.AsSlice())
+}
+
+// EquivalentToIPMask reports whether m and ipm describe the same mask, as
+// used by the net package. Besides the 4-byte form, an IPv4 mask is also
+// equivalent to the 16-byte form of ipm with its first 12 bytes set, as
+// net.IP.Mask treats those the same. The zero Mask is only equivalent to an
+// empty ipm.
+func EquivalentToIPMask(m Mask, ipm net.IPMask) bool {
+	switch {
+	case m.z == z0:
+		return len(ipm) == 0
+	case m.z == z4 && len(ipm) == 16:
+		return m.Map4In6() == maskFrom16Slice(ipm)
+	case m.z == z4 && len(ipm) == 4:
+		return m == MaskFrom4(*(*[4]byte)(ipm))
+	case m.z == z6 && len(ipm) == 16:
+		return m == maskFrom16Slice(ipm)
 	}
-	return n
+
+	return false
+}
+
+// maskFrom16Slice returns the IPv6 Mask of a 16-byte slice.
+func maskFrom16Slice(b []byte) Mask {
+	return MaskFrom16(*(*[16]byte)(b))
 }
 
 // AsSlice returns an IPv4 or IPv6 mask in its respective 4-byte or 16-byte representation.
@@ -112,25 +125,48 @@ func (mask Mask) AsSlice() []byte {
 	case z0:
 		return nil
 	case z4:
-		var ret [4]byte
-		binary.BigEndian.PutUint32(ret[:], mask.mask)
+		ret := mask.As4()
 		return ret[:]
 	default:
-		var ret [16]byte
-		n := uint(mask.mask)
-		for i := 0; i < 16; i++ {
-			if n >= 8 {
-				ret[i] = 0xFF
-				n -= 8
-				continue
-			}
-			ret[i] = ^byte(0xFF >> n)
-			n = 0
-		}
+		ret := mask.As16()
 		return ret[:]
 	}
 }
 
+// As4 returns an IPv4 mask in its 4-byte representation. If mask is the zero
+// Mask or an IPv6 mask, As4 panics.
+func (mask Mask) As4() (m [4]byte) {
+	if mask.z != z4 {
+		panic("As4 called on Mask of wrong family")
+	}
+
+	binary.BigEndian.PutUint32(m[:], mask.v4())
+	return m
+}
+
+// As16 returns an IPv6 mask in its 16-byte representation. If mask is the
+// zero Mask or an IPv4 mask, As16 panics.
+func (mask Mask) As16() [16]byte {
+	if mask.z != z6 {
+		panic("As16 called on Mask of wrong family")
+	}
+
+	return mask.v6()
+}
+
+// v4 returns the 32-bit representation of an IPv4 mask.
+func (mask Mask) v4() uint32 {
+	return uint32(mask.mask.hi >> 32)
+}
+
+// v6 returns the 16-byte representation of an IPv6 mask.
+func (mask Mask) v6() [16]byte {
+	var ret [16]byte
+	binary.BigEndian.PutUint64(ret[:8], mask.mask.hi)
+	binary.BigEndian.PutUint64(ret[8:], mask.mask.lo)
+	return ret
+}
+
 // MaskFrom returns a Mask consisting of 'ones' 1 bits followed by 0s up to a total length
 // of 'bits' bits.
 func MaskFrom(ones, bits int) Mask {
@@ -140,21 +176,13 @@ func MaskFrom(ones, bits int) Mask {
 
 	switch bits {
 	case 32:
-		var mask [4]byte
-		n := uint(ones)
-		for i := 0; i < 4; i++ {
-			if n >= 8 {
-				mask[i] = 0xFF
-				n -= 8
-				continue
-			}
-			mask[i] = ^byte(0xFF >> n)
-			n = 0
+		return Mask{
+			mask: mask6(ones),
+			z:    z4,
 		}
-		return MaskFrom4(mask)
 	case 128:
 		return Mask{
-			mask: uint32(ones),
+			mask: mask6(ones),
 			z:    z6,
 		}
 	default:
@@ -162,9 +190,20 @@ func MaskFrom(ones, bits int) Mask {
 	}
 }
 
+// MaskFromPrefix returns the mask of the prefix p. The family of the mask
+// follows the family of the prefix's address, so IPv4-mapped IPv6 prefixes
+// result in an IPv6 mask. If p is invalid, the zero Mask is returned.
+func MaskFromPrefix(p netip.Prefix) Mask {
+	if !p.IsValid() {
+		return Mask{}
+	}
+
+	return MaskFrom(p.Bits(), p.Addr().BitLen())
+}
+
 // IsValid reports whether the Mask is an initialized mask (not the zero Mask).
 //
-// Note that a non-prefix mask is considered valid, even for IPv6.
+// Note that a non-prefix mask is considered valid.
 func (mask Mask) IsValid() bool {
 	return mask.z != z0
 }
@@ -179,19 +218,307 @@ func (mask Mask) Is6() bool {
 	return mask.z == z6
 }
 
+// OnesCount returns the number of bits set in the mask, regardless of whether
+// they are contiguous. For prefix masks it equals Bits. The zero Mask has no
+// bits set.
+func (mask Mask) OnesCount() int {
+	return mask.mask.onesCount()
+}
+
+// IsHostMask reports whether all bits of the mask are set, matching a single
+// host, such as 255.255.255.255 or /128.
+func (mask Mask) IsHostMask() bool {
+	return mask.z != z0 && mask.mask == mask.ones()
+}
+
+// IsZeroBits reports whether the mask is valid but has no bits set, matching
+// every address of its family, such as 0.0.0.0 or /0.
+func (mask Mask) IsZeroBits() bool {
+	return mask.z != z0 && mask.mask.isZero()
+}
+
+// IsPrefix reports whether the mask is valid and consists of contiguous
+// leading one bits, meaning Bits does not return -1.
+func (mask Mask) IsPrefix() bool {
+	return mask.Bits() >= 0
+}
+
 // Bits returns the masks's prefix length.
 //
 // It reports -1 if the mask does not contain a prefix.
 func (mask Mask) Bits() int {
-	switch mask.z {
-	case z0:
+	if mask.z == z0 {
 		return -1
+	}
+
+	return mask.mask.prefixLength()
+}
+
+// Size returns the number of leading ones and total bits in the mask, like
+// net.IPMask.Size. If the mask is not a prefix, or is the zero Mask, Size
+// returns 0, 0.
+func (mask Mask) Size() (ones, bits int) {
+	ones = mask.Bits()
+	if ones < 0 {
+		return 0, 0
+	}
+
+	if mask.z == z4 {
+		return ones, 32
+	}
+	return ones, 128
+}
+
+// Widen returns the mask with its prefix length shortened by n bits, covering
+// a larger network. For example, widening 255.255.255.0 by 8 bits results in
+// 255.255.0.0.
+//
+// The zero Mask is returned if the mask is not a prefix, n is negative, or
+// the resulting prefix length would be negative.
+func (mask Mask) Widen(n int) Mask {
+	return mask.resize(-n, n)
+}
+
+// Narrow returns the mask with its prefix length extended by n bits, covering
+// a smaller network. For example, narrowing 255.255.0.0 by 8 bits results in
+// 255.255.255.0.
+//
+// The zero Mask is returned if the mask is not a prefix, n is negative, or
+// the resulting prefix length would exceed the length of its family.
+func (mask Mask) Narrow(n int) Mask {
+	return mask.resize(n, n)
+}
+
+// resize implements Widen and Narrow, adding delta to the prefix length.
+func (mask Mask) resize(delta, n int) Mask {
+	ones := mask.Bits()
+	if ones < 0 || n < 0 {
+		return Mask{}
+	}
+
+	bits := 32
+	if mask.z == z6 {
+		bits = 128
+	}
+
+	ones += delta
+	if ones < 0 || ones > bits {
+		return Mask{}
+	}
+
+	return MaskFrom(ones, bits)
+}
+
+// Is4In6 reports whether the mask is an IPv4-mapped IPv6 mask, meaning its
+// first 96 bits are set, as returned by Map4In6.
+func (mask Mask) Is4In6() bool {
+	return mask.z == z6 && mask.mask.and(mask6(96)) == mask6(96)
+}
+
+// Map4In6 converts an IPv4 mask to its IPv4-mapped IPv6 equivalent, so it can
+// be applied to IPv4-mapped IPv6 addresses. For example, 255.255.255.0 maps
+// to a prefix length of 120. Masks that are not IPv4 are returned unchanged.
+func (mask Mask) Map4In6() Mask {
+	if mask.z != z4 {
+		return mask
+	}
+
+	return Mask{
+		mask: uint128{hi: ^uint64(0), lo: 0xFFFF_FFFF_0000_0000 | uint64(mask.v4())},
+		z:    z6,
+	}
+}
+
+// Unmap returns mask with any IPv4-mapped IPv6 mask converted back to its
+// IPv4 equivalent. Masks that are not IPv4-mapped are returned unchanged.
+func (mask Mask) Unmap() Mask {
+	if !mask.Is4In6() {
+		return mask
+	}
+
+	return Mask{mask: uint128{hi: mask.mask.lo << 32}, z: z4}
+}
+
+// Hostmask returns the bitwise complement of the mask within its family,
+// also known as a wildcard or inverse mask. For example, the hostmask of
+// 255.255.255.0 is 0.0.0.255. The hostmask of the zero Mask is the zero Mask.
+func (mask Mask) Hostmask() Mask {
+	if mask.z == z0 {
+		return Mask{}
+	}
+
+	return Mask{
+		mask: mask.mask.not().and(mask.ones()),
+		z:    mask.z,
+	}
+}
+
+// And returns the bitwise AND of the masks. Both masks must be of the same
+// family, otherwise the zero Mask is returned.
+func (mask Mask) And(m Mask) Mask {
+	if mask.z != m.z {
+		return Mask{}
+	}
+
+	return Mask{mask: mask.mask.and(m.mask), z: mask.z}
+}
+
+// Or returns the bitwise OR of the masks. Both masks must be of the same
+// family, otherwise the zero Mask is returned.
+func (mask Mask) Or(m Mask) Mask {
+	if mask.z != m.z {
+		return Mask{}
+	}
+
+	return Mask{mask: mask.mask.or(m.mask), z: mask.z}
+}
+
+// Xor returns the bitwise XOR of the masks. Both masks must be of the same
+// family, otherwise the zero Mask is returned.
+func (mask Mask) Xor(m Mask) Mask {
+	if mask.z != m.z {
+		return Mask{}
+	}
+
+	return Mask{mask: mask.mask.xor(m.mask), z: mask.z}
+}
+
+// Not returns the bitwise NOT of the mask within its family. It is the same
+// as Hostmask.
+func (mask Mask) Not() Mask {
+	return mask.Hostmask()
+}
+
+// ones returns all bits of the mask's family set.
+func (mask Mask) ones() uint128 {
+	if mask.z == z4 {
+		return mask6(32)
+	}
+	return mask6(128)
+}
+
+// Apply returns addr with the mask applied, keeping only the network portion
+// of the address and dropping any IPv6 zone.
+//
+// If the mask is invalid, or addr is not of the same family as the mask, the
+// zero Addr is returned. IPv4-mapped IPv6 addresses are considered IPv6.
+func (mask Mask) Apply(addr netip.Addr) netip.Addr {
+	switch {
+	case mask.z == z4 && addr.Is4():
+		a := addr.As4()
+		m := binary.BigEndian.Uint32(a[:]) & mask.v4()
+		binary.BigEndian.PutUint32(a[:], m)
+		return netip.AddrFrom4(a)
+	case mask.z == z6 && addr.Is6():
+		a := addr.As16()
+		binary.BigEndian.PutUint64(a[:8], binary.BigEndian.Uint64(a[:8])&mask.mask.hi)
+		binary.BigEndian.PutUint64(a[8:], binary.BigEndian.Uint64(a[8:])&mask.mask.lo)
+		return netip.AddrFrom16(a)
+	default:
+		return netip.Addr{}
+	}
+}
+
+// Prefix returns the prefix given by addr and the mask, with the host bits of
+// addr masked off and any IPv6 zone dropped.
+//
+// It reports false if the mask is not a prefix, or addr is not of the same
+// family as the mask.
+func (mask Mask) Prefix(addr netip.Addr) (netip.Prefix, bool) {
+	ones := mask.Bits()
+	if ones < 0 || mask.Apply(addr) == (netip.Addr{}) {
+		return netip.Prefix{}, false
+	}
+
+	p, err := addr.Prefix(ones)
+	return p, err == nil
+}
+
+// Contains reports whether addr is part of the network given by base and
+// the mask, meaning both addresses are equal after applying the mask.
+//
+// Contains reports false if the mask is invalid, or either address is not of
+// the same family as the mask.
+func (mask Mask) Contains(base, addr netip.Addr) bool {
+	network := mask.Apply(base)
+	return network.IsValid() && network == mask.Apply(addr)
+}
+
+// Range returns the first and last address of the network given by base and
+// the mask, dropping any IPv6 zone. For non-prefix masks, not every address
+// between first and last is part of the network.
+//
+// It reports false if the mask is invalid, or base is not of the same family
+// as the mask.
+func (mask Mask) Range(base netip.Addr) (first, last netip.Addr, ok bool) {
+	first = mask.Apply(base)
+	if !first.IsValid() {
+		return netip.Addr{}, netip.Addr{}, false
+	}
+
+	host := mask.Hostmask()
+	switch mask.z {
 	case z4:
-		mask := mask.AsSlice()
-		return prefixLength(mask)
+		a := first.As4()
+		binary.BigEndian.PutUint32(a[:], binary.BigEndian.Uint32(a[:])|host.v4())
+		last = netip.AddrFrom4(a)
+	default:
+		a := first.As16()
+		binary.BigEndian.PutUint64(a[:8], binary.BigEndian.Uint64(a[:8])|host.mask.hi)
+		binary.BigEndian.PutUint64(a[8:], binary.BigEndian.Uint64(a[8:])|host.mask.lo)
+		last = netip.AddrFrom16(a)
+	}
+
+	return first, last, true
+}
+
+// AddressCount returns the number of addresses covered by the mask, that is
+// two to the power of the number of host bits. Host bits need not be
+// contiguous, so non-prefix masks are counted as well.
+//
+// It reports false if the mask is invalid, or if the count does not fit in
+// an uint64, which happens for IPv6 masks with 64 or more host bits.
+func (mask Mask) AddressCount() (uint64, bool) {
+	if mask.z == z0 {
+		return 0, false
+	}
+
+	n := mask.Hostmask().mask.onesCount()
+	if n >= 64 {
+		return 0, false
+	}
+
+	return 1 << n, true
+}
+
+// PutTo writes the mask into b in the layout of the IPVS netmask netlink
+// attribute, returning the number of bytes written. This avoids the
+// intermediate slices of MarshalBinary when encoding netlink messages.
+//
+// The attribute is a 4-byte u32 for both families: IPv4 masks are written in
+// network byte order, while IPv6 masks are written as their prefix length in
+// native byte order, since the kernel only supports IPv6 prefix masks. The
+// zero Mask writes nothing.
+//
+// An error is returned if b is shorter than 4 bytes, or the mask is an IPv6
+// mask that is not a prefix.
+func (mask Mask) PutTo(b []byte) (n int, err error) {
+	switch {
+	case mask.z == z0:
+		return 0, nil
+	case len(b) < 4:
+		return 0, io.ErrShortBuffer
+	case mask.z == z4:
+		binary.BigEndian.PutUint32(b, mask.v4())
 	default:
-		return int(mask.mask)
+		ones := mask.Bits()
+		if ones < 0 {
+			return 0, errors.New("netmask: IPv6 mask is not a prefix")
+		}
+		native.Endian.PutUint32(b, uint32(ones))
 	}
+
+	return 4, nil
 }
 
 // AppendBinary implements the [encoding.BinaryAppender] interface.
@@ -200,20 +527,20 @@ func (mask Mask) AppendBinary(b []byte) ([]byte, error) {
 	case z0:
 		return b, nil
 	case z4:
-		return append(b,
-			byte(mask.mask>>24),
-			byte(mask.mask>>16&0xFF),
-			byte(mask.mask>>8&0xFF),
-			byte(mask.mask&0xFF),
-		), nil
+		return binary.BigEndian.AppendUint32(b, mask.v4()), nil
 	default:
-		return append(b, byte(mask.mask)), nil
+		if ones := mask.Bits(); ones >= 0 {
+			return append(b, byte(ones)), nil
+		}
+		b = binary.BigEndian.AppendUint64(b, mask.mask.hi)
+		return binary.BigEndian.AppendUint64(b, mask.mask.lo), nil
 	}
 }
 
 // MarshalBinary implements the [encoding.BinaryMarshaler] interface.
 // It returns a zero-length slice for the zero Mask, the 4-byte mask
-// for IPv4, and a 1-byte prefix for IPv6.
+// for IPv4, a 1-byte prefix for IPv6 prefix masks, and the 16-byte
+// mask for other IPv6 masks.
 func (mask Mask) MarshalBinary() ([]byte, error) {
 	return mask.AppendBinary(make([]byte, 0, mask.marshalBinarySize()))
 }
@@ -225,27 +552,82 @@ func (mask Mask) marshalBinarySize() int {
 	case z4:
 		return 4
 	default:
-		return 1
+		if mask.Bits() >= 0 {
+			return 1
+		}
+		return 16
 	}
 }
 
+// binaryVersion2 is the leading byte of the versioned binary format written
+// by MarshalBinaryV2.
+const binaryVersion2 = 2
+
+// AppendBinaryV2 appends the versioned binary form of the mask to b, see
+// MarshalBinaryV2.
+func (mask Mask) AppendBinaryV2(b []byte) []byte {
+	switch mask.z {
+	case z0:
+		return append(b, binaryVersion2, 0)
+	case z4:
+		b = append(b, binaryVersion2, 4)
+		return binary.BigEndian.AppendUint32(b, mask.v4())
+	default:
+		b = append(b, binaryVersion2, 6)
+		b = binary.BigEndian.AppendUint64(b, mask.mask.hi)
+		return binary.BigEndian.AppendUint64(b, mask.mask.lo)
+	}
+}
+
+// MarshalBinaryV2 returns a versioned binary form of the mask, which unlike
+// MarshalBinary does not depend on the length of the data to tell the forms
+// apart, making it suitable for persisted state. It consists of a version
+// byte of 2, a family byte of 0, 4 or 6, and the 0-, 4- or 16-byte mask.
+// UnmarshalBinary accepts both forms.
+func (mask Mask) MarshalBinaryV2() []byte {
+	return mask.AppendBinaryV2(make([]byte, 0, 18))
+}
+
 // UnmarshalBinary implements the [encoding.BinaryUnmarshaler] interface. It
-// expects data in the form generated by MarshalBinary.
+// expects data in the form generated by MarshalBinary or MarshalBinaryV2.
 func (mask *Mask) UnmarshalBinary(b []byte) error {
 	n := len(b)
 	switch {
+	case n >= 2 && b[0] == binaryVersion2 && (n == 2 || n == 6 || n == 18):
+		return mask.unmarshalBinaryV2(b)
 	case n == 0:
 		*mask = Mask{}
 		return nil
 	case n == 4:
 		*mask = MaskFrom4(*(*[4]byte)(b))
 		return nil
-	case n == 1:
+	case n == 16:
+		*mask = MaskFrom16(*(*[16]byte)(b))
+		return nil
+	case n == 1 && b[0] <= 128:
 		*mask = MaskFrom(int(b[0]), 128)
 		return nil
+	case n == 1:
+		return &ParseError{Input: string(b), Err: ErrRange}
+	}
+
+	return &ParseError{Input: string(b), Err: ErrSyntax}
+}
+
+// unmarshalBinaryV2 decodes the form generated by MarshalBinaryV2.
+func (mask *Mask) unmarshalBinaryV2(b []byte) error {
+	switch {
+	case b[1] == 0 && len(b) == 2:
+		*mask = Mask{}
+	case b[1] == 4 && len(b) == 6:
+		*mask = MaskFrom4(*(*[4]byte)(b[2:]))
+	case b[1] == 6 && len(b) == 18:
+		*mask = MaskFrom16(*(*[16]byte)(b[2:]))
+	default:
+		return &ParseError{Input: string(b), Offset: 1, Err: ErrSyntax}
 	}
 
-	return errors.New("unexpected slice size")
+	return nil
 }
 
 // AppendText implements the [encoding.TextAppender] interface.
@@ -256,7 +638,7 @@ func (mask Mask) AppendText(b []byte) ([]byte, error) {
 	case z4:
 		return appendTextIPv4(mask, b), nil
 	default:
-		return strconv.AppendUint(b, uint64(mask.mask), 10), nil
+		return appendTextIPv6(mask, b), nil
 	}
 }
 
@@ -274,44 +656,32 @@ func (mask Mask) marshalTextSize() int {
 	case z4:
 		return len("255.255.255.255")
 	default:
-		return 1
+		if mask.Bits() >= 0 {
+			return len("128")
+		}
+		return len("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")
 	}
 }
 
 // UnmarshalText implements the [encoding.TextUnmarshaler] interface. The mask
-// is expected in a form generated by MarshalText.
+// is expected in a form generated by MarshalText, or any form accepted by
+// ParseMask.
 func (mask *Mask) UnmarshalText(text []byte) error {
-	n := len(text)
-	switch {
-	case n == 0:
+	if len(text) == 0 {
 		*mask = Mask{}
 		return nil
-	case n >= 1 && n <= 3:
-		u, err := strconv.ParseUint(string(text[:]), 10, 64)
-		if err != nil {
-			return err
-		}
-		*mask = MaskFrom(int(u), 128)
-		return nil
-	case n >= len("1.1.1.1") && n <= len("255.255.255.255"):
-		sub := strings.SplitN(string(text), ".", 4)
-		if len(sub) != 4 {
-			return errors.New("unexpected slice type")
-		}
+	}
 
-		var fields [4]uint32
-		for i, s := range sub {
-			f, err := strconv.ParseUint(s, 10, 8)
-			if err != nil {
-				return err
-			}
-			fields[i] = uint32(f)
-		}
-		*mask = Mask{mask: uint32(fields[0]<<24 | fields[1]<<16 | fields[2]<<8 | fields[3]), z: z4}
-		return nil
+	// Parsing does not retain its input, so it can safely look at text
+	// without copying it to a string.
+	m, err := parseMask(*(*string)(unsafe.Pointer(&text)))
+	if err != nil {
+		err.Input = string(text)
+		return err
 	}
 
-	return errors.New("unexpected slice size")
+	*mask = m
+	return nil
 }
 
 // String returns the string form of the Mask mask. It returns one of these forms:
@@ -319,29 +689,70 @@ func (mask *Mask) UnmarshalText(text []byte) error {
 // - "invalid Mask", if mask is the zero Mask
 // - IPv4 dotted decimal ("255.255.255.0")
 // - IPv6 prefix ("64")
+// - IPv6 address form, if the IPv6 mask is not a prefix ("ffff::ffff")
 func (mask Mask) String() string {
 	switch mask.z {
 	case z0:
 		return "invalid Mask"
-	case z4:
-		b := make([]byte, 0, len("255.255.255.255"))
-		return string(appendTextIPv4(mask, b))
 	default:
-		return strconv.FormatUint(uint64(mask.mask), 10)
+		b := make([]byte, 0, mask.marshalTextSize())
+		b, _ = mask.AppendText(b)
+		return string(b)
+	}
+}
+
+// StringSlash is like String, but writes IPv6 prefixes with a leading slash
+// ("/64"), as expected by tools like ipvsadm and keepalived. ParseMask
+// accepts both forms.
+func (mask Mask) StringSlash() string {
+	if mask.z != z6 || mask.Bits() < 0 {
+		return mask.String()
 	}
+
+	b := make([]byte, 0, len("/128"))
+	b = append(b, '/')
+	b = appendTextIPv6(mask, b)
+	return string(b)
 }
 
 func (x Mask) Equal(y Mask) bool {
 	return x == y
 }
 
+// Compare returns an integer comparing two masks. The result will be 0 if
+// x == y, -1 if x < y, and +1 if x > y.
+//
+// Masks are ordered first by family, with the zero Mask sorting before IPv4
+// and IPv4 before IPv6, and then by the numeric value of their bits. For
+// prefix masks this means ordering by prefix length, shortest first, while
+// non-prefix masks sort between the prefixes enclosing them.
+func (x Mask) Compare(y Mask) int {
+	switch {
+	case x.z < y.z:
+		return -1
+	case x.z > y.z:
+		return 1
+	}
+
+	return x.mask.compare(y.mask)
+}
+
 func appendTextIPv4(mask Mask, b []byte) []byte {
-	b = strconv.AppendUint(b, uint64(uint8(mask.mask>>24)), 10)
+	m := mask.v4()
+	b = strconv.AppendUint(b, uint64(uint8(m>>24)), 10)
 	b = append(b, '.')
-	b = strconv.AppendUint(b, uint64(uint8(mask.mask>>16)), 10)
+	b = strconv.AppendUint(b, uint64(uint8(m>>16)), 10)
 	b = append(b, '.')
-	b = strconv.AppendUint(b, uint64(uint8(mask.mask>>8)), 10)
+	b = strconv.AppendUint(b, uint64(uint8(m>>8)), 10)
 	b = append(b, '.')
-	b = strconv.AppendUint(b, uint64(uint8(mask.mask)), 10)
+	b = strconv.AppendUint(b, uint64(uint8(m)), 10)
 	return b
 }
+
+func appendTextIPv6(mask Mask, b []byte) []byte {
+	if ones := mask.Bits(); ones >= 0 {
+		return strconv.AppendUint(b, uint64(ones), 10)
+	}
+
+	return netip.AddrFrom16(mask.v6()).AppendTo(b)
+}
@@ -1,12 +1,27 @@
 package netmask
 
 import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"sort"
 	"testing"
 
+	"github.com/josharian/native"
 	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
 	"pgregory.net/rapid"
 )
 
+// weird6 is a non-prefix IPv6 mask.
+var weird6 = MaskFrom16([...]byte{
+	0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
+	0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
+})
+
 func TestNetmask_MaskFrom4(t *testing.T) {
 	type testCase struct {
 		name     string
@@ -24,7 +39,7 @@ func TestNetmask_MaskFrom4(t *testing.T) {
 			name: "0",
 			mask: [...]byte{0, 0, 0, 0},
 			expected: Mask{
-				mask: 0,
+				mask: uint128{hi: 0x0000_0000_0000_0000},
 				z:    z4,
 			},
 		},
@@ -32,7 +47,7 @@ func TestNetmask_MaskFrom4(t *testing.T) {
 			name: "8",
 			mask: [...]byte{255, 0, 0, 0},
 			expected: Mask{
-				mask: 0xFF00_0000,
+				mask: uint128{hi: 0xFF00_0000_0000_0000},
 				z:    z4,
 			},
 		},
@@ -40,7 +55,7 @@ func TestNetmask_MaskFrom4(t *testing.T) {
 			name: "24",
 			mask: [...]byte{255, 255, 255, 0},
 			expected: Mask{
-				mask: 0xFFFF_FF00,
+				mask: uint128{hi: 0xFFFF_FF00_0000_0000},
 				z:    z4,
 			},
 		},
@@ -48,7 +63,7 @@ func TestNetmask_MaskFrom4(t *testing.T) {
 			name: "32",
 			mask: [...]byte{255, 255, 255, 255},
 			expected: Mask{
-				mask: 0xFFFF_FFFF,
+				mask: uint128{hi: 0xFFFF_FFFF_0000_0000},
 				z:    z4,
 			},
 		},
@@ -56,7 +71,7 @@ func TestNetmask_MaskFrom4(t *testing.T) {
 			name: "non-canonical mask",
 			mask: [...]byte{0, 0, 255, 0},
 			expected: Mask{
-				mask: 0x0000_FF00,
+				mask: uint128{hi: 0x0000_FF00_0000_0000},
 				z:    z4,
 			},
 		},
@@ -90,7 +105,7 @@ func TestNetmask_MaskFrom16(t *testing.T) {
 				0, 0, 0, 0, 0, 0, 0, 0,
 			},
 			expected: Mask{
-				mask: 0,
+				mask: mask6(0),
 				z:    z6,
 			},
 		},
@@ -101,7 +116,7 @@ func TestNetmask_MaskFrom16(t *testing.T) {
 				0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
 			},
 			expected: Mask{
-				mask: 128,
+				mask: mask6(128),
 				z:    z6,
 			},
 		},
@@ -112,7 +127,7 @@ func TestNetmask_MaskFrom16(t *testing.T) {
 				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
 			},
 			expected: Mask{
-				mask: 96,
+				mask: mask6(96),
 				z:    z6,
 			},
 		},
@@ -122,7 +137,10 @@ func TestNetmask_MaskFrom16(t *testing.T) {
 				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
 				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
 			},
-			expected: Mask{},
+			expected: Mask{
+				mask: uint128{hi: 0xFFFF_FFFF_0000_0000, lo: 0xFFFF_FFFF_0000_0000},
+				z:    z6,
+			},
 		},
 	}
 
@@ -152,7 +170,7 @@ func TestNemask_MaskFromSlice(t *testing.T) {
 			name: "IPv4 /0",
 			mask: []byte{0, 0, 0, 0},
 			expected: Mask{
-				mask: 0,
+				mask: uint128{hi: 0x0000_0000_0000_0000},
 				z:    z4,
 			},
 		},
@@ -160,7 +178,7 @@ func TestNemask_MaskFromSlice(t *testing.T) {
 			name: "IPv4 /24",
 			mask: []byte{255, 255, 255, 0},
 			expected: Mask{
-				mask: 0xFFFF_FF00,
+				mask: uint128{hi: 0xFFFF_FF00_0000_0000},
 				z:    z4,
 			},
 		},
@@ -171,7 +189,7 @@ func TestNemask_MaskFromSlice(t *testing.T) {
 				0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
 			},
 			expected: Mask{
-				mask: 128,
+				mask: mask6(128),
 				z:    z6,
 			},
 		},
@@ -182,7 +200,7 @@ func TestNemask_MaskFromSlice(t *testing.T) {
 				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
 			},
 			expected: Mask{
-				mask: 96,
+				mask: mask6(96),
 				z:    z6,
 			},
 		},
@@ -192,7 +210,10 @@ func TestNemask_MaskFromSlice(t *testing.T) {
 				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
 				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
 			},
-			expected: Mask{},
+			expected: Mask{
+				mask: uint128{hi: 0xFFFF_FFFF_0000_0000, lo: 0xFFFF_FFFF_0000_0000},
+				z:    z6,
+			},
 		},
 	}
 
@@ -225,7 +246,7 @@ func TestNemask_AsSlice(t *testing.T) {
 		{
 			name: "IPv4 /0",
 			mask: Mask{
-				mask: 0,
+				mask: uint128{hi: 0x0000_0000_0000_0000},
 				z:    z4,
 			},
 			expected: []byte{0, 0, 0, 0},
@@ -233,7 +254,7 @@ func TestNemask_AsSlice(t *testing.T) {
 		{
 			name: "IPv4 /24",
 			mask: Mask{
-				mask: 0xFFFF_FF00,
+				mask: uint128{hi: 0xFFFF_FF00_0000_0000},
 				z:    z4,
 			},
 			expected: []byte{255, 255, 255, 0},
@@ -241,7 +262,7 @@ func TestNemask_AsSlice(t *testing.T) {
 		{
 			name: "IPv6 /128",
 			mask: Mask{
-				mask: 128,
+				mask: mask6(128),
 				z:    z6,
 			},
 			expected: []byte{
@@ -252,7 +273,7 @@ func TestNemask_AsSlice(t *testing.T) {
 		{
 			name: "IPv6 /96",
 			mask: Mask{
-				mask: 96,
+				mask: mask6(96),
 				z:    z6,
 			},
 			expected: []byte{
@@ -277,9 +298,16 @@ func TestNetmask_SliceRoundtrip(t *testing.T) {
 
 			switch z {
 			case z4:
-				return Mask{mask: rapid.Uint32().Draw(t, "mask"), z: z4}
+				return MaskFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "mask")))
 			case z6:
-				return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+				return rapid.OneOf(
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+					}),
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+					}),
+				).Draw(t, "mask6")
 			default:
 				return Mask{}
 			}
@@ -312,7 +340,7 @@ func TestNetmask_MaskFrom(t *testing.T) {
 			ones: 0,
 			bits: 32,
 			expected: Mask{
-				mask: 0,
+				mask: uint128{hi: 0x0000_0000_0000_0000},
 				z:    z4,
 			},
 		},
@@ -321,7 +349,7 @@ func TestNetmask_MaskFrom(t *testing.T) {
 			ones: 24,
 			bits: 32,
 			expected: Mask{
-				mask: 0xFFFF_FF00,
+				mask: uint128{hi: 0xFFFF_FF00_0000_0000},
 				z:    z4,
 			},
 		},
@@ -330,7 +358,7 @@ func TestNetmask_MaskFrom(t *testing.T) {
 			ones: 128,
 			bits: 128,
 			expected: Mask{
-				mask: 128,
+				mask: mask6(128),
 				z:    z6,
 			},
 		},
@@ -339,7 +367,7 @@ func TestNetmask_MaskFrom(t *testing.T) {
 			ones: 96,
 			bits: 128,
 			expected: Mask{
-				mask: 96,
+				mask: mask6(96),
 				z:    z6,
 			},
 		},
@@ -374,7 +402,7 @@ func TestNetmask_IsValid(t *testing.T) {
 		{
 			name: "IPv4",
 			mask: Mask{
-				mask: 0,
+				mask: uint128{hi: 0x0000_0000_0000_0000},
 				z:    z4,
 			},
 			expected: true,
@@ -382,7 +410,7 @@ func TestNetmask_IsValid(t *testing.T) {
 		{
 			name: "IPv6",
 			mask: Mask{
-				mask: 128,
+				mask: mask6(128),
 				z:    z6,
 			},
 			expected: true,
@@ -417,7 +445,7 @@ func TestNetmask_Is4(t *testing.T) {
 		{
 			name: "IPv4",
 			mask: Mask{
-				mask: 0,
+				mask: uint128{hi: 0x0000_0000_0000_0000},
 				z:    z4,
 			},
 			expected: true,
@@ -425,7 +453,7 @@ func TestNetmask_Is4(t *testing.T) {
 		{
 			name: "IPv6",
 			mask: Mask{
-				mask: 128,
+				mask: mask6(128),
 				z:    z6,
 			},
 			expected: false,
@@ -460,7 +488,7 @@ func TestNetmask_Is6(t *testing.T) {
 		{
 			name: "IPv4",
 			mask: Mask{
-				mask: 0,
+				mask: uint128{hi: 0x0000_0000_0000_0000},
 				z:    z4,
 			},
 			expected: false,
@@ -468,7 +496,7 @@ func TestNetmask_Is6(t *testing.T) {
 		{
 			name: "IPv6",
 			mask: Mask{
-				mask: 128,
+				mask: mask6(128),
 				z:    z6,
 			},
 			expected: true,
@@ -529,6 +557,7 @@ func TestNetmask_AppendBinary(t *testing.T) {
 		{name: "ipv4 mask", mask: MaskFrom(31, 32), expected: []byte{0xFF, 0xFF, 0xFF, 0xFE}},
 		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: []byte{0xFF, 0x00, 0xFF, 0x00}},
 		{name: "ipv6 mask", mask: MaskFrom(128, 128), expected: []byte{128}},
+		{name: "weird ipv6 mask", mask: weird6, expected: weird6.AsSlice()},
 	}
 
 	for _, tc := range testCases {
@@ -557,6 +586,7 @@ func TestNetmask_MarshalBinary(t *testing.T) {
 		{name: "ipv4 mask", mask: MaskFrom(31, 32), expected: []byte{0xFF, 0xFF, 0xFF, 0xFE}},
 		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: []byte{0xFF, 0x00, 0xFF, 0x00}},
 		{name: "ipv6 mask", mask: MaskFrom(128, 128), expected: []byte{128}},
+		{name: "weird ipv6 mask", mask: weird6, expected: weird6.AsSlice()},
 	}
 
 	for _, tc := range testCases {
@@ -586,6 +616,38 @@ func TestNetmask_UnmarshalBinary(t *testing.T) {
 		{name: "ipv4 mask", mask: []byte{0xFF, 0xFF, 0xFF, 0xFE}, expected: MaskFrom(31, 32)},
 		{name: "weird ipv4 mask", mask: []byte{0xFF, 0x00, 0xFF, 0x00}, expected: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00})},
 		{name: "ipv6 mask", mask: []byte{128}, expected: MaskFrom(128, 128)},
+		{name: "weird ipv6 mask", mask: weird6.AsSlice(), expected: weird6},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_UnmarshalBinaryError(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     []byte
+		expected ParseError
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		out := MaskFrom(8, 32)
+		err := out.UnmarshalBinary(tc.mask)
+
+		var pe *ParseError
+		assert.Assert(t, errors.As(err, &pe), "got error: %v", err)
+		assert.Equal(t, *pe, tc.expected)
+		assert.Equal(t, out, MaskFrom(8, 32))
+	}
+
+	testCases := []testCase{
+		{name: "short", mask: []byte{0xFF, 0xFF}, expected: ParseError{Input: "\xff\xff", Err: ErrSyntax}},
+		{name: "long", mask: make([]byte, 17), expected: ParseError{Input: string(make([]byte, 17)), Err: ErrSyntax}},
+		{name: "prefix range", mask: []byte{129}, expected: ParseError{Input: "\x81", Err: ErrRange}},
 	}
 
 	for _, tc := range testCases {
@@ -605,7 +667,14 @@ func TestNetmask_BinaryMarshaller(t *testing.T) {
 			case z4:
 				return MaskFrom(rapid.IntRange(0, 32).Draw(t, "ones"), 32)
 			case z6:
-				return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+				return rapid.OneOf(
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+					}),
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+					}),
+				).Draw(t, "mask6")
 			default:
 				return Mask{}
 			}
@@ -630,7 +699,14 @@ func TestNetmask_BinaryEncodingEquivalence(t *testing.T) {
 			case z4:
 				return MaskFrom(rapid.IntRange(0, 32).Draw(t, "ones"), 32)
 			case z6:
-				return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+				return rapid.OneOf(
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+					}),
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+					}),
+				).Draw(t, "mask6")
 			default:
 				return Mask{}
 			}
@@ -665,6 +741,7 @@ func TestNetmask_MarshalText(t *testing.T) {
 		{name: "ipv4 mask", mask: MaskFrom(31, 32), expected: "255.255.255.254"},
 		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: "255.0.255.0"},
 		{name: "ipv6 mask", mask: MaskFrom(128, 128), expected: "128"},
+		{name: "weird ipv6 mask", mask: weird6, expected: "ffff:ffff::ffff:ffff:0:0"},
 	}
 
 	for _, tc := range testCases {
@@ -691,9 +768,10 @@ func TestNetmask_UnmarshalText(t *testing.T) {
 
 	testCases := []testCase{
 		{name: "zero mask", mask: []byte{}, expected: Mask{}},
-		{name: "ipv4 mask", mask: []byte("255.255.255.254"), expected: Mask{mask: 4294967294, z: z4}},
-		{name: "weird ipv4 mask", mask: []byte("255.0.255.0"), expected: Mask{mask: 4278255360, z: z4}},
-		{name: "ipv6 mask", mask: []byte("56"), expected: Mask{mask: 56, z: z6}},
+		{name: "ipv4 mask", mask: []byte("255.255.255.254"), expected: Mask{mask: uint128{hi: 0xFFFF_FFFE_0000_0000}, z: z4}},
+		{name: "weird ipv4 mask", mask: []byte("255.0.255.0"), expected: Mask{mask: uint128{hi: 0xFF00_FF00_0000_0000}, z: z4}},
+		{name: "ipv6 mask", mask: []byte("56"), expected: Mask{mask: mask6(56), z: z6}},
+		{name: "weird ipv6 mask", mask: []byte("ffff:ffff::ffff:ffff:0:0"), expected: weird6},
 	}
 
 	for _, tc := range testCases {
@@ -704,6 +782,16 @@ func TestNetmask_UnmarshalText(t *testing.T) {
 	}
 }
 
+func TestNetmask_UnmarshalTextError(t *testing.T) {
+	out := MaskFrom(8, 32)
+	err := out.UnmarshalText([]byte("255.255.256.0"))
+
+	var pe *ParseError
+	assert.Assert(t, errors.As(err, &pe), "got error: %v", err)
+	assert.Equal(t, *pe, ParseError{Input: "255.255.256.0", Offset: 8, Err: ErrRange})
+	assert.Equal(t, out, MaskFrom(8, 32))
+}
+
 func TestNetmask_TextMarshaller(t *testing.T) {
 	rapid.Check(t, func(t *rapid.T) {
 		mask := rapid.Custom[Mask](func(t *rapid.T) Mask {
@@ -713,7 +801,14 @@ func TestNetmask_TextMarshaller(t *testing.T) {
 			case z4:
 				return MaskFrom(rapid.IntRange(0, 32).Draw(t, "ones"), 32)
 			case z6:
-				return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+				return rapid.OneOf(
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+					}),
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+					}),
+				).Draw(t, "mask6")
 			default:
 				return Mask{}
 			}
@@ -738,7 +833,14 @@ func TestNetmask_TextEncodingEquivalence(t *testing.T) {
 			case z4:
 				return MaskFrom(rapid.IntRange(0, 32).Draw(t, "ones"), 32)
 			case z6:
-				return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+				return rapid.OneOf(
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+					}),
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+					}),
+				).Draw(t, "mask6")
 			default:
 				return Mask{}
 			}
@@ -772,6 +874,386 @@ func TestNetmask_String(t *testing.T) {
 		{name: "ipv4 mask", mask: MaskFrom(31, 32), expected: "255.255.255.254"},
 		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: "255.0.255.0"},
 		{name: "ipv6 mask", mask: MaskFrom(128, 128), expected: "128"},
+		{name: "weird ipv6 mask", mask: weird6, expected: "ffff:ffff::ffff:ffff:0:0"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_Apply(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		addr     netip.Addr
+		expected netip.Addr
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		out := tc.mask.Apply(tc.addr)
+		assert.Equal(t, out, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "ipv4 /24",
+			mask:     MaskFrom(24, 32),
+			addr:     netip.MustParseAddr("192.0.2.55"),
+			expected: netip.MustParseAddr("192.0.2.0"),
+		},
+		{
+			name:     "weird ipv4 mask",
+			mask:     MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}),
+			addr:     netip.MustParseAddr("192.0.2.55"),
+			expected: netip.MustParseAddr("192.0.2.0"),
+		},
+		{
+			name:     "ipv6 /64",
+			mask:     MaskFrom(64, 128),
+			addr:     netip.MustParseAddr("2001:db8::1"),
+			expected: netip.MustParseAddr("2001:db8::"),
+		},
+		{
+			name:     "ipv6 /70 with zone",
+			mask:     MaskFrom(70, 128),
+			addr:     netip.MustParseAddr("2001:db8:0:0:ffff::1%eth0"),
+			expected: netip.MustParseAddr("2001:db8:0:0:fc00::"),
+		},
+		{
+			name:     "family mismatch",
+			mask:     MaskFrom(24, 32),
+			addr:     netip.MustParseAddr("2001:db8::1"),
+			expected: netip.Addr{},
+		},
+		{
+			name:     "ipv4-mapped address",
+			mask:     MaskFrom(24, 32),
+			addr:     netip.MustParseAddr("::ffff:192.0.2.55"),
+			expected: netip.Addr{},
+		},
+		{
+			name:     "zero mask",
+			mask:     Mask{},
+			addr:     netip.MustParseAddr("192.0.2.55"),
+			expected: netip.Addr{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ApplyPrefix(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		var addr netip.Addr
+		var bits int
+		switch rapid.SampledFrom([]int8{z4, z6}).Draw(t, "z") {
+		case z4:
+			addr = netip.AddrFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "addr")))
+			bits = 32
+		default:
+			addr = netip.AddrFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "addr")))
+			bits = 128
+		}
+		ones := rapid.IntRange(0, bits).Draw(t, "ones")
+
+		prefix, err := addr.Prefix(ones)
+		assert.NilError(t, err)
+
+		assert.Equal(t, MaskFrom(ones, bits).Apply(addr), prefix.Addr())
+	})
+}
+
+func TestNetmask_Contains(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		base     netip.Addr
+		addr     netip.Addr
+		expected bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.mask.Contains(tc.base, tc.addr), tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "ipv4 inside",
+			mask:     MaskFrom(24, 32),
+			base:     netip.MustParseAddr("192.0.2.0"),
+			addr:     netip.MustParseAddr("192.0.2.55"),
+			expected: true,
+		},
+		{
+			name:     "ipv4 base with host bits",
+			mask:     MaskFrom(24, 32),
+			base:     netip.MustParseAddr("192.0.2.1"),
+			addr:     netip.MustParseAddr("192.0.2.55"),
+			expected: true,
+		},
+		{
+			name:     "ipv4 outside",
+			mask:     MaskFrom(24, 32),
+			base:     netip.MustParseAddr("192.0.2.0"),
+			addr:     netip.MustParseAddr("192.0.3.55"),
+			expected: false,
+		},
+		{
+			name:     "ipv6 inside",
+			mask:     MaskFrom(64, 128),
+			base:     netip.MustParseAddr("2001:db8::"),
+			addr:     netip.MustParseAddr("2001:db8::ffff"),
+			expected: true,
+		},
+		{
+			name:     "ipv6 outside",
+			mask:     MaskFrom(64, 128),
+			base:     netip.MustParseAddr("2001:db8::"),
+			addr:     netip.MustParseAddr("2001:db8:1::"),
+			expected: false,
+		},
+		{
+			name:     "family mismatch",
+			mask:     MaskFrom(0, 32),
+			base:     netip.MustParseAddr("0.0.0.0"),
+			addr:     netip.MustParseAddr("::"),
+			expected: false,
+		},
+		{
+			name:     "zero mask",
+			mask:     Mask{},
+			base:     netip.Addr{},
+			addr:     netip.Addr{},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_MaskFromPrefix(t *testing.T) {
+	type testCase struct {
+		name     string
+		prefix   netip.Prefix
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, MaskFromPrefix(tc.prefix), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "ipv4", prefix: netip.MustParsePrefix("192.0.2.0/24"), expected: MaskFrom(24, 32)},
+		{name: "ipv4 host bits", prefix: netip.MustParsePrefix("192.0.2.1/31"), expected: MaskFrom(31, 32)},
+		{name: "ipv6", prefix: netip.MustParsePrefix("2001:db8::/64"), expected: MaskFrom(64, 128)},
+		{name: "ipv4-mapped", prefix: netip.MustParsePrefix("::ffff:192.0.2.0/120"), expected: MaskFrom(120, 128)},
+		{name: "zero prefix", prefix: netip.Prefix{}, expected: Mask{}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_Prefix(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		addr     netip.Addr
+		expected netip.Prefix
+		ok       bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		p, ok := tc.mask.Prefix(tc.addr)
+		assert.Equal(t, ok, tc.ok)
+		assert.Equal(t, p, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "ipv4",
+			mask:     MaskFrom(24, 32),
+			addr:     netip.MustParseAddr("192.0.2.55"),
+			expected: netip.MustParsePrefix("192.0.2.0/24"),
+			ok:       true,
+		},
+		{
+			name:     "ipv6 with zone",
+			mask:     MaskFrom(64, 128),
+			addr:     netip.MustParseAddr("2001:db8::1%eth0"),
+			expected: netip.MustParsePrefix("2001:db8::/64"),
+			ok:       true,
+		},
+		{
+			name: "non-prefix mask",
+			mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}),
+			addr: netip.MustParseAddr("192.0.2.55"),
+		},
+		{
+			name: "family mismatch",
+			mask: MaskFrom(64, 128),
+			addr: netip.MustParseAddr("192.0.2.55"),
+		},
+		{
+			name: "zero mask",
+			mask: Mask{},
+			addr: netip.MustParseAddr("192.0.2.55"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_PrefixRoundtrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		var addr netip.Addr
+		switch rapid.SampledFrom([]int8{z4, z6}).Draw(t, "z") {
+		case z4:
+			addr = netip.AddrFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "addr")))
+		default:
+			addr = netip.AddrFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "addr")))
+		}
+		prefix := netip.PrefixFrom(addr, rapid.IntRange(0, addr.BitLen()).Draw(t, "bits")).Masked()
+
+		out, ok := MaskFromPrefix(prefix).Prefix(addr)
+		assert.Assert(t, ok)
+		assert.Equal(t, out, prefix)
+	})
+}
+
+func TestNetmask_As4(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected [4]byte
+		panics   bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		if tc.panics {
+			assert.Assert(t, cmp.Panics(func() { tc.mask.As4() }))
+			return
+		}
+
+		assert.Equal(t, tc.mask.As4(), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "ipv4 mask", mask: MaskFrom(31, 32), expected: [...]byte{0xFF, 0xFF, 0xFF, 0xFE}},
+		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: [...]byte{0xFF, 0x00, 0xFF, 0x00}},
+		{name: "ipv6 mask", mask: MaskFrom(128, 128), panics: true},
+		{name: "zero mask", mask: Mask{}, panics: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_As16(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected [16]byte
+		panics   bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		if tc.panics {
+			assert.Assert(t, cmp.Panics(func() { tc.mask.As16() }))
+			return
+		}
+
+		assert.Equal(t, tc.mask.As16(), tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name: "ipv6 mask",
+			mask: MaskFrom(96, 128),
+			expected: [...]byte{
+				0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
+			},
+		},
+		{
+			name: "weird ipv6 mask",
+			mask: weird6,
+			expected: [...]byte{
+				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
+				0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0,
+			},
+		},
+		{name: "ipv4 mask", mask: MaskFrom(32, 32), panics: true},
+		{name: "zero mask", mask: Mask{}, panics: true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_AsAllocs(t *testing.T) {
+	v4 := MaskFrom(24, 32)
+	v6 := MaskFrom(64, 128)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = v4.As4()
+		_ = v6.As16()
+	})
+	assert.Equal(t, allocs, float64(0))
+}
+
+func TestNetmask_Hostmask(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.mask.Hostmask(), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "ipv4 /24", mask: MaskFrom(24, 32), expected: MaskFrom4([...]byte{0, 0, 0, 0xFF})},
+		{name: "ipv4 /0", mask: MaskFrom(0, 32), expected: MaskFrom(32, 32)},
+		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: MaskFrom4([...]byte{0x00, 0xFF, 0x00, 0xFF})},
+		{name: "ipv6 /128", mask: MaskFrom(128, 128), expected: MaskFrom(0, 128)},
+		{name: "weird ipv6 mask", mask: weird6, expected: MaskFrom16([...]byte{
+			0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF,
+			0, 0, 0, 0, 0xFF, 0xFF, 0xFF, 0xFF,
+		})},
+		{name: "zero mask", mask: Mask{}, expected: Mask{}},
 	}
 
 	for _, tc := range testCases {
@@ -781,3 +1263,631 @@ func TestNetmask_String(t *testing.T) {
 		})
 	}
 }
+
+func TestNetmask_Compare(t *testing.T) {
+	type testCase struct {
+		name     string
+		x, y     Mask
+		expected int
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.x.Compare(tc.y), tc.expected)
+		assert.Equal(t, tc.y.Compare(tc.x), -tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "equal", x: MaskFrom(24, 32), y: MaskFrom(24, 32), expected: 0},
+		{name: "zero masks", x: Mask{}, y: Mask{}, expected: 0},
+		{name: "zero before ipv4", x: Mask{}, y: MaskFrom(0, 32), expected: -1},
+		{name: "ipv4 before ipv6", x: MaskFrom(32, 32), y: MaskFrom(0, 128), expected: -1},
+		{name: "ipv4 prefix length", x: MaskFrom(16, 32), y: MaskFrom(24, 32), expected: -1},
+		{name: "ipv6 prefix length", x: MaskFrom(64, 128), y: MaskFrom(65, 128), expected: -1},
+		{name: "ipv6 prefix length low bits", x: MaskFrom(100, 128), y: MaskFrom(127, 128), expected: -1},
+		{name: "weird ipv4 mask", x: MaskFrom(8, 32), y: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: -1},
+		{name: "weird ipv4 mask enclosed", x: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), y: MaskFrom(9, 32), expected: -1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_CompareSort(t *testing.T) {
+	masks := []Mask{MaskFrom(64, 128), MaskFrom(24, 32), Mask{}, MaskFrom(8, 32), MaskFrom(0, 128)}
+	sort.Slice(masks, func(i, j int) bool {
+		return masks[i].Compare(masks[j]) < 0
+	})
+
+	expected := []Mask{{}, MaskFrom(8, 32), MaskFrom(24, 32), MaskFrom(0, 128), MaskFrom(64, 128)}
+	for i := range expected {
+		assert.Equal(t, masks[i], expected[i])
+	}
+}
+
+func TestNetmask_AddressCount(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected uint64
+		ok       bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		n, ok := tc.mask.AddressCount()
+		assert.Equal(t, ok, tc.ok)
+		assert.Equal(t, n, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}},
+		{name: "ipv4 host", mask: MaskFrom(32, 32), expected: 1, ok: true},
+		{name: "ipv4 prefix", mask: MaskFrom(24, 32), expected: 256, ok: true},
+		{name: "ipv4 zero prefix", mask: MaskFrom(0, 32), expected: 1 << 32, ok: true},
+		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: 1 << 16, ok: true},
+		{name: "ipv6 host", mask: MaskFrom(128, 128), expected: 1, ok: true},
+		{name: "ipv6 prefix", mask: MaskFrom(120, 128), expected: 256, ok: true},
+		{name: "ipv6 largest", mask: MaskFrom(65, 128), expected: 1 << 63, ok: true},
+		{name: "ipv6 overflow", mask: MaskFrom(64, 128)},
+		{name: "ipv6 zero prefix", mask: MaskFrom(0, 128)},
+		{name: "weird ipv6 mask", mask: MaskFrom16([...]byte{0xFF, 0, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}), expected: 256, ok: true},
+		{name: "weird ipv6 overflow", mask: weird6},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_Bitwise(t *testing.T) {
+	type testCase struct {
+		name string
+		x, y Mask
+		and  Mask
+		or   Mask
+		xor  Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.x.And(tc.y), tc.and)
+		assert.Equal(t, tc.x.Or(tc.y), tc.or)
+		assert.Equal(t, tc.x.Xor(tc.y), tc.xor)
+	}
+
+	testCases := []testCase{
+		{
+			name: "ipv4 prefixes",
+			x:    MaskFrom(16, 32),
+			y:    MaskFrom(24, 32),
+			and:  MaskFrom(16, 32),
+			or:   MaskFrom(24, 32),
+			xor:  MaskFrom4([...]byte{0x00, 0x00, 0xFF, 0x00}),
+		},
+		{
+			name: "weird ipv4 masks",
+			x:    MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}),
+			y:    MaskFrom4([...]byte{0xFF, 0xFF, 0x00, 0x00}),
+			and:  MaskFrom(8, 32),
+			or:   MaskFrom4([...]byte{0xFF, 0xFF, 0xFF, 0x00}),
+			xor:  MaskFrom4([...]byte{0x00, 0xFF, 0xFF, 0x00}),
+		},
+		{
+			name: "ipv6 masks",
+			x:    weird6,
+			y:    MaskFrom(64, 128),
+			and:  MaskFrom(32, 128),
+			or:   MaskFrom(96, 128),
+			xor:  MaskFrom16([16]byte{4: 0xFF, 5: 0xFF, 6: 0xFF, 7: 0xFF, 8: 0xFF, 9: 0xFF, 10: 0xFF, 11: 0xFF}),
+		},
+		{
+			name: "mixed families",
+			x:    MaskFrom(24, 32),
+			y:    MaskFrom(24, 128),
+		},
+		{
+			name: "zero mask",
+			x:    MaskFrom(24, 32),
+			y:    Mask{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_Not(t *testing.T) {
+	assert.Equal(t, MaskFrom(24, 32).Not(), MaskFrom4([...]byte{0x00, 0x00, 0x00, 0xFF}))
+	assert.Equal(t, weird6.Not().Not(), weird6)
+	assert.Equal(t, Mask{}.Not(), Mask{})
+}
+
+func TestNetmask_Map4In6(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		out := tc.mask.Map4In6()
+		assert.Equal(t, out, tc.expected)
+		assert.Equal(t, out.Unmap(), tc.mask)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, expected: Mask{}},
+		{name: "ipv4 prefix", mask: MaskFrom(24, 32), expected: MaskFrom(120, 128)},
+		{name: "ipv4 zero prefix", mask: MaskFrom(0, 32), expected: MaskFrom(96, 128)},
+		{name: "ipv4 full prefix", mask: MaskFrom(32, 32), expected: MaskFrom(128, 128)},
+		{
+			name:     "weird ipv4 mask",
+			mask:     MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}),
+			expected: MaskFrom16([...]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0xFF, 0x00}),
+		},
+		{name: "ipv6 prefix", mask: MaskFrom(64, 128), expected: MaskFrom(64, 128)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_Unmap(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.mask.Unmap(), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, expected: Mask{}},
+		{name: "ipv4 prefix", mask: MaskFrom(24, 32), expected: MaskFrom(24, 32)},
+		{name: "mapped prefix", mask: MaskFrom(112, 128), expected: MaskFrom(16, 32)},
+		{name: "mapped host", mask: MaskFrom(128, 128), expected: MaskFrom(32, 32)},
+		{name: "short ipv6 prefix", mask: MaskFrom(95, 128), expected: MaskFrom(95, 128)},
+		{name: "weird ipv6 mask", mask: weird6, expected: weird6},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_Map4In6Apply(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		mask := MaskFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "mask")))
+		addr := netip.AddrFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "addr")))
+
+		mapped := mask.Map4In6().Apply(netip.AddrFrom16(addr.As16()))
+		assert.Equal(t, mapped.Unmap(), mask.Apply(addr))
+	})
+}
+
+func TestNetmask_WidenNarrow(t *testing.T) {
+	type testCase struct {
+		name   string
+		mask   Mask
+		n      int
+		widen  Mask
+		narrow Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.mask.Widen(tc.n), tc.widen)
+		assert.Equal(t, tc.mask.Narrow(tc.n), tc.narrow)
+	}
+
+	testCases := []testCase{
+		{name: "ipv4", mask: MaskFrom(16, 32), n: 8, widen: MaskFrom(8, 32), narrow: MaskFrom(24, 32)},
+		{name: "ipv4 zero", mask: MaskFrom(24, 32), n: 0, widen: MaskFrom(24, 32), narrow: MaskFrom(24, 32)},
+		{name: "ipv4 bounds", mask: MaskFrom(16, 32), n: 16, widen: MaskFrom(0, 32), narrow: MaskFrom(32, 32)},
+		{name: "ipv4 out of range", mask: MaskFrom(16, 32), n: 17},
+		{name: "ipv6", mask: MaskFrom(64, 128), n: 32, widen: MaskFrom(32, 128), narrow: MaskFrom(96, 128)},
+		{name: "ipv6 narrow out of range", mask: MaskFrom(100, 128), n: 29, widen: MaskFrom(71, 128)},
+		{name: "negative", mask: MaskFrom(16, 32), n: -1},
+		{name: "weird mask", mask: weird6, n: 1},
+		{name: "zero mask", mask: Mask{}, n: 1},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_IPMask(t *testing.T) {
+	type testCase struct {
+		name   string
+		ipmask net.IPMask
+		mask   Mask
+		ones   int
+		bits   int
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask, ok := MaskFromIPMask(tc.ipmask)
+		assert.Assert(t, ok)
+		assert.Equal(t, mask, tc.mask)
+		assert.DeepEqual(t, mask.IPMask(), tc.ipmask)
+
+		ones, bits := mask.Size()
+		assert.Equal(t, ones, tc.ones)
+		assert.Equal(t, bits, tc.bits)
+
+		ones, bits = tc.ipmask.Size()
+		assert.Equal(t, ones, tc.ones)
+		assert.Equal(t, bits, tc.bits)
+	}
+
+	testCases := []testCase{
+		{name: "ipv4 prefix", ipmask: net.CIDRMask(24, 32), mask: MaskFrom(24, 32), ones: 24, bits: 32},
+		{name: "ipv4 zero prefix", ipmask: net.CIDRMask(0, 32), mask: MaskFrom(0, 32), ones: 0, bits: 32},
+		{name: "ipv4 mask", ipmask: net.IPv4Mask(255, 255, 0, 0), mask: MaskFrom(16, 32), ones: 16, bits: 32},
+		{name: "weird ipv4 mask", ipmask: net.IPv4Mask(255, 0, 255, 0), mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00})},
+		{name: "ipv6 prefix", ipmask: net.CIDRMask(64, 128), mask: MaskFrom(64, 128), ones: 64, bits: 128},
+		{name: "weird ipv6 mask", ipmask: net.IPMask(weird6.AsSlice()), mask: weird6},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_IPMaskInvalid(t *testing.T) {
+	_, ok := MaskFromIPMask(net.IPMask{0xFF, 0xFF})
+	assert.Assert(t, !ok)
+
+	assert.Assert(t, Mask{}.IPMask() == nil)
+
+	ones, bits := Mask{}.Size()
+	assert.Equal(t, ones, 0)
+	assert.Equal(t, bits, 0)
+}
+
+func TestNetmask_Range(t *testing.T) {
+	type testCase struct {
+		name  string
+		mask  Mask
+		base  netip.Addr
+		first netip.Addr
+		last  netip.Addr
+		ok    bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		first, last, ok := tc.mask.Range(tc.base)
+		assert.Equal(t, ok, tc.ok)
+		assert.Equal(t, first, tc.first)
+		assert.Equal(t, last, tc.last)
+	}
+
+	testCases := []testCase{
+		{
+			name:  "ipv4 prefix",
+			mask:  MaskFrom(24, 32),
+			base:  netip.MustParseAddr("10.0.1.17"),
+			first: netip.MustParseAddr("10.0.1.0"),
+			last:  netip.MustParseAddr("10.0.1.255"),
+			ok:    true,
+		},
+		{
+			name:  "ipv4 host",
+			mask:  MaskFrom(32, 32),
+			base:  netip.MustParseAddr("10.0.1.17"),
+			first: netip.MustParseAddr("10.0.1.17"),
+			last:  netip.MustParseAddr("10.0.1.17"),
+			ok:    true,
+		},
+		{
+			name:  "ipv4 zero prefix",
+			mask:  MaskFrom(0, 32),
+			base:  netip.MustParseAddr("10.0.1.17"),
+			first: netip.MustParseAddr("0.0.0.0"),
+			last:  netip.MustParseAddr("255.255.255.255"),
+			ok:    true,
+		},
+		{
+			name:  "weird ipv4 mask",
+			mask:  MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}),
+			base:  netip.MustParseAddr("10.1.2.3"),
+			first: netip.MustParseAddr("10.0.2.0"),
+			last:  netip.MustParseAddr("10.255.2.255"),
+			ok:    true,
+		},
+		{
+			name:  "ipv6 prefix",
+			mask:  MaskFrom(64, 128),
+			base:  netip.MustParseAddr("2001:db8::1%eth0"),
+			first: netip.MustParseAddr("2001:db8::"),
+			last:  netip.MustParseAddr("2001:db8::ffff:ffff:ffff:ffff"),
+			ok:    true,
+		},
+		{
+			name: "family mismatch",
+			mask: MaskFrom(24, 32),
+			base: netip.MustParseAddr("2001:db8::1"),
+		},
+		{
+			name: "zero mask",
+			mask: Mask{},
+			base: netip.MustParseAddr("10.0.1.17"),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_StringSlash(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.mask.StringSlash(), tc.expected)
+
+		if tc.mask.IsValid() {
+			out, err := ParseMask(tc.expected)
+			assert.NilError(t, err)
+			assert.Equal(t, out, tc.mask)
+		}
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, expected: "invalid Mask"},
+		{name: "ipv4 mask", mask: MaskFrom(24, 32), expected: "255.255.255.0"},
+		{name: "ipv6 prefix", mask: MaskFrom(64, 128), expected: "/64"},
+		{name: "ipv6 zero prefix", mask: MaskFrom(0, 128), expected: "/0"},
+		{name: "ipv6 full prefix", mask: MaskFrom(128, 128), expected: "/128"},
+		{name: "weird ipv6 mask", mask: weird6, expected: "ffff:ffff::ffff:ffff:0:0"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_Predicates(t *testing.T) {
+	type testCase struct {
+		name       string
+		mask       Mask
+		isHostMask bool
+		isZeroBits bool
+		isPrefix   bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.mask.IsHostMask(), tc.isHostMask)
+		assert.Equal(t, tc.mask.IsZeroBits(), tc.isZeroBits)
+		assert.Equal(t, tc.mask.IsPrefix(), tc.isPrefix)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}},
+		{name: "ipv4 host", mask: MaskFrom(32, 32), isHostMask: true, isPrefix: true},
+		{name: "ipv4 zero bits", mask: MaskFrom(0, 32), isZeroBits: true, isPrefix: true},
+		{name: "ipv4 prefix", mask: MaskFrom(24, 32), isPrefix: true},
+		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00})},
+		{name: "ipv6 host", mask: MaskFrom(128, 128), isHostMask: true, isPrefix: true},
+		{name: "ipv6 zero bits", mask: MaskFrom(0, 128), isZeroBits: true, isPrefix: true},
+		{name: "ipv6 prefix", mask: MaskFrom(64, 128), isPrefix: true},
+		{name: "ipv6 mapped host", mask: MaskFrom(32, 32).Map4In6(), isHostMask: true, isPrefix: true},
+		{name: "weird ipv6 mask", mask: weird6},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_OnesCount(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected int
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.mask.OnesCount(), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, expected: 0},
+		{name: "ipv4 prefix", mask: MaskFrom(24, 32), expected: 24},
+		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xF0, 0x01}), expected: 13},
+		{name: "ipv6 prefix", mask: MaskFrom(100, 128), expected: 100},
+		{name: "weird ipv6 mask", mask: weird6, expected: 64},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_OnesCountBits(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		bits := rapid.SampledFrom([]int{32, 128}).Draw(t, "bits")
+		mask := MaskFrom(rapid.IntRange(0, bits).Draw(t, "ones"), bits)
+
+		assert.Equal(t, mask.OnesCount(), mask.Bits())
+		assert.Equal(t, mask.OnesCount()+mask.Hostmask().OnesCount(), bits)
+	})
+}
+
+func TestNetmask_PutTo(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected []byte
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		b := make([]byte, 8)
+		n, err := tc.mask.PutTo(b)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, b[:n], tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, expected: []byte{}},
+		{name: "ipv4 mask", mask: MaskFrom(31, 32), expected: []byte{0xFF, 0xFF, 0xFF, 0xFE}},
+		{name: "weird ipv4 mask", mask: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00}), expected: []byte{0xFF, 0x00, 0xFF, 0x00}},
+		{name: "ipv6 mask", mask: MaskFrom(128, 128), expected: native.Endian.AppendUint32(nil, 128)},
+		{name: "ipv6 zero mask", mask: MaskFrom(0, 128), expected: []byte{0, 0, 0, 0}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_PutToError(t *testing.T) {
+	_, err := MaskFrom(24, 32).PutTo(make([]byte, 3))
+	assert.ErrorIs(t, err, io.ErrShortBuffer)
+
+	_, err = weird6.PutTo(make([]byte, 4))
+	assert.ErrorContains(t, err, "not a prefix")
+}
+
+func TestNetmask_PutToAllocs(t *testing.T) {
+	b := make([]byte, 4)
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = MaskFrom(64, 128).PutTo(b)
+	})
+	assert.Equal(t, allocs, 0.0)
+}
+
+func TestNetmask_MarshalBinaryV2(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected []byte
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		b := tc.mask.MarshalBinaryV2()
+		assert.DeepEqual(t, b, tc.expected)
+
+		out := MaskFrom(8, 32)
+		assert.NilError(t, out.UnmarshalBinary(b))
+		assert.Equal(t, out, tc.mask)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, expected: []byte{2, 0}},
+		{name: "ipv4 mask", mask: MaskFrom(24, 32), expected: []byte{2, 4, 0xFF, 0xFF, 0xFF, 0x00}},
+		{name: "ipv6 mask", mask: MaskFrom(8, 128), expected: []byte{2, 6, 0xFF, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{name: "weird ipv6 mask", mask: weird6, expected: append([]byte{2, 6}, weird6.AsSlice()...)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_UnmarshalBinaryV2Error(t *testing.T) {
+	type testCase struct {
+		name string
+		in   []byte
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var out Mask
+		err := out.UnmarshalBinary(tc.in)
+		assert.ErrorIs(t, err, ErrSyntax)
+	}
+
+	testCases := []testCase{
+		{name: "unknown family", in: []byte{2, 5, 0xFF, 0xFF, 0xFF, 0x00}},
+		{name: "family length mismatch", in: []byte{2, 4}},
+		{name: "ipv6 length mismatch", in: []byte{2, 6, 0xFF, 0xFF, 0xFF, 0x00}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_GobRoundtrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		mask := rapid.Custom[Mask](func(t *rapid.T) Mask {
+			z := rapid.SampledFrom([]int8{z0, z4, z6}).Draw(t, "z")
+
+			switch z {
+			case z4:
+				return MaskFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "mask")))
+			case z6:
+				return rapid.OneOf(
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+					}),
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+					}),
+				).Draw(t, "mask6")
+			default:
+				return Mask{}
+			}
+		}).Draw(t, "mask")
+
+		var buf bytes.Buffer
+		assert.NilError(t, gob.NewEncoder(&buf).Encode(mask))
+
+		var out Mask
+		assert.NilError(t, gob.NewDecoder(&buf).Decode(&out))
+		assert.Equal(t, out, mask)
+
+		var v2 Mask
+		assert.NilError(t, v2.UnmarshalBinary(mask.MarshalBinaryV2()))
+		assert.Equal(t, v2, mask)
+	})
+}
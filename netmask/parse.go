@@ -0,0 +1,293 @@
+package netmask
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Reasons wrapped by the *ParseError returned from ParseMask and from
+// unmarshalling a Mask. They can be distinguished with errors.Is.
+var (
+	// ErrSyntax indicates that the input is not in any of the accepted forms.
+	ErrSyntax = errors.New("invalid syntax")
+
+	// ErrRange indicates that an octet or prefix length is out of range.
+	ErrRange = errors.New("value out of range")
+
+	// ErrNonContiguous indicates that a mask is not a prefix, which is only
+	// rejected by ParseMaskStrict.
+	ErrNonContiguous = errors.New("non-contiguous mask")
+)
+
+// ParseError describes a mask that could not be parsed, pointing at the
+// offending part of the input. It is returned by ParseMask, ParseMaskStrict,
+// Mask.UnmarshalText and Mask.UnmarshalBinary. For the latter, Input holds
+// the raw bytes.
+type ParseError struct {
+	// Input is the string being parsed.
+	Input string
+	// Offset is the byte offset in Input of the field that was rejected.
+	Offset int
+	// Err is the reason, one of ErrSyntax, ErrRange or ErrNonContiguous.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("netmask: invalid mask %q at offset %d: %v", e.Input, e.Offset, e.Err)
+}
+
+// Unwrap returns the reason of the error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseMask parses s as a Mask. The following forms are accepted:
+//
+//   - IPv4 dotted decimal ("255.255.255.0"), which may be a non-prefix mask.
+//   - IPv6 prefix length, with or without a leading slash ("64" or "/64").
+//   - IPv6 address form ("ffff:ffff:ffff:ffff::"), which may be a non-prefix mask.
+//   - A wildcard mask of either family prefixed with a tilde ("~0.0.0.255"),
+//     which results in the complement of the wildcard mask, see Mask.Hostmask.
+//
+// The family of the resulting Mask is decided by the form alone: dotted
+// decimal is always IPv4, while the address form and a bare or slashed
+// prefix length are always IPv6, since IPv6 masks are written as prefix
+// lengths by IPVS and by String. This guarantees that ParseMask(m.String())
+// returns m for every valid Mask. IPv4 prefix lengths can be converted with MaskFrom(ones, 32).
+//
+// Errors are always of type *ParseError.
+func ParseMask(s string) (Mask, error) {
+	mask, err := parseMask(s)
+	if err != nil {
+		err.Input = s
+		return Mask{}, err
+	}
+
+	return mask, nil
+}
+
+// MustParseMask calls ParseMask(s) and panics on error.
+// It is intended for use in tests with hard-coded strings.
+func MustParseMask(s string) Mask {
+	mask, err := ParseMask(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return mask
+}
+
+// ParseMaskStrict parses s like ParseMask, but only accepts prefix masks and
+// rejects octets with leading zeros, such as "255.255.255.00". IPVS
+// persistence masks must be prefixes, so this catches misconfiguration that
+// ParseMask silently accepts.
+//
+// Errors are always of type *ParseError.
+func ParseMaskStrict(s string) (Mask, error) {
+	mask, err := parseMaskStrict(s)
+	if err != nil {
+		err.Input = s
+		return Mask{}, err
+	}
+
+	return mask, nil
+}
+
+// parseMaskStrict implements ParseMaskStrict.
+func parseMaskStrict(s string) (Mask, *ParseError) {
+	mask, err := parseMask(s)
+	if err != nil {
+		return Mask{}, err
+	}
+
+	off := 0
+	if s[0] == '~' {
+		off = 1
+	}
+
+	if mask.Is4() {
+		if err := checkLeadingZeros(s[off:]); err != nil {
+			err.Offset += off
+			return Mask{}, err
+		}
+	}
+
+	if mask.Bits() < 0 {
+		if mask.Is4() {
+			off += fieldOffset(s[off:], nonContiguousBit(mask.mask)/8)
+		}
+		return Mask{}, &ParseError{Offset: off, Err: ErrNonContiguous}
+	}
+
+	return mask, nil
+}
+
+// checkLeadingZeros rejects octets of a dotted decimal mask which have a
+// leading zero.
+func checkLeadingZeros(s string) *ParseError {
+	off := 0
+	for _, field := range strings.Split(s, ".") {
+		if len(field) > 1 && field[0] == '0' {
+			return &ParseError{Offset: off, Err: ErrSyntax}
+		}
+		off += len(field) + 1
+	}
+
+	return nil
+}
+
+// fieldOffset returns the byte offset of the i'th field of a dotted decimal
+// mask.
+func fieldOffset(s string, i int) int {
+	off := 0
+	for ; i > 0; i-- {
+		off += strings.IndexByte(s[off:], '.') + 1
+	}
+
+	return off
+}
+
+// nonContiguousBit returns the number of the first set bit in u following
+// its leading ones.
+func nonContiguousBit(u uint128) int {
+	n := u.leadingOnes()
+	rest := u.and(mask6(n).not())
+	if rest.hi != 0 {
+		return bits.LeadingZeros64(rest.hi)
+	}
+
+	return 64 + bits.LeadingZeros64(rest.lo)
+}
+
+// parseMask implements ParseMask. Errors carry the offset of the offending
+// field, but not the input.
+func parseMask(s string) (Mask, *ParseError) {
+	switch {
+	case s == "":
+		return Mask{}, &ParseError{Err: ErrSyntax}
+	case s[0] == '~':
+		mask, err := parseWildcard(s[1:])
+		if err != nil {
+			err.Offset++
+		}
+		return mask, err
+	case strings.IndexByte(s, ':') >= 0:
+		return parseIPv6(s)
+	case strings.IndexByte(s, '.') >= 0:
+		return parseIPv4(s)
+	case s[0] == '/':
+		mask, err := parsePrefix(s[1:])
+		if err != nil {
+			err.Offset++
+		}
+		return mask, err
+	default:
+		return parsePrefix(s)
+	}
+}
+
+// parseWildcard parses a wildcard mask without the leading tilde, returning
+// its complement.
+func parseWildcard(s string) (Mask, *ParseError) {
+	if s == "" || s[0] == '~' {
+		return Mask{}, &ParseError{Err: ErrSyntax}
+	}
+
+	mask, err := parseMask(s)
+	if err != nil {
+		return Mask{}, err
+	}
+
+	return mask.Hostmask(), nil
+}
+
+// parseIPv4 parses a dotted decimal IPv4 mask. It scans the input directly,
+// like netip does for addresses, so that parsing does not allocate.
+func parseIPv4(s string) (Mask, *ParseError) {
+	var mask [4]byte
+	var field, off int
+	for i := 0; i <= len(s); i++ {
+		if i < len(s) && s[i] != '.' {
+			continue
+		}
+
+		if field == 4 || i == off {
+			return Mask{}, &ParseError{Offset: off, Err: ErrSyntax}
+		}
+
+		v, err := parseOctet(s[off:i])
+		if err != nil {
+			return Mask{}, &ParseError{Offset: off, Err: err}
+		}
+
+		mask[field] = v
+		field++
+		off = i + 1
+	}
+
+	if field < 4 {
+		return Mask{}, &ParseError{Offset: len(s), Err: ErrSyntax}
+	}
+
+	return MaskFrom4(mask), nil
+}
+
+// parseOctet parses a non-empty decimal octet, returning ErrSyntax for any
+// character other than a digit, and ErrRange if the value exceeds 255.
+func parseOctet(s string) (byte, error) {
+	var v int
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, ErrSyntax
+		}
+		if v <= 255 {
+			v = v*10 + int(s[i]-'0')
+		}
+	}
+
+	if v > 255 {
+		return 0, ErrRange
+	}
+
+	return byte(v), nil
+}
+
+// parseIPv6 parses the address form of an IPv6 mask.
+func parseIPv6(s string) (Mask, *ParseError) {
+	addr, ok := parseAddr6(s)
+	if !ok {
+		return Mask{}, &ParseError{Err: ErrSyntax}
+	}
+
+	return MaskFrom16(addr), nil
+}
+
+// parsePrefix parses an IPv6 prefix length without the leading slash.
+func parsePrefix(s string) (Mask, *ParseError) {
+	if s == "" {
+		return Mask{}, &ParseError{Err: ErrSyntax}
+	}
+
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil {
+		return Mask{}, &ParseError{Err: numError(err)}
+	}
+	if v > 128 {
+		return Mask{}, &ParseError{Err: ErrRange}
+	}
+
+	return MaskFrom(int(v), 128), nil
+}
+
+// numError maps strconv errors onto ErrSyntax and ErrRange.
+func numError(err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return ErrRange
+	}
+
+	return ErrSyntax
+}
@@ -0,0 +1,131 @@
+// Portions of netmask adapted from the Go Standard Library.
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the go.LICENSE file.
+
+package netmask
+
+// parseAddr6 parses s as an IPv6 address without a zone, including the
+// embedded IPv4 form, reporting false if s is not valid.
+//
+// It is adapted from netip.ParseAddr, which retains its input in the error
+// it returns, causing callers converting a []byte to a string to allocate.
+func parseAddr6(s string) (ip [16]byte, ok bool) {
+	ellipsis := -1 // position of ellipsis in ip
+
+	// Might have leading ellipsis
+	if len(s) >= 2 && s[0] == ':' && s[1] == ':' {
+		ellipsis = 0
+		s = s[2:]
+		// Might be only ellipsis
+		if len(s) == 0 {
+			return ip, true
+		}
+	}
+
+	// Loop, parsing hex numbers followed by colon.
+	i := 0
+	for i < 16 {
+		// Hex number. Similar to parseIPv4, inlining the hex number
+		// parsing yields a significant performance increase.
+		off := 0
+		acc := uint32(0)
+		for ; off < len(s); off++ {
+			c := s[off]
+			switch {
+			case c >= '0' && c <= '9':
+				acc = (acc << 4) + uint32(c-'0')
+			case c >= 'a' && c <= 'f':
+				acc = (acc << 4) + uint32(c-'a'+10)
+			case c >= 'A' && c <= 'F':
+				acc = (acc << 4) + uint32(c-'A'+10)
+			default:
+				goto done
+			}
+			if off > 3 {
+				// more than 4 digits in group, fail.
+				return ip, false
+			}
+		}
+	done:
+		if off == 0 {
+			// No digits found, fail.
+			return ip, false
+		}
+
+		// If followed by dot, might be in trailing IPv4.
+		if off < len(s) && s[off] == '.' {
+			if ellipsis < 0 && i != 12 {
+				// Not the right place.
+				return ip, false
+			}
+			if i+4 > 16 {
+				// Not enough room.
+				return ip, false
+			}
+
+			mask, err := parseIPv4(s)
+			if err != nil {
+				return ip, false
+			}
+			v4 := mask.As4()
+			copy(ip[i:], v4[:])
+			s = ""
+			i += 4
+			break
+		}
+
+		// Save this 16-bit chunk.
+		ip[i] = byte(acc >> 8)
+		ip[i+1] = byte(acc)
+		i += 2
+
+		// Stop at end of string.
+		s = s[off:]
+		if len(s) == 0 {
+			break
+		}
+
+		// Otherwise must be followed by colon and more.
+		if s[0] != ':' || len(s) == 1 {
+			return ip, false
+		}
+		s = s[1:]
+
+		// Look for ellipsis.
+		if s[0] == ':' {
+			if ellipsis >= 0 { // already have one
+				return ip, false
+			}
+			ellipsis = i
+			s = s[1:]
+			if len(s) == 0 { // can be at end
+				break
+			}
+		}
+	}
+
+	// Must have used entire string.
+	if len(s) != 0 {
+		return ip, false
+	}
+
+	// If didn't parse enough, expand ellipsis.
+	if i < 16 {
+		if ellipsis < 0 {
+			return ip, false
+		}
+		n := 16 - i
+		for j := i - 1; j >= ellipsis; j-- {
+			ip[j+n] = ip[j]
+		}
+		for j := ellipsis + n - 1; j >= ellipsis; j-- {
+			ip[j] = 0
+		}
+	} else if ellipsis >= 0 {
+		// Ellipsis must represent at least one 0 group.
+		return ip, false
+	}
+
+	return ip, true
+}
@@ -0,0 +1,280 @@
+package netmask
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"pgregory.net/rapid"
+)
+
+func TestNetmask_ParseMask(t *testing.T) {
+	type testCase struct {
+		name     string
+		in       string
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask, err := ParseMask(tc.in)
+		assert.NilError(t, err)
+		assert.Equal(t, mask, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "ipv4 mask", in: "255.255.255.0", expected: MaskFrom(24, 32)},
+		{name: "ipv4 zero mask", in: "0.0.0.0", expected: MaskFrom(0, 32)},
+		{name: "weird ipv4 mask", in: "255.0.255.0", expected: MaskFrom4([...]byte{0xFF, 0x00, 0xFF, 0x00})},
+		{name: "ipv6 prefix", in: "64", expected: MaskFrom(64, 128)},
+		{name: "ipv6 slash prefix", in: "/64", expected: MaskFrom(64, 128)},
+		{name: "ipv6 zero prefix", in: "/0", expected: MaskFrom(0, 128)},
+		{name: "ipv6 full prefix", in: "128", expected: MaskFrom(128, 128)},
+		{name: "ipv6 address form", in: "ffff:ffff:ffff:ffff::", expected: MaskFrom(64, 128)},
+		{name: "weird ipv6 mask", in: "ffff::ffff", expected: MaskFrom16([...]byte{0: 0xFF, 1: 0xFF, 14: 0xFF, 15: 0xFF})},
+		{name: "ipv4 wildcard", in: "~0.0.0.255", expected: MaskFrom(24, 32)},
+		{name: "ipv6 wildcard", in: "~::ffff:ffff", expected: MaskFrom(96, 128)},
+		{name: "ipv6 prefix wildcard", in: "~/96", expected: MaskFrom16([16]byte{12: 0xFF, 13: 0xFF, 14: 0xFF, 15: 0xFF})},
+		{name: "ipv4-mapped form", in: "::ffff:255.255.255.0", expected: MaskFrom16([16]byte{10: 0xFF, 11: 0xFF, 12: 0xFF, 13: 0xFF, 14: 0xFF})},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ParseMaskError(t *testing.T) {
+	type testCase struct {
+		name     string
+		in       string
+		expected error
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask, err := ParseMask(tc.in)
+		assert.Assert(t, errors.Is(err, tc.expected), "got error: %v", err)
+		assert.Equal(t, mask, Mask{})
+
+		var pe *ParseError
+		assert.Assert(t, errors.As(err, &pe), "got error: %v", err)
+		assert.Equal(t, pe.Input, tc.in)
+	}
+
+	testCases := []testCase{
+		{name: "empty", in: "", expected: ErrSyntax},
+		{name: "slash only", in: "/", expected: ErrSyntax},
+		{name: "three octets", in: "255.255.0", expected: ErrSyntax},
+		{name: "five octets", in: "255.255.255.0.0", expected: ErrSyntax},
+		{name: "empty octet", in: "255..255.0", expected: ErrSyntax},
+		{name: "letter octet", in: "255.a.255.0", expected: ErrSyntax},
+		{name: "negative octet", in: "255.-1.255.0", expected: ErrSyntax},
+		{name: "large octet", in: "255.256.255.0", expected: ErrRange},
+		{name: "large prefix", in: "129", expected: ErrRange},
+		{name: "very large prefix", in: "/1024", expected: ErrRange},
+		{name: "signed prefix", in: "+24", expected: ErrSyntax},
+		{name: "wildcard only", in: "~", expected: ErrSyntax},
+		{name: "double wildcard", in: "~~0.0.0.255", expected: ErrSyntax},
+		{name: "wildcard range", in: "~0.0.0.256", expected: ErrRange},
+		{name: "ipv6 zone", in: "ffff::%eth0", expected: ErrSyntax},
+		{name: "ipv6 bad address", in: "ffff:::", expected: ErrSyntax},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ParseMaskString(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		mask := rapid.Custom(func(t *rapid.T) Mask {
+			z := rapid.SampledFrom([]int8{z4, z6}).Draw(t, "z")
+
+			switch z {
+			case z4:
+				return MaskFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "mask")))
+			default:
+				return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+			}
+		}).Draw(t, "mask")
+
+		out, err := ParseMask(mask.String())
+		assert.NilError(t, err)
+		assert.Equal(t, out, mask)
+	})
+}
+
+func TestNetmask_ParseMaskStrict(t *testing.T) {
+	type testCase struct {
+		name     string
+		in       string
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask, err := ParseMaskStrict(tc.in)
+		assert.NilError(t, err)
+		assert.Equal(t, mask, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "ipv4 mask", in: "255.255.255.0", expected: MaskFrom(24, 32)},
+		{name: "ipv4 zero mask", in: "0.0.0.0", expected: MaskFrom(0, 32)},
+		{name: "ipv4 wildcard", in: "~0.0.0.255", expected: MaskFrom(24, 32)},
+		{name: "ipv6 prefix", in: "/64", expected: MaskFrom(64, 128)},
+		{name: "ipv6 address form", in: "ffff:ffff::", expected: MaskFrom(32, 128)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ParseMaskStrictError(t *testing.T) {
+	type testCase struct {
+		name     string
+		in       string
+		expected ParseError
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask, err := ParseMaskStrict(tc.in)
+		assert.Equal(t, mask, Mask{})
+
+		var pe *ParseError
+		assert.Assert(t, errors.As(err, &pe), "got error: %v", err)
+		assert.Equal(t, *pe, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "empty", in: "", expected: ParseError{Input: "", Offset: 0, Err: ErrSyntax}},
+		{name: "weird ipv4 mask", in: "255.0.255.0", expected: ParseError{Input: "255.0.255.0", Offset: 6, Err: ErrNonContiguous}},
+		{name: "weird ipv4 mask in last octet", in: "255.255.255.5", expected: ParseError{Input: "255.255.255.5", Offset: 12, Err: ErrNonContiguous}},
+		{name: "weird ipv4 wildcard", in: "~0.255.0.255", expected: ParseError{Input: "~0.255.0.255", Offset: 7, Err: ErrNonContiguous}},
+		{name: "leading zero", in: "255.255.255.00", expected: ParseError{Input: "255.255.255.00", Offset: 12, Err: ErrSyntax}},
+		{name: "leading zero octet", in: "255.0255.0.0", expected: ParseError{Input: "255.0255.0.0", Offset: 4, Err: ErrSyntax}},
+		{name: "wildcard leading zero", in: "~0.0.0.0255", expected: ParseError{Input: "~0.0.0.0255", Offset: 7, Err: ErrSyntax}},
+		{name: "weird ipv6 mask", in: "ffff::ffff", expected: ParseError{Input: "ffff::ffff", Offset: 0, Err: ErrNonContiguous}},
+		{name: "large octet", in: "255.256.0.0", expected: ParseError{Input: "255.256.0.0", Offset: 4, Err: ErrRange}},
+		{name: "three octets", in: "255.255.0", expected: ParseError{Input: "255.255.0", Offset: 9, Err: ErrSyntax}},
+		{name: "five octets", in: "255.255.0.0.0", expected: ParseError{Input: "255.255.0.0.0", Offset: 12, Err: ErrSyntax}},
+		{name: "large prefix", in: "/129", expected: ParseError{Input: "/129", Offset: 1, Err: ErrRange}},
+		{name: "double wildcard", in: "~~0.0.0.255", expected: ParseError{Input: "~~0.0.0.255", Offset: 1, Err: ErrSyntax}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ParseErrorString(t *testing.T) {
+	_, err := ParseMaskStrict("255.0.255.0")
+	assert.Error(t, err, `netmask: invalid mask "255.0.255.0" at offset 6: non-contiguous mask`)
+	assert.Assert(t, errors.Is(err, ErrNonContiguous))
+}
+
+func TestNetmask_MustParseMask(t *testing.T) {
+	assert.Equal(t, MustParseMask("255.255.255.0"), MaskFrom(24, 32))
+	assert.Equal(t, MustParseMask("/64"), MaskFrom(64, 128))
+
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+	MustParseMask("255.255.255")
+}
+
+func TestNetmask_ParseMaskErrorString(t *testing.T) {
+	_, err := ParseMask("~255.255.0.1024")
+	assert.Error(t, err, `netmask: invalid mask "~255.255.0.1024" at offset 11: value out of range`)
+}
+
+func TestNetmask_ParseMaskAllocs(t *testing.T) {
+	for _, in := range []string{"255.255.255.0", "~0.0.0.255", "/64", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00", "::ffff:255.255.255.0"} {
+		text := []byte(in)
+		var mask Mask
+
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = mask.UnmarshalText(text)
+		})
+		assert.Equal(t, allocs, 0.0, "input: %s", in)
+	}
+}
+
+func TestNetmask_ParseAddr6(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		addr := netip.AddrFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.SampledFrom([]byte{0x00, 0x0F, 0xF0, 0xFF}), 16, 16).Draw(t, "addr")))
+
+		for _, s := range []string{addr.String(), addr.StringExpanded()} {
+			ip, ok := parseAddr6(s)
+			assert.Assert(t, ok, "input: %s", s)
+			assert.Equal(t, ip, addr.As16())
+		}
+	})
+}
+
+func TestNetmask_ParseAddr6Invalid(t *testing.T) {
+	for _, s := range []string{
+		"", ":", ":::", "1:2:3:4:5:6:7", "1:2:3:4:5:6:7:8:9", "1::2::3", "12345::",
+		"::g", "1:2:3:4:5:6:7:8::", "fe80::1%eth0", "1.2.3.4", "::1.2.3", "1:2:3:4:5:6:7:1.2.3.4",
+	} {
+		_, ok := parseAddr6(s)
+		assert.Assert(t, !ok, "input: %s", s)
+	}
+}
+
+func BenchmarkParseMask(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		in   string
+	}{
+		{name: "ipv4", in: "255.255.255.0"},
+		{name: "ipv4 wildcard", in: "~0.0.0.255"},
+		{name: "ipv6 prefix", in: "/64"},
+		{name: "ipv6 address form", in: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00"},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ParseMask(bm.in); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUnmarshalText(b *testing.B) {
+	benchmarks := []struct {
+		name string
+		in   []byte
+	}{
+		{name: "ipv4", in: []byte("255.255.255.0")},
+		{name: "ipv6 prefix", in: []byte("64")},
+		{name: "ipv6 address form", in: []byte("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00")},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			var mask Mask
+			for i := 0; i < b.N; i++ {
+				if err := mask.UnmarshalText(bm.in); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
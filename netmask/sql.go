@@ -0,0 +1,46 @@
+package netmask
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+var (
+	_ sql.Scanner   = (*Mask)(nil)
+	_ driver.Valuer = Mask{}
+)
+
+// Value implements the [driver.Valuer] interface. The mask is stored in its
+// text form, see Mask.MarshalText, and the zero Mask is stored as NULL.
+func (mask Mask) Value() (driver.Value, error) {
+	if mask.z == z0 {
+		return nil, nil
+	}
+
+	return mask.String(), nil
+}
+
+// Scan implements the [sql.Scanner] interface. NULL scans into the zero Mask,
+// strings are parsed as text, see Mask.UnmarshalText, and byte slices are
+// parsed as text with a fallback to the binary form, see Mask.UnmarshalBinary.
+//
+// A single-byte binary mask is ambiguous with a one-digit text prefix length
+// and is always read as text, so byte columns should hold text or 4 and 16
+// byte binary masks.
+func (mask *Mask) Scan(src any) error {
+	switch src := src.(type) {
+	case nil:
+		*mask = Mask{}
+		return nil
+	case string:
+		return mask.UnmarshalText([]byte(src))
+	case []byte:
+		if err := mask.UnmarshalText(src); err == nil {
+			return nil
+		}
+		return mask.UnmarshalBinary(src)
+	}
+
+	return fmt.Errorf("netmask: cannot scan %T into Mask", src)
+}
@@ -0,0 +1,126 @@
+package netmask
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"pgregory.net/rapid"
+)
+
+func TestNetmask_Value(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		expected driver.Value
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		v, err := tc.mask.Value()
+		assert.NilError(t, err)
+		assert.Equal(t, v, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "zero mask", mask: Mask{}, expected: nil},
+		{name: "ipv4 mask", mask: MaskFrom(24, 32), expected: "255.255.255.0"},
+		{name: "ipv6 mask", mask: MaskFrom(64, 128), expected: "64"},
+		{name: "weird ipv6 mask", mask: weird6, expected: "ffff:ffff::ffff:ffff:0:0"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_Scan(t *testing.T) {
+	type testCase struct {
+		name     string
+		src      any
+		expected Mask
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		mask := MaskFrom(8, 32)
+		assert.NilError(t, mask.Scan(tc.src))
+		assert.Equal(t, mask, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "null", src: nil, expected: Mask{}},
+		{name: "empty string", src: "", expected: Mask{}},
+		{name: "ipv4 string", src: "255.255.255.0", expected: MaskFrom(24, 32)},
+		{name: "ipv6 string", src: "64", expected: MaskFrom(64, 128)},
+		{name: "ipv4 text bytes", src: []byte("255.255.255.0"), expected: MaskFrom(24, 32)},
+		{name: "ipv6 text bytes", src: []byte("/64"), expected: MaskFrom(64, 128)},
+		{name: "ipv4 binary", src: []byte{0xFF, 0xFF, 0xFF, 0x00}, expected: MaskFrom(24, 32)},
+		{name: "ipv6 binary", src: weird6.AsSlice(), expected: weird6},
+		{name: "ipv6 binary prefix", src: []byte{96}, expected: MaskFrom(96, 128)},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ScanError(t *testing.T) {
+	type testCase struct {
+		name string
+		src  any
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var mask Mask
+		assert.Assert(t, mask.Scan(tc.src) != nil)
+	}
+
+	testCases := []testCase{
+		{name: "integer", src: int64(24)},
+		{name: "bad string", src: "255.255.255"},
+		{name: "bad bytes", src: []byte{0xFF, 0xFF, 0xFF}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_ValueScan(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		mask := rapid.Custom[Mask](func(t *rapid.T) Mask {
+			z := rapid.SampledFrom([]int8{z0, z4, z6}).Draw(t, "z")
+
+			switch z {
+			case z4:
+				return MaskFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "mask")))
+			case z6:
+				return rapid.OneOf(
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom(rapid.IntRange(0, 128).Draw(t, "ones"), 128)
+					}),
+					rapid.Custom(func(t *rapid.T) Mask {
+						return MaskFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "mask")))
+					}),
+				).Draw(t, "mask6")
+			default:
+				return Mask{}
+			}
+		}).Draw(t, "mask")
+
+		v, err := mask.Value()
+		assert.NilError(t, err)
+
+		var out Mask
+		assert.NilError(t, out.Scan(v))
+		assert.Equal(t, out, mask)
+	})
+}
@@ -0,0 +1,62 @@
+//go:build go1.23
+
+package netmask
+
+import (
+	"encoding/binary"
+	"iter"
+	"net/netip"
+)
+
+// Subnets returns an iterator over the child networks of newBits prefix
+// length, in ascending order, that make up the network given by base and
+// the mask. For example, splitting 10.0.0.0 with 255.255.255.0 into newBits
+// of 26 yields 10.0.0.0/26, 10.0.0.64/26, 10.0.0.128/26 and 10.0.0.192/26.
+//
+// Nothing is yielded if the mask is not a prefix, base is not of the same
+// family as the mask, or newBits is shorter than the mask's prefix length or
+// longer than the family's bit length.
+func (mask Mask) Subnets(base netip.Addr, newBits int) iter.Seq[netip.Prefix] {
+	return func(yield func(netip.Prefix) bool) {
+		ones, bits := mask.Size()
+		network := mask.Apply(base)
+		if !mask.IsPrefix() || !network.IsValid() || newBits < ones || newBits > bits {
+			return
+		}
+
+		// Work on the mask's 128 bit representation, where IPv4 addresses
+		// take up the top 32 bits.
+		var cur uint128
+		if mask.z == z4 {
+			a := network.As4()
+			cur = uint128{hi: uint64(binary.BigEndian.Uint32(a[:])) << 32}
+		} else {
+			a := network.As16()
+			cur = uint128{hi: binary.BigEndian.Uint64(a[:8]), lo: binary.BigEndian.Uint64(a[8:])}
+		}
+
+		// step is the lowest bit of the new prefix. A new prefix length
+		// of zero only yields a single network, so never needs a step.
+		var step uint128
+		if newBits > 0 {
+			step = mask6(newBits).xor(mask6(newBits - 1))
+		}
+		last := cur.or(mask.Hostmask().mask).and(mask6(newBits))
+		for {
+			var addr netip.Addr
+			if mask.z == z4 {
+				addr = netip.AddrFrom4([4]byte{byte(cur.hi >> 56), byte(cur.hi >> 48), byte(cur.hi >> 40), byte(cur.hi >> 32)})
+			} else {
+				var a [16]byte
+				binary.BigEndian.PutUint64(a[:8], cur.hi)
+				binary.BigEndian.PutUint64(a[8:], cur.lo)
+				addr = netip.AddrFrom16(a)
+			}
+
+			if !yield(netip.PrefixFrom(addr, newBits)) || cur == last {
+				return
+			}
+			cur = cur.add(step)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+//go:build go1.23
+
+package netmask
+
+import (
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNetmask_Subnets(t *testing.T) {
+	type testCase struct {
+		name     string
+		mask     Mask
+		base     netip.Addr
+		newBits  int
+		expected []netip.Prefix
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		var out []netip.Prefix
+		for p := range tc.mask.Subnets(tc.base, tc.newBits) {
+			out = append(out, p)
+		}
+
+		assert.Equal(t, len(out), len(tc.expected))
+		for i := range tc.expected {
+			assert.Equal(t, out[i], tc.expected[i])
+		}
+	}
+
+	testCases := []testCase{
+		{
+			name:    "ipv4",
+			mask:    MaskFrom(24, 32),
+			base:    netip.MustParseAddr("10.0.0.17"),
+			newBits: 26,
+			expected: []netip.Prefix{
+				netip.MustParsePrefix("10.0.0.0/26"),
+				netip.MustParsePrefix("10.0.0.64/26"),
+				netip.MustParsePrefix("10.0.0.128/26"),
+				netip.MustParsePrefix("10.0.0.192/26"),
+			},
+		},
+		{
+			name:     "ipv4 same length",
+			mask:     MaskFrom(24, 32),
+			base:     netip.MustParseAddr("10.0.0.17"),
+			newBits:  24,
+			expected: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/24")},
+		},
+		{
+			name:     "ipv4 zero prefix",
+			mask:     MaskFrom(0, 32),
+			base:     netip.MustParseAddr("10.0.0.17"),
+			newBits:  0,
+			expected: []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0")},
+		},
+		{
+			name:    "ipv4 top of range",
+			mask:    MaskFrom(31, 32),
+			base:    netip.MustParseAddr("255.255.255.255"),
+			newBits: 32,
+			expected: []netip.Prefix{
+				netip.MustParsePrefix("255.255.255.254/32"),
+				netip.MustParsePrefix("255.255.255.255/32"),
+			},
+		},
+		{
+			name:    "ipv6 carry",
+			mask:    MaskFrom(63, 128),
+			base:    netip.MustParseAddr("2001:db8::"),
+			newBits: 64,
+			expected: []netip.Prefix{
+				netip.MustParsePrefix("2001:db8::/64"),
+				netip.MustParsePrefix("2001:db8:0:1::/64"),
+			},
+		},
+		{
+			name:    "ipv6 low bits",
+			mask:    MaskFrom(126, 128),
+			base:    netip.MustParseAddr("2001:db8::1"),
+			newBits: 127,
+			expected: []netip.Prefix{
+				netip.MustParsePrefix("2001:db8::/127"),
+				netip.MustParsePrefix("2001:db8::2/127"),
+			},
+		},
+		{name: "shorter bits", mask: MaskFrom(24, 32), base: netip.MustParseAddr("10.0.0.0"), newBits: 16},
+		{name: "longer bits", mask: MaskFrom(24, 32), base: netip.MustParseAddr("10.0.0.0"), newBits: 33},
+		{name: "family mismatch", mask: MaskFrom(24, 32), base: netip.MustParseAddr("2001:db8::"), newBits: 26},
+		{name: "weird mask", mask: weird6, base: netip.MustParseAddr("2001:db8::"), newBits: 128},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestNetmask_SubnetsBreak(t *testing.T) {
+	var n int
+	for range MaskFrom(0, 128).Subnets(netip.IPv6Unspecified(), 128) {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	assert.Equal(t, n, 3)
+}
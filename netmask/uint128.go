@@ -0,0 +1,92 @@
+// Portions of netmask adapted from the Go Standard Library.
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the go.LICENSE file.
+
+package netmask
+
+import "math/bits"
+
+// uint128 represents a uint128 using two uint64s.
+//
+// When the methods below mention a bit number, bit 0 is the most
+// significant bit (in hi) and bit 127 is the lowest (lo&1).
+type uint128 struct {
+	hi uint64
+	lo uint64
+}
+
+// mask6 returns a uint128 bitmask with the topmost n bits of a
+// 128-bit number.
+func mask6(n int) uint128 {
+	return uint128{^(^uint64(0) >> n), ^uint64(0) << (128 - n)}
+}
+
+// isZero reports whether u == 0.
+func (u uint128) isZero() bool { return u.hi|u.lo == 0 }
+
+// and returns the bitwise AND of u and m (u&m).
+func (u uint128) and(m uint128) uint128 {
+	return uint128{u.hi & m.hi, u.lo & m.lo}
+}
+
+// xor returns the bitwise XOR of u and m (u^m).
+func (u uint128) xor(m uint128) uint128 {
+	return uint128{u.hi ^ m.hi, u.lo ^ m.lo}
+}
+
+// or returns the bitwise OR of u and m (u|m).
+func (u uint128) or(m uint128) uint128 {
+	return uint128{u.hi | m.hi, u.lo | m.lo}
+}
+
+// not returns the bitwise NOT of u.
+func (u uint128) not() uint128 {
+	return uint128{^u.hi, ^u.lo}
+}
+
+// add returns the sum of u and m, wrapping around on overflow.
+func (u uint128) add(m uint128) uint128 {
+	lo, carry := bits.Add64(u.lo, m.lo, 0)
+	hi, _ := bits.Add64(u.hi, m.hi, carry)
+	return uint128{hi, lo}
+}
+
+// compare returns -1, 0 or +1 depending on whether u is less than, equal
+// to, or greater than m.
+func (u uint128) compare(m uint128) int {
+	switch {
+	case u.hi < m.hi:
+		return -1
+	case u.hi > m.hi:
+		return 1
+	case u.lo < m.lo:
+		return -1
+	case u.lo > m.lo:
+		return 1
+	}
+	return 0
+}
+
+// onesCount returns the number of one bits in u.
+func (u uint128) onesCount() int {
+	return bits.OnesCount64(u.hi) + bits.OnesCount64(u.lo)
+}
+
+// leadingOnes returns the number of leading one bits in u.
+func (u uint128) leadingOnes() int {
+	if u.hi != ^uint64(0) {
+		return bits.LeadingZeros64(^u.hi)
+	}
+	return 64 + bits.LeadingZeros64(^u.lo)
+}
+
+// prefixLength returns the number of leading one bits in u. If the
+// leading ones aren't followed entirely by zero bits, -1 is returned.
+func (u uint128) prefixLength() int {
+	n := u.leadingOnes()
+	if u != mask6(n) {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,100 @@
+package ipvs
+
+import (
+	"net/netip"
+
+	"github.com/cloudflare/ipvs/netmask"
+)
+
+// Normalize returns the service in the form IPVS reports it, so that a
+// desired Service can be compared with one fetched from IPVS:
+//
+//   - IPv4-mapped IPv6 addresses of INET services are unmapped.
+//   - An unset Netmask defaults to the host mask of the family.
+//   - The ServiceHashed flag, which is set by IPVS, is cleared.
+//   - Firewall mark services have their Protocol, Address and Port cleared.
+func (svc Service) Normalize() Service {
+	if svc.Family == INET {
+		svc.Address = svc.Address.Unmap()
+	}
+
+	if !svc.Netmask.IsValid() {
+		svc.Netmask = defaultNetmask(svc.Family)
+	}
+
+	svc.Flags &^= ServiceHashed
+
+	if svc.FWMark != 0 {
+		svc.Protocol = 0
+		svc.Address = netip.Addr{}
+		svc.Port = 0
+	}
+
+	return svc
+}
+
+// Equal reports whether svc and o configure the same Service, after
+// normalizing both.
+func (svc Service) Equal(o Service) bool {
+	return svc.Normalize() == o.Normalize()
+}
+
+// Normalize returns the destination in the form IPVS reports it, so that a
+// desired Destination can be compared with one fetched from IPVS:
+//
+//   - An unset Family is derived from the Address.
+//   - IPv4-mapped IPv6 addresses of INET destinations are unmapped.
+//   - The tunnel fields are cleared unless the Tunnel forwarding method is
+//     used.
+//
+// The Weight is kept as is, since a zero weight quiesces a Destination.
+func (dest Destination) Normalize() Destination {
+	if dest.Family == 0 && dest.Address.IsValid() {
+		dest.Family = INET
+		if dest.Address.Is6() && !dest.Address.Is4In6() {
+			dest.Family = INET6
+		}
+	}
+
+	if dest.Family == INET {
+		dest.Address = dest.Address.Unmap()
+	}
+
+	if dest.FwdMethod != Tunnel {
+		dest.TunnelType = IPIP
+		dest.TunnelPort = 0
+		dest.TunnelFlags = TunnelEncapNoChecksum
+	}
+
+	return dest
+}
+
+// Equal reports whether dest and o configure the same Destination, after
+// normalizing both. Use DestinationExtended.Destination to compare
+// destinations fetched from IPVS, ignoring their connection counts and
+// statistics.
+func (dest Destination) Equal(o Destination) bool {
+	return dest.Normalize() == o.Normalize()
+}
+
+// Clone returns a copy of s which does not share its Destinations.
+func (s ServiceWithDestinations) Clone() ServiceWithDestinations {
+	if s.Destinations != nil {
+		s.Destinations = append([]DestinationExtended(nil), s.Destinations...)
+	}
+
+	return s
+}
+
+// defaultNetmask returns the host mask of the family, or the zero Mask for
+// unknown families.
+func defaultNetmask(family AddressFamily) netmask.Mask {
+	switch family {
+	case INET:
+		return netmask.MaskFrom(32, 32)
+	case INET6:
+		return netmask.MaskFrom(128, 128)
+	}
+
+	return netmask.Mask{}
+}
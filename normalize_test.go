@@ -0,0 +1,158 @@
+package ipvs
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/ipvs/netmask"
+	"gotest.tools/v3/assert"
+)
+
+func TestService_Equal(t *testing.T) {
+	type testCase struct {
+		name     string
+		a, b     Service
+		expected bool
+	}
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+
+	with := func(fn func(*Service)) Service {
+		s := svc
+		fn(&s)
+		return s
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.a.Equal(tc.b), tc.expected)
+		assert.Equal(t, tc.b.Equal(tc.a), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "identical", a: svc, b: svc, expected: true},
+		{
+			name:     "default netmask",
+			a:        svc,
+			b:        with(func(s *Service) { s.Netmask = netmask.MaskFrom(32, 32) }),
+			expected: true,
+		},
+		{
+			name:     "hashed flag",
+			a:        svc,
+			b:        with(func(s *Service) { s.Flags = ServiceHashed }),
+			expected: true,
+		},
+		{
+			name:     "ipv4-mapped address",
+			a:        svc,
+			b:        with(func(s *Service) { s.Address = netip.MustParseAddr("::ffff:192.0.2.1") }),
+			expected: true,
+		},
+		{
+			name:     "fwmark ignores address",
+			a:        Service{FWMark: 1, Family: INET, Scheduler: RoundRobin},
+			b:        Service{FWMark: 1, Family: INET, Scheduler: RoundRobin, Protocol: TCP, Port: 80},
+			expected: true,
+		},
+		{
+			name: "scheduler",
+			a:    svc,
+			b:    with(func(s *Service) { s.Scheduler = WeightedRoundRobin }),
+		},
+		{
+			name: "netmask",
+			a:    svc,
+			b:    with(func(s *Service) { s.Netmask = netmask.MaskFrom(24, 32) }),
+		},
+		{
+			name: "persistent flag",
+			a:    svc,
+			b:    with(func(s *Service) { s.Flags = ServicePersistent }),
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestDestination_Equal(t *testing.T) {
+	type testCase struct {
+		name     string
+		a, b     Destination
+		expected bool
+	}
+
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 80, Family: INET, Weight: 1}
+
+	with := func(fn func(*Destination)) Destination {
+		d := dest
+		fn(&d)
+		return d
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.a.Equal(tc.b), tc.expected)
+		assert.Equal(t, tc.b.Equal(tc.a), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "identical", a: dest, b: dest, expected: true},
+		{
+			name:     "derived family",
+			a:        dest,
+			b:        with(func(d *Destination) { d.Family = 0 }),
+			expected: true,
+		},
+		{
+			name:     "ipv4-mapped address",
+			a:        dest,
+			b:        with(func(d *Destination) { d.Address = netip.MustParseAddr("::ffff:198.51.100.1"); d.Family = 0 }),
+			expected: true,
+		},
+		{
+			name:     "tunnel fields without tunnel",
+			a:        dest,
+			b:        with(func(d *Destination) { d.TunnelType = GUE; d.TunnelPort = 6080 }),
+			expected: true,
+		},
+		{
+			name: "tunnel fields with tunnel",
+			a:    with(func(d *Destination) { d.FwdMethod = Tunnel }),
+			b:    with(func(d *Destination) { d.FwdMethod = Tunnel; d.TunnelType = GUE; d.TunnelPort = 6080 }),
+		},
+		{
+			name: "weight",
+			a:    dest,
+			b:    with(func(d *Destination) { d.Weight = 0 }),
+		},
+		{
+			name:     "ipv6 family",
+			a:        with(func(d *Destination) { d.Address = netip.MustParseAddr("2001:db8::1"); d.Family = 0 }),
+			b:        with(func(d *Destination) { d.Address = netip.MustParseAddr("2001:db8::1"); d.Family = INET6 }),
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestServiceWithDestinations_Clone(t *testing.T) {
+	s := ServiceWithDestinations{
+		Destinations: []DestinationExtended{{Destination: Destination{Weight: 1}}},
+	}
+
+	c := s.Clone()
+	c.Destinations[0].Weight = 2
+
+	assert.Equal(t, s.Destinations[0].Weight, uint32(1))
+	assert.Assert(t, ServiceWithDestinations{}.Clone().Destinations == nil)
+}
@@ -0,0 +1,109 @@
+package ipvs
+
+import "time"
+
+// Option configures a Client created by NewClient.
+type Option func(*options)
+
+// options holds the configuration built from a list of Options.
+type options struct {
+	netNSPath       string
+	netNSFD         int
+	timeout         time.Duration
+	readBuffer      int
+	forceReadBuffer bool
+	writeBuffer     int
+	modprobe        bool
+	retry           RetryPolicy
+	dryRun          bool
+
+	instrumentation Instrumentation
+	logger          mutationLogger
+	audit           Auditor
+}
+
+// buildOptions applies opts on top of the defaults.
+func buildOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithNetNSPath connects the Client to IPVS in the network namespace at path,
+// such as "/var/run/netns/blue" or "/proc/1234/ns/net", instead of the
+// namespace of the calling thread.
+func WithNetNSPath(path string) Option {
+	return func(o *options) {
+		o.netNSPath = path
+	}
+}
+
+// WithNetNSFD connects the Client to IPVS in the network namespace referred
+// to by the open file descriptor fd. The descriptor is only used while
+// connecting, and is not closed by the Client.
+func WithNetNSFD(fd int) Option {
+	return func(o *options) {
+		o.netNSFD = fd
+	}
+}
+
+// NetNS identifies a network namespace, either by the path of its file or
+// by an open file descriptor. The zero NetNS is the namespace of the calling
+// thread.
+type NetNS struct {
+	// Path is a namespace file, such as "/var/run/netns/blue" or
+	// "/proc/1234/ns/net". It takes precedence over FD.
+	Path string
+	// FD is an open file descriptor of a namespace file, which is not
+	// closed by the Client.
+	FD int
+}
+
+// WithTimeout sets the maximum duration of each request made by the Client,
+// including receiving all of its replies. By default requests do not time
+// out.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithReadBuffer sets the size in bytes of the receive buffer of the
+// underlying netlink socket. Large buffers help when dumping many services
+// or destinations.
+func WithReadBuffer(bytes int) Option {
+	return func(o *options) {
+		o.readBuffer = bytes
+	}
+}
+
+// WithReadBufferForce is like WithReadBuffer, but exceeds the
+// net.core.rmem_max limit of the kernel with SO_RCVBUFFORCE, which requires
+// the CAP_NET_ADMIN capability. Without it, the size is capped to the limit
+// like with WithReadBuffer.
+func WithReadBufferForce(bytes int) Option {
+	return func(o *options) {
+		o.readBuffer = bytes
+		o.forceReadBuffer = true
+	}
+}
+
+// WithWriteBuffer sets the size in bytes of the send buffer of the
+// underlying netlink socket.
+func WithWriteBuffer(bytes int) Option {
+	return func(o *options) {
+		o.writeBuffer = bytes
+	}
+}
+
+// WithModprobe makes the Client load the ip_vs kernel module by running
+// modprobe, when IPVS is missing from the kernel. This requires the
+// CAP_SYS_MODULE capability, and modprobe in the PATH.
+func WithModprobe() Option {
+	return func(o *options) {
+		o.modprobe = true
+	}
+}
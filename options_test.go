@@ -0,0 +1,64 @@
+package ipvs
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestBuildOptions(t *testing.T) {
+	type testCase struct {
+		name     string
+		opts     []Option
+		expected options
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, buildOptions(tc.opts), tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "defaults", opts: nil, expected: options{}},
+		{
+			name: "all",
+			opts: []Option{
+				WithNetNSPath("/var/run/netns/blue"),
+				WithNetNSFD(7),
+				WithTimeout(time.Second),
+				WithReadBuffer(1 << 20),
+				WithWriteBuffer(1 << 16),
+				WithModprobe(),
+				WithRetryPolicy(DefaultRetryPolicy),
+				WithDryRun(),
+			},
+			expected: options{
+				netNSPath:   "/var/run/netns/blue",
+				netNSFD:     7,
+				timeout:     time.Second,
+				readBuffer:  1 << 20,
+				writeBuffer: 1 << 16,
+				modprobe:    true,
+				retry:       DefaultRetryPolicy,
+				dryRun:      true,
+			},
+		},
+		{
+			name:     "read buffer force",
+			opts:     []Option{WithReadBufferForce(1 << 24)},
+			expected: options{readBuffer: 1 << 24, forceReadBuffer: true},
+		},
+		{
+			name:     "last wins",
+			opts:     []Option{WithTimeout(time.Second), WithTimeout(time.Minute)},
+			expected: options{timeout: time.Minute},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package ipvs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// ReadOwners reads the owners of services kept in the file at path by
+// WriteOwners, for ApplyOptions.Owners. A missing file holds no owners.
+func ReadOwners(path string) (map[ServiceKey]string, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return make(map[ServiceKey]string), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[ServiceKey]string)
+	if err := json.Unmarshal(b, &owners); err != nil {
+		return nil, fmt.Errorf("ipvs: reading owners from %s: %w", path, err)
+	}
+
+	return owners, nil
+}
+
+// WriteOwners replaces the file at path with owners, usually the Owners of
+// an ApplyResult, as a JSON object keyed by service, such as
+// {"TCP 192.0.2.1:80": "web"}. The file is replaced atomically, so that a
+// crash leaves either the previous or the new owners.
+func WriteOwners(path string, owners map[ServiceKey]string) error {
+	b, err := json.MarshalIndent(owners, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
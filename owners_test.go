@@ -0,0 +1,65 @@
+package ipvs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestOwners(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owners.json")
+
+	owners, err := ReadOwners(path)
+	assert.NilError(t, err)
+	assert.Equal(t, len(owners), 0)
+
+	expected := map[ServiceKey]string{
+		{Family: INET, FWMark: 42}:                     "lb",
+		mustParseServiceKey(t, "TCP 192.0.2.1:80"):     "web",
+		mustParseServiceKey(t, "UDP [2001:db8::1]:53"): "dns",
+	}
+	assert.NilError(t, WriteOwners(path, expected))
+
+	b, err := os.ReadFile(path)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), `{
+	"FWM 42": "lb",
+	"TCP 192.0.2.1:80": "web",
+	"UDP [2001:db8::1]:53": "dns"
+}
+`)
+
+	owners, err = ReadOwners(path)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, owners, expected)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NilError(t, err)
+	assert.Equal(t, len(entries), 1, "temporary files left behind")
+
+	assert.NilError(t, os.WriteFile(path, []byte(`{"TCP nope": "web"}`), 0o600))
+	_, err = ReadOwners(path)
+	assert.ErrorContains(t, err, "reading owners from")
+}
+
+func TestOwnConventions(t *testing.T) {
+	fwm := OwnFWMarks(100, 199)
+	assert.Assert(t, fwm(ServiceKey{Family: INET, FWMark: 100}))
+	assert.Assert(t, !fwm(ServiceKey{Family: INET, FWMark: 200}))
+	assert.Assert(t, !fwm(mustParseServiceKey(t, "TCP 192.0.2.1:150")))
+
+	ports := OwnPorts(30000, 32767)
+	assert.Assert(t, ports(mustParseServiceKey(t, "TCP 192.0.2.1:30080")))
+	assert.Assert(t, !ports(mustParseServiceKey(t, "TCP 192.0.2.1:80")))
+	assert.Assert(t, !ports(ServiceKey{Family: INET, FWMark: 30080}))
+}
+
+func mustParseServiceKey(t *testing.T, s string) ServiceKey {
+	t.Helper()
+
+	key, err := ParseServiceKey(s)
+	assert.NilError(t, err)
+	return key
+}
@@ -0,0 +1,162 @@
+// Package prometheus provides a Prometheus collector exporting the statistics
+// of IPVS services and their destinations.
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/cloudflare/ipvs"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "ipvs"
+
+var (
+	serviceLabels     = []string{"vip", "port", "protocol", "fwmark", "scheduler"}
+	destinationLabels = append(serviceLabels[:len(serviceLabels):len(serviceLabels)], "rip", "rport")
+)
+
+// metric describes a metric exported for each service or destination.
+type metric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(ipvs.Stats) float64
+}
+
+// Collector is a prometheus.Collector exporting the connection, packet and
+// byte counters of all IPVS services and destinations.
+//
+// Services are labeled by vip, port, protocol, fwmark and scheduler, where
+// firewall mark services have an empty vip, port and protocol, and address
+// based services an empty fwmark. Destinations additionally carry the rip and
+// rport labels.
+type Collector struct {
+	client ipvs.Client
+
+	services     []metric
+	destinations []metric
+
+	activeConnections   *prometheus.Desc
+	inactiveConnections *prometheus.Desc
+	weight              *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// NewCollector returns a Collector reading the statistics through c on each
+// scrape.
+func NewCollector(c ipvs.Client) *Collector {
+	return &Collector{
+		client:       c,
+		services:     statsMetrics("service", serviceLabels),
+		destinations: statsMetrics("destination", destinationLabels),
+
+		activeConnections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "destination", "active_connections"),
+			"Number of active connections to the destination.",
+			destinationLabels, nil,
+		),
+		inactiveConnections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "destination", "inactive_connections"),
+			"Number of inactive connections to the destination.",
+			destinationLabels, nil,
+		),
+		weight: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "destination", "weight"),
+			"Weight of the destination.",
+			destinationLabels, nil,
+		),
+	}
+}
+
+// statsMetrics returns the counters of ipvs.Stats as metrics of subsystem.
+func statsMetrics(subsystem string, labels []string) []metric {
+	counter := func(name, help string, value func(ipvs.Stats) uint64) metric {
+		return metric{
+			desc: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, subsystem, name),
+				help, labels, nil,
+			),
+			valueType: prometheus.CounterValue,
+			value: func(s ipvs.Stats) float64 {
+				return float64(value(s))
+			},
+		}
+	}
+
+	return []metric{
+		counter("connections_total", "Total number of connections scheduled.",
+			func(s ipvs.Stats) uint64 { return s.Connections }),
+		counter("incoming_packets_total", "Total number of incoming packets.",
+			func(s ipvs.Stats) uint64 { return s.IncomingPackets }),
+		counter("outgoing_packets_total", "Total number of outgoing packets.",
+			func(s ipvs.Stats) uint64 { return s.OutgoingPackets }),
+		counter("incoming_bytes_total", "Total number of incoming bytes.",
+			func(s ipvs.Stats) uint64 { return s.IncomingBytes }),
+		counter("outgoing_bytes_total", "Total number of outgoing bytes.",
+			func(s ipvs.Stats) uint64 { return s.OutgoingBytes }),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.services {
+		ch <- m.desc
+	}
+	for _, m := range c.destinations {
+		ch <- m.desc
+	}
+
+	ch <- c.activeConnections
+	ch <- c.inactiveConnections
+	ch <- c.weight
+}
+
+// Collect implements prometheus.Collector. Empty tables export no metrics.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	svcs, err := c.client.ServicesWithDestinations(context.Background())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		for _, m := range c.services {
+			ch <- prometheus.NewInvalidMetric(m.desc, err)
+		}
+		return
+	}
+
+	for _, svc := range svcs {
+		labels := serviceLabelValues(svc.Service)
+		for _, m := range c.services {
+			ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, m.value(svc.Stats), labels...)
+		}
+
+		for _, dest := range svc.Destinations {
+			labels := append(labels[:len(labels):len(labels)],
+				dest.Address.String(), strconv.Itoa(int(dest.Port)))
+
+			for _, m := range c.destinations {
+				ch <- prometheus.MustNewConstMetric(m.desc, m.valueType, m.value(dest.Stats), labels...)
+			}
+
+			ch <- prometheus.MustNewConstMetric(c.activeConnections, prometheus.GaugeValue, float64(dest.ActiveConnections), labels...)
+			ch <- prometheus.MustNewConstMetric(c.inactiveConnections, prometheus.GaugeValue, float64(dest.InactiveConnections), labels...)
+			ch <- prometheus.MustNewConstMetric(c.weight, prometheus.GaugeValue, float64(dest.Weight), labels...)
+		}
+	}
+}
+
+// serviceLabelValues returns the values of serviceLabels for svc.
+func serviceLabelValues(svc ipvs.Service) []string {
+	if svc.FWMark != 0 {
+		return []string{"", "", "", strconv.FormatUint(uint64(svc.FWMark), 10), string(svc.Scheduler)}
+	}
+
+	return []string{
+		svc.Address.String(),
+		strconv.Itoa(int(svc.Port)),
+		svc.Protocol.String(),
+		"",
+		string(svc.Scheduler),
+	}
+}
@@ -0,0 +1,105 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/ipvs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gotest.tools/v3/assert"
+)
+
+// fakeClient returns fixed services through an ipvs.Client.
+type fakeClient struct {
+	ipvs.Client
+	svcs []ipvs.ServiceWithDestinations
+	err  error
+}
+
+func (c *fakeClient) ServicesWithDestinations(context.Context, ...ipvs.ListOption) ([]ipvs.ServiceWithDestinations, error) {
+	return c.svcs, c.err
+}
+
+func TestCollector(t *testing.T) {
+	c := &fakeClient{svcs: []ipvs.ServiceWithDestinations{
+		{
+			ServiceExtended: ipvs.ServiceExtended{
+				Service: ipvs.Service{
+					Address:   netip.MustParseAddr("192.0.2.1"),
+					Port:      80,
+					Family:    ipvs.INET,
+					Protocol:  ipvs.TCP,
+					Scheduler: ipvs.RoundRobin,
+				},
+				Stats: ipvs.Stats{Connections: 3, IncomingPackets: 30, OutgoingPackets: 20, IncomingBytes: 3000, OutgoingBytes: 2000},
+			},
+			Destinations: []ipvs.DestinationExtended{{
+				Destination: ipvs.Destination{
+					Address: netip.MustParseAddr("198.51.100.1"),
+					Port:    8080,
+					Family:  ipvs.INET,
+					Weight:  10,
+				},
+				ActiveConnections:   2,
+				InactiveConnections: 1,
+				Stats:               ipvs.Stats{Connections: 3, IncomingPackets: 30, OutgoingPackets: 20, IncomingBytes: 3000, OutgoingBytes: 2000},
+			}},
+		},
+		{
+			ServiceExtended: ipvs.ServiceExtended{
+				Service: ipvs.Service{FWMark: 42, Family: ipvs.INET, Scheduler: ipvs.RoundRobin},
+				Stats:   ipvs.Stats{Connections: 1},
+			},
+		},
+	}}
+
+	expected := `
+# HELP ipvs_destination_active_connections Number of active connections to the destination.
+# TYPE ipvs_destination_active_connections gauge
+ipvs_destination_active_connections{fwmark="",port="80",protocol="TCP",rip="198.51.100.1",rport="8080",scheduler="rr",vip="192.0.2.1"} 2
+# HELP ipvs_destination_connections_total Total number of connections scheduled.
+# TYPE ipvs_destination_connections_total counter
+ipvs_destination_connections_total{fwmark="",port="80",protocol="TCP",rip="198.51.100.1",rport="8080",scheduler="rr",vip="192.0.2.1"} 3
+# HELP ipvs_destination_weight Weight of the destination.
+# TYPE ipvs_destination_weight gauge
+ipvs_destination_weight{fwmark="",port="80",protocol="TCP",rip="198.51.100.1",rport="8080",scheduler="rr",vip="192.0.2.1"} 10
+# HELP ipvs_service_connections_total Total number of connections scheduled.
+# TYPE ipvs_service_connections_total counter
+ipvs_service_connections_total{fwmark="",port="80",protocol="TCP",scheduler="rr",vip="192.0.2.1"} 3
+ipvs_service_connections_total{fwmark="42",port="",protocol="",scheduler="rr",vip=""} 1
+# HELP ipvs_service_incoming_bytes_total Total number of incoming bytes.
+# TYPE ipvs_service_incoming_bytes_total counter
+ipvs_service_incoming_bytes_total{fwmark="",port="80",protocol="TCP",scheduler="rr",vip="192.0.2.1"} 3000
+ipvs_service_incoming_bytes_total{fwmark="42",port="",protocol="",scheduler="rr",vip=""} 0
+`
+
+	err := testutil.CollectAndCompare(NewCollector(c), strings.NewReader(expected),
+		"ipvs_destination_active_connections",
+		"ipvs_destination_connections_total",
+		"ipvs_destination_weight",
+		"ipvs_service_connections_total",
+		"ipvs_service_incoming_bytes_total",
+	)
+	assert.NilError(t, err)
+
+	assert.Equal(t, testutil.CollectAndCount(NewCollector(c)), 2*5+5+3)
+}
+
+func TestCollector_Error(t *testing.T) {
+	c := &fakeClient{err: errors.New("netlink down")}
+
+	err := testutil.CollectAndCompare(NewCollector(c), strings.NewReader(""))
+	assert.ErrorContains(t, err, "netlink down")
+}
+
+func TestCollector_Empty(t *testing.T) {
+	// The kernel reports empty tables as missing.
+	c := &fakeClient{err: os.ErrNotExist}
+
+	err := testutil.CollectAndCompare(NewCollector(c), strings.NewReader(""))
+	assert.NilError(t, err)
+}
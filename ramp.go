@@ -0,0 +1,105 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// RampCurve maps the progress of a weight ramp, from 0 to 1, onto the
+// fraction of the target weight, from 0 to 1.
+type RampCurve func(progress float64) float64
+
+// Curves for RampOptions.
+var (
+	// LinearRamp increases the weight at a constant rate.
+	LinearRamp RampCurve = func(progress float64) float64 {
+		return progress
+	}
+
+	// ExponentialRamp doubles the weight every tenth of the duration,
+	// keeping the weight low while the real server warms up.
+	ExponentialRamp RampCurve = func(progress float64) float64 {
+		return (math.Exp2(10*progress) - 1) / (math.Exp2(10) - 1)
+	}
+)
+
+// RampOptions configure RampDestinationWeight.
+type RampOptions struct {
+	// Duration is the time over which the weight is increased.
+	Duration time.Duration
+
+	// Curve shapes the increase, which defaults to LinearRamp.
+	Curve RampCurve
+
+	// Ticks drive the ramp, such as the channel of a time.Ticker. The weight
+	// is updated on every tick, based on the time received.
+	Ticks <-chan time.Time
+}
+
+// RampDestinationWeight slowly starts a Destination of svc, identified by the
+// Address, Port and Family of dest, by increasing its weight from 1 to weight
+// over the duration of opts, to avoid overwhelming a cold real server. The
+// weight is only updated when it changes, and set to weight once the duration
+// has passed, which ends the ramp.
+func RampDestinationWeight(ctx context.Context, c Client, svc Service, dest Destination, weight uint32, opts RampOptions) error {
+	if opts.Ticks == nil {
+		return errors.New("ipvs: weight ramp requires ticks")
+	}
+
+	curve := opts.Curve
+	if curve == nil {
+		curve = LinearRamp
+	}
+
+	start := time.Now()
+	current := rampWeight(curve, 0, weight)
+	if err := c.SetDestinationWeight(ctx, svc, dest, current); err != nil {
+		return err
+	}
+
+	for current != weight {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case now = <-opts.Ticks:
+		}
+
+		progress := 1.0
+		if elapsed := now.Sub(start); elapsed < opts.Duration {
+			progress = float64(elapsed) / float64(opts.Duration)
+		}
+
+		next := rampWeight(curve, progress, weight)
+		if next == current {
+			continue
+		}
+
+		if err := c.SetDestinationWeight(ctx, svc, dest, next); err != nil {
+			return err
+		}
+		current = next
+	}
+
+	return nil
+}
+
+// rampWeight returns the weight at progress of a ramp to weight, which is at
+// least 1 so that the Destination is not quiesced.
+func rampWeight(curve RampCurve, progress float64, weight uint32) uint32 {
+	if progress >= 1 {
+		return weight
+	}
+
+	w := math.Round(curve(progress) * float64(weight))
+	switch {
+	case w < 1:
+		return 1
+	case w > float64(weight):
+		return weight
+	}
+
+	return uint32(w)
+}
@@ -0,0 +1,89 @@
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// weightClient records the weights set through a Client.
+type weightClient struct {
+	Client
+	weights []uint32
+}
+
+func (c *weightClient) SetDestinationWeight(_ context.Context, _ Service, _ Destination, weight uint32) error {
+	c.weights = append(c.weights, weight)
+	return nil
+}
+
+func TestRampDestinationWeight(t *testing.T) {
+	type testCase struct {
+		name     string
+		curve    RampCurve
+		ticks    []time.Duration
+		expected []uint32
+	}
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET}
+
+	run := func(t *testing.T, tc testCase) {
+		start := time.Now()
+		ticks := make(chan time.Time, len(tc.ticks))
+		for _, d := range tc.ticks {
+			ticks <- start.Add(d)
+		}
+
+		c := &weightClient{}
+		err := RampDestinationWeight(context.Background(), c, svc, dest, 100, RampOptions{
+			Duration: 100 * time.Second,
+			Curve:    tc.curve,
+			Ticks:    ticks,
+		})
+		assert.NilError(t, err)
+		assert.DeepEqual(t, c.weights, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "linear",
+			ticks:    []time.Duration{25 * time.Second, 50 * time.Second, 75 * time.Second, 100 * time.Second},
+			expected: []uint32{1, 25, 50, 75, 100},
+		},
+		{
+			name:     "exponential",
+			curve:    ExponentialRamp,
+			ticks:    []time.Duration{10 * time.Second, 50 * time.Second, 90 * time.Second, 200 * time.Second},
+			expected: []uint32{1, 3, 50, 100},
+		},
+		{
+			name:     "overdue",
+			ticks:    []time.Duration{time.Hour},
+			expected: []uint32{1, 100},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestRampDestinationWeight_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := &weightClient{}
+	err := RampDestinationWeight(ctx, c, Service{}, Destination{}, 100, RampOptions{
+		Duration: time.Minute,
+		Ticks:    make(chan time.Time),
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.DeepEqual(t, c.weights, []uint32{1})
+}
@@ -0,0 +1,119 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// ErrDumpInterrupted indicates that IPVS changed while services or
+// destinations were dumped, so the dump may be inconsistent.
+var ErrDumpInterrupted = errors.New("ipvs: dump interrupted by a concurrent change")
+
+// RetryPolicy configures how a Client retries requests failing with
+// transient errors: EINTR, EBUSY, ENOBUFS and ErrDumpInterrupted.
+//
+// Reads are retried whatever the cause. Changes are only retried when IPVS
+// rejected them, as a change whose acknowledgement was lost may have been
+// applied already.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts of a request, including the
+	// first. Requests are not retried when it is zero or one.
+	Attempts int
+	// Backoff is the delay before the first retry, which doubles for each
+	// further retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries. It is unlimited when zero.
+	MaxBackoff time.Duration
+	// Jitter is the fraction of each delay, between 0 and 1, which is
+	// randomized, spreading out the retries of concurrent clients.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is a RetryPolicy suitable for most callers, attempting
+// requests up to four times over about a tenth of a second.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   4,
+	Backoff:    10 * time.Millisecond,
+	MaxBackoff: time.Second,
+	Jitter:     0.2,
+}
+
+// WithRetryPolicy makes the Client retry requests failing with transient
+// errors according to p. By default requests are not retried.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = p
+	}
+}
+
+// backoff returns the delay before the nth retry, with rnd returning a
+// random number in [0, 1).
+func (p RetryPolicy) backoff(n int, rnd func() float64) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		d -= time.Duration(float64(d) * j * rnd())
+	}
+
+	return d
+}
+
+// RetryError is returned when a request still fails with a transient error
+// after all the attempts of the RetryPolicy. It wraps the last error.
+type RetryError struct {
+	// Op is the name of the IPVS command, as reported to Instrumentation.
+	Op string
+	// Attempts is the number of attempts made.
+	Attempts int
+	// Err is the error of the last attempt.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("ipvs: %s failed after %d attempts: %v", e.Op, e.Attempts, e.Err)
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// isTransient reports whether err is transient, so that retrying the request
+// may succeed.
+func isTransient(err error) bool {
+	return errors.Is(err, syscall.EINTR) ||
+		errors.Is(err, syscall.EBUSY) ||
+		errors.Is(err, syscall.ENOBUFS) ||
+		errors.Is(err, ErrDumpInterrupted)
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+package ipvs
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	type testCase struct {
+		name     string
+		policy   RetryPolicy
+		retry    int
+		rnd      float64
+		expected time.Duration
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		d := tc.policy.backoff(tc.retry, func() float64 { return tc.rnd })
+		assert.Equal(t, d, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "first",
+			policy:   RetryPolicy{Backoff: 10 * time.Millisecond},
+			retry:    1,
+			expected: 10 * time.Millisecond,
+		},
+		{
+			name:     "doubles",
+			policy:   RetryPolicy{Backoff: 10 * time.Millisecond},
+			retry:    4,
+			expected: 80 * time.Millisecond,
+		},
+		{
+			name:     "capped",
+			policy:   RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond},
+			retry:    40,
+			expected: 50 * time.Millisecond,
+		},
+		{
+			name:     "jitter",
+			policy:   RetryPolicy{Backoff: 100 * time.Millisecond, Jitter: 0.2},
+			retry:    1,
+			rnd:      0.5,
+			expected: 90 * time.Millisecond,
+		},
+		{
+			name:     "jitter above one",
+			policy:   RetryPolicy{Backoff: 100 * time.Millisecond, Jitter: 3},
+			retry:    1,
+			rnd:      0.5,
+			expected: 50 * time.Millisecond,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	assert.Assert(t, isTransient(syscall.EINTR))
+	assert.Assert(t, isTransient(syscall.EBUSY))
+	assert.Assert(t, isTransient(&kernelError{sentinel: ErrServiceNotFound, err: syscall.ENOBUFS}))
+	assert.Assert(t, isTransient(ErrDumpInterrupted))
+	assert.Assert(t, !isTransient(syscall.EEXIST))
+	assert.Assert(t, !isTransient(errors.New("boom")))
+}
+
+func TestRetryError(t *testing.T) {
+	err := error(&RetryError{Op: "get_service", Attempts: 3, Err: syscall.EBUSY})
+
+	assert.Error(t, err, "ipvs: get_service failed after 3 attempts: device or resource busy")
+	assert.Assert(t, errors.Is(err, syscall.EBUSY))
+}
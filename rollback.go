@@ -0,0 +1,174 @@
+package ipvs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RollbackError is returned by Client.ApplyWithRollback when a change
+// failed, after undoing the changes made before it.
+type RollbackError struct {
+	// Change is the change which failed with Err.
+	Change Change
+	Err    error
+
+	// RolledBack lists the changes undone, in the order they were undone,
+	// which is the reverse of the order they were made.
+	RolledBack Changeset
+	// NotRolledBack lists the changes which could not be undone, along with
+	// the error undoing them. IPVS is left in the state they made.
+	NotRolledBack []UndoError
+}
+
+// UndoError is the failure to undo a Change.
+type UndoError struct {
+	Change Change
+	Err    error
+}
+
+// Error implements error.
+func (e *UndoError) Error() string {
+	return fmt.Sprintf("ipvs: undo %s: %v", e.Change, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *UndoError) Unwrap() error {
+	return e.Err
+}
+
+// Error implements error.
+func (e *RollbackError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ipvs: %s failed: %v", e.Change, e.Err)
+
+	n := len(e.RolledBack) + len(e.NotRolledBack)
+	if len(e.NotRolledBack) == 0 {
+		fmt.Fprintf(&b, "; rolled back %d changes", n)
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "; rolled back %d of %d changes", len(e.RolledBack), n)
+	for _, u := range e.NotRolledBack {
+		fmt.Fprintf(&b, "; could not undo %s: %v", u.Change, u.Err)
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the error of the change which failed.
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyWithRollback is Apply, except that when a change fails, it undoes
+// the changes made before it, best-effort and in reverse order, from the
+// state of IPVS fetched before making any change. It then returns the
+// changes made, along with a *RollbackError listing those which were and
+// were not undone.
+//
+// Undoing ignores the cancellation of ctx, so that a change failing because
+// ctx is done is still rolled back. A Client created WithTimeout bounds the
+// time it takes.
+func (c *client) ApplyWithRollback(ctx context.Context, cfg TableConfig, opts ApplyOptions) (ApplyResult, error) {
+	return applyWithRollback(ctx, c, cfg, opts)
+}
+
+// applyWithRollback implements Client.ApplyWithRollback over c.
+func applyWithRollback(ctx context.Context, c Client, cfg TableConfig, opts ApplyOptions) (ApplyResult, error) {
+	p, err := planApply(ctx, c, cfg, opts)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+
+	var made Changeset
+	for _, ch := range p.changes {
+		if err := ch.apply(ctx, c, &p.result, p.desired); err != nil {
+			rerr := &RollbackError{Change: ch, Err: err}
+			p.rollback(withoutCancel{ctx}, c, made, opts, rerr)
+			return p.result, rerr
+		}
+		if !ch.skipped(p.desired) {
+			made = append(made, ch)
+		}
+	}
+
+	return p.result, nil
+}
+
+// rollback undoes the changes made, recording the outcome in rerr, and the
+// owners of the services restored or removed in the result of p.
+func (p *applyPlan) rollback(ctx context.Context, c Client, made Changeset, opts ApplyOptions, rerr *RollbackError) {
+	for i := len(made) - 1; i >= 0; i-- {
+		ch := made[i]
+		if err := p.undo(ctx, c, ch); err != nil {
+			rerr.NotRolledBack = append(rerr.NotRolledBack, UndoError{Change: ch, Err: err})
+			continue
+		}
+		rerr.RolledBack = append(rerr.RolledBack, ch)
+
+		key := ch.Service.Normalize().Key()
+		switch ch.Type {
+		case ServiceAdded:
+			delete(p.result.Owners, key)
+		case ServiceRemoved:
+			if owner := opts.owner(key); owner != "" {
+				p.result.Owners[key] = owner
+			}
+		}
+	}
+}
+
+// undo reverts the change ch through c. The destinations of a service
+// removed are recreated from the state of IPVS before Apply.
+func (p *applyPlan) undo(ctx context.Context, c Client, ch Change) error {
+	switch ch.Type {
+	case ServiceAdded:
+		return c.RemoveService(ctx, ch.Service)
+	case ServiceUpdated:
+		return c.UpdateService(ctx, ch.PreviousService)
+	case ServiceRemoved:
+		if err := c.CreateService(ctx, ch.Service); err != nil {
+			return err
+		}
+		for _, dest := range p.current[ch.Service.Normalize().Key()].dests {
+			if err := c.CreateDestination(ctx, ch.Service, dest); err != nil {
+				return err
+			}
+		}
+	case DestinationAdded:
+		return c.RemoveDestination(ctx, ch.Service, ch.Destination)
+	case DestinationUpdated, DestinationWeightChanged:
+		return c.UpdateDestination(ctx, ch.Service, ch.PreviousDestination)
+	case DestinationRemoved:
+		return c.CreateDestination(ctx, ch.Service, ch.Destination)
+	}
+
+	return nil
+}
+
+// skipped reports whether Change.apply skips ch, a destination removed
+// along with its service, which is not removed on its own.
+func (ch Change) skipped(desired map[ServiceKey]watchedService) bool {
+	if ch.Type != DestinationRemoved {
+		return false
+	}
+
+	_, ok := desired[ch.Service.Normalize().Key()]
+	return !ok
+}
+
+// withoutCancel is a context which keeps the values of its parent, but is
+// never done.
+type withoutCancel struct {
+	parent context.Context
+}
+
+func (withoutCancel) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (withoutCancel) Done() <-chan struct{}       { return nil }
+func (withoutCancel) Err() error                  { return nil }
+
+func (c withoutCancel) Value(key any) any {
+	return c.parent.Value(key)
+}
@@ -0,0 +1,142 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestApplyWithRollback(t *testing.T) {
+	type testCase struct {
+		name       string
+		fail       []string
+		rolledBack int
+		undoErrs   []string
+		err        string
+	}
+
+	svc := func(last byte) Service {
+		return Service{Address: netip.AddrFrom4([4]byte{192, 0, 2, last}), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	}
+	dest := func(last byte, weight uint32) Destination {
+		return Destination{Address: netip.AddrFrom4([4]byte{198, 51, 100, last}), Port: 8080, Family: INET, Weight: weight}
+	}
+	wrr := svc(1)
+	wrr.Scheduler = WeightedRoundRobin
+
+	current := []ServiceConfig{
+		{Service: svc(1), Destinations: []Destination{dest(1, 1), dest(2, 1)}},
+		{Service: svc(2), Destinations: []Destination{dest(1, 1)}},
+		{Service: svc(3)},
+	}
+	cfg := TableConfig{Services: []ServiceConfig{
+		{Service: wrr, Destinations: []Destination{dest(1, 5), dest(3, 1)}},
+		{Service: svc(4), Destinations: []Destination{dest(1, 1)}},
+	}}
+	owners := map[ServiceKey]string{svc(1).Key(): "a", svc(2).Key(): "a", svc(3).Key(): "a"}
+	opts := ApplyOptions{Prune: true, Owner: "a", Owners: owners}
+
+	run := func(t *testing.T, tc testCase) {
+		c := newTableClient(current...)
+		before, err := c.ServicesWithDestinations(context.Background())
+		assert.NilError(t, err)
+		c.fail = tc.fail
+
+		r, err := applyWithRollback(context.Background(), c, cfg, opts)
+		if tc.err == "" {
+			assert.NilError(t, err)
+			assert.DeepEqual(t, r.Owners, map[ServiceKey]string{wrr.Key(): "a", svc(4).Key(): "a"})
+			return
+		}
+		assert.Error(t, err, tc.err)
+		assert.Assert(t, r.Changed())
+
+		var rerr *RollbackError
+		assert.Assert(t, errors.As(err, &rerr))
+		assert.Equal(t, rerr.Err.Error(), "injected failure")
+		assert.Equal(t, len(rerr.RolledBack), tc.rolledBack)
+		var undoErrs []string
+		for _, u := range rerr.NotRolledBack {
+			undoErrs = append(undoErrs, u.Change.String())
+		}
+		assert.DeepEqual(t, undoErrs, tc.undoErrs)
+
+		if len(tc.undoErrs) > 0 {
+			return
+		}
+
+		// IPVS is back in its state before Apply.
+		after, err := c.ServicesWithDestinations(context.Background())
+		assert.NilError(t, err)
+		cs, err := Diff(TableConfigOf(before), TableConfigOf(after))
+		assert.NilError(t, err)
+		assert.Equal(t, len(cs), 0, cs.Format())
+		assert.DeepEqual(t, r.Owners, owners)
+	}
+
+	testCases := []testCase{
+		{
+			name: "success",
+		},
+		{
+			name:       "rolled back",
+			fail:       []string{"remove_service TCP 192.0.2.3:80"},
+			rolledBack: 7,
+			err: "ipvs: delete service TCP 192.0.2.3:80 failed: injected failure; " +
+				"rolled back 7 changes",
+		},
+		{
+			name: "partially rolled back",
+			fail: []string{
+				"remove_service TCP 192.0.2.3:80",
+				"remove_destination TCP 192.0.2.1:80 198.51.100.3:8080",
+			},
+			rolledBack: 6,
+			undoErrs:   []string{"create destination 198.51.100.3:8080 of service TCP 192.0.2.1:80"},
+			err: "ipvs: delete service TCP 192.0.2.3:80 failed: injected failure; " +
+				"rolled back 6 of 7 changes; " +
+				"could not undo create destination 198.51.100.3:8080 of service TCP 192.0.2.1:80: injected failure",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) { run(t, tc) })
+	}
+}
+
+func TestApplyWithRollback_Canceled(t *testing.T) {
+	c := newTableClient()
+	c.fail = []string{"create_destination TCP 192.0.2.1:80 198.51.100.1:8080"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+	_, err := applyWithRollback(ctx, &ctxClient{c}, TableConfig{Services: []ServiceConfig{
+		{Service: svc, Destinations: []Destination{dest}},
+	}}, ApplyOptions{})
+
+	// The service is removed although ctx is canceled.
+	var rerr *RollbackError
+	assert.Assert(t, errors.As(err, &rerr))
+	assert.Equal(t, len(rerr.RolledBack), 1)
+	assert.Equal(t, len(c.svcs), 0)
+}
+
+// ctxClient is a tableClient failing removals when their context is done.
+type ctxClient struct {
+	*tableClient
+}
+
+func (c *ctxClient) RemoveService(ctx context.Context, svc Service) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.tableClient.RemoveService(ctx, svc)
+}
@@ -0,0 +1,512 @@
+package ipvs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/ipvs/netmask"
+)
+
+// FormatRules returns cfg as rules in the form saved by "ipvsadm -S -n", one
+// -A line per service followed by one -a line per destination, which
+// ParseRules and "ipvsadm -R" restore. As in ipvsadm, the Local forwarding
+// method is written as -g.
+func FormatRules(cfg TableConfig) string {
+	var b strings.Builder
+	for _, sc := range cfg.Services {
+		svc := sc.Normalize()
+		target := ruleTarget(svc)
+
+		fmt.Fprintf(&b, "-A %s -s %s", target, svc.Scheduler)
+		if svc.Flags&ServicePersistent != 0 {
+			fmt.Fprintf(&b, " -p %d", svc.Timeout)
+			if svc.Netmask != defaultNetmask(svc.Family) {
+				fmt.Fprintf(&b, " -M %s", svc.Netmask)
+			}
+		}
+		if svc.PEName != "" {
+			fmt.Fprintf(&b, " --pe %s", svc.PEName)
+		}
+		if svc.Flags&ServiceOnePacket != 0 {
+			b.WriteString(" -o")
+		}
+		if flags := schedulerFlags(svc.Scheduler, svc.Flags); len(flags) > 0 {
+			fmt.Fprintf(&b, " -b %s", strings.Join(flags, ","))
+		}
+		b.WriteByte('\n')
+
+		for _, dest := range sc.Destinations {
+			dest = dest.Normalize()
+
+			fmt.Fprintf(&b, "-a %s -r %s %s -w %d", target, netip.AddrPortFrom(dest.Address, dest.Port), forwardSwitch(dest.FwdMethod), dest.Weight)
+			if dest.UpperThreshold != 0 {
+				fmt.Fprintf(&b, " -x %d", dest.UpperThreshold)
+			}
+			if dest.LowerThreshold != 0 {
+				fmt.Fprintf(&b, " -y %d", dest.LowerThreshold)
+			}
+			if dest.FwdMethod == Tunnel && dest.TunnelType != IPIP {
+				fmt.Fprintf(&b, " --tun-type %s", strings.ToLower(dest.TunnelType.String()))
+				if dest.TunnelType == GUE {
+					fmt.Fprintf(&b, " --tun-port %d", dest.TunnelPort)
+				}
+				switch dest.TunnelFlags {
+				case TunnelEncapChecksum:
+					b.WriteString(" --tun-csum")
+				case TunnelEncapRemoteChecksum:
+					b.WriteString(" --tun-remcsum")
+				}
+			}
+			b.WriteByte('\n')
+		}
+	}
+
+	return b.String()
+}
+
+// ruleTarget returns the options identifying svc in ipvsadm rules.
+func ruleTarget(svc Service) string {
+	if svc.FWMark != 0 {
+		if svc.Family == INET6 {
+			return fmt.Sprintf("-f %d -6", svc.FWMark)
+		}
+		return fmt.Sprintf("-f %d", svc.FWMark)
+	}
+
+	// IPVS only balances TCP, UDP and SCTP.
+	opt := "--sctp-service"
+	switch svc.Protocol {
+	case TCP:
+		opt = "-t"
+	case UDP:
+		opt = "-u"
+	}
+
+	return opt + " " + netip.AddrPortFrom(svc.Address, svc.Port).String()
+}
+
+// forwardSwitch returns the option of ipvsadm selecting fwd.
+func forwardSwitch(fwd ForwardType) string {
+	switch fwd {
+	case Masquerade:
+		return "-m"
+	case Tunnel:
+		return "-i"
+	}
+
+	return "-g"
+}
+
+// ParseRules parses the rules saved by "ipvsadm -S -n" or generated by
+// keepalived from r. Services are added by -A lines, and their destinations
+// by -a lines following them; both the short and long options of ipvsadm
+// are accepted. Blank lines and lines starting with "#" are ignored.
+//
+// Options omitted take the defaults of ipvsadm: the wlc scheduler, a
+// persistence timeout of 360 seconds, the -g forwarding method, a weight of
+// 1 and the port of the service for destinations.
+func ParseRules(r io.Reader) (TableConfig, error) {
+	var (
+		cfg   TableConfig
+		index = make(map[ServiceKey]int)
+	)
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if err := cfg.parseRule(line, index); err != nil {
+			return TableConfig{}, fmt.Errorf("ipvs: ParseRules: line %d: %w", n, err)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return TableConfig{}, fmt.Errorf("ipvs: ParseRules: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// parseRule adds the service or destination of the rule line to cfg, with
+// index holding the position of each service in cfg.
+func (cfg *TableConfig) parseRule(line string, index map[ServiceKey]int) error {
+	p := ruleParser{args: strings.Fields(line)}
+	cmd, _ := p.next()
+
+	var isService bool
+	switch cmd {
+	case "-A", "--add-service":
+		isService = true
+	case "-a", "--add-server":
+	default:
+		return fmt.Errorf("unsupported command %q", cmd)
+	}
+
+	r := rule{
+		svc:  Service{Scheduler: WeightedLeastConnection},
+		dest: Destination{FwdMethod: DirectRoute, Weight: 1},
+	}
+	for {
+		opt, ok := p.next()
+		if !ok {
+			break
+		}
+
+		var err error
+		switch {
+		case r.target(&p, opt, &err):
+		case isService:
+			err = r.serviceOption(&p, opt)
+		default:
+			err = r.serverOption(&p, opt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	svc, err := r.service()
+	if err != nil {
+		return err
+	}
+
+	key := svc.Normalize().Key()
+	i, exists := index[key]
+
+	if isService {
+		if exists {
+			return fmt.Errorf("service %s added twice", key)
+		}
+
+		index[key] = len(cfg.Services)
+		cfg.Services = append(cfg.Services, ServiceConfig{Service: svc})
+		return nil
+	}
+
+	if !exists {
+		return fmt.Errorf("server added to unknown service %s", key)
+	}
+
+	dest, err := r.destination()
+	if err != nil {
+		return err
+	}
+
+	sc := &cfg.Services[i]
+	sc.Destinations = append(sc.Destinations, dest)
+	return nil
+}
+
+// rule is the state of an ipvsadm rule being parsed.
+type rule struct {
+	svc  Service
+	dest Destination
+
+	// inet6 is set by the -6 option of firewall mark services.
+	inet6 bool
+	// mask is the value of the -M option.
+	mask string
+	// server is the value of the -r option.
+	server string
+	// tunnelPort is set by the --tun-port option.
+	tunnelPort bool
+}
+
+// target parses opt into r if it identifies the service, reporting whether
+// it did, with the error stored in err.
+func (r *rule) target(p *ruleParser, opt string, err *error) bool {
+	switch opt {
+	case "-t", "--tcp-service":
+		*err = p.service(opt, &r.svc, TCP)
+	case "-u", "--udp-service":
+		*err = p.service(opt, &r.svc, UDP)
+	case "-q", "--sctp-service":
+		*err = p.service(opt, &r.svc, SCTP)
+	case "-f", "--fwmark-service":
+		r.svc.FWMark, *err = p.uint32(opt)
+	case "-6", "--ipv6":
+		r.inet6 = true
+	default:
+		return false
+	}
+
+	return true
+}
+
+// serviceOption parses the option opt of an -A rule into r.
+func (r *rule) serviceOption(p *ruleParser, opt string) error {
+	var err error
+	switch opt {
+	case "-s", "--scheduler":
+		var v string
+		v, err = p.value(opt)
+		r.svc.Scheduler = Scheduler(v)
+	case "-p", "--persistent":
+		r.svc.Flags |= ServicePersistent
+		r.svc.Timeout = 360
+		if v, ok := p.optionalValue(); ok {
+			r.svc.Timeout, err = parseRuleUint32(opt, v)
+		}
+	case "-M", "--netmask":
+		r.mask, err = p.value(opt)
+	case "--pe":
+		r.svc.PEName, err = p.value(opt)
+	case "-o", "--ops":
+		r.svc.Flags |= ServiceOnePacket
+	case "-b", "--sched-flags":
+		var v string
+		if v, err = p.value(opt); err == nil {
+			err = parseSchedulerFlags(v, &r.svc.Flags)
+		}
+	default:
+		err = fmt.Errorf("unsupported service option %q", opt)
+	}
+
+	return err
+}
+
+// serverOption parses the option opt of an -a rule into r.
+func (r *rule) serverOption(p *ruleParser, opt string) error {
+	var err error
+	switch opt {
+	case "-r", "--real-server":
+		r.server, err = p.value(opt)
+	case "-g", "--gatewaying":
+		r.dest.FwdMethod = DirectRoute
+	case "-i", "--ipip":
+		r.dest.FwdMethod = Tunnel
+	case "-m", "--masquerading":
+		r.dest.FwdMethod = Masquerade
+	case "-w", "--weight":
+		r.dest.Weight, err = p.uint32(opt)
+	case "-x", "--u-threshold":
+		r.dest.UpperThreshold, err = p.uint32(opt)
+	case "-y", "--l-threshold":
+		r.dest.LowerThreshold, err = p.uint32(opt)
+	case "--tun-type":
+		var v string
+		if v, err = p.value(opt); err == nil {
+			r.dest.TunnelType, err = parseTunnelType(v)
+		}
+	case "--tun-port":
+		var v string
+		if v, err = p.value(opt); err == nil {
+			var port uint64
+			if port, err = strconv.ParseUint(v, 10, 16); err != nil {
+				return fmt.Errorf("invalid value %q of %s", v, opt)
+			}
+			r.dest.TunnelPort = uint16(port)
+			r.tunnelPort = true
+		}
+	case "--tun-nocsum":
+		r.dest.TunnelFlags = TunnelEncapNoChecksum
+	case "--tun-csum":
+		r.dest.TunnelFlags = TunnelEncapChecksum
+	case "--tun-remcsum":
+		r.dest.TunnelFlags = TunnelEncapRemoteChecksum
+	default:
+		err = fmt.Errorf("unsupported server option %q", opt)
+	}
+
+	return err
+}
+
+// service returns the service of r, deriving its family.
+func (r *rule) service() (Service, error) {
+	svc := r.svc
+	switch {
+	case svc.FWMark != 0 && svc.Address.IsValid():
+		return Service{}, errors.New("both a firewall mark and an address given")
+	case svc.FWMark != 0:
+		svc.Family = INET
+		if r.inet6 {
+			svc.Family = INET6
+		}
+	case svc.Address.IsValid():
+		svc.Family = INET
+		if svc.Address.Is6() {
+			svc.Family = INET6
+		}
+	default:
+		return Service{}, errors.New("missing service")
+	}
+
+	if r.mask != "" {
+		mask, err := netmask.ParseMask(r.mask)
+		if err != nil {
+			return Service{}, err
+		}
+		svc.Netmask = mask
+	}
+
+	return svc, nil
+}
+
+// destination returns the destination of an -a rule.
+func (r *rule) destination() (Destination, error) {
+	if r.server == "" {
+		return Destination{}, errors.New("missing real server")
+	}
+
+	ap, err := parseRuleServer(r.server, r.svc.Port)
+	if err != nil {
+		return Destination{}, err
+	}
+
+	if r.dest.FwdMethod == Tunnel && r.dest.TunnelType == GUE && !r.tunnelPort {
+		return Destination{}, errors.New("missing --tun-port of GUE tunnel")
+	}
+
+	dest := r.dest
+	dest.Address = ap.Addr()
+	dest.Port = ap.Port()
+	return dest.Normalize(), nil
+}
+
+// ruleParser iterates over the options of an ipvsadm rule.
+type ruleParser struct {
+	args []string
+	// inline is the value given to the last option in the "--opt=value"
+	// form, if any.
+	inline    string
+	hasInline bool
+}
+
+// next returns the next option.
+func (p *ruleParser) next() (string, bool) {
+	if len(p.args) == 0 {
+		return "", false
+	}
+
+	opt := p.args[0]
+	p.args = p.args[1:]
+
+	p.inline, p.hasInline = "", false
+	if strings.HasPrefix(opt, "--") {
+		if name, v, ok := strings.Cut(opt, "="); ok {
+			opt, p.inline, p.hasInline = name, v, true
+		}
+	}
+
+	return opt, true
+}
+
+// value returns the value of the option opt.
+func (p *ruleParser) value(opt string) (string, error) {
+	if v, ok := p.optionalValue(); ok {
+		return v, nil
+	}
+
+	return "", fmt.Errorf("missing value of %s", opt)
+}
+
+// optionalValue returns the value of the last option, if given.
+func (p *ruleParser) optionalValue() (string, bool) {
+	if p.hasInline {
+		p.hasInline = false
+		return p.inline, true
+	}
+
+	if len(p.args) == 0 || strings.HasPrefix(p.args[0], "-") {
+		return "", false
+	}
+
+	v := p.args[0]
+	p.args = p.args[1:]
+	return v, true
+}
+
+// uint32 returns the value of the option opt as an integer.
+func (p *ruleParser) uint32(opt string) (uint32, error) {
+	v, err := p.value(opt)
+	if err != nil {
+		return 0, err
+	}
+
+	return parseRuleUint32(opt, v)
+}
+
+// service parses the address of the service of protocol given to opt into
+// svc.
+func (p *ruleParser) service(opt string, svc *Service, protocol Protocol) error {
+	v, err := p.value(opt)
+	if err != nil {
+		return err
+	}
+
+	ap, err := netip.ParseAddrPort(v)
+	if err != nil {
+		return err
+	}
+
+	svc.Protocol = protocol
+	svc.Address = ap.Addr()
+	svc.Port = ap.Port()
+	return nil
+}
+
+// parseRuleServer parses the address of a real server, which defaults to
+// the port of its service.
+func parseRuleServer(s string, port uint16) (netip.AddrPort, error) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap, nil
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid real server %q", s)
+	}
+
+	return netip.AddrPortFrom(addr, port), nil
+}
+
+// parseRuleUint32 parses the value v of the option opt.
+func parseRuleUint32(opt, v string) (uint32, error) {
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q of %s", v, opt)
+	}
+
+	return uint32(n), nil
+}
+
+// parseSchedulerFlags adds the comma-separated scheduler flags of s, in the
+// form of ipvsadm, to flags.
+func parseSchedulerFlags(s string, flags *Flags) error {
+	for _, name := range strings.Split(s, ",") {
+		switch name {
+		case "flag-1", "sh-fallback", "mh-fallback":
+			*flags |= ServiceSchedulerOpt1
+		case "flag-2", "sh-port", "mh-port":
+			*flags |= ServiceSchedulerOpt2
+		case "flag-3":
+			*flags |= ServiceSchedulerOpt3
+		default:
+			return fmt.Errorf("unknown scheduler flag %q", name)
+		}
+	}
+
+	return nil
+}
+
+// parseTunnelType parses the tunnel type of ipvsadm named s.
+func parseTunnelType(s string) (TunnelType, error) {
+	switch s {
+	case "ipip":
+		return IPIP, nil
+	case "gue":
+		return GUE, nil
+	case "gre":
+		return GRE, nil
+	}
+
+	return 0, fmt.Errorf("unknown tunnel type %q", s)
+}
@@ -0,0 +1,130 @@
+package ipvs
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/cloudflare/ipvs/netmask"
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+)
+
+const testRules = `-A -t 192.0.2.1:80 -s rr
+-a -t 192.0.2.1:80 -r 198.51.100.1:8080 -m -w 1
+-a -t 192.0.2.1:80 -r 198.51.100.2:8080 -g -w 0 -x 100 -y 50
+-A -u [2001:db8::1]:53 -s sh -p 300 -M 64 -o -b sh-fallback,sh-port
+-a -u [2001:db8::1]:53 -r [2001:db8::2]:53 -i -w 5
+-a -u [2001:db8::1]:53 -r 198.51.100.3:53 -i -w 1 --tun-type gue --tun-port 6080 --tun-remcsum
+-A --sctp-service 192.0.2.2:5060 -s wlc -p 60 -M 255.255.255.0 --pe sip
+-A -f 42 -6 -s mh -b mh-port
+-a -f 42 -6 -r [2001:db8::3]:0 -g -w 1
+`
+
+// cmpRules compares the configurations of rules.
+var cmpRules = []cmp.Option{
+	cmp.Comparer(func(x, y netip.Addr) bool { return x == y }),
+	cmp.Comparer(netmask.Mask.Equal),
+}
+
+func TestParseRules(t *testing.T) {
+	cfg, err := ParseRules(strings.NewReader(testRules))
+	assert.NilError(t, err)
+
+	v4 := func(s string) netip.Addr { return netip.MustParseAddr(s) }
+	expected := TableConfig{Services: []ServiceConfig{
+		{
+			Service: Service{Address: v4("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin},
+			Destinations: []Destination{
+				{Address: v4("198.51.100.1"), Port: 8080, Family: INET, FwdMethod: Masquerade, Weight: 1},
+				{Address: v4("198.51.100.2"), Port: 8080, Family: INET, FwdMethod: DirectRoute, UpperThreshold: 100, LowerThreshold: 50},
+			},
+		},
+		{
+			Service: Service{
+				Address: v4("2001:db8::1"), Port: 53, Family: INET6, Protocol: UDP, Scheduler: SourceHashing,
+				Flags: ServicePersistent | ServiceOnePacket | SourceHashFallback | SourceHashPort, Timeout: 300, Netmask: netmask.MaskFrom(64, 128),
+			},
+			Destinations: []Destination{
+				{Address: v4("2001:db8::2"), Port: 53, Family: INET6, FwdMethod: Tunnel, Weight: 5},
+				{Address: v4("198.51.100.3"), Port: 53, Family: INET, FwdMethod: Tunnel, Weight: 1, TunnelType: GUE, TunnelPort: 6080, TunnelFlags: TunnelEncapRemoteChecksum},
+			},
+		},
+		{
+			Service: Service{
+				Address: v4("192.0.2.2"), Port: 5060, Family: INET, Protocol: SCTP, Scheduler: WeightedLeastConnection,
+				Flags: ServicePersistent, Timeout: 60, Netmask: netmask.MaskFrom(24, 32), PEName: "sip",
+			},
+		},
+		{
+			Service: Service{FWMark: 42, Family: INET6, Scheduler: MaglevHashing, Flags: MaglevPort},
+			Destinations: []Destination{
+				{Address: v4("2001:db8::3"), Family: INET6, FwdMethod: DirectRoute, Weight: 1},
+			},
+		},
+	}}
+	assert.DeepEqual(t, cfg, expected, cmpRules...)
+
+	// Formatting the parsed rules returns them as is.
+	assert.Equal(t, FormatRules(cfg), testRules)
+}
+
+func TestParseRules_Defaults(t *testing.T) {
+	// Rules in the form generated by keepalived and written by hand.
+	cfg, err := ParseRules(strings.NewReader(`
+# Generated by keepalived
+--add-service --tcp-service=192.0.2.1:443 --persistent
+--add-server --tcp-service 192.0.2.1:443 --real-server 198.51.100.1 --weight=3
+--add-service --fwmark-service 7 --scheduler wrr --persistent --netmask 255.255.0.0
+--add-server --fwmark-service 7 --real-server 198.51.100.2 --masquerading
+`))
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(cfg.Services), 2)
+	assert.Equal(t, cfg.Services[0].Scheduler, WeightedLeastConnection)
+	assert.Equal(t, cfg.Services[0].Timeout, uint32(360))
+	assert.DeepEqual(t, cfg.Services[0].Destinations, []Destination{
+		{Address: netip.MustParseAddr("198.51.100.1"), Port: 443, Family: INET, FwdMethod: DirectRoute, Weight: 3},
+	}, cmpRules...)
+	assert.Equal(t, cfg.Services[1].Key(), ServiceKey{FWMark: 7, Family: INET})
+	assert.Equal(t, cfg.Services[1].Netmask, netmask.MaskFrom(16, 32))
+	assert.DeepEqual(t, cfg.Services[1].Destinations, []Destination{
+		{Address: netip.MustParseAddr("198.51.100.2"), Family: INET, FwdMethod: Masquerade, Weight: 1},
+	}, cmpRules...)
+}
+
+func TestParseRules_Errors(t *testing.T) {
+	type testCase struct {
+		name  string
+		rules string
+		err   string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		_, err := ParseRules(strings.NewReader(tc.rules))
+		assert.ErrorContains(t, err, tc.err)
+	}
+
+	testCases := []testCase{
+		{name: "command", rules: "-E -t 192.0.2.1:80 -s rr", err: `line 1: unsupported command "-E"`},
+		{name: "missing service", rules: "-A -s rr", err: "missing service"},
+		{name: "missing value", rules: "-A -t 192.0.2.1:80 -s", err: "missing value of -s"},
+		{name: "service option", rules: "-A -t 192.0.2.1:80 -r 198.51.100.1", err: `unsupported service option "-r"`},
+		{name: "server option", rules: "-A -t 192.0.2.1:80\n-a -t 192.0.2.1:80 -r 198.51.100.1 -s rr", err: `line 2: unsupported server option "-s"`},
+		{name: "duplicate service", rules: "-A -t 192.0.2.1:80\n-A -t 192.0.2.1:80", err: "service TCP 192.0.2.1:80 added twice"},
+		{name: "unknown service", rules: "-a -t 192.0.2.1:80 -r 198.51.100.1", err: "server added to unknown service TCP 192.0.2.1:80"},
+		{name: "missing server", rules: "-A -t 192.0.2.1:80\n-a -t 192.0.2.1:80 -w 1", err: "missing real server"},
+		{name: "weight", rules: "-A -t 192.0.2.1:80\n-a -t 192.0.2.1:80 -r 198.51.100.1 -w heavy", err: `invalid value "heavy" of -w`},
+		{name: "scheduler flag", rules: "-A -t 192.0.2.1:80 -s sh -b sh-nope", err: `unknown scheduler flag "sh-nope"`},
+		{name: "tunnel type", rules: "-A -t 192.0.2.1:80\n-a -t 192.0.2.1:80 -r 198.51.100.1 -i --tun-type vxlan", err: `unknown tunnel type "vxlan"`},
+		{name: "tunnel port", rules: "-A -t 192.0.2.1:80\n-a -t 192.0.2.1:80 -r 198.51.100.1 -i --tun-type gue", err: "missing --tun-port"},
+		{name: "mark and address", rules: "-A -f 1 -t 192.0.2.1:80", err: "both a firewall mark and an address given"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
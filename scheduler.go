@@ -0,0 +1,37 @@
+package ipvs
+
+// Scheduler is the name of the algorithm IPVS uses to pick a Destination for
+// new connections to a Service.
+type Scheduler string
+
+// Schedulers shipped with Linux.
+const (
+	RoundRobin                              Scheduler = "rr"
+	WeightedRoundRobin                      Scheduler = "wrr"
+	LeastConnection                         Scheduler = "lc"
+	WeightedLeastConnection                 Scheduler = "wlc"
+	LocalityBasedLeastConnection            Scheduler = "lblc"
+	LocalityBasedLeastConnectionReplication Scheduler = "lblcr"
+	DestinationHashing                      Scheduler = "dh"
+	SourceHashing                           Scheduler = "sh"
+	ShortestExpectedDelay                   Scheduler = "sed"
+	NeverQueue                              Scheduler = "nq"
+	WeightedFailover                        Scheduler = "fo"
+	WeightedOverflow                        Scheduler = "ovf"
+	MaglevHashing                           Scheduler = "mh"
+	WeightedRandomTwoChoices                Scheduler = "twos"
+)
+
+// IsValid reports whether s is one of the schedulers shipped with Linux.
+// Whether the scheduler is available still depends on the kernel modules.
+func (s Scheduler) IsValid() bool {
+	switch s {
+	case RoundRobin, WeightedRoundRobin, LeastConnection, WeightedLeastConnection,
+		LocalityBasedLeastConnection, LocalityBasedLeastConnectionReplication,
+		DestinationHashing, SourceHashing, ShortestExpectedDelay, NeverQueue,
+		WeightedFailover, WeightedOverflow, MaglevHashing, WeightedRandomTwoChoices:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,96 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// AvailableSchedulers returns the schedulers whose ip_vs_<name> kernel module
+// is loaded, built into the kernel, or installed to be loaded on demand.
+//
+// The modules are those of the running kernel, found through /proc/modules
+// and the modules.dep and modules.builtin files of /lib/modules. Files that
+// are missing, such as in containers without /lib/modules, are skipped, so
+// the result may be incomplete.
+func (c *client) AvailableSchedulers(ctx context.Context) ([]Scheduler, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return availableSchedulers(os.DirFS("/"))
+}
+
+// availableSchedulers implements AvailableSchedulers with the files of fsys.
+func availableSchedulers(fsys fs.FS) ([]Scheduler, error) {
+	found := map[Scheduler]bool{}
+	add := func(module string) {
+		s := Scheduler(strings.TrimPrefix(module, "ip_vs_"))
+		if strings.HasPrefix(module, "ip_vs_") && s.IsValid() {
+			found[s] = true
+		}
+	}
+
+	err := readLines(fsys, "proc/modules", func(line string) {
+		name, _, _ := strings.Cut(line, " ")
+		add(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := fs.ReadFile(fsys, "proc/sys/kernel/osrelease")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	if rel := strings.TrimSpace(string(release)); rel != "" {
+		dir := path.Join("lib/modules", rel)
+		for _, file := range []string{"modules.dep", "modules.builtin"} {
+			err := readLines(fsys, path.Join(dir, file), func(line string) {
+				p, _, _ := strings.Cut(line, ":")
+				name, _, _ := strings.Cut(path.Base(p), ".ko")
+				add(name)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	out := make([]Scheduler, 0, len(found))
+	for s := range found {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+
+	return out, nil
+}
+
+// readLines calls fn for each line of the file name in fsys, doing nothing if
+// the file does not exist.
+func readLines(fsys fs.FS, name string, fn func(line string)) error {
+	f, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fn(s.Text())
+	}
+
+	return s.Err()
+}
@@ -0,0 +1,74 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAvailableSchedulers(t *testing.T) {
+	type testCase struct {
+		name     string
+		fsys     fstest.MapFS
+		expected []Scheduler
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		actual, err := availableSchedulers(tc.fsys)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, actual, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "no files",
+			fsys:     fstest.MapFS{},
+			expected: []Scheduler{},
+		},
+		{
+			name: "loaded",
+			fsys: fstest.MapFS{
+				"proc/modules": {Data: []byte(
+					"ip_vs_wrr 12288 0 - Live 0x0000000000000000\n" +
+						"ip_vs_rr 12288 1 - Live 0x0000000000000000\n" +
+						"ip_vs_pe_sip 12288 0 - Live 0x0000000000000000\n" +
+						"ip_vs 217088 7 ip_vs_wrr,ip_vs_rr,ip_vs_pe_sip, Live 0x0000000000000000\n",
+				)},
+			},
+			expected: []Scheduler{RoundRobin, WeightedRoundRobin},
+		},
+		{
+			name: "loadable and builtin",
+			fsys: fstest.MapFS{
+				"proc/modules":              {Data: []byte("ip_vs_rr 12288 1 - Live 0x0000000000000000\n")},
+				"proc/sys/kernel/osrelease": {Data: []byte("6.1.0-13-amd64\n")},
+				"lib/modules/6.1.0-13-amd64/modules.dep": {Data: []byte(
+					"kernel/net/netfilter/ipvs/ip_vs_rr.ko.xz: kernel/net/netfilter/ipvs/ip_vs.ko.xz\n" +
+						"kernel/net/netfilter/ipvs/ip_vs_mh.ko.zst: kernel/net/netfilter/ipvs/ip_vs.ko.zst\n" +
+						"kernel/net/netfilter/ipvs/ip_vs_ftp.ko: kernel/net/netfilter/ipvs/ip_vs.ko\n",
+				)},
+				"lib/modules/6.1.0-13-amd64/modules.builtin": {Data: []byte("kernel/net/netfilter/ipvs/ip_vs_sh.ko\n")},
+			},
+			expected: []Scheduler{MaglevHashing, RoundRobin, SourceHashing},
+		},
+		{
+			name: "other release",
+			fsys: fstest.MapFS{
+				"proc/sys/kernel/osrelease":              {Data: []byte("6.1.0-13-amd64\n")},
+				"lib/modules/5.10.0-9-amd64/modules.dep": {Data: []byte("kernel/net/netfilter/ipvs/ip_vs_mh.ko:\n")},
+			},
+			expected: []Scheduler{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package ipvs
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestScheduler_IsValid(t *testing.T) {
+	type testCase struct {
+		scheduler Scheduler
+		expected  bool
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.scheduler.IsValid(), tc.expected)
+	}
+
+	testCases := []testCase{
+		{scheduler: RoundRobin, expected: true},
+		{scheduler: WeightedRandomTwoChoices, expected: true},
+		{scheduler: "mh", expected: true},
+		{scheduler: ""},
+		{scheduler: "RR"},
+		{scheduler: "rrr"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(string(tc.scheduler), func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package ipvs
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// ServiceKey is the identity of a virtual server, holding the fields IPVS
+// uses to look up a Service. It is comparable, so it can be used as a map key
+// or to diff sets of services.
+//
+// Services are identified either by their firewall mark, or by their
+// protocol, address and port. For the former, Protocol, Address and Port are
+// left unset.
+type ServiceKey struct {
+	Family   AddressFamily
+	Protocol Protocol
+	Address  netip.Addr
+	Port     uint16
+	FWMark   uint32
+}
+
+// Key returns the identity of the service.
+func (svc Service) Key() ServiceKey {
+	if svc.FWMark != 0 {
+		return ServiceKey{Family: svc.Family, FWMark: svc.FWMark}
+	}
+
+	return ServiceKey{
+		Family:   svc.Family,
+		Protocol: svc.Protocol,
+		Address:  svc.Address,
+		Port:     svc.Port,
+	}
+}
+
+// Service returns a Service with only the identifying fields set, suitable
+// for referencing an existing service.
+func (k ServiceKey) Service() Service {
+	return Service{
+		Family:   k.Family,
+		Protocol: k.Protocol,
+		Address:  k.Address,
+		Port:     k.Port,
+		FWMark:   k.FWMark,
+	}
+}
+
+// Compare returns an integer comparing two keys, which is 0 if k == o, -1 if
+// k < o, and +1 if k > o. Keys are ordered by family, then address based
+// services before firewall mark services, then by firewall mark, protocol,
+// address and port.
+func (k ServiceKey) Compare(o ServiceKey) int {
+	switch {
+	case k.Family != o.Family:
+		return compareInt(k.Family, o.Family)
+	case k.FWMark != o.FWMark:
+		return compareInt(k.FWMark, o.FWMark)
+	case k.Protocol != o.Protocol:
+		return compareInt(k.Protocol, o.Protocol)
+	case k.Address != o.Address:
+		return k.Address.Compare(o.Address)
+	}
+
+	return compareInt(k.Port, o.Port)
+}
+
+// compareInt compares two integers like ServiceKey.Compare.
+func compareInt[T ~uint16 | ~uint32](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+
+	return 0
+}
+
+// String returns the key in the form used by ipvsadm, such as
+// "TCP 192.0.2.1:80", "UDP [2001:db8::1]:53" or "FWM 42". Firewall mark
+// services of the INET6 family are suffixed with " IPv6".
+func (k ServiceKey) String() string {
+	b, _ := k.AppendText(nil)
+	return string(b)
+}
+
+// AppendText implements the [encoding.TextAppender] interface.
+func (k ServiceKey) AppendText(b []byte) ([]byte, error) {
+	if k.FWMark != 0 {
+		b = append(b, "FWM "...)
+		b = strconv.AppendUint(b, uint64(k.FWMark), 10)
+		if k.Family == INET6 {
+			b = append(b, " IPv6"...)
+		}
+		return b, nil
+	}
+
+	b = append(b, k.Protocol.String()...)
+	b = append(b, ' ')
+	return netip.AddrPortFrom(k.Address, k.Port).AppendTo(b), nil
+}
+
+// MarshalText implements the [encoding.TextMarshaler] interface.
+func (k ServiceKey) MarshalText() ([]byte, error) {
+	return k.AppendText(nil)
+}
+
+// UnmarshalText implements the [encoding.TextUnmarshaler] interface. The key
+// is expected in a form accepted by ParseServiceKey.
+func (k *ServiceKey) UnmarshalText(text []byte) error {
+	key, err := ParseServiceKey(string(text))
+	if err != nil {
+		return err
+	}
+
+	*k = key
+	return nil
+}
+
+// ParseServiceKey parses s as a ServiceKey, in the form returned by
+// ServiceKey.String. The protocol name is case-insensitive. The family of
+// address based services is derived from the address.
+func ParseServiceKey(s string) (ServiceKey, error) {
+	key, err := parseServiceKey(s)
+	if err != nil {
+		return ServiceKey{}, fmt.Errorf("ipvs: ParseServiceKey(%q): %w", s, err)
+	}
+
+	return key, nil
+}
+
+// parseServiceKey implements ParseServiceKey.
+func parseServiceKey(s string) (ServiceKey, error) {
+	proto, rest, ok := strings.Cut(s, " ")
+	if !ok {
+		return ServiceKey{}, errors.New("missing protocol")
+	}
+
+	if strings.EqualFold(proto, "FWM") {
+		mark, family, _ := strings.Cut(rest, " ")
+
+		key := ServiceKey{Family: INET}
+		switch family {
+		case "":
+		case "IPv6":
+			key.Family = INET6
+		default:
+			return ServiceKey{}, fmt.Errorf("unexpected %q after firewall mark", family)
+		}
+
+		v, err := strconv.ParseUint(mark, 10, 32)
+		if err != nil || v == 0 {
+			return ServiceKey{}, fmt.Errorf("invalid firewall mark %q", mark)
+		}
+		key.FWMark = uint32(v)
+
+		return key, nil
+	}
+
+	var key ServiceKey
+	switch strings.ToUpper(proto) {
+	case "TCP":
+		key.Protocol = TCP
+	case "UDP":
+		key.Protocol = UDP
+	case "SCTP":
+		key.Protocol = SCTP
+	default:
+		return ServiceKey{}, fmt.Errorf("unknown protocol %q", proto)
+	}
+
+	ap, err := netip.ParseAddrPort(rest)
+	if err != nil {
+		return ServiceKey{}, err
+	}
+
+	key.Address = ap.Addr()
+	key.Port = ap.Port()
+	key.Family = INET
+	if key.Address.Is6() {
+		key.Family = INET6
+	}
+
+	return key, nil
+}
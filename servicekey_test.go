@@ -0,0 +1,183 @@
+package ipvs
+
+import (
+	"encoding/json"
+	"net/netip"
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"pgregory.net/rapid"
+)
+
+func TestServiceKey_String(t *testing.T) {
+	type testCase struct {
+		name     string
+		key      ServiceKey
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.Equal(t, tc.key.String(), tc.expected)
+
+		key, err := ParseServiceKey(tc.expected)
+		assert.NilError(t, err)
+		assert.Equal(t, key, tc.key)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "ipv4 tcp",
+			key:      ServiceKey{Family: INET, Protocol: TCP, Address: netip.MustParseAddr("192.0.2.1"), Port: 80},
+			expected: "TCP 192.0.2.1:80",
+		},
+		{
+			name:     "ipv6 udp",
+			key:      ServiceKey{Family: INET6, Protocol: UDP, Address: netip.MustParseAddr("2001:db8::1"), Port: 53},
+			expected: "UDP [2001:db8::1]:53",
+		},
+		{
+			name:     "ipv4 sctp",
+			key:      ServiceKey{Family: INET, Protocol: SCTP, Address: netip.MustParseAddr("192.0.2.1"), Port: 0},
+			expected: "SCTP 192.0.2.1:0",
+		},
+		{
+			name:     "ipv4 fwmark",
+			key:      ServiceKey{Family: INET, FWMark: 42},
+			expected: "FWM 42",
+		},
+		{
+			name:     "ipv6 fwmark",
+			key:      ServiceKey{Family: INET6, FWMark: 42},
+			expected: "FWM 42 IPv6",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestParseServiceKey(t *testing.T) {
+	key, err := ParseServiceKey("tcp 192.0.2.1:443")
+	assert.NilError(t, err)
+	assert.Equal(t, key, ServiceKey{Family: INET, Protocol: TCP, Address: netip.MustParseAddr("192.0.2.1"), Port: 443})
+}
+
+func TestParseServiceKeyError(t *testing.T) {
+	type testCase struct {
+		name     string
+		in       string
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		_, err := ParseServiceKey(tc.in)
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "empty", in: "", expected: "missing protocol"},
+		{name: "unknown protocol", in: "ICMP 192.0.2.1:0", expected: `unknown protocol "ICMP"`},
+		{name: "missing port", in: "TCP 192.0.2.1", expected: "ParseServiceKey"},
+		{name: "zero fwmark", in: "FWM 0", expected: `invalid firewall mark "0"`},
+		{name: "bad fwmark", in: "FWM x", expected: `invalid firewall mark "x"`},
+		{name: "bad fwmark family", in: "FWM 1 IPv5", expected: `unexpected "IPv5"`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestServiceKey_Service(t *testing.T) {
+	svc := Service{
+		Address:   netip.MustParseAddr("192.0.2.1"),
+		Scheduler: "wlc",
+		Timeout:   60,
+		Port:      80,
+		Family:    INET,
+		Protocol:  TCP,
+	}
+
+	key := svc.Key()
+	assert.Equal(t, key, ServiceKey{Family: INET, Protocol: TCP, Address: netip.MustParseAddr("192.0.2.1"), Port: 80})
+	assert.Equal(t, key.Service().Key(), key)
+
+	fwm := Service{FWMark: 7, Family: INET6, Protocol: TCP, Scheduler: "rr"}
+	assert.Equal(t, fwm.Key(), ServiceKey{Family: INET6, FWMark: 7})
+}
+
+func TestServiceKey_MapKey(t *testing.T) {
+	m := map[ServiceKey]int{}
+	m[Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: "wlc"}.Key()]++
+	m[Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: "rr"}.Key()]++
+	assert.Equal(t, len(m), 1)
+
+	b, err := json.Marshal(m)
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), `{"TCP 192.0.2.1:80":2}`)
+
+	var out map[ServiceKey]int
+	assert.NilError(t, json.Unmarshal(b, &out))
+	assert.DeepEqual(t, out, m)
+}
+
+func TestServiceKey_TextRoundtrip(t *testing.T) {
+	rapid.Check(t, func(t *rapid.T) {
+		var key ServiceKey
+		if rapid.Bool().Draw(t, "fwmark") {
+			key.Family = rapid.SampledFrom([]AddressFamily{INET, INET6}).Draw(t, "family")
+			key.FWMark = rapid.Uint32Min(1).Draw(t, "fwmark")
+		} else {
+			key.Protocol = rapid.SampledFrom([]Protocol{TCP, UDP, SCTP}).Draw(t, "protocol")
+			key.Port = rapid.Uint16().Draw(t, "port")
+			if rapid.Bool().Draw(t, "ipv6") {
+				key.Family = INET6
+				key.Address = netip.AddrFrom16(*(*[16]byte)(rapid.SliceOfN(rapid.Byte(), 16, 16).Draw(t, "addr")))
+			} else {
+				key.Family = INET
+				key.Address = netip.AddrFrom4(*(*[4]byte)(rapid.SliceOfN(rapid.Byte(), 4, 4).Draw(t, "addr")))
+			}
+		}
+
+		b, err := key.MarshalText()
+		assert.NilError(t, err)
+
+		var out ServiceKey
+		assert.NilError(t, out.UnmarshalText(b))
+		assert.Equal(t, out, key)
+	})
+}
+
+func TestServiceKey_Compare(t *testing.T) {
+	// Keys in ascending order.
+	keys := []ServiceKey{
+		{Family: INET, Protocol: TCP, Address: netip.MustParseAddr("192.0.2.1"), Port: 80},
+		{Family: INET, Protocol: TCP, Address: netip.MustParseAddr("192.0.2.1"), Port: 443},
+		{Family: INET, Protocol: TCP, Address: netip.MustParseAddr("192.0.2.2"), Port: 80},
+		{Family: INET, Protocol: UDP, Address: netip.MustParseAddr("192.0.2.1"), Port: 53},
+		{Family: INET, FWMark: 1},
+		{Family: INET, FWMark: 2},
+		{Family: INET6, Protocol: TCP, Address: netip.MustParseAddr("2001:db8::1"), Port: 80},
+		{Family: INET6, FWMark: 1},
+	}
+
+	for i, a := range keys {
+		for j, b := range keys {
+			expected := 0
+			switch {
+			case i < j:
+				expected = -1
+			case i > j:
+				expected = 1
+			}
+			assert.Equal(t, a.Compare(b), expected, "%s <=> %s", a, b)
+		}
+	}
+}
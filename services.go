@@ -0,0 +1,86 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+)
+
+// ServiceWithDestinations is a Service together with its Destinations.
+type ServiceWithDestinations struct {
+	ServiceExtended
+	Destinations []DestinationExtended
+}
+
+// ServicesWithDestinations returns the Services matching opts, each with its
+// Destinations.
+//
+// IPVS cannot dump Destinations across Services, so this dumps the Services
+// followed by the Destinations of each. Services removed in between are left
+// out of the result, while Services added in between are not seen.
+func (c *client) ServicesWithDestinations(ctx context.Context, opts ...ListOption) ([]ServiceWithDestinations, error) {
+	svcs, err := c.Services(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ServiceWithDestinations, 0, len(svcs))
+	for _, svc := range svcs {
+		dests, err := c.Destinations(ctx, svc.Service)
+		switch {
+		case errors.Is(err, ErrServiceNotFound):
+			continue
+		case errors.Is(err, os.ErrNotExist):
+			dests = nil
+		case err != nil:
+			return nil, err
+		}
+
+		out = append(out, ServiceWithDestinations{ServiceExtended: svc, Destinations: dests})
+	}
+
+	return out, nil
+}
+
+// destination fetches the Destination of svc identified by the Address, Port
+// and Family of dest, an unset Family being derived from the Address.
+func (c *client) destination(ctx context.Context, svc Service, dest Destination) (DestinationExtended, error) {
+	dest = dest.Normalize()
+	dests, err := c.Destinations(ctx, svc)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return DestinationExtended{}, err
+	}
+
+	for _, d := range dests {
+		if d.Address.Unmap() == dest.Address.Unmap() && d.Port == dest.Port && d.Family == dest.Family {
+			return d, nil
+		}
+	}
+
+	return DestinationExtended{}, fmt.Errorf("%w: %s", ErrDestinationNotFound, netip.AddrPortFrom(dest.Address, dest.Port))
+}
+
+// sortServices sorts svcs by their ServiceKey.
+func sortServices(svcs []ServiceExtended) {
+	sort.Slice(svcs, func(i, j int) bool {
+		return svcs[i].Key().Compare(svcs[j].Key()) < 0
+	})
+}
+
+// sortDestinations sorts dests by their address, port and family.
+func sortDestinations(dests []DestinationExtended) {
+	sort.Slice(dests, func(i, j int) bool {
+		a, b := dests[i], dests[j]
+		switch {
+		case a.Address != b.Address:
+			return a.Address.Less(b.Address)
+		case a.Port != b.Port:
+			return a.Port < b.Port
+		}
+
+		return a.Family < b.Family
+	})
+}
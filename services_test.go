@@ -0,0 +1,171 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/cloudflare/ipvs/netmask"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"gotest.tools/v3/assert"
+)
+
+func TestServicesWithDestinations(t *testing.T) {
+	web := Service{Address: netip.MustParseAddr("192.0.2.1"), Netmask: netmask.MaskFrom(32, 32), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dns := Service{Address: netip.MustParseAddr("192.0.2.1"), Netmask: netmask.MaskFrom(32, 32), Port: 53, Family: INET, Protocol: UDP, Scheduler: RoundRobin}
+	dests := []Destination{
+		{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1},
+		{Address: netip.MustParseAddr("198.51.100.2"), Port: 8080, Family: INET, Weight: 1},
+	}
+
+	encode := func(typ uint16, fn func() ([]byte, error)) genetlink.Message {
+		ae := netlink.NewAttributeEncoder()
+		ae.Do(typ, fn)
+		b, err := ae.Encode()
+		assert.NilError(t, err)
+		return genetlink.Message{Data: b}
+	}
+
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		switch gerq.Header.Command {
+		case cipvs.CmdGetService:
+			return []genetlink.Message{encode(cipvs.CmdAttrService, packService(web)), encode(cipvs.CmdAttrService, packService(dns))}, nil
+		case cipvs.CmdGetDest:
+			var svc ServiceExtended
+			ad, err := netlink.NewAttributeDecoder(gerq.Data)
+			assert.NilError(t, err)
+			for ad.Next() {
+				if ad.Type() == cipvs.CmdAttrService {
+					ad.Do(unpackService(&svc))
+				}
+			}
+			assert.NilError(t, ad.Err())
+
+			if svc.Port != web.Port {
+				return nil, io.EOF
+			}
+
+			var msgs []genetlink.Message
+			for _, dest := range dests {
+				msgs = append(msgs, encode(cipvs.CmdAttrDest, packDest(dest)))
+			}
+			return msgs, nil
+		}
+
+		t.Fatalf("unexpected command %d", gerq.Header.Command)
+		return nil, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	out, err := client.ServicesWithDestinations(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, len(out), 2)
+
+	assert.DeepEqual(t, out[0].Service, web, cmp.Comparer(NetipAddrCompare), cmp.Comparer(netmask.Mask.Equal))
+	assert.Equal(t, len(out[0].Destinations), 2)
+	for i, dest := range out[0].Destinations {
+		assert.DeepEqual(t, dest.Destination, dests[i], cmp.Comparer(NetipAddrCompare))
+	}
+
+	assert.DeepEqual(t, out[1].Service, dns, cmp.Comparer(NetipAddrCompare), cmp.Comparer(netmask.Mask.Equal))
+	assert.Equal(t, len(out[1].Destinations), 0)
+
+	out, err = client.ServicesWithDestinations(context.Background(), MatchProtocol(UDP))
+	assert.NilError(t, err)
+	assert.Equal(t, len(out), 1)
+	assert.Equal(t, out[0].Key(), dns.Key())
+}
+
+func TestServicesFunc(t *testing.T) {
+	web := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dns := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 53, Family: INET, Protocol: UDP, Scheduler: RoundRobin}
+	ssh := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 22, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dests := []Destination{
+		{Address: netip.MustParseAddr("198.51.100.2"), Port: 8080, Family: INET, Weight: 1},
+		{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1},
+	}
+
+	encode := func(typ uint16, fn func() ([]byte, error)) genetlink.Message {
+		ae := netlink.NewAttributeEncoder()
+		ae.Do(typ, fn)
+		b, err := ae.Encode()
+		assert.NilError(t, err)
+		return genetlink.Message{Data: b}
+	}
+
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		switch gerq.Header.Command {
+		case cipvs.CmdGetService:
+			return []genetlink.Message{
+				encode(cipvs.CmdAttrService, packService(web)),
+				encode(cipvs.CmdAttrService, packService(dns)),
+				encode(cipvs.CmdAttrService, packService(ssh)),
+			}, nil
+		case cipvs.CmdGetDest:
+			var msgs []genetlink.Message
+			for _, dest := range dests {
+				msgs = append(msgs, encode(cipvs.CmdAttrDest, packDest(dest)))
+			}
+			return msgs, nil
+		}
+
+		t.Fatalf("unexpected command %d", gerq.Header.Command)
+		return nil, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	// Services are delivered in the order of the dump, filtered by the
+	// options.
+	var ports []uint16
+	err := client.ServicesFunc(context.Background(), func(svc ServiceExtended) error {
+		ports = append(ports, svc.Port)
+		return nil
+	}, MatchProtocol(TCP))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, ports, []uint16{80, 22})
+
+	// Returning an error stops the iteration.
+	errStop := errors.New("stop")
+	ports = nil
+	err = client.ServicesFunc(context.Background(), func(svc ServiceExtended) error {
+		ports = append(ports, svc.Port)
+		return errStop
+	})
+	assert.Equal(t, err, errStop)
+	assert.DeepEqual(t, ports, []uint16{80})
+
+	var addrs []netip.Addr
+	err = client.DestinationsFunc(context.Background(), web, func(dest DestinationExtended) error {
+		addrs = append(addrs, dest.Address)
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, addrs, []netip.Addr{dests[0].Address, dests[1].Address}, cmp.Comparer(NetipAddrCompare))
+}
+
+func TestSortDestinations(t *testing.T) {
+	dests := []DestinationExtended{
+		{Destination: Destination{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6}},
+		{Destination: Destination{Address: netip.MustParseAddr("198.51.100.2"), Port: 80, Family: INET}},
+		{Destination: Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET}},
+		{Destination: Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 80, Family: INET}},
+	}
+
+	sortDestinations(dests)
+
+	var got []string
+	for _, dest := range dests {
+		got = append(got, netip.AddrPortFrom(dest.Address, dest.Port).String())
+	}
+	assert.DeepEqual(t, got, []string{"198.51.100.1:80", "198.51.100.1:8080", "198.51.100.2:80", "[2001:db8::1]:80"})
+}
@@ -0,0 +1,128 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// Snapshot is an image of the state of IPVS taken by Client.Snapshot. It
+// can be serialized, such as with encoding/json, to be restored later on
+// the same or another machine.
+type Snapshot struct {
+	// Time is when the snapshot was taken.
+	Time time.Time
+	// Table holds the services and their destinations.
+	Table TableConfig
+	// Timeouts are the connection timeouts.
+	Timeouts Timeouts
+	// Daemons are the running connection synchronization daemons.
+	Daemons []Daemon
+}
+
+// RestoreOptions configure Client.Restore.
+type RestoreOptions struct {
+	// ApplyOptions configure how the services and destinations are
+	// restored. Prune removes the services created after the snapshot.
+	ApplyOptions
+
+	// SkipTimeouts leaves the connection timeouts as they are.
+	SkipTimeouts bool
+	// SkipDaemons leaves the synchronization daemons as they are.
+	SkipDaemons bool
+}
+
+// Snapshot captures the services, destinations, timeouts and daemons of
+// IPVS. Their statistics and connections are not part of the snapshot.
+func (c *client) Snapshot(ctx context.Context) (Snapshot, error) {
+	return snapshot(ctx, c)
+}
+
+// snapshot implements Client.Snapshot over c.
+func snapshot(ctx context.Context, c Client) (Snapshot, error) {
+	snap := Snapshot{Time: time.Now()}
+
+	svcs, err := c.ServicesWithDestinations(ctx)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, err
+	}
+	snap.Table = TableConfigOf(svcs)
+
+	if snap.Timeouts, err = c.GetTimeouts(ctx); err != nil {
+		return Snapshot{}, err
+	}
+
+	if snap.Daemons, err = c.Daemons(ctx); err != nil {
+		return Snapshot{}, err
+	}
+
+	return snap, nil
+}
+
+// Restore reinstates snap: the services and destinations are restored with
+// Apply, the timeouts are set, and the daemons which differ from snap are
+// stopped before those of snap are started. The services restored are
+// reported like by Apply.
+func (c *client) Restore(ctx context.Context, snap Snapshot, opts RestoreOptions) (ApplyResult, error) {
+	return restore(ctx, c, snap, opts)
+}
+
+// restore implements Client.Restore over c.
+func restore(ctx context.Context, c Client, snap Snapshot, opts RestoreOptions) (ApplyResult, error) {
+	r, err := apply(ctx, c, snap.Table, opts.ApplyOptions)
+	if err != nil {
+		return r, err
+	}
+
+	if !opts.SkipTimeouts {
+		if err := c.SetTimeouts(ctx, snap.Timeouts); err != nil {
+			return r, err
+		}
+	}
+
+	if !opts.SkipDaemons {
+		if err := restoreDaemons(ctx, c, snap.Daemons); err != nil {
+			return r, err
+		}
+	}
+
+	return r, nil
+}
+
+// restoreDaemons makes the running daemons match daemons.
+func restoreDaemons(ctx context.Context, c Client, daemons []Daemon) error {
+	running, err := c.Daemons(ctx)
+	if err != nil {
+		return err
+	}
+
+	want := make(map[DaemonState]Daemon, len(daemons))
+	for _, d := range daemons {
+		want[d.State] = d
+	}
+
+	have := make(map[DaemonState]Daemon, len(running))
+	for _, d := range running {
+		if w, ok := want[d.State]; ok && w == d {
+			have[d.State] = d
+			continue
+		}
+
+		if err := c.StopDaemon(ctx, d.State); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range daemons {
+		if _, ok := have[d.State]; ok {
+			continue
+		}
+
+		if err := c.StartDaemon(ctx, d); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
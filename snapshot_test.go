@@ -0,0 +1,117 @@
+package ipvs
+
+import (
+	"context"
+	"encoding/json"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/ipvs/netmask"
+	"github.com/google/go-cmp/cmp"
+	"gotest.tools/v3/assert"
+)
+
+// snapshotClient is a tableClient which also keeps timeouts and daemons.
+type snapshotClient struct {
+	*tableClient
+	timeouts Timeouts
+	daemons  []Daemon
+}
+
+func (c *snapshotClient) GetTimeouts(context.Context) (Timeouts, error) {
+	return c.timeouts, nil
+}
+
+func (c *snapshotClient) SetTimeouts(_ context.Context, t Timeouts) error {
+	c.ops = append(c.ops, "set_timeouts")
+	c.timeouts = t
+	return nil
+}
+
+func (c *snapshotClient) Daemons(context.Context) ([]Daemon, error) {
+	return append([]Daemon(nil), c.daemons...), nil
+}
+
+func (c *snapshotClient) StartDaemon(_ context.Context, d Daemon) error {
+	c.ops = append(c.ops, "start_daemon "+d.State.String())
+	c.daemons = append(c.daemons, d)
+	return nil
+}
+
+func (c *snapshotClient) StopDaemon(_ context.Context, state DaemonState) error {
+	c.ops = append(c.ops, "stop_daemon "+state.String())
+	for i, d := range c.daemons {
+		if d.State == state {
+			c.daemons = append(c.daemons[:i], c.daemons[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestSnapshot(t *testing.T) {
+	web := Service{
+		Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin,
+		Flags: ServicePersistent, Timeout: 300, Netmask: netmask.MaskFrom(24, 32),
+	}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, FwdMethod: Masquerade, Weight: 1}
+	master := Daemon{State: DaemonMaster, MulticastInterface: "eth0", SyncID: 1, MulticastGroup: netip.MustParseAddr("224.0.0.81"), MulticastPort: 8848, MulticastTTL: 1}
+	backup := Daemon{State: DaemonBackup, MulticastInterface: "eth1"}
+
+	c := &snapshotClient{
+		tableClient: newTableClient(ServiceConfig{Service: web, Destinations: []Destination{dest}}),
+		timeouts:    Timeouts{TCP: 900 * time.Second, TCPFin: 120 * time.Second, UDP: 300 * time.Second},
+		daemons:     []Daemon{master},
+	}
+
+	snap, err := snapshot(context.Background(), c)
+	assert.NilError(t, err)
+	assert.Assert(t, !snap.Time.IsZero())
+
+	// Snapshots survive a round trip through JSON.
+	b, err := json.Marshal(snap)
+	assert.NilError(t, err)
+	var decoded Snapshot
+	assert.NilError(t, json.Unmarshal(b, &decoded))
+	assert.DeepEqual(t, decoded, snap,
+		cmp.Comparer(func(x, y netip.Addr) bool { return x == y }),
+		cmp.Comparer(netmask.Mask.Equal),
+		cmp.Comparer(func(x, y time.Time) bool { return x.Equal(y) }))
+
+	// Change everything, then restore the snapshot.
+	other := web
+	other.Port = 443
+	c.svcs = newTableClient(ServiceConfig{Service: other}).svcs
+	c.timeouts = Timeouts{TCP: time.Second}
+	c.daemons = []Daemon{{State: DaemonMaster, MulticastInterface: "eth9"}, backup}
+
+	r, err := restore(context.Background(), c, decoded, RestoreOptions{ApplyOptions: ApplyOptions{Prune: true}})
+	assert.NilError(t, err)
+	assert.Assert(t, r.Changed())
+	assert.DeepEqual(t, c.ops, []string{
+		"create_service TCP 192.0.2.1:80",
+		"create_destination TCP 192.0.2.1:80 198.51.100.1:8080",
+		"remove_service TCP 192.0.2.1:443",
+		"set_timeouts",
+		"stop_daemon DaemonMaster",
+		"stop_daemon DaemonBackup",
+		"start_daemon DaemonMaster",
+	})
+	assert.DeepEqual(t, c.daemons, []Daemon{master}, cmp.Comparer(func(x, y netip.Addr) bool { return x == y }))
+	assert.Equal(t, c.timeouts, snap.Timeouts)
+
+	// Restoring again only sets the timeouts.
+	c.ops = nil
+	r, err = restore(context.Background(), c, decoded, RestoreOptions{ApplyOptions: ApplyOptions{Prune: true}})
+	assert.NilError(t, err)
+	assert.Assert(t, !r.Changed())
+	assert.DeepEqual(t, c.ops, []string{"set_timeouts"})
+
+	// Skipped parts are left as is.
+	c.ops = nil
+	c.daemons = nil
+	_, err = restore(context.Background(), c, decoded, RestoreOptions{SkipTimeouts: true, SkipDaemons: true})
+	assert.NilError(t, err)
+	assert.Equal(t, len(c.ops), 0)
+}
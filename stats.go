@@ -0,0 +1,132 @@
+package ipvs
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseGlobalStats parses the totals and rates of IPVS in the format of
+// /proc/net/ip_vs_stats, where all values are hexadecimal.
+func parseGlobalStats(r io.Reader) (Stats, error) {
+	var rows [][5]uint64
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		row, ok := parseStatsRow(strings.Fields(s.Text()))
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	if len(rows) != 2 {
+		return Stats{}, fmt.Errorf("ipvs: parsing global stats: %d rows of values, want 2", len(rows))
+	}
+
+	return statsFromRows(rows[0], rows[1]), nil
+}
+
+// PerCPUStats holds the statistics of IPVS as a whole, broken down by CPU,
+// which shows imbalances in how packets are spread over the CPUs.
+type PerCPUStats struct {
+	// CPUs holds the counters of each CPU, indexed by CPU number. IPVS does
+	// not estimate rates per CPU.
+	CPUs []Stats
+	// Total holds the counters of all CPUs, and the rates.
+	Total Stats
+}
+
+// parsePerCPUStats parses the statistics of IPVS in the format of
+// /proc/net/ip_vs_stats_percpu, where all values are hexadecimal.
+func parsePerCPUStats(r io.Reader) (PerCPUStats, error) {
+	var stats PerCPUStats
+	var totals, rates *[5]uint64
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+
+		// The rates are not prefixed by a CPU.
+		if row, ok := parseStatsRow(fields); ok {
+			rates = &row
+			continue
+		}
+
+		if len(fields) != 6 {
+			continue
+		}
+
+		row, ok := parseStatsRow(fields[1:])
+		if !ok {
+			continue
+		}
+
+		if fields[0] == "~" {
+			totals = &row
+			continue
+		}
+
+		cpu, err := strconv.ParseUint(fields[0], 16, 16)
+		if err != nil {
+			continue
+		}
+
+		for len(stats.CPUs) <= int(cpu) {
+			stats.CPUs = append(stats.CPUs, Stats{})
+		}
+		stats.CPUs[cpu] = statsFromRows(row, [5]uint64{})
+	}
+
+	if err := s.Err(); err != nil {
+		return PerCPUStats{}, err
+	}
+
+	if totals == nil || rates == nil {
+		return PerCPUStats{}, errors.New("ipvs: parsing per-CPU stats: missing totals or rates")
+	}
+
+	stats.Total = statsFromRows(*totals, *rates)
+	return stats, nil
+}
+
+// parseStatsRow parses a row of 5 hexadecimal values, reporting false for
+// other rows, such as headers.
+func parseStatsRow(fields []string) ([5]uint64, bool) {
+	var row [5]uint64
+	if len(fields) != len(row) {
+		return row, false
+	}
+
+	for i, f := range fields {
+		v, err := strconv.ParseUint(f, 16, 64)
+		if err != nil {
+			return row, false
+		}
+		row[i] = v
+	}
+
+	return row, true
+}
+
+// statsFromRows returns the Stats of a row of totals and a row of rates.
+func statsFromRows(totals, rates [5]uint64) Stats {
+	return Stats{
+		Connections:        totals[0],
+		IncomingPackets:    totals[1],
+		OutgoingPackets:    totals[2],
+		IncomingBytes:      totals[3],
+		OutgoingBytes:      totals[4],
+		ConnectionRate:     rates[0],
+		IncomingPacketRate: rates[1],
+		OutgoingPacketRate: rates[2],
+		IncomingByteRate:   rates[3],
+		OutgoingByteRate:   rates[4],
+	}
+}
@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// GlobalStats returns the statistics of IPVS as a whole, over all Services.
+// IPVS does not expose them over netlink, so they are parsed from
+// /proc/net/ip_vs_stats, of the network namespace of c.
+func (c *client) GlobalStats(ctx context.Context) (Stats, error) {
+	if err := ctx.Err(); err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	err := inNetNS(c.opts, func() error {
+		var err error
+		stats, err = readGlobalStats(procNetDir)
+		return err
+	})
+	return stats, err
+}
+
+// readGlobalStats implements GlobalStats with the file in dir.
+func readGlobalStats(dir string) (Stats, error) {
+	f, err := os.Open(filepath.Join(dir, "ip_vs_stats"))
+	if err != nil {
+		return Stats{}, fmt.Errorf("ipvs: reading global stats: %w", err)
+	}
+	defer f.Close()
+
+	return parseGlobalStats(f)
+}
+
+// PerCPUStats returns the statistics of IPVS as a whole, broken down by CPU.
+// They are parsed from /proc/net/ip_vs_stats_percpu, of the network namespace
+// of c.
+func (c *client) PerCPUStats(ctx context.Context) (PerCPUStats, error) {
+	if err := ctx.Err(); err != nil {
+		return PerCPUStats{}, err
+	}
+
+	var stats PerCPUStats
+	err := inNetNS(c.opts, func() error {
+		var err error
+		stats, err = readPerCPUStats(procNetDir)
+		return err
+	})
+	return stats, err
+}
+
+// readPerCPUStats implements PerCPUStats with the file in dir.
+func readPerCPUStats(dir string) (PerCPUStats, error) {
+	f, err := os.Open(filepath.Join(dir, "ip_vs_stats_percpu"))
+	if err != nil {
+		return PerCPUStats{}, fmt.Errorf("ipvs: reading per-CPU stats: %w", err)
+	}
+	defer f.Close()
+
+	return parsePerCPUStats(f)
+}
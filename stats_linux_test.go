@@ -0,0 +1,47 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestReadGlobalStats(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "ip_vs_stats"), []byte(testGlobalStats), 0o644))
+
+	actual, err := readGlobalStats(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, actual.Connections, uint64(500))
+
+	_, err = readGlobalStats(t.TempDir())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestStats_NetNSNotExist(t *testing.T) {
+	c := &client{opts: options{netNSPath: "/var/run/netns/does-not-exist"}}
+
+	_, err := c.GlobalStats(context.Background())
+	assert.ErrorContains(t, err, "ipvs: opening network namespace")
+
+	_, err = c.PerCPUStats(context.Background())
+	assert.ErrorContains(t, err, "ipvs: opening network namespace")
+}
+
+func TestReadPerCPUStats(t *testing.T) {
+	dir := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(dir, "ip_vs_stats_percpu"), []byte(testPerCPUStats), 0o644))
+
+	actual, err := readPerCPUStats(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(actual.CPUs), 2)
+
+	_, err = readPerCPUStats(t.TempDir())
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
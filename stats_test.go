@@ -0,0 +1,68 @@
+package ipvs
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+const testGlobalStats = `   Total Incoming Outgoing         Incoming         Outgoing
+   Conns  Packets  Packets            Bytes            Bytes
+     1F4     9C40        0          3D09000                0
+
+ Conns/s   Pkts/s   Pkts/s          Bytes/s          Bytes/s
+       A      1F4        0            1E848                0
+`
+
+func TestParseGlobalStats(t *testing.T) {
+	actual, err := parseGlobalStats(strings.NewReader(testGlobalStats))
+	assert.NilError(t, err)
+	assert.Equal(t, actual, Stats{
+		Connections:        500,
+		IncomingPackets:    40000,
+		IncomingBytes:      64000000,
+		ConnectionRate:     10,
+		IncomingPacketRate: 500,
+		IncomingByteRate:   125000,
+	})
+}
+
+func TestParseGlobalStats_Truncated(t *testing.T) {
+	_, err := parseGlobalStats(strings.NewReader(testGlobalStats[:200]))
+	assert.ErrorContains(t, err, "1 rows of values, want 2")
+}
+
+const testPerCPUStats = `       Total Incoming Outgoing         Incoming         Outgoing
+CPU    Conns  Packets  Packets            Bytes            Bytes
+  0       64     2710        0           186A00                0
+  1      190     7530        0          3B82600                0
+  ~      1F4     9C40        0          3D09000                0
+
+     Conns/s   Pkts/s   Pkts/s          Bytes/s          Bytes/s
+           A      1F4        0            1E848                0
+`
+
+func TestParsePerCPUStats(t *testing.T) {
+	actual, err := parsePerCPUStats(strings.NewReader(testPerCPUStats))
+	assert.NilError(t, err)
+	assert.DeepEqual(t, actual, PerCPUStats{
+		CPUs: []Stats{
+			{Connections: 100, IncomingPackets: 10000, IncomingBytes: 1600000},
+			{Connections: 400, IncomingPackets: 30000, IncomingBytes: 62400000},
+		},
+		Total: Stats{
+			Connections:        500,
+			IncomingPackets:    40000,
+			IncomingBytes:      64000000,
+			ConnectionRate:     10,
+			IncomingPacketRate: 500,
+			IncomingByteRate:   125000,
+		},
+	})
+}
+
+func TestParsePerCPUStats_Truncated(t *testing.T) {
+	_, err := parsePerCPUStats(strings.NewReader(testPerCPUStats[:200]))
+	assert.ErrorContains(t, err, "missing totals or rates")
+}
@@ -0,0 +1,211 @@
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+	"time"
+)
+
+// StatsPoller polls the statistics of all Services and Destinations on an
+// interval, and computes the change of their counters between polls. It is a
+// building block for exporters, which may ship the increases instead of the
+// counters.
+//
+// A StatsPoller is not safe for concurrent use.
+type StatsPoller struct {
+	client   Client
+	interval time.Duration
+
+	prev     map[statsKey]Stats
+	prevTime time.Time
+}
+
+// StatsSnapshot is the result of a poll of a StatsPoller.
+type StatsSnapshot struct {
+	// Time is when the poll was made, with a monotonic clock reading.
+	Time time.Time
+	// Interval is the time since the previous successful poll, or zero for
+	// the first poll.
+	Interval time.Duration
+	// Services holds the statistics of each Service, and its Destinations.
+	Services []ServiceStatsDelta
+	// Err is set when the poll failed, in which case there are no Services.
+	Err error
+}
+
+// ServiceStatsDelta holds the statistics of a Service and its Destinations.
+type ServiceStatsDelta struct {
+	Service ServiceExtended
+	StatsDelta
+	Destinations []DestinationStatsDelta
+}
+
+// DestinationStatsDelta holds the statistics of a Destination.
+type DestinationStatsDelta struct {
+	Destination DestinationExtended
+	StatsDelta
+}
+
+// StatsDelta is the change of statistics between two polls.
+type StatsDelta struct {
+	// Delta holds the increase of the counters since the previous poll, and
+	// in the rate fields the rates per second derived from them. Delta is
+	// zero when the Service or Destination was not seen by the previous
+	// poll.
+	Delta Stats
+	// Reset reports whether a counter went backwards, such as when IPVS
+	// wrapped a 32-bit counter, or the Service was recreated between polls.
+	// The increase is then counted from zero.
+	Reset bool
+}
+
+// statsKey identifies a Service, or a Destination of a Service, across polls.
+type statsKey struct {
+	svc        ServiceKey
+	dest       netip.AddrPort
+	destFamily AddressFamily
+}
+
+// NewStatsPoller returns a StatsPoller polling c every interval.
+func NewStatsPoller(c Client, interval time.Duration) *StatsPoller {
+	return &StatsPoller{client: c, interval: interval}
+}
+
+// Run polls right away, and then every interval until ctx is done, calling
+// fn with each snapshot, including failed polls. It returns the error of ctx.
+func (p *StatsPoller) Run(ctx context.Context, fn func(StatsSnapshot)) error {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	for {
+		snapshot, err := p.Poll(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			snapshot = StatsSnapshot{Time: time.Now(), Err: err}
+		}
+
+		fn(snapshot)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Snapshots runs the StatsPoller in a goroutine, delivering its snapshots on
+// the returned channel, which is closed once ctx is done. Snapshots are sent
+// unbuffered, so a slow receiver delays the next poll.
+func (p *StatsPoller) Snapshots(ctx context.Context) <-chan StatsSnapshot {
+	ch := make(chan StatsSnapshot)
+
+	go func() {
+		defer close(ch)
+
+		p.Run(ctx, func(snapshot StatsSnapshot) {
+			select {
+			case ch <- snapshot:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return ch
+}
+
+// Poll takes a single snapshot, computing the change since the previous
+// successful poll.
+func (p *StatsPoller) Poll(ctx context.Context) (StatsSnapshot, error) {
+	svcs, err := p.client.ServicesWithDestinations(ctx)
+	if err != nil {
+		return StatsSnapshot{}, err
+	}
+
+	now := time.Now()
+	snapshot := StatsSnapshot{Time: now}
+	if !p.prevTime.IsZero() {
+		snapshot.Interval = now.Sub(p.prevTime)
+	}
+
+	stats := make(map[statsKey]Stats, len(p.prev))
+	delta := func(key statsKey, cur Stats) StatsDelta {
+		stats[key] = cur
+
+		prev, ok := p.prev[key]
+		if !ok {
+			return StatsDelta{}
+		}
+
+		return statsDelta(prev, cur, snapshot.Interval)
+	}
+
+	snapshot.Services = make([]ServiceStatsDelta, 0, len(svcs))
+	for _, svc := range svcs {
+		key := statsKey{svc: svc.Key()}
+		s := ServiceStatsDelta{
+			Service:      svc.ServiceExtended,
+			StatsDelta:   delta(key, svc.Stats),
+			Destinations: make([]DestinationStatsDelta, 0, len(svc.Destinations)),
+		}
+
+		for _, dest := range svc.Destinations {
+			key.dest = netip.AddrPortFrom(dest.Address, dest.Port)
+			key.destFamily = dest.Family
+			s.Destinations = append(s.Destinations, DestinationStatsDelta{
+				Destination: dest,
+				StatsDelta:  delta(key, dest.Stats),
+			})
+		}
+
+		snapshot.Services = append(snapshot.Services, s)
+	}
+
+	p.prev, p.prevTime = stats, now
+	return snapshot, nil
+}
+
+// statsDelta computes the change from prev to cur over interval.
+func statsDelta(prev, cur Stats, interval time.Duration) StatsDelta {
+	var d StatsDelta
+	sub := func(prev, cur uint64) uint64 {
+		if cur < prev {
+			d.Reset = true
+		}
+		return cur - prev
+	}
+
+	d.Delta = Stats{
+		Connections:     sub(prev.Connections, cur.Connections),
+		IncomingPackets: sub(prev.IncomingPackets, cur.IncomingPackets),
+		OutgoingPackets: sub(prev.OutgoingPackets, cur.OutgoingPackets),
+		IncomingBytes:   sub(prev.IncomingBytes, cur.IncomingBytes),
+		OutgoingBytes:   sub(prev.OutgoingBytes, cur.OutgoingBytes),
+	}
+
+	if d.Reset {
+		d.Delta = Stats{
+			Connections:     cur.Connections,
+			IncomingPackets: cur.IncomingPackets,
+			OutgoingPackets: cur.OutgoingPackets,
+			IncomingBytes:   cur.IncomingBytes,
+			OutgoingBytes:   cur.OutgoingBytes,
+		}
+	}
+
+	if seconds := interval.Seconds(); seconds > 0 {
+		rate := func(v uint64) uint64 {
+			return uint64(float64(v) / seconds)
+		}
+
+		d.Delta.ConnectionRate = rate(d.Delta.Connections)
+		d.Delta.IncomingPacketRate = rate(d.Delta.IncomingPackets)
+		d.Delta.OutgoingPacketRate = rate(d.Delta.OutgoingPackets)
+		d.Delta.IncomingByteRate = rate(d.Delta.IncomingBytes)
+		d.Delta.OutgoingByteRate = rate(d.Delta.OutgoingBytes)
+	}
+
+	return d
+}
@@ -0,0 +1,150 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// statsClient returns a scripted list of services through a Client. With
+// notExist, empty tables fail with os.ErrNotExist, like the kernel does.
+type statsClient struct {
+	Client
+	polls    [][]ServiceWithDestinations
+	notExist bool
+}
+
+func (c *statsClient) ServicesWithDestinations(context.Context, ...ListOption) ([]ServiceWithDestinations, error) {
+	if len(c.polls) == 0 {
+		return nil, errors.New("no more polls")
+	}
+
+	svcs := c.polls[0]
+	c.polls = c.polls[1:]
+	if len(svcs) == 0 && c.notExist {
+		return nil, os.ErrNotExist
+	}
+	return svcs, nil
+}
+
+func TestStatsDelta(t *testing.T) {
+	type testCase struct {
+		name     string
+		prev     Stats
+		cur      Stats
+		interval time.Duration
+		expected StatsDelta
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		assert.DeepEqual(t, statsDelta(tc.prev, tc.cur, tc.interval), tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "increase",
+			prev:     Stats{Connections: 10, IncomingPackets: 100, OutgoingPackets: 50, IncomingBytes: 1000, OutgoingBytes: 500, ConnectionRate: 7},
+			cur:      Stats{Connections: 30, IncomingPackets: 300, OutgoingPackets: 50, IncomingBytes: 5000, OutgoingBytes: 900, ConnectionRate: 9},
+			interval: 2 * time.Second,
+			expected: StatsDelta{Delta: Stats{
+				Connections: 20, IncomingPackets: 200, OutgoingPackets: 0, IncomingBytes: 4000, OutgoingBytes: 400,
+				ConnectionRate: 10, IncomingPacketRate: 100, OutgoingPacketRate: 0, IncomingByteRate: 2000, OutgoingByteRate: 200,
+			}},
+		},
+		{
+			name:     "no interval",
+			prev:     Stats{Connections: 10},
+			cur:      Stats{Connections: 30},
+			expected: StatsDelta{Delta: Stats{Connections: 20}},
+		},
+		{
+			name:     "reset",
+			prev:     Stats{Connections: 10, IncomingBytes: 1000},
+			cur:      Stats{Connections: 4, IncomingBytes: 2000},
+			interval: time.Second,
+			expected: StatsDelta{
+				Delta: Stats{Connections: 4, IncomingBytes: 2000, ConnectionRate: 4, IncomingByteRate: 2000},
+				Reset: true,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestStatsPoller_Poll(t *testing.T) {
+	svc := ServiceExtended{Service: Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP}}
+	dest := DestinationExtended{Destination: Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET}}
+	other := DestinationExtended{Destination: Destination{Address: netip.MustParseAddr("198.51.100.2"), Port: 8080, Family: INET}}
+
+	poll := func(svcStats, destStats Stats, dests ...DestinationExtended) []ServiceWithDestinations {
+		svc := svc
+		svc.Stats = svcStats
+
+		var ds []DestinationExtended
+		for _, d := range dests {
+			d.Stats = destStats
+			ds = append(ds, d)
+		}
+
+		return []ServiceWithDestinations{{ServiceExtended: svc, Destinations: ds}}
+	}
+
+	c := &statsClient{polls: [][]ServiceWithDestinations{
+		poll(Stats{Connections: 10}, Stats{Connections: 10}, dest),
+		poll(Stats{Connections: 15}, Stats{Connections: 5}, dest, other),
+	}}
+	p := NewStatsPoller(c, time.Second)
+
+	first, err := p.Poll(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, first.Interval, time.Duration(0))
+	assert.Equal(t, len(first.Services), 1)
+	assert.DeepEqual(t, first.Services[0].StatsDelta, StatsDelta{})
+	assert.DeepEqual(t, first.Services[0].Destinations[0].StatsDelta, StatsDelta{})
+
+	second, err := p.Poll(context.Background())
+	assert.NilError(t, err)
+	assert.Assert(t, second.Interval > 0)
+	assert.Equal(t, second.Services[0].Delta.Connections, uint64(5))
+	assert.Equal(t, second.Services[0].Reset, false)
+
+	dests := second.Services[0].Destinations
+	assert.Equal(t, len(dests), 2)
+	assert.Equal(t, dests[0].Delta.Connections, uint64(5))
+	assert.Equal(t, dests[0].Reset, true)
+	assert.DeepEqual(t, dests[1].StatsDelta, StatsDelta{})
+
+	_, err = p.Poll(context.Background())
+	assert.ErrorContains(t, err, "no more polls")
+}
+
+func TestStatsPoller_Snapshots(t *testing.T) {
+	c := &statsClient{polls: [][]ServiceWithDestinations{{}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := NewStatsPoller(c, time.Millisecond).Snapshots(ctx)
+
+	snapshot := <-ch
+	assert.NilError(t, snapshot.Err)
+	assert.Equal(t, len(snapshot.Services), 0)
+
+	snapshot = <-ch
+	assert.ErrorContains(t, snapshot.Err, "no more polls")
+
+	cancel()
+	for range ch {
+	}
+}
@@ -0,0 +1,60 @@
+package ipvs
+
+import (
+	"fmt"
+	"time"
+)
+
+// SyncConfig holds the sysctls of net.ipv4.vs that tune the connection
+// synchronization between daemons. They should be configured alike on the
+// master and backups, before starting the daemons.
+type SyncConfig struct {
+	// Version is the protocol version of the synchronization messages, where
+	// 0 is understood by kernels before Linux 2.6.39 and 1 is the default.
+	Version int
+	// Threshold and Period control how often a connection is synchronized:
+	// after Threshold packets, and then every Period packets. A zero Period
+	// synchronizes only on state changes.
+	Threshold int
+	Period    int
+	// RefreshPeriod resynchronizes long-lived connections after this long,
+	// in whole seconds. Zero disables it.
+	RefreshPeriod time.Duration
+	// Retries is the number of times, from 0 to 3, that changes to a
+	// connection are resent when RefreshPeriod is set.
+	Retries int
+	// QueueLenMax limits the synchronization messages queued for sending.
+	QueueLenMax int
+	// SocketSize sets the send buffer size of the master, and receive buffer
+	// size of the backup, in bytes. Zero keeps the system default.
+	SocketSize int
+	// Ports is the number of threads and ports used for synchronization, a
+	// power of two.
+	Ports int
+}
+
+// validate checks that IPVS accepts the sysctls of cfg.
+func (cfg SyncConfig) validate() error {
+	switch {
+	case cfg.Version != 0 && cfg.Version != 1:
+		return fmt.Errorf("ipvs: unknown sync version %d", cfg.Version)
+	case cfg.Threshold < 0 || cfg.Period < 0:
+		return fmt.Errorf("ipvs: sync threshold %d and period %d are negative", cfg.Threshold, cfg.Period)
+	case cfg.Period != 0 && cfg.Threshold >= cfg.Period:
+		return fmt.Errorf("ipvs: sync threshold %d is not below period %d", cfg.Threshold, cfg.Period)
+	case cfg.Retries < 0 || cfg.Retries > 3:
+		return fmt.Errorf("ipvs: sync retries %d is not between 0 and 3", cfg.Retries)
+	case cfg.QueueLenMax < 1:
+		return fmt.Errorf("ipvs: sync queue length %d is not positive", cfg.QueueLenMax)
+	case cfg.SocketSize < 0:
+		return fmt.Errorf("ipvs: sync socket size %d is negative", cfg.SocketSize)
+	case cfg.Ports < 1 || cfg.Ports&(cfg.Ports-1) != 0:
+		return fmt.Errorf("ipvs: sync ports %d is not a power of two", cfg.Ports)
+	}
+
+	if _, err := timeoutSeconds("sync refresh", cfg.RefreshPeriod); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sysctlDir is the directory of the net.ipv4.vs sysctls.
+const sysctlDir = "/proc/sys/net/ipv4/vs"
+
+// SyncConfig reads the synchronization sysctls. The sysctls are those of the
+// network namespace of c, and exist once IPVS is loaded.
+func (c *client) SyncConfig(ctx context.Context) (SyncConfig, error) {
+	if err := ctx.Err(); err != nil {
+		return SyncConfig{}, err
+	}
+
+	var cfg SyncConfig
+	err := inNetNS(c.opts, func() error {
+		var err error
+		cfg, err = readSyncConfig(sysctlDir)
+		return err
+	})
+	return cfg, err
+}
+
+// SetSyncConfig writes all synchronization sysctls, so changing some of them
+// starts from the values read by SyncConfig. The sysctls are those of the
+// network namespace of c. Dry runs only validate cfg.
+func (c *client) SetSyncConfig(ctx context.Context, cfg SyncConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if isDryRun(ctx, c.dryRun) {
+		return nil
+	}
+
+	return inNetNS(c.opts, func() error {
+		return writeSyncConfig(sysctlDir, cfg)
+	})
+}
+
+// readSyncConfig implements SyncConfig with the sysctls in dir.
+func readSyncConfig(dir string) (SyncConfig, error) {
+	var cfg SyncConfig
+	var refresh int
+
+	for _, s := range []struct {
+		name   string
+		values []*int
+	}{
+		{"sync_version", []*int{&cfg.Version}},
+		{"sync_threshold", []*int{&cfg.Threshold, &cfg.Period}},
+		{"sync_refresh_period", []*int{&refresh}},
+		{"sync_retries", []*int{&cfg.Retries}},
+		{"sync_qlen_max", []*int{&cfg.QueueLenMax}},
+		{"sync_sock_size", []*int{&cfg.SocketSize}},
+		{"sync_ports", []*int{&cfg.Ports}},
+	} {
+		if err := readSysctl(dir, s.name, s.values...); err != nil {
+			return SyncConfig{}, err
+		}
+	}
+
+	cfg.RefreshPeriod = time.Duration(refresh) * time.Second
+	return cfg, nil
+}
+
+// writeSyncConfig implements SetSyncConfig with the sysctls in dir.
+func writeSyncConfig(dir string, cfg SyncConfig) error {
+	for _, s := range []struct {
+		name   string
+		values []int
+	}{
+		{"sync_version", []int{cfg.Version}},
+		{"sync_threshold", []int{cfg.Threshold, cfg.Period}},
+		{"sync_refresh_period", []int{int(cfg.RefreshPeriod / time.Second)}},
+		{"sync_retries", []int{cfg.Retries}},
+		{"sync_qlen_max", []int{cfg.QueueLenMax}},
+		{"sync_sock_size", []int{cfg.SocketSize}},
+		{"sync_ports", []int{cfg.Ports}},
+	} {
+		if err := writeSysctl(dir, s.name, s.values...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readSysctl parses the whitespace separated integers of the sysctl name in
+// dir into values.
+func readSysctl(dir, name string, values ...*int) error {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("ipvs: reading sysctl %s: %w", name, err)
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) != len(values) {
+		return fmt.Errorf("ipvs: sysctl %s has %d values, want %d", name, len(fields), len(values))
+	}
+
+	for i, f := range fields {
+		if *values[i], err = strconv.Atoi(f); err != nil {
+			return fmt.Errorf("ipvs: parsing sysctl %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// writeSysctl writes values, separated by spaces, to the sysctl name in dir.
+func writeSysctl(dir, name string, values ...int) error {
+	fields := make([]string, len(values))
+	for i, v := range values {
+		fields[i] = strconv.Itoa(v)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_TRUNC, 0)
+	if err == nil {
+		_, err = f.WriteString(strings.Join(fields, " ") + "\n")
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("ipvs: writing sysctl %s: %w", name, err)
+	}
+
+	return nil
+}
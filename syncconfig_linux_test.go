@@ -0,0 +1,100 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func writeSysctlFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		assert.NilError(t, err)
+	}
+
+	return dir
+}
+
+func TestReadSyncConfig(t *testing.T) {
+	dir := writeSysctlFiles(t, map[string]string{
+		"sync_version":        "1\n",
+		"sync_threshold":      "3\t50\n",
+		"sync_refresh_period": "0\n",
+		"sync_retries":        "0\n",
+		"sync_qlen_max":       "31672\n",
+		"sync_sock_size":      "0\n",
+		"sync_ports":          "1\n",
+	})
+
+	actual, err := readSyncConfig(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, actual, SyncConfig{Version: 1, Threshold: 3, Period: 50, QueueLenMax: 31672, Ports: 1})
+}
+
+func TestReadSyncConfig_Errors(t *testing.T) {
+	dir := writeSysctlFiles(t, map[string]string{
+		"sync_version": "1\n",
+	})
+	_, err := readSyncConfig(dir)
+	assert.ErrorContains(t, err, "reading sysctl sync_threshold")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	dir = writeSysctlFiles(t, map[string]string{
+		"sync_version":   "1\n",
+		"sync_threshold": "3\n",
+	})
+	_, err = readSyncConfig(dir)
+	assert.ErrorContains(t, err, "sysctl sync_threshold has 1 values, want 2")
+}
+
+func TestSyncConfig_NetNSNotExist(t *testing.T) {
+	c := &client{opts: options{netNSPath: "/var/run/netns/does-not-exist"}}
+
+	_, err := c.SyncConfig(context.Background())
+	assert.ErrorContains(t, err, "ipvs: opening network namespace")
+
+	err = c.SetSyncConfig(context.Background(), SyncConfig{Version: 1, Threshold: 3, Period: 50, QueueLenMax: 8192, Ports: 1})
+	assert.ErrorContains(t, err, "ipvs: opening network namespace")
+}
+
+func TestWriteSyncConfig(t *testing.T) {
+	names := []string{
+		"sync_version", "sync_threshold", "sync_refresh_period", "sync_retries",
+		"sync_qlen_max", "sync_sock_size", "sync_ports",
+	}
+	files := map[string]string{}
+	for _, name := range names {
+		files[name] = "garbage that is longer than the values\n"
+	}
+	dir := writeSysctlFiles(t, files)
+
+	cfg := SyncConfig{
+		Version:       0,
+		Threshold:     2,
+		Period:        100,
+		RefreshPeriod: 10 * time.Minute,
+		Retries:       2,
+		QueueLenMax:   4096,
+		SocketSize:    1 << 20,
+		Ports:         4,
+	}
+	assert.NilError(t, writeSyncConfig(dir, cfg))
+
+	b, err := os.ReadFile(filepath.Join(dir, "sync_threshold"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "2 100\n")
+
+	actual, err := readSyncConfig(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, actual, cfg)
+}
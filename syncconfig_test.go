@@ -0,0 +1,78 @@
+package ipvs
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestSyncConfig_Validate(t *testing.T) {
+	type testCase struct {
+		name     string
+		modify   func(cfg *SyncConfig)
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		cfg := SyncConfig{Version: 1, Threshold: 3, Period: 50, QueueLenMax: 8192, Ports: 1}
+		if tc.modify != nil {
+			tc.modify(&cfg)
+		}
+
+		err := cfg.validate()
+		if tc.expected == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "defaults"},
+		{name: "state changes only", modify: func(cfg *SyncConfig) { cfg.Period = 0 }},
+		{name: "refresh", modify: func(cfg *SyncConfig) { cfg.RefreshPeriod = time.Minute; cfg.Retries = 3 }},
+		{
+			name:     "unknown version",
+			modify:   func(cfg *SyncConfig) { cfg.Version = 2 },
+			expected: "unknown sync version 2",
+		},
+		{
+			name:     "threshold above period",
+			modify:   func(cfg *SyncConfig) { cfg.Threshold = 50 },
+			expected: "sync threshold 50 is not below period 50",
+		},
+		{
+			name:     "negative period",
+			modify:   func(cfg *SyncConfig) { cfg.Period = -1 },
+			expected: "are negative",
+		},
+		{
+			name:     "retries",
+			modify:   func(cfg *SyncConfig) { cfg.Retries = 4 },
+			expected: "sync retries 4",
+		},
+		{
+			name:     "queue length",
+			modify:   func(cfg *SyncConfig) { cfg.QueueLenMax = 0 },
+			expected: "sync queue length 0",
+		},
+		{
+			name:     "ports",
+			modify:   func(cfg *SyncConfig) { cfg.Ports = 3 },
+			expected: "sync ports 3 is not a power of two",
+		},
+		{
+			name:     "fractional refresh",
+			modify:   func(cfg *SyncConfig) { cfg.RefreshPeriod = 1500 * time.Millisecond },
+			expected: "not a whole number of seconds",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
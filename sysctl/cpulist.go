@@ -0,0 +1,78 @@
+package sysctl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseCPUList parses a list of CPUs in the kernel's format, such as "0-3,8",
+// returning the CPUs in ascending order without duplicates.
+func ParseCPUList(s string) ([]int, error) {
+	cpus := []int{}
+	seen := map[int]bool{}
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return cpus, nil
+	}
+
+	for _, r := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(r, "-")
+
+		lo, err := strconv.Atoi(first)
+		if err != nil || lo < 0 {
+			return nil, fmt.Errorf("invalid CPU %q", first)
+		}
+
+		hi := lo
+		if isRange {
+			if hi, err = strconv.Atoi(last); err != nil || hi < lo {
+				return nil, fmt.Errorf("invalid CPU range %q", r)
+			}
+		}
+
+		for cpu := lo; cpu <= hi; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+
+	sort.Ints(cpus)
+	return cpus, nil
+}
+
+// FormatCPUList formats cpus in the kernel's list format, collapsing
+// consecutive CPUs into ranges, such as "0-3,8".
+func FormatCPUList(cpus []int) (string, error) {
+	sorted := append([]int(nil), cpus...)
+	sort.Ints(sorted)
+
+	var b strings.Builder
+	for i := 0; i < len(sorted); {
+		if sorted[i] < 0 {
+			return "", fmt.Errorf("invalid CPU %d", sorted[i])
+		}
+
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(sorted[i]))
+		if sorted[j] != sorted[i] {
+			b.WriteByte('-')
+			b.WriteString(strconv.Itoa(sorted[j]))
+		}
+
+		i = j + 1
+	}
+
+	return b.String(), nil
+}
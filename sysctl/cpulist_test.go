@@ -0,0 +1,78 @@
+package sysctl
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseCPUList(t *testing.T) {
+	type testCase struct {
+		in       string
+		expected []int
+		err      string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		cpus, err := ParseCPUList(tc.in)
+		if tc.err != "" {
+			assert.ErrorContains(t, err, tc.err)
+			return
+		}
+		assert.NilError(t, err)
+		assert.DeepEqual(t, cpus, tc.expected)
+	}
+
+	testCases := []testCase{
+		{in: "", expected: []int{}},
+		{in: "\n", expected: []int{}},
+		{in: "0", expected: []int{0}},
+		{in: "0-3,8", expected: []int{0, 1, 2, 3, 8}},
+		{in: "8,0-1,1", expected: []int{0, 1, 8}},
+		{in: "a", err: `invalid CPU "a"`},
+		{in: "3-1", err: `invalid CPU range "3-1"`},
+		{in: "0,", err: `invalid CPU ""`},
+		{in: "-1", err: `invalid CPU ""`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.in, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	type testCase struct {
+		name     string
+		cpus     []int
+		expected string
+		err      string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		s, err := FormatCPUList(tc.cpus)
+		if tc.err != "" {
+			assert.ErrorContains(t, err, tc.err)
+			return
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, s, tc.expected)
+	}
+
+	testCases := []testCase{
+		{name: "none", cpus: nil, expected: ""},
+		{name: "single", cpus: []int{2}, expected: "2"},
+		{name: "ranges", cpus: []int{0, 1, 2, 3, 8, 10, 11}, expected: "0-3,8,10-11"},
+		{name: "unsorted duplicates", cpus: []int{3, 1, 2, 2}, expected: "1-3"},
+		{name: "negative", cpus: []int{-1}, err: "invalid CPU -1"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
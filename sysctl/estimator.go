@@ -0,0 +1,149 @@
+package sysctl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Estimator configures the kernel threads estimating the rates of the IPVS
+// statistics, which require Linux 6.2 or later. On large installations the
+// estimation can take a noticeable share of CPU time, which can be kept off
+// latency-critical CPUs.
+type Estimator struct {
+	// CPUs the estimator threads run on. No CPUs stops the estimation of
+	// rates.
+	CPUs []int
+	// Nice is the niceness of the estimator threads, from -20 to 19.
+	Nice int
+}
+
+// Estimator reads the configuration of the estimator threads.
+func (s *Sysctls) Estimator() (Estimator, error) {
+	cpus, err := s.EstimatorCPUList()
+	if err != nil {
+		return Estimator{}, err
+	}
+
+	nice, err := s.EstimatorNice()
+	if err != nil {
+		return Estimator{}, err
+	}
+
+	return Estimator{CPUs: cpus, Nice: nice}, nil
+}
+
+// SetEstimator configures the estimator threads. The niceness is set first,
+// so that threads started for new CPUs already run with it.
+func (s *Sysctls) SetEstimator(e Estimator) error {
+	if err := s.SetEstimatorNice(e.Nice); err != nil {
+		return err
+	}
+
+	return s.SetEstimatorCPUList(e.CPUs)
+}
+
+// EstimatorThread is a running estimator kernel thread.
+type EstimatorThread struct {
+	PID int
+	// Name is the name of the thread, such as "ipvs-e:0:1".
+	Name string
+	// CPU is the CPU the thread last ran on.
+	CPU int
+	// Time is the CPU time the thread has used, whose increase between two
+	// calls to EstimatorThreads is the load of the estimation.
+	Time time.Duration
+}
+
+// EstimatorThreads returns the estimator threads of all network namespaces,
+// ordered by PID, from /proc.
+func EstimatorThreads() ([]EstimatorThread, error) {
+	return estimatorThreads("/proc")
+}
+
+// userHZ is the unit of the CPU times in /proc, which is fixed to 100 on all
+// architectures supported by Go.
+const userHZ = 100
+
+// estimatorThreads implements EstimatorThreads with the processes in proc.
+func estimatorThreads(proc string) ([]EstimatorThread, error) {
+	entries, err := os.ReadDir(proc)
+	if err != nil {
+		return nil, fmt.Errorf("sysctl: listing processes: %w", err)
+	}
+
+	threads := []EstimatorThread{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(proc, entry.Name(), "stat"))
+		// The process may have exited since listing.
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("sysctl: reading process %d: %w", pid, err)
+		}
+
+		t, ok, err := parseEstimatorStat(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("sysctl: parsing process %d: %w", pid, err)
+		}
+
+		if ok {
+			t.PID = pid
+			threads = append(threads, t)
+		}
+	}
+
+	sort.Slice(threads, func(i, j int) bool { return threads[i].PID < threads[j].PID })
+	return threads, nil
+}
+
+// parseEstimatorStat parses the /proc/<pid>/stat file of a process, reporting
+// whether it is an estimator thread.
+func parseEstimatorStat(stat string) (EstimatorThread, bool, error) {
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return EstimatorThread{}, false, errors.New("missing command name")
+	}
+
+	name := stat[open+1 : end]
+	if !strings.HasPrefix(name, "ipvs-e:") {
+		return EstimatorThread{}, false, nil
+	}
+
+	// Fields start at the state, which is the third field of the file.
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 37 {
+		return EstimatorThread{}, false, fmt.Errorf("%d fields, want at least 39", len(fields)+2)
+	}
+
+	var ticks [2]uint64
+	for i, f := range fields[11:13] {
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return EstimatorThread{}, false, err
+		}
+		ticks[i] = v
+	}
+
+	cpu, err := strconv.Atoi(fields[36])
+	if err != nil {
+		return EstimatorThread{}, false, err
+	}
+
+	return EstimatorThread{
+		Name: name,
+		CPU:  cpu,
+		Time: time.Duration(ticks[0]+ticks[1]) * time.Second / userHZ,
+	}, true, nil
+}
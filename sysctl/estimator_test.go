@@ -0,0 +1,67 @@
+package sysctl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// testStat returns a /proc/<pid>/stat file of a kernel thread.
+func testStat(pid int, name string, utime, stime, cpu int) string {
+	return fmt.Sprintf("%d (%s) S 2 0 0 0 -1 2129984 0 0 0 0 %d %d 0 0 20 0 1 0 123 0 0 "+
+		"18446744073709551615 0 0 0 0 0 0 0 2147483647 0 0 0 0 17 %d 0 0 0 0 0 0 0 0 0 0 0 0 0\n",
+		pid, name, utime, stime, cpu)
+}
+
+func TestSysctls_SetEstimator(t *testing.T) {
+	s, dir := testSysctls(t, map[string]string{
+		"est_nice":    "0\n",
+		"est_cpulist": "0-7\n",
+	})
+
+	assert.NilError(t, s.SetEstimator(Estimator{CPUs: []int{0, 1}, Nice: 10}))
+
+	e, err := s.Estimator()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, e, Estimator{CPUs: []int{0, 1}, Nice: 10})
+
+	b, err := os.ReadFile(filepath.Join(dir, "est_cpulist"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "0-1\n")
+}
+
+func TestEstimatorThreads(t *testing.T) {
+	proc := t.TempDir()
+	for pid, stat := range map[int]string{
+		1:    "1 (systemd) S 0 1 1 0 -1 4194560 0 0 0 0 500 300 0 0 20 0 1 0 1 0 0\n",
+		812:  testStat(812, "ipvs-e:0:1", 120, 30, 3),
+		811:  testStat(811, "ipvs-e:0:0", 250, 50, 0),
+		900:  testStat(900, "kworker/0:1 (x)", 1, 1, 0),
+		1000: testStat(1000, "ipvs-e:1:0", 0, 0, 1),
+	} {
+		assert.NilError(t, os.MkdirAll(filepath.Join(proc, fmt.Sprint(pid)), 0o755))
+		assert.NilError(t, os.WriteFile(filepath.Join(proc, fmt.Sprint(pid), "stat"), []byte(stat), 0o644))
+	}
+	assert.NilError(t, os.WriteFile(filepath.Join(proc, "uptime"), []byte("1.00 1.00\n"), 0o644))
+	assert.NilError(t, os.MkdirAll(filepath.Join(proc, "1234"), 0o755))
+
+	threads, err := estimatorThreads(proc)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, threads, []EstimatorThread{
+		{PID: 811, Name: "ipvs-e:0:0", CPU: 0, Time: 3 * time.Second},
+		{PID: 812, Name: "ipvs-e:0:1", CPU: 3, Time: 1500 * time.Millisecond},
+		{PID: 1000, Name: "ipvs-e:1:0", CPU: 1},
+	})
+}
+
+func TestParseEstimatorStat_Errors(t *testing.T) {
+	_, _, err := parseEstimatorStat("42 ipvs-e:0:0 S")
+	assert.ErrorContains(t, err, "missing command name")
+
+	_, _, err = parseEstimatorStat("42 (ipvs-e:0:0) S 2 0")
+	assert.ErrorContains(t, err, "5 fields, want at least 39")
+}
@@ -0,0 +1,260 @@
+// Package sysctl reads and writes the net.ipv4.vs sysctls, which tune the
+// behavior of IPVS beyond the configuration exposed over netlink.
+//
+// The sysctls exist once the ip_vs kernel module is loaded, and are kept per
+// network namespace.
+package sysctl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cloudflare/ipvs/internal/netns"
+)
+
+// DefaultRoot is the directory of the net.ipv4.vs sysctls.
+const DefaultRoot = "/proc/sys/net/ipv4/vs"
+
+// Sysctls accesses the IPVS sysctls of a network namespace. It is safe for
+// concurrent use by multiple goroutines.
+type Sysctls struct {
+	root      string
+	netNSPath string
+	netNSFD   int
+}
+
+// Option configures Sysctls created by New.
+type Option func(*Sysctls)
+
+// WithNetNSPath accesses the sysctls of the network namespace at path, such
+// as "/var/run/netns/blue" or "/proc/1234/ns/net", instead of the namespace of
+// the calling thread. This requires the CAP_SYS_ADMIN capability.
+func WithNetNSPath(path string) Option {
+	return func(s *Sysctls) {
+		s.netNSPath = path
+	}
+}
+
+// WithNetNSFD accesses the sysctls of the network namespace referred to by
+// the open file descriptor fd, which is not closed.
+func WithNetNSFD(fd int) Option {
+	return func(s *Sysctls) {
+		s.netNSFD = fd
+	}
+}
+
+// WithRoot reads and writes the sysctls in dir instead of DefaultRoot.
+func WithRoot(dir string) Option {
+	return func(s *Sysctls) {
+		s.root = dir
+	}
+}
+
+// New returns Sysctls configured by opts, accessing the sysctls of the
+// network namespace of the calling thread by default.
+func New(opts ...Option) *Sysctls {
+	s := &Sysctls{root: DefaultRoot}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Conntrack reports whether IPVS records its connections in netfilter
+// conntrack, which is needed to combine IPVS with netfilter NAT or stateful
+// firewall rules.
+func (s *Sysctls) Conntrack() (bool, error) {
+	return s.bool("conntrack")
+}
+
+// SetConntrack sets whether IPVS records its connections in conntrack.
+func (s *Sysctls) SetConntrack(v bool) error {
+	return s.setBool("conntrack", v)
+}
+
+// ExpireNoDestConn reports whether the connections of removed destinations
+// are expired immediately, instead of dropping their packets until they time
+// out.
+func (s *Sysctls) ExpireNoDestConn() (bool, error) {
+	return s.bool("expire_nodest_conn")
+}
+
+// SetExpireNoDestConn sets whether the connections of removed destinations
+// are expired immediately.
+func (s *Sysctls) SetExpireNoDestConn(v bool) error {
+	return s.setBool("expire_nodest_conn", v)
+}
+
+// ExpireQuiescentTemplate reports whether the persistence templates of
+// destinations with a zero weight are expired, so that persistent clients
+// move to other destinations.
+func (s *Sysctls) ExpireQuiescentTemplate() (bool, error) {
+	return s.bool("expire_quiescent_template")
+}
+
+// SetExpireQuiescentTemplate sets whether the persistence templates of
+// destinations with a zero weight are expired.
+func (s *Sysctls) SetExpireQuiescentTemplate(v bool) error {
+	return s.setBool("expire_quiescent_template", v)
+}
+
+// SloppyTCP reports whether IPVS creates connections for any TCP packet, not
+// only SYN packets, which allows failing over between directors.
+func (s *Sysctls) SloppyTCP() (bool, error) {
+	return s.bool("sloppy_tcp")
+}
+
+// SetSloppyTCP sets whether IPVS creates connections for any TCP packet.
+func (s *Sysctls) SetSloppyTCP(v bool) error {
+	return s.setBool("sloppy_tcp", v)
+}
+
+// SloppySCTP reports whether IPVS creates connections for any SCTP packet,
+// not only INIT packets.
+func (s *Sysctls) SloppySCTP() (bool, error) {
+	return s.bool("sloppy_sctp")
+}
+
+// SetSloppySCTP sets whether IPVS creates connections for any SCTP packet.
+func (s *Sysctls) SetSloppySCTP(v bool) error {
+	return s.setBool("sloppy_sctp", v)
+}
+
+// IgnoreTunneled reports whether IPVS ignores packets that were received
+// through a tunnel, so that they can be handled by another director.
+func (s *Sysctls) IgnoreTunneled() (bool, error) {
+	return s.bool("ignore_tunneled")
+}
+
+// SetIgnoreTunneled sets whether IPVS ignores tunneled packets.
+func (s *Sysctls) SetIgnoreTunneled(v bool) error {
+	return s.setBool("ignore_tunneled", v)
+}
+
+// ScheduleICMP reports whether IPVS schedules ICMP packets, which lets path
+// MTU discovery work for services without a connection yet.
+func (s *Sysctls) ScheduleICMP() (bool, error) {
+	return s.bool("schedule_icmp")
+}
+
+// SetScheduleICMP sets whether IPVS schedules ICMP packets.
+func (s *Sysctls) SetScheduleICMP(v bool) error {
+	return s.setBool("schedule_icmp", v)
+}
+
+// EstimatorNice returns the niceness, from -20 to 19, of the kernel threads
+// estimating the rates of the statistics. This requires Linux 6.2 or later.
+func (s *Sysctls) EstimatorNice() (int, error) {
+	return s.int("est_nice")
+}
+
+// SetEstimatorNice sets the niceness of the estimator kernel threads.
+func (s *Sysctls) SetEstimatorNice(nice int) error {
+	if nice < -20 || nice > 19 {
+		return fmt.Errorf("sysctl: est_nice %d is not between -20 and 19", nice)
+	}
+
+	return s.write("est_nice", strconv.Itoa(nice))
+}
+
+// EstimatorCPUList returns the CPUs the estimator kernel threads run on,
+// in ascending order. This requires Linux 6.2 or later.
+func (s *Sysctls) EstimatorCPUList() ([]int, error) {
+	v, err := s.read("est_cpulist")
+	if err != nil {
+		return nil, err
+	}
+
+	cpus, err := ParseCPUList(v)
+	if err != nil {
+		return nil, fmt.Errorf("sysctl: parsing est_cpulist: %w", err)
+	}
+
+	return cpus, nil
+}
+
+// SetEstimatorCPUList sets the CPUs the estimator kernel threads run on. No
+// CPUs stops the estimation of rates.
+func (s *Sysctls) SetEstimatorCPUList(cpus []int) error {
+	v, err := FormatCPUList(cpus)
+	if err != nil {
+		return fmt.Errorf("sysctl: formatting est_cpulist: %w", err)
+	}
+
+	return s.write("est_cpulist", v)
+}
+
+// bool reads the boolean sysctl name.
+func (s *Sysctls) bool(name string) (bool, error) {
+	v, err := s.int(name)
+	if err != nil {
+		return false, err
+	}
+
+	return v != 0, nil
+}
+
+// setBool writes the boolean sysctl name.
+func (s *Sysctls) setBool(name string, v bool) error {
+	if v {
+		return s.write(name, "1")
+	}
+
+	return s.write(name, "0")
+}
+
+// int reads the integer sysctl name.
+func (s *Sysctls) int(name string) (int, error) {
+	v, err := s.read(name)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("sysctl: parsing %s: %w", name, err)
+	}
+
+	return n, nil
+}
+
+// read returns the value of the sysctl name, without surrounding whitespace.
+func (s *Sysctls) read(name string) (string, error) {
+	var b []byte
+	err := netns.Do(s.netNSPath, s.netNSFD, func() error {
+		var err error
+		b, err = os.ReadFile(filepath.Join(s.root, name))
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("sysctl: reading %s: %w", name, err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// write sets the sysctl name to v.
+func (s *Sysctls) write(name, v string) error {
+	err := netns.Do(s.netNSPath, s.netNSFD, func() error {
+		f, err := os.OpenFile(filepath.Join(s.root, name), os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.WriteString(v + "\n"); err != nil {
+			f.Close()
+			return err
+		}
+
+		return f.Close()
+	})
+	if err != nil {
+		return fmt.Errorf("sysctl: writing %s: %w", name, err)
+	}
+
+	return nil
+}
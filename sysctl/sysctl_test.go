@@ -0,0 +1,107 @@
+package sysctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func testSysctls(t *testing.T, files map[string]string) (*Sysctls, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)
+		assert.NilError(t, err)
+	}
+
+	return New(WithRoot(dir)), dir
+}
+
+func TestSysctls_Bool(t *testing.T) {
+	type testCase struct {
+		name string
+		get  func(s *Sysctls) (bool, error)
+		set  func(s *Sysctls, v bool) error
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		s, dir := testSysctls(t, map[string]string{tc.name: "0\n"})
+
+		v, err := tc.get(s)
+		assert.NilError(t, err)
+		assert.Equal(t, v, false)
+
+		assert.NilError(t, tc.set(s, true))
+		b, err := os.ReadFile(filepath.Join(dir, tc.name))
+		assert.NilError(t, err)
+		assert.Equal(t, string(b), "1\n")
+
+		v, err = tc.get(s)
+		assert.NilError(t, err)
+		assert.Equal(t, v, true)
+	}
+
+	testCases := []testCase{
+		{name: "conntrack", get: (*Sysctls).Conntrack, set: (*Sysctls).SetConntrack},
+		{name: "expire_nodest_conn", get: (*Sysctls).ExpireNoDestConn, set: (*Sysctls).SetExpireNoDestConn},
+		{name: "expire_quiescent_template", get: (*Sysctls).ExpireQuiescentTemplate, set: (*Sysctls).SetExpireQuiescentTemplate},
+		{name: "sloppy_tcp", get: (*Sysctls).SloppyTCP, set: (*Sysctls).SetSloppyTCP},
+		{name: "sloppy_sctp", get: (*Sysctls).SloppySCTP, set: (*Sysctls).SetSloppySCTP},
+		{name: "ignore_tunneled", get: (*Sysctls).IgnoreTunneled, set: (*Sysctls).SetIgnoreTunneled},
+		{name: "schedule_icmp", get: (*Sysctls).ScheduleICMP, set: (*Sysctls).SetScheduleICMP},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestSysctls_Estimator(t *testing.T) {
+	s, dir := testSysctls(t, map[string]string{
+		"est_nice":    "0\n",
+		"est_cpulist": "0-3,8\n",
+	})
+
+	nice, err := s.EstimatorNice()
+	assert.NilError(t, err)
+	assert.Equal(t, nice, 0)
+
+	assert.NilError(t, s.SetEstimatorNice(-5))
+	nice, err = s.EstimatorNice()
+	assert.NilError(t, err)
+	assert.Equal(t, nice, -5)
+
+	assert.ErrorContains(t, s.SetEstimatorNice(20), "est_nice 20 is not between -20 and 19")
+
+	cpus, err := s.EstimatorCPUList()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, cpus, []int{0, 1, 2, 3, 8})
+
+	assert.NilError(t, s.SetEstimatorCPUList([]int{6, 4, 5}))
+	b, err := os.ReadFile(filepath.Join(dir, "est_cpulist"))
+	assert.NilError(t, err)
+	assert.Equal(t, string(b), "4-6\n")
+}
+
+func TestSysctls_Errors(t *testing.T) {
+	s, _ := testSysctls(t, map[string]string{"conntrack": "yes\n"})
+
+	_, err := s.Conntrack()
+	assert.ErrorContains(t, err, "sysctl: parsing conntrack")
+
+	_, err = s.SloppyTCP()
+	assert.ErrorContains(t, err, "sysctl: reading sloppy_tcp")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	err = s.SetSloppyTCP(true)
+	assert.ErrorContains(t, err, "sysctl: writing sloppy_tcp")
+
+	_, err = New(WithNetNSPath(filepath.Join(t.TempDir(), "missing"))).Conntrack()
+	assert.ErrorContains(t, err, "network namespace")
+}
@@ -0,0 +1,66 @@
+package ipvs
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Timeouts are the idle timeouts IPVS applies to connections, the
+// time.Duration counterpart of Config. Timeouts are whole seconds, as kept
+// by IPVS.
+type Timeouts struct {
+	// TCP is the timeout of established TCP connections.
+	TCP time.Duration
+	// TCPFin is the timeout of TCP connections after receiving a FIN packet.
+	TCPFin time.Duration
+	// UDP is the timeout of UDP packets.
+	UDP time.Duration
+}
+
+// GetTimeouts fetches the connection timeouts from IPVS.
+func (c *client) GetTimeouts(ctx context.Context) (Timeouts, error) {
+	config, err := c.Config(ctx)
+	if err != nil {
+		return Timeouts{}, err
+	}
+
+	return Timeouts{
+		TCP:    time.Duration(config.TCPTimeout) * time.Second,
+		TCPFin: time.Duration(config.TCPFinTimeout) * time.Second,
+		UDP:    time.Duration(config.UDPTimeout) * time.Second,
+	}, nil
+}
+
+// SetTimeouts changes the connection timeouts of IPVS. A zero timeout leaves
+// the current value unchanged.
+func (c *client) SetTimeouts(ctx context.Context, t Timeouts) error {
+	var config Config
+	var err error
+	if config.TCPTimeout, err = timeoutSeconds("TCP", t.TCP); err != nil {
+		return err
+	}
+	if config.TCPFinTimeout, err = timeoutSeconds("TCP FIN", t.TCPFin); err != nil {
+		return err
+	}
+	if config.UDPTimeout, err = timeoutSeconds("UDP", t.UDP); err != nil {
+		return err
+	}
+
+	return c.SetConfig(ctx, config)
+}
+
+// timeoutSeconds converts the named timeout d to the seconds used by IPVS.
+func timeoutSeconds(name string, d time.Duration) (uint32, error) {
+	switch {
+	case d < 0:
+		return 0, fmt.Errorf("ipvs: %s timeout %s is negative", name, d)
+	case d%time.Second != 0:
+		return 0, fmt.Errorf("ipvs: %s timeout %s is not a whole number of seconds", name, d)
+	case d/time.Second > math.MaxInt32:
+		return 0, fmt.Errorf("ipvs: %s timeout %s is out of range", name, d)
+	}
+
+	return uint32(d / time.Second), nil
+}
@@ -0,0 +1,98 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"gotest.tools/v3/assert"
+)
+
+func TestGetTimeouts(t *testing.T) {
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		return []genetlink.Message{
+			{
+				Data: nltest.MustMarshalAttributes([]netlink.Attribute{
+					{Type: cipvs.CmdAttrTimeoutTcp, Data: []byte{0x84, 0x03, 0x00, 0x00}},
+					{Type: cipvs.CmdAttrTimeoutTcpFin, Data: []byte{0x78, 0x00, 0x00, 0x00}},
+					{Type: cipvs.CmdAttrTimeoutUdp, Data: []byte{0x2C, 0x01, 0x00, 0x00}},
+				}),
+			},
+		}, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	timeouts, err := client.GetTimeouts(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, timeouts, Timeouts{
+		TCP:    15 * time.Minute,
+		TCPFin: 2 * time.Minute,
+		UDP:    5 * time.Minute,
+	})
+}
+
+func TestSetTimeouts(t *testing.T) {
+	type testCase struct {
+		name     string
+		timeouts Timeouts
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			assert.DeepEqual(t, gerq.Data, nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: cipvs.CmdAttrTimeoutTcp, Data: []byte{0x84, 0x03, 0x00, 0x00}},
+				{Type: cipvs.CmdAttrTimeoutTcpFin, Data: []byte{0x00, 0x00, 0x00, 0x00}},
+				{Type: cipvs.CmdAttrTimeoutUdp, Data: []byte{0x2C, 0x01, 0x00, 0x00}},
+			}))
+			return []genetlink.Message{{}}, nil
+		}
+		client := testClient(t, fn)
+		defer client.Close()
+
+		err := client.SetTimeouts(context.Background(), tc.timeouts)
+		if tc.expected == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name:     "valid",
+			timeouts: Timeouts{TCP: 15 * time.Minute, UDP: 5 * time.Minute},
+		},
+		{
+			name:     "negative",
+			timeouts: Timeouts{TCP: -time.Second},
+			expected: "TCP timeout -1s is negative",
+		},
+		{
+			name:     "fractional",
+			timeouts: Timeouts{TCPFin: 1500 * time.Millisecond},
+			expected: "TCP FIN timeout 1.5s is not a whole number of seconds",
+		},
+		{
+			name:     "out of range",
+			timeouts: Timeouts{UDP: (math.MaxInt32 + 1) * time.Second},
+			expected: "out of range",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
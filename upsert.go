@@ -0,0 +1,54 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+)
+
+// AddOrUpdateService creates svc, or updates it when it already exists and
+// its configuration differs, reporting whether IPVS was changed.
+func (c *client) AddOrUpdateService(ctx context.Context, svc Service) (bool, error) {
+	err := c.CreateService(ctx, svc)
+	if !errors.Is(err, ErrServiceExists) {
+		return err == nil, err
+	}
+
+	current, err := c.Service(ctx, svc)
+	if err != nil {
+		return false, err
+	}
+
+	if current.Equal(svc) {
+		return false, nil
+	}
+
+	if err := c.UpdateService(ctx, svc); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// AddOrUpdateDestination creates dest for svc, or updates it when it already
+// exists and its configuration differs, reporting whether IPVS was changed.
+func (c *client) AddOrUpdateDestination(ctx context.Context, svc Service, dest Destination) (bool, error) {
+	err := c.CreateDestination(ctx, svc, dest)
+	if !errors.Is(err, ErrDestinationExists) {
+		return err == nil, err
+	}
+
+	current, err := c.destination(ctx, svc, dest)
+	if err != nil {
+		return false, err
+	}
+
+	if current.Equal(dest) {
+		return false, nil
+	}
+
+	if err := c.UpdateDestination(ctx, svc, dest); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,201 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+	"syscall"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/genetlink/genltest"
+	"github.com/mdlayher/netlink"
+	"gotest.tools/v3/assert"
+)
+
+func TestAddOrUpdateService(t *testing.T) {
+	type testCase struct {
+		name     string
+		current  *Service
+		fail     bool
+		expected []uint8
+		changed  bool
+	}
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+
+	run := func(t *testing.T, tc testCase) {
+		var commands []uint8
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			commands = append(commands, gerq.Header.Command)
+			switch gerq.Header.Command {
+			case cipvs.CmdNewService:
+				if tc.current != nil {
+					return nil, genltest.Error(int(syscall.EEXIST))
+				}
+			case cipvs.CmdGetService:
+				ae := netlink.NewAttributeEncoder()
+				ae.Do(cipvs.CmdAttrService, packService(*tc.current))
+				b, err := ae.Encode()
+				assert.NilError(t, err)
+				return []genetlink.Message{{Data: b}}, nil
+			case cipvs.CmdSetService:
+				if tc.fail {
+					return nil, genltest.Error(int(syscall.EINVAL))
+				}
+			}
+			return []genetlink.Message{{}}, nil
+		}
+		client := testClient(t, fn)
+		defer client.Close()
+
+		changed, err := client.AddOrUpdateService(context.Background(), svc)
+		if tc.fail {
+			assert.ErrorIs(t, err, syscall.EINVAL)
+		} else {
+			assert.NilError(t, err)
+		}
+		assert.Equal(t, changed, tc.changed)
+		assert.DeepEqual(t, commands, tc.expected)
+	}
+
+	updated := svc
+	updated.Scheduler = WeightedRoundRobin
+
+	testCases := []testCase{
+		{
+			name:     "missing",
+			expected: []uint8{cipvs.CmdNewService},
+			changed:  true,
+		},
+		{
+			name:     "unchanged",
+			current:  &svc,
+			expected: []uint8{cipvs.CmdNewService, cipvs.CmdGetService},
+		},
+		{
+			name:     "changed",
+			current:  &updated,
+			expected: []uint8{cipvs.CmdNewService, cipvs.CmdGetService, cipvs.CmdSetService},
+			changed:  true,
+		},
+		{
+			name:     "update fails",
+			current:  &updated,
+			fail:     true,
+			expected: []uint8{cipvs.CmdNewService, cipvs.CmdGetService, cipvs.CmdSetService},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestAddOrUpdateDestination(t *testing.T) {
+	type testCase struct {
+		name        string
+		current     *Destination
+		fail        bool
+		unsetFamily bool
+		expected    []uint8
+		changed     bool
+	}
+
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+
+	run := func(t *testing.T, tc testCase) {
+		var commands []uint8
+		fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+			commands = append(commands, gerq.Header.Command)
+			switch gerq.Header.Command {
+			case cipvs.CmdNewDest:
+				if tc.current != nil {
+					return nil, genltest.Error(int(syscall.EEXIST))
+				}
+			case cipvs.CmdGetDest:
+				ae := netlink.NewAttributeEncoder()
+				ae.Do(cipvs.CmdAttrDest, packDest(*tc.current))
+				b, err := ae.Encode()
+				assert.NilError(t, err)
+				return []genetlink.Message{{Data: b}}, nil
+			case cipvs.CmdSetDest:
+				if tc.fail {
+					return nil, genltest.Error(int(syscall.EINVAL))
+				}
+			}
+			return []genetlink.Message{{}}, nil
+		}
+		client := testClient(t, fn)
+		defer client.Close()
+
+		dest := dest
+		if tc.unsetFamily {
+			dest.Family = 0
+		}
+
+		changed, err := client.AddOrUpdateDestination(context.Background(), svc, dest)
+		if tc.fail {
+			assert.ErrorIs(t, err, syscall.EINVAL)
+		} else {
+			assert.NilError(t, err)
+		}
+		assert.Equal(t, changed, tc.changed)
+		assert.DeepEqual(t, commands, tc.expected)
+	}
+
+	updated := dest
+	updated.Weight = 10
+
+	testCases := []testCase{
+		{
+			name:     "missing",
+			expected: []uint8{cipvs.CmdNewDest},
+			changed:  true,
+		},
+		{
+			name:     "unchanged",
+			current:  &dest,
+			expected: []uint8{cipvs.CmdNewDest, cipvs.CmdGetDest},
+		},
+		{
+			name:     "changed",
+			current:  &updated,
+			expected: []uint8{cipvs.CmdNewDest, cipvs.CmdGetDest, cipvs.CmdSetDest},
+			changed:  true,
+		},
+		{
+			name:        "unset family unchanged",
+			current:     &dest,
+			unsetFamily: true,
+			expected:    []uint8{cipvs.CmdNewDest, cipvs.CmdGetDest},
+		},
+		{
+			name:        "unset family changed",
+			current:     &updated,
+			unsetFamily: true,
+			expected:    []uint8{cipvs.CmdNewDest, cipvs.CmdGetDest, cipvs.CmdSetDest},
+			changed:     true,
+		},
+		{
+			name:     "update fails",
+			current:  &updated,
+			fail:     true,
+			expected: []uint8{cipvs.CmdNewDest, cipvs.CmdGetDest, cipvs.CmdSetDest},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
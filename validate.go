@@ -0,0 +1,257 @@
+package ipvs
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+)
+
+// validate reports whether the key identifies a service IPVS can look up.
+func (k ServiceKey) validate() error {
+	if k.FWMark != 0 {
+		if k.Family != INET && k.Family != INET6 {
+			return errors.New("ipvs: firewall mark service requires the INET or INET6 family")
+		}
+		return nil
+	}
+
+	if !k.Protocol.IsValid() {
+		return fmt.Errorf("ipvs: unsupported protocol %s", k.Protocol)
+	}
+
+	return validateAddr(k.Family, k.Address)
+}
+
+// validateAddr reports whether addr is an address of family.
+func validateAddr(family AddressFamily, addr netip.Addr) error {
+	switch {
+	case family == INET && addr.Unmap().Is4():
+	case family == INET6 && addr.Is6():
+	default:
+		return fmt.Errorf("ipvs: address %s does not match family %s", addr, family)
+	}
+
+	return nil
+}
+
+// validate reports whether the service can be created or updated in IPVS.
+func (svc Service) validate() error {
+	if err := svc.Key().validate(); err != nil {
+		return err
+	}
+
+	if !svc.Scheduler.IsValid() {
+		return fmt.Errorf("ipvs: unknown scheduler %q", svc.Scheduler)
+	}
+
+	if len(svc.PEName) >= cipvs.PenameMaxlen {
+		return fmt.Errorf("ipvs: persistence engine name %q exceeds %d characters", svc.PEName, cipvs.PenameMaxlen-1)
+	}
+
+	// IPVS encodes IPv6 persistence masks as a prefix length.
+	if svc.Netmask.IsValid() {
+		switch {
+		case svc.Family == INET && !svc.Netmask.Is4(), svc.Family == INET6 && !svc.Netmask.Is6():
+			return fmt.Errorf("ipvs: netmask %s does not match family %s", svc.Netmask, svc.Family)
+		case svc.Family == INET6 && !svc.Netmask.IsPrefix():
+			return fmt.Errorf("ipvs: IPv6 netmask %s is not a prefix", svc.Netmask)
+		}
+	}
+
+	return nil
+}
+
+// validate reports whether the destination can be created or updated in
+// IPVS for svc. An unset family is derived from the address.
+func (dest Destination) validate(svc Service) error {
+	dest = dest.Normalize()
+	if err := validateAddr(dest.Family, dest.Address); err != nil {
+		return err
+	}
+
+	// Kernels since 4.1 only forward between families by tunneling.
+	if dest.Family != svc.Family && dest.FwdMethod != Tunnel {
+		return fmt.Errorf("ipvs: destination family %s differs from service family %s, which requires the Tunnel forwarding method", dest.Family, svc.Family)
+	}
+
+	if dest.LowerThreshold > dest.UpperThreshold {
+		return fmt.Errorf("ipvs: lower threshold %d exceeds upper threshold %d", dest.LowerThreshold, dest.UpperThreshold)
+	}
+
+	if dest.FwdMethod == Tunnel {
+		return dest.validateTunnel()
+	}
+
+	return nil
+}
+
+// validateTunnel reports whether the tunnel parameters of the destination are
+// accepted by IPVS.
+func (dest Destination) validateTunnel() error {
+	switch dest.TunnelType {
+	case IPIP, GRE:
+	case GUE:
+		if dest.TunnelPort == 0 {
+			return errors.New("ipvs: GUE tunnel requires a port")
+		}
+	default:
+		return fmt.Errorf("ipvs: unsupported tunnel type %s", dest.TunnelType)
+	}
+
+	if dest.TunnelFlags&TunnelEncapChecksum != 0 && dest.TunnelFlags&TunnelEncapRemoteChecksum != 0 {
+		return errors.New("ipvs: tunnel checksum and remote checksum offload are exclusive")
+	}
+
+	if dest.TunnelFlags&^(TunnelEncapChecksum|TunnelEncapRemoteChecksum) != 0 {
+		return fmt.Errorf("ipvs: unsupported tunnel flags %s", dest.TunnelFlags)
+	}
+
+	return nil
+}
+
+// Validate reports whether IPVS accepts svc, without a round trip to the
+// kernel. It does not check that the scheduler module is available.
+func (svc Service) Validate() error {
+	return svc.validate()
+}
+
+// Validate reports whether IPVS accepts dest as a destination of svc,
+// without a round trip to the kernel.
+func (dest Destination) Validate(svc Service) error {
+	return dest.validate(svc)
+}
+
+// Validate checks cfg before touching the kernel, reporting all the problems
+// found in a *ConfigError. Besides the checks made when creating services
+// and destinations, it reports:
+//
+//   - schedulers which are not available according to caps;
+//   - one-packet scheduling of services other than UDP;
+//   - services or destinations configured twice;
+//   - masqueraded destinations without a port, unless the service is a
+//     firewall mark or port 0 service;
+//   - tunnel attributes of destinations not using the Tunnel forwarding
+//     method;
+//   - tunnel types and mixed family destinations unsupported by the kernel.
+//
+// The checks depending on the kernel are skipped when caps is the zero
+// Capabilities, as done by Apply.
+func (cfg TableConfig) Validate(caps Capabilities) error {
+	var (
+		errs []error
+		svcs = make(map[ServiceKey]bool, len(cfg.Services))
+	)
+
+	for _, sc := range cfg.Services {
+		svc := sc.Service
+		key := svc.Normalize().Key()
+		fail := func(err error) {
+			errs = append(errs, &configError{where: "service " + key.String(), err: err})
+		}
+
+		if svcs[key] {
+			fail(errors.New("configured twice"))
+		}
+		svcs[key] = true
+
+		if err := svc.validate(); err != nil {
+			fail(err)
+		}
+
+		if svc.Scheduler.IsValid() && !caps.hasScheduler(svc.Scheduler) {
+			fail(fmt.Errorf("scheduler %s is not available", svc.Scheduler))
+		}
+
+		if svc.Flags&ServiceOnePacket != 0 && svc.FWMark == 0 && svc.Protocol != UDP {
+			fail(fmt.Errorf("one-packet scheduling requires UDP, not %s", svc.Protocol))
+		}
+
+		dests := make(map[destinationKey]bool, len(sc.Destinations))
+		for _, dest := range sc.Destinations {
+			norm := dest.Normalize()
+			dkey := destinationKeyOf(norm)
+			fail := func(err error) {
+				errs = append(errs, &configError{where: fmt.Sprintf("service %s destination %s", key, dkey.addr), err: err})
+			}
+
+			if dests[dkey] {
+				fail(errors.New("configured twice"))
+			}
+			dests[dkey] = true
+
+			if dest.FwdMethod != Tunnel && (dest.TunnelType != IPIP || dest.TunnelPort != 0 || dest.TunnelFlags != TunnelEncapNoChecksum) {
+				fail(fmt.Errorf("tunnel attributes require the Tunnel forwarding method, not %s", dest.FwdMethod))
+			}
+
+			if err := norm.validate(svc.Normalize()); err != nil {
+				fail(err)
+			}
+
+			// Firewall mark and port 0 services masquerade to the port each
+			// client connected to when the destination port is 0.
+			if norm.FwdMethod == Masquerade && norm.Port == 0 && svc.FWMark == 0 && svc.Port != 0 {
+				fail(errors.New("masquerading requires a destination port"))
+			}
+
+			if !caps.known() {
+				continue
+			}
+
+			switch {
+			case norm.FwdMethod == Tunnel && norm.TunnelType == GUE && !caps.TunnelGUE,
+				norm.FwdMethod == Tunnel && norm.TunnelType == GRE && !caps.TunnelGRE:
+				fail(fmt.Errorf("tunnel type %s requires Linux 5.3 or later", norm.TunnelType))
+			}
+
+			if norm.Family != svc.Family && !caps.MixedFamilyDestinations {
+				fail(fmt.Errorf("destination family %s differing from service family %s requires Linux 4.1 or later", norm.Family, svc.Family))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ConfigError{Errors: errs}
+	}
+
+	return nil
+}
+
+// ConfigError reports the problems found by TableConfig.Validate.
+type ConfigError struct {
+	// Errors holds one error per problem, in the order of the
+	// configuration.
+	Errors []error
+}
+
+// Error implements error.
+func (e *ConfigError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	return fmt.Sprintf("ipvs: %d problems in configuration, first: %v", len(e.Errors), e.Errors[0])
+}
+
+// Unwrap returns the problems found.
+func (e *ConfigError) Unwrap() []error {
+	return e.Errors
+}
+
+// configError is a problem with a service or destination of a TableConfig.
+type configError struct {
+	where string
+	err   error
+}
+
+// Error implements error.
+func (e *configError) Error() string {
+	return "ipvs: " + e.where + ": " + strings.TrimPrefix(e.err.Error(), "ipvs: ")
+}
+
+// Unwrap returns the underlying error.
+func (e *configError) Unwrap() error {
+	return e.err
+}
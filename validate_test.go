@@ -0,0 +1,338 @@
+package ipvs
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/ipvs/netmask"
+	"gotest.tools/v3/assert"
+)
+
+func TestService_Validate(t *testing.T) {
+	type testCase struct {
+		name     string
+		svc      Service
+		expected string
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		err := tc.svc.validate()
+		if tc.expected == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name: "address",
+			svc:  Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: "rr"},
+		},
+		{
+			name: "sctp",
+			svc:  Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 3868, Family: INET, Protocol: SCTP, Scheduler: "rr"},
+		},
+		{
+			name:     "missing protocol",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Scheduler: "rr"},
+			expected: "unsupported protocol Protocol(0)",
+		},
+		{
+			name:     "unknown protocol",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: 0x01, Scheduler: "rr"},
+			expected: "unsupported protocol Protocol(1)",
+		},
+		{
+			name: "ipv6",
+			svc:  Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6, Protocol: TCP, Netmask: netmask.MaskFrom(64, 128), Scheduler: "rr"},
+		},
+		{
+			name: "ipv4-mapped",
+			svc:  Service{Address: netip.MustParseAddr("::ffff:192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: "rr"},
+		},
+		{
+			name:     "ipv4 address in INET6",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET6, Protocol: TCP, Scheduler: "rr"},
+			expected: "address 192.0.2.1 does not match family INET6",
+		},
+		{
+			name:     "ipv6 address in INET",
+			svc:      Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: "rr"},
+			expected: "address 2001:db8::1 does not match family INET",
+		},
+		{
+			name:     "missing address",
+			svc:      Service{Port: 80, Family: INET, Protocol: TCP, Scheduler: "rr"},
+			expected: "address invalid IP does not match family INET",
+		},
+		{
+			name:     "ipv4 netmask in INET6",
+			svc:      Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6, Protocol: TCP, Netmask: netmask.MaskFrom(24, 32), Scheduler: "rr"},
+			expected: "netmask 255.255.255.0 does not match family INET6",
+		},
+		{
+			name:     "non-prefix ipv6 netmask",
+			svc:      Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6, Protocol: TCP, Netmask: netmask.MustParseMask("ffff::ffff"), Scheduler: "rr"},
+			expected: "IPv6 netmask ffff::ffff is not a prefix",
+		},
+		{
+			name:     "missing scheduler",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP},
+			expected: `unknown scheduler ""`,
+		},
+		{
+			name:     "unknown scheduler",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: "wrrr"},
+			expected: `unknown scheduler "wrrr"`,
+		},
+		{
+			name: "persistence engine",
+			svc:  Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 5060, Family: INET, Protocol: UDP, Scheduler: "rr", PEName: "sip"},
+		},
+		{
+			name:     "persistence engine name too long",
+			svc:      Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 5060, Family: INET, Protocol: UDP, Scheduler: "rr", PEName: "sipsipsipsipsips"},
+			expected: "exceeds 15 characters",
+		},
+		{
+			name: "fwmark",
+			svc:  Service{FWMark: 1, Family: INET6, Scheduler: "rr"},
+		},
+		{
+			name:     "fwmark without family",
+			svc:      Service{FWMark: 1, Scheduler: "rr"},
+			expected: "firewall mark service requires the INET or INET6 family",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestDestination_Validate(t *testing.T) {
+	type testCase struct {
+		name     string
+		dest     Destination
+		expected string
+	}
+
+	svc := Service{Address: netip.MustParseAddr("2001:db8::1"), Port: 80, Family: INET6, Protocol: TCP, Scheduler: "rr"}
+
+	run := func(t *testing.T, tc testCase) {
+		err := tc.dest.validate(svc)
+		if tc.expected == "" {
+			assert.NilError(t, err)
+			return
+		}
+		assert.ErrorContains(t, err, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name: "same family",
+			dest: Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, FwdMethod: Masquerade},
+		},
+		{
+			name: "family of address",
+			dest: Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, FwdMethod: Masquerade},
+		},
+		{
+			name:     "family of address differs",
+			dest:     Destination{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, FwdMethod: Masquerade},
+			expected: "destination family INET differs from service family INET6",
+		},
+		{
+			name: "mixed family tunnel",
+			dest: Destination{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, FwdMethod: Tunnel},
+		},
+		{
+			name: "gue tunnel",
+			dest: Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, FwdMethod: Tunnel, TunnelType: GUE, TunnelPort: 6080, TunnelFlags: TunnelEncapRemoteChecksum},
+		},
+		{
+			name: "gre tunnel",
+			dest: Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, FwdMethod: Tunnel, TunnelType: GRE, TunnelFlags: TunnelEncapChecksum},
+		},
+		{
+			name:     "gue tunnel without port",
+			dest:     Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, FwdMethod: Tunnel, TunnelType: GUE},
+			expected: "GUE tunnel requires a port",
+		},
+		{
+			name:     "exclusive checksums",
+			dest:     Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, FwdMethod: Tunnel, TunnelType: GUE, TunnelPort: 6080, TunnelFlags: TunnelEncapChecksum | TunnelEncapRemoteChecksum},
+			expected: "tunnel checksum and remote checksum offload are exclusive",
+		},
+		{
+			name:     "unknown tunnel type",
+			dest:     Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, FwdMethod: Tunnel, TunnelType: 7},
+			expected: "unsupported tunnel type TunnelType(7)",
+		},
+		{
+			name:     "unknown tunnel flags",
+			dest:     Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, FwdMethod: Tunnel, TunnelType: IPIP, TunnelFlags: 0x10},
+			expected: "unsupported tunnel flags TunnelFlags(16)",
+		},
+		{
+			name: "thresholds",
+			dest: Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, UpperThreshold: 100, LowerThreshold: 50},
+		},
+		{
+			name:     "lower threshold exceeds upper",
+			dest:     Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, UpperThreshold: 50, LowerThreshold: 100},
+			expected: "lower threshold 100 exceeds upper threshold 50",
+		},
+		{
+			name:     "lower threshold without upper",
+			dest:     Destination{Address: netip.MustParseAddr("2001:db8::2"), Port: 80, Family: INET6, LowerThreshold: 10},
+			expected: "lower threshold 10 exceeds upper threshold 0",
+		},
+		{
+			name:     "mixed family direct route",
+			dest:     Destination{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, FwdMethod: DirectRoute},
+			expected: "destination family INET differs from service family INET6",
+		},
+		{
+			name:     "address of other family",
+			dest:     Destination{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET6, FwdMethod: Masquerade},
+			expected: "address 192.0.2.1 does not match family INET6",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
+
+func TestTableConfig_Validate(t *testing.T) {
+	type testCase struct {
+		name     string
+		cfg      TableConfig
+		caps     Capabilities
+		expected []string
+	}
+
+	web := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	dns := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 53, Family: INET, Protocol: UDP, Scheduler: RoundRobin, Flags: ServiceOnePacket}
+	dest := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, FwdMethod: Masquerade, Weight: 1}
+	old := capabilitiesFrom([3]int{4, 19, 0})
+	old.Schedulers = []Scheduler{RoundRobin, WeightedRoundRobin}
+
+	with := func(svc Service, fn func(*Service)) Service {
+		fn(&svc)
+		return svc
+	}
+	withDest := func(dest Destination, fn func(*Destination)) Destination {
+		fn(&dest)
+		return dest
+	}
+
+	run := func(t *testing.T, tc testCase) {
+		err := tc.cfg.Validate(tc.caps)
+		if tc.expected == nil {
+			assert.NilError(t, err)
+			return
+		}
+
+		var cerr *ConfigError
+		assert.Assert(t, errors.As(err, &cerr), "error %v", err)
+
+		var msgs []string
+		for _, err := range cerr.Errors {
+			msgs = append(msgs, err.Error())
+		}
+		assert.DeepEqual(t, msgs, tc.expected)
+	}
+
+	testCases := []testCase{
+		{
+			name: "valid",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: web, Destinations: []Destination{dest}},
+				{Service: dns},
+			}},
+			caps: old,
+		},
+		{
+			name: "services",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: with(web, func(svc *Service) { svc.Scheduler = MaglevHashing })},
+				{Service: with(web, func(svc *Service) { svc.Flags = ServiceOnePacket; svc.Port = 81 })},
+				{Service: with(web, func(svc *Service) { svc.Netmask = netmask.MaskFrom(64, 128); svc.Port = 82 })},
+				{Service: web},
+			}},
+			caps: old,
+			expected: []string{
+				"ipvs: service TCP 192.0.2.1:80: scheduler mh is not available",
+				"ipvs: service TCP 192.0.2.1:81: one-packet scheduling requires UDP, not TCP",
+				"ipvs: service TCP 192.0.2.1:82: netmask 64 does not match family INET",
+				"ipvs: service TCP 192.0.2.1:80: configured twice",
+			},
+		},
+		{
+			name: "destinations",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: web, Destinations: []Destination{
+					dest,
+					dest,
+					withDest(dest, func(d *Destination) { d.Port = 0; d.Address = netip.MustParseAddr("198.51.100.2") }),
+					withDest(dest, func(d *Destination) { d.TunnelType = GUE; d.TunnelPort = 6080; d.Port = 8081 }),
+				}},
+			}},
+			expected: []string{
+				"ipvs: service TCP 192.0.2.1:80 destination 198.51.100.1:8080: configured twice",
+				"ipvs: service TCP 192.0.2.1:80 destination 198.51.100.2:0: masquerading requires a destination port",
+				"ipvs: service TCP 192.0.2.1:80 destination 198.51.100.1:8081: tunnel attributes require the Tunnel forwarding method, not Masquerade",
+			},
+		},
+		{
+			name: "masquerading to the original port",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: Service{FWMark: 42, Family: INET, Scheduler: RoundRobin}, Destinations: []Destination{
+					withDest(dest, func(d *Destination) { d.Port = 0 }),
+				}},
+				{Service: with(web, func(svc *Service) { svc.Port = 0 }), Destinations: []Destination{
+					withDest(dest, func(d *Destination) { d.Port = 0 }),
+				}},
+			}},
+		},
+		{
+			name: "kernel",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: web, Destinations: []Destination{
+					withDest(dest, func(d *Destination) { d.FwdMethod = Tunnel; d.TunnelType = GRE }),
+				}},
+				{Service: with(web, func(svc *Service) { svc.Scheduler = WeightedRandomTwoChoices; svc.Port = 81 })},
+			}},
+			caps: capabilitiesFrom([3]int{4, 19, 0}),
+			expected: []string{
+				"ipvs: service TCP 192.0.2.1:80 destination 198.51.100.1:8080: tunnel type GRE requires Linux 5.3 or later",
+				"ipvs: service TCP 192.0.2.1:81: scheduler twos is not available",
+			},
+		},
+		{
+			name: "unknown kernel",
+			cfg: TableConfig{Services: []ServiceConfig{
+				{Service: with(web, func(svc *Service) { svc.Scheduler = WeightedRandomTwoChoices }), Destinations: []Destination{
+					withDest(dest, func(d *Destination) { d.FwdMethod = Tunnel; d.TunnelType = GRE }),
+				}},
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			run(t, tc)
+		})
+	}
+}
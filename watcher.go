@@ -0,0 +1,259 @@
+package ipvs
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"os"
+	"sort"
+	"time"
+)
+
+// EventType is the kind of change reported by an Event.
+type EventType int
+
+// Event types.
+const (
+	ServiceAdded EventType = iota + 1
+	ServiceUpdated
+	ServiceRemoved
+	DestinationAdded
+	// DestinationUpdated reports changes to a destination other than only
+	// its weight.
+	DestinationUpdated
+	// DestinationWeightChanged reports a destination of which only the
+	// weight changed.
+	DestinationWeightChanged
+	DestinationRemoved
+)
+
+// Event is a change to IPVS detected by a Watcher.
+type Event struct {
+	Type EventType
+	// Service is the service changed, or the service of the destination
+	// changed. For ServiceRemoved it holds the last state seen.
+	Service Service
+	// Destination is the destination changed, for destination events. For
+	// DestinationRemoved it holds the last state seen.
+	Destination Destination
+
+	// PreviousService is the state before a ServiceUpdated event.
+	PreviousService Service
+	// PreviousDestination is the state before a DestinationUpdated or
+	// DestinationWeightChanged event.
+	PreviousDestination Destination
+}
+
+// Watcher periodically snapshots the services and destinations of IPVS, and
+// emits the differences with the previous snapshot as Events. It detects
+// changes made out-of-band by other tools, such as ipvsadm.
+//
+// The first snapshot is emitted as ServiceAdded and DestinationAdded events,
+// so that consumers can build their state from the events alone.
+type Watcher struct {
+	client   Client
+	interval time.Duration
+
+	// resync is signaled by Resync, and is buffered so that pending
+	// requests are coalesced.
+	resync chan struct{}
+
+	prev map[ServiceKey]watchedService
+}
+
+// watchedService is the state of a service in a snapshot of a Watcher.
+type watchedService struct {
+	svc   Service
+	dests map[destinationKey]Destination
+}
+
+// destinationKey identifies a destination of a service.
+type destinationKey struct {
+	addr   netip.AddrPort
+	family AddressFamily
+}
+
+// NewWatcher returns a Watcher snapshotting IPVS through c every interval.
+func NewWatcher(c Client, interval time.Duration) *Watcher {
+	return &Watcher{
+		client:   c,
+		interval: interval,
+		resync:   make(chan struct{}, 1),
+	}
+}
+
+// Resync makes the Watcher take a snapshot right away, emitting the whole
+// table as ServiceAdded and DestinationAdded events, as for the first
+// snapshot. It is safe to call concurrently with Run.
+func (w *Watcher) Resync() {
+	select {
+	case w.resync <- struct{}{}:
+	default:
+	}
+}
+
+// Run snapshots IPVS right away, and then every interval, sending the
+// changes on events until ctx is done or a snapshot fails. Run may be called
+// again after it returns, continuing from the last snapshot.
+func (w *Watcher) Run(ctx context.Context, events chan<- Event) error {
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		if err := w.poll(ctx, events); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		case <-w.resync:
+			w.prev = nil
+		}
+	}
+}
+
+// poll takes a snapshot, and sends the changes since the previous one on
+// events. An empty table is an empty snapshot.
+func (w *Watcher) poll(ctx context.Context, events chan<- Event) error {
+	svcs, err := w.client.ServicesWithDestinations(ctx)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	cur := make(map[ServiceKey]watchedService, len(svcs))
+	for _, svc := range svcs {
+		ws := watchedService{
+			svc:   svc.Service,
+			dests: make(map[destinationKey]Destination, len(svc.Destinations)),
+		}
+		for _, dest := range svc.Destinations {
+			ws.dests[destinationKeyOf(dest.Destination)] = dest.Destination
+		}
+
+		cur[svc.Key()] = ws
+	}
+
+	for _, ev := range diffWatched(w.prev, cur) {
+		select {
+		case events <- ev:
+		case <-ctx.Done():
+			// Keep the previous snapshot, so that the changes are sent
+			// again by the next Run.
+			return ctx.Err()
+		}
+	}
+
+	w.prev = cur
+	return nil
+}
+
+// destinationKeyOf returns the identity of dest within its service.
+func destinationKeyOf(dest Destination) destinationKey {
+	return destinationKey{
+		addr:   netip.AddrPortFrom(dest.Address, dest.Port),
+		family: dest.Family,
+	}
+}
+
+// diffWatched returns the events turning prev into cur. Services are
+// ordered by key, and destinations by address. A service is added before its
+// destinations, and removed after them.
+func diffWatched(prev, cur map[ServiceKey]watchedService) []Event {
+	var events []Event
+
+	for _, key := range sortedServiceKeys(cur) {
+		ws := cur[key]
+
+		old, ok := prev[key]
+		switch {
+		case !ok:
+			events = append(events, Event{Type: ServiceAdded, Service: ws.svc})
+		case !old.svc.Equal(ws.svc):
+			events = append(events, Event{Type: ServiceUpdated, Service: ws.svc, PreviousService: old.svc})
+		}
+
+		events = append(events, diffDestinations(ws.svc, old.dests, ws.dests)...)
+	}
+
+	for _, key := range sortedServiceKeys(prev) {
+		if _, ok := cur[key]; ok {
+			continue
+		}
+
+		old := prev[key]
+		events = append(events, diffDestinations(old.svc, old.dests, nil)...)
+		events = append(events, Event{Type: ServiceRemoved, Service: old.svc})
+	}
+
+	return events
+}
+
+// diffDestinations returns the events turning the destinations prev of svc
+// into cur.
+func diffDestinations(svc Service, prev, cur map[destinationKey]Destination) []Event {
+	var events []Event
+
+	for _, key := range sortedDestinationKeys(cur) {
+		dest := cur[key]
+
+		old, ok := prev[key]
+		switch {
+		case !ok:
+			events = append(events, Event{Type: DestinationAdded, Service: svc, Destination: dest})
+		case old.Equal(dest):
+		case old.Weight != dest.Weight && withWeight(old, dest.Weight).Equal(dest):
+			events = append(events, Event{Type: DestinationWeightChanged, Service: svc, Destination: dest, PreviousDestination: old})
+		default:
+			events = append(events, Event{Type: DestinationUpdated, Service: svc, Destination: dest, PreviousDestination: old})
+		}
+	}
+
+	for _, key := range sortedDestinationKeys(prev) {
+		if _, ok := cur[key]; !ok {
+			events = append(events, Event{Type: DestinationRemoved, Service: svc, Destination: prev[key]})
+		}
+	}
+
+	return events
+}
+
+// withWeight returns dest with its weight set to weight.
+func withWeight(dest Destination, weight uint32) Destination {
+	dest.Weight = weight
+	return dest
+}
+
+// sortedServiceKeys returns the keys of m in order.
+func sortedServiceKeys(m map[ServiceKey]watchedService) []ServiceKey {
+	keys := make([]ServiceKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Compare(keys[j]) < 0
+	})
+	return keys
+}
+
+// sortedDestinationKeys returns the keys of m in order.
+func sortedDestinationKeys(m map[destinationKey]Destination) []destinationKey {
+	keys := make([]destinationKey, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		switch {
+		case a.family != b.family:
+			return a.family < b.family
+		case a.addr.Addr() != b.addr.Addr():
+			return a.addr.Addr().Less(b.addr.Addr())
+		}
+		return a.addr.Port() < b.addr.Port()
+	})
+	return keys
+}
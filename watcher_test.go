@@ -0,0 +1,119 @@
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestWatcher_Run(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	fwm := Service{FWMark: 42, Family: INET, Scheduler: RoundRobin}
+	updated := svc
+	updated.Scheduler = WeightedLeastConnection
+
+	d1 := Destination{Address: netip.MustParseAddr("198.51.100.1"), Port: 8080, Family: INET, Weight: 1}
+	d2 := Destination{Address: netip.MustParseAddr("198.51.100.2"), Port: 8080, Family: INET, Weight: 1}
+	d3 := Destination{Address: netip.MustParseAddr("198.51.100.3"), Port: 8080, Family: INET, Weight: 1}
+	reweighted := d1
+	reweighted.Weight = 5
+	tunneled := d2
+	tunneled.FwdMethod = Tunnel
+
+	table := func(svcs ...ServiceWithDestinations) []ServiceWithDestinations { return svcs }
+	service := func(svc Service, dests ...Destination) ServiceWithDestinations {
+		s := ServiceWithDestinations{ServiceExtended: ServiceExtended{Service: svc}}
+		for _, d := range dests {
+			s.Destinations = append(s.Destinations, DestinationExtended{Destination: d})
+		}
+		return s
+	}
+
+	c := &statsClient{polls: [][]ServiceWithDestinations{
+		table(service(svc, d2, d1), service(fwm, d3)),
+		table(service(svc, d1, d2), service(fwm, d3)),
+		table(service(updated, reweighted, tunneled, d3)),
+	}}
+
+	events := make(chan Event, 32)
+	err := NewWatcher(c, time.Millisecond).Run(context.Background(), events)
+	assert.ErrorContains(t, err, "no more polls")
+	close(events)
+
+	var got []Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+
+	expected := []Event{
+		{Type: ServiceAdded, Service: svc},
+		{Type: DestinationAdded, Service: svc, Destination: d1},
+		{Type: DestinationAdded, Service: svc, Destination: d2},
+		{Type: ServiceAdded, Service: fwm},
+		{Type: DestinationAdded, Service: fwm, Destination: d3},
+
+		{Type: ServiceUpdated, Service: updated, PreviousService: svc},
+		{Type: DestinationWeightChanged, Service: updated, Destination: reweighted, PreviousDestination: d1},
+		{Type: DestinationUpdated, Service: updated, Destination: tunneled, PreviousDestination: d2},
+		{Type: DestinationAdded, Service: updated, Destination: d3},
+		{Type: DestinationRemoved, Service: fwm, Destination: d3},
+		{Type: ServiceRemoved, Service: fwm},
+	}
+	assert.DeepEqual(t, got, expected)
+}
+
+func TestWatcher_Empty(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	tbl := []ServiceWithDestinations{{ServiceExtended: ServiceExtended{Service: svc}}}
+
+	c := &statsClient{polls: [][]ServiceWithDestinations{nil, tbl, nil}, notExist: true}
+
+	events := make(chan Event, 32)
+	err := NewWatcher(c, time.Millisecond).Run(context.Background(), events)
+	assert.ErrorContains(t, err, "no more polls")
+	close(events)
+
+	var got []Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+
+	expected := []Event{
+		{Type: ServiceAdded, Service: svc},
+		{Type: ServiceRemoved, Service: svc},
+	}
+	assert.DeepEqual(t, got, expected)
+}
+
+func TestWatcher_Resync(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP, Scheduler: RoundRobin}
+	tbl := []ServiceWithDestinations{{ServiceExtended: ServiceExtended{Service: svc}}}
+
+	c := &statsClient{polls: [][]ServiceWithDestinations{tbl, tbl}}
+	w := NewWatcher(c, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan Event)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- w.Run(ctx, events)
+	}()
+
+	assert.DeepEqual(t, <-events, Event{Type: ServiceAdded, Service: svc})
+
+	w.Resync()
+	assert.DeepEqual(t, <-events, Event{Type: ServiceAdded, Service: svc})
+
+	cancel()
+	assert.ErrorIs(t, <-errc, context.Canceled)
+}
+
+func TestEventType_String(t *testing.T) {
+	assert.Equal(t, DestinationWeightChanged.String(), "DestinationWeightChanged")
+	assert.Equal(t, EventType(0).String(), "EventType(0)")
+}
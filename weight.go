@@ -0,0 +1,21 @@
+package ipvs
+
+import "context"
+
+// SetDestinationWeight changes the weight of a Destination of svc, which is
+// identified by the Address, Port and Family of dest, an unset Family being
+// derived from the Address. The remaining configuration, such as thresholds
+// and the forwarding method, is fetched from IPVS and preserved, so only the
+// weight changes.
+//
+// A weight of zero quiesces the Destination: existing connections are kept,
+// but no new connections are scheduled to it.
+func (c *client) SetDestinationWeight(ctx context.Context, svc Service, dest Destination, weight uint32) error {
+	d, err := c.destination(ctx, svc, dest)
+	if err != nil {
+		return err
+	}
+
+	d.Weight = weight
+	return c.UpdateDestination(ctx, svc, d.Destination)
+}
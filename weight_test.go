@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package ipvs
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/cloudflare/ipvs/internal/cipvs"
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"gotest.tools/v3/assert"
+)
+
+func TestSetDestinationWeight(t *testing.T) {
+	svc := Service{Address: netip.MustParseAddr("192.0.2.1"), Port: 80, Family: INET, Protocol: TCP}
+	current := Destination{
+		Address:        netip.MustParseAddr("198.51.100.1"),
+		Port:           8080,
+		Family:         INET,
+		FwdMethod:      DirectRoute,
+		Weight:         100,
+		UpperThreshold: 1000,
+		LowerThreshold: 500,
+	}
+	other := Destination{Address: netip.MustParseAddr("198.51.100.2"), Port: 8080, Family: INET, Weight: 100}
+
+	var updated []Destination
+	fn := func(gerq genetlink.Message, _ netlink.Message) ([]genetlink.Message, error) {
+		switch gerq.Header.Command {
+		case cipvs.CmdGetDest:
+			var msgs []genetlink.Message
+			for _, dest := range []Destination{other, current} {
+				ae := netlink.NewAttributeEncoder()
+				ae.Do(cipvs.CmdAttrDest, packDest(dest))
+				b, err := ae.Encode()
+				assert.NilError(t, err)
+				msgs = append(msgs, genetlink.Message{Data: b})
+			}
+			return msgs, nil
+		case cipvs.CmdSetDest:
+			ad, err := netlink.NewAttributeDecoder(gerq.Data)
+			assert.NilError(t, err)
+
+			var dest DestinationExtended
+			for ad.Next() {
+				if ad.Type() == cipvs.CmdAttrDest {
+					ad.Do(unpackDestination(&dest))
+				}
+			}
+			assert.NilError(t, ad.Err())
+
+			updated = append(updated, dest.Destination)
+			return []genetlink.Message{{}}, nil
+		}
+
+		t.Fatalf("unexpected command %d", gerq.Header.Command)
+		return nil, nil
+	}
+	client := testClient(t, fn)
+	defer client.Close()
+
+	err := client.SetDestinationWeight(context.Background(), svc, Destination{Address: current.Address, Port: current.Port, Family: INET}, 10)
+	assert.NilError(t, err)
+
+	expected := current
+	expected.Weight = 10
+	assert.DeepEqual(t, updated, []Destination{expected}, cmp.Comparer(NetipAddrCompare))
+
+	// An unset family is that of the address.
+	updated = nil
+	err = client.SetDestinationWeight(context.Background(), svc, Destination{Address: current.Address, Port: current.Port}, 10)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, updated, []Destination{expected}, cmp.Comparer(NetipAddrCompare))
+
+	err = client.SetDestinationWeight(context.Background(), svc, Destination{Address: current.Address, Port: 9090, Family: INET}, 10)
+	assert.ErrorIs(t, err, ErrDestinationNotFound)
+}